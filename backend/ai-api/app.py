@@ -8,6 +8,11 @@ from chunker import chunker_blueprint
 app = Flask(__name__)
 app.register_blueprint(chunker_blueprint, url_prefix='/chunker')
 
+# Checked by the readiness probe of lucidify-api
+@app.route('/health')
+def health():
+    return jsonify({"status": "ok"})
+
 if __name__ == '__main__':
     app.run(host='0.0.0.0', port=5000, debug=True)
 
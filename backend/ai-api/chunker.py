@@ -28,7 +28,10 @@ def split_sentences():
         if not text:
             return jsonify({"error": "No text provided"}), 400
 
-        tt = TextTilingTokenizer(w=20, k=10)
+        # Pseudosentence and block sizes of TextTiling
+        w = int(request.json.get('w', 20))
+        k = int(request.json.get('k', 10))
+        tt = TextTilingTokenizer(w=w, k=k)
 
         try:
             segments = tt.tokenize(text)
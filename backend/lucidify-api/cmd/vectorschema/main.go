@@ -0,0 +1,101 @@
+// Command vectorschema shows and migrates the schema version of the Weaviate
+// classes holding the document chunks.
+//
+//	vectorschema [-local] status|migrate|abort|prune
+//
+// status prints the active version and the pending migrations. migrate
+// backfills each pending version from document_chunks and switches to it.
+// abort stops a migration in progress, and prune drops the classes of the
+// versions no longer in use.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"lucidify-api/data/store/postgresqlclient"
+	"lucidify-api/data/store/weaviateclient"
+	"lucidify-api/server/config"
+	"lucidify-api/service/embeddingservice"
+	"lucidify-api/service/vectorschemaservice"
+	"os"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func main() {
+	local := flag.Bool("local", false, "connect to the Weaviate instance exposed on localhost:8090")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-local] [config flags] status|migrate|abort|prune\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	config, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	postgre, err := postgresqlclient.NewPostgreSQL(config.PostgresqlURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var weaviate weaviateclient.WeaviateClient
+	if *local {
+		weaviate, err = weaviateclient.NewWeaviateClientTest(config.OPENAI_API_KEY)
+	} else {
+		// Chunks are backfilled with the same vectors as the server computes
+		embeddingService := embeddingservice.NewEmbeddingService(
+			postgre,
+			embeddingservice.NewOpenAIEmbedder(openai.NewClient(config.OPENAI_API_KEY)),
+			config.EmbeddingModel,
+			config.EmbeddingCacheSize,
+		)
+		weaviate, err = weaviateclient.NewWeaviateClient(
+			config.WeaviateHost,
+			config.WeaviateScheme,
+			config.WeaviateTimeout,
+			config.OPENAI_API_KEY,
+			embeddingService,
+		)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	vectorSchemaService := vectorschemaservice.NewVectorSchemaService(postgre, weaviate)
+
+	ctx := context.Background()
+	var result interface{}
+	switch flag.Arg(0) {
+	case "status":
+		result, err = vectorSchemaService.Status(ctx)
+	case "migrate":
+		result, err = vectorSchemaService.Migrate(ctx)
+	case "abort":
+		if err = vectorSchemaService.Abort(ctx); err == nil {
+			result, err = vectorSchemaService.Status(ctx)
+		}
+	case "prune":
+		var dropped []int
+		dropped, err = vectorSchemaService.Prune(ctx)
+		result = map[string][]int{"dropped_versions": dropped}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	output, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(output))
+}
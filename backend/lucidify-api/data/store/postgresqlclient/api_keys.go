@@ -0,0 +1,102 @@
+package postgresqlclient
+
+import (
+	"context"
+	"database/sql"
+	"lucidify-api/data/store/storemodels"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const apiKeyColumns = `api_key_id, user_id, name, key_prefix, key_hash, scopes, created_at, last_used_at, expires_at, revoked_at`
+
+func (s *PostgreSQL) CreateAPIKey(ctx context.Context, apiKey *storemodels.APIKey) error {
+	query := `INSERT INTO api_keys (user_id, name, key_prefix, key_hash, scopes, expires_at)
+	          VALUES ($1, $2, $3, $4, $5, $6)
+	          RETURNING api_key_id, created_at`
+	return s.db.QueryRowContext(ctx, query, apiKey.UserID, apiKey.Name, apiKey.KeyPrefix, apiKey.KeyHash, pq.Array(apiKey.Scopes), apiKey.ExpiresAt).
+		Scan(&apiKey.APIKeyID, &apiKey.CreatedAt)
+}
+
+// GetAPIKeyByHash returns the key with the given hash, including revoked and
+// expired keys. It is up to the caller to check whether the key is still valid.
+func (s *PostgreSQL) GetAPIKeyByHash(ctx context.Context, keyHash string) (*storemodels.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+	return scanAPIKey(s.db.QueryRowContext(ctx, query, keyHash))
+}
+
+// GetAPIKeysByUser returns the keys of a user that have not been revoked,
+// newest first.
+func (s *PostgreSQL) GetAPIKeysByUser(ctx context.Context, userID string) ([]storemodels.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys
+	          WHERE user_id = $1 AND revoked_at IS NULL
+	          ORDER BY created_at DESC`
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var apiKeys []storemodels.APIKey
+	for rows.Next() {
+		apiKey, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		apiKeys = append(apiKeys, *apiKey)
+	}
+	return apiKeys, rows.Err()
+}
+
+// RevokeAPIKey revokes a key of the given user. It returns sql.ErrNoRows if the
+// user has no such key, or it has already been revoked.
+func (s *PostgreSQL) RevokeAPIKey(ctx context.Context, userID string, apiKeyID uuid.UUID) error {
+	query := `UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP
+	          WHERE api_key_id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	result, err := s.db.ExecContext(ctx, query, apiKeyID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// TouchAPIKey records that a key has been used. The timestamp is only written
+// once a minute, so that busy keys do not cause a write per request.
+func (s *PostgreSQL) TouchAPIKey(ctx context.Context, apiKeyID uuid.UUID) error {
+	query := `UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP
+	          WHERE api_key_id = $1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')`
+	_, err := s.db.ExecContext(ctx, query, apiKeyID)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*storemodels.APIKey, error) {
+	var apiKey storemodels.APIKey
+	err := row.Scan(
+		&apiKey.APIKeyID,
+		&apiKey.UserID,
+		&apiKey.Name,
+		&apiKey.KeyPrefix,
+		&apiKey.KeyHash,
+		pq.Array(&apiKey.Scopes),
+		&apiKey.CreatedAt,
+		&apiKey.LastUsedAt,
+		&apiKey.ExpiresAt,
+		&apiKey.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
@@ -0,0 +1,131 @@
+package postgresqlclient
+
+import (
+	"context"
+	"database/sql"
+	"lucidify-api/data/store/storemodels"
+
+	"github.com/google/uuid"
+)
+
+func (s *PostgreSQL) CreateCollection(ctx context.Context, collection *storemodels.Collection) error {
+	query := `INSERT INTO collections (user_id, name)
+	          VALUES ($1, $2)
+	          RETURNING collection_id, created_at, updated_at`
+	return s.db.QueryRowContext(ctx, query, collection.UserID, collection.Name).
+		Scan(&collection.CollectionID, &collection.CreatedAt, &collection.UpdatedAt)
+}
+
+// GetCollectionsByUser returns the collections of a user by name, each with
+// the number of its documents that are not in the trash.
+func (s *PostgreSQL) GetCollectionsByUser(ctx context.Context, userID string) ([]storemodels.Collection, error) {
+	query := `SELECT c.collection_id, c.user_id, c.name, COUNT(d.document_id), c.created_at, c.updated_at
+	          FROM collections c
+	          LEFT JOIN collection_documents cd ON cd.collection_id = c.collection_id
+	          LEFT JOIN documents d ON d.document_id = cd.document_id AND d.deleted_at IS NULL
+	          WHERE c.user_id = $1
+	          GROUP BY c.collection_id
+	          ORDER BY c.name`
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var collections []storemodels.Collection
+	for rows.Next() {
+		var collection storemodels.Collection
+		err := rows.Scan(&collection.CollectionID, &collection.UserID, &collection.Name, &collection.DocumentCount, &collection.CreatedAt, &collection.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, collection)
+	}
+	return collections, rows.Err()
+}
+
+// GetCollection returns sql.ErrNoRows if the user has no such collection.
+func (s *PostgreSQL) GetCollection(ctx context.Context, userID string, collectionID uuid.UUID) (*storemodels.Collection, error) {
+	var collection storemodels.Collection
+	query := `SELECT collection_id, user_id, name, created_at, updated_at
+	          FROM collections WHERE collection_id = $1 AND user_id = $2`
+	err := s.db.QueryRowContext(ctx, query, collectionID, userID).Scan(
+		&collection.CollectionID, &collection.UserID, &collection.Name, &collection.CreatedAt, &collection.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// RenameCollection returns sql.ErrNoRows if the user has no such collection.
+func (s *PostgreSQL) RenameCollection(ctx context.Context, userID string, collectionID uuid.UUID, name string) error {
+	query := `UPDATE collections SET name = $1, updated_at = CURRENT_TIMESTAMP
+	          WHERE collection_id = $2 AND user_id = $3`
+	return execAffectingRows(ctx, s.db, query, name, collectionID, userID)
+}
+
+// DeleteCollection deletes the collection but not its documents. It returns
+// sql.ErrNoRows if the user has no such collection.
+func (s *PostgreSQL) DeleteCollection(ctx context.Context, userID string, collectionID uuid.UUID) error {
+	query := `DELETE FROM collections WHERE collection_id = $1 AND user_id = $2`
+	return execAffectingRows(ctx, s.db, query, collectionID, userID)
+}
+
+// AddDocumentToCollection does nothing if the document is already in the
+// collection.
+func (s *PostgreSQL) AddDocumentToCollection(ctx context.Context, collectionID, documentID uuid.UUID) error {
+	query := `INSERT INTO collection_documents (collection_id, document_id)
+	          VALUES ($1, $2)
+	          ON CONFLICT (collection_id, document_id) DO NOTHING`
+	_, err := s.db.ExecContext(ctx, query, collectionID, documentID)
+	return err
+}
+
+// RemoveDocumentFromCollection returns sql.ErrNoRows if the document is not in
+// the collection.
+func (s *PostgreSQL) RemoveDocumentFromCollection(ctx context.Context, collectionID, documentID uuid.UUID) error {
+	query := `DELETE FROM collection_documents WHERE collection_id = $1 AND document_id = $2`
+	return execAffectingRows(ctx, s.db, query, collectionID, documentID)
+}
+
+// GetCollectionDocumentIDs returns the ids of the documents of the collection
+// that are not in the trash, most recently added first.
+func (s *PostgreSQL) GetCollectionDocumentIDs(ctx context.Context, collectionID uuid.UUID) ([]uuid.UUID, error) {
+	query := `SELECT cd.document_id
+	          FROM collection_documents cd
+	          JOIN documents d ON d.document_id = cd.document_id
+	          WHERE cd.collection_id = $1 AND d.deleted_at IS NULL
+	          ORDER BY cd.added_at DESC`
+	rows, err := s.db.QueryContext(ctx, query, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documentIDs []uuid.UUID
+	for rows.Next() {
+		var documentID uuid.UUID
+		if err := rows.Scan(&documentID); err != nil {
+			return nil, err
+		}
+		documentIDs = append(documentIDs, documentID)
+	}
+	return documentIDs, rows.Err()
+}
+
+// execAffectingRows runs a statement and returns sql.ErrNoRows if it did not
+// affect any row.
+func execAffectingRows(ctx context.Context, db *sql.DB, query string, args ...interface{}) error {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -1,29 +1,33 @@
 package postgresqlclient
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"lucidify-api/data/store/storemodels"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-func (s *PostgreSQL) UploadChunks(chunks []storemodels.Chunk) ([]storemodels.Chunk, error) {
-	tx, err := s.db.Begin()
+func (s *PostgreSQL) UploadChunks(ctx context.Context, chunks []storemodels.Chunk) ([]storemodels.Chunk, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
 	// Include user_id in the INSERT statement
-	query := `INSERT INTO document_chunks (user_id, document_id, chunk_content, chunk_index)
-	          VALUES ($1, $2, $3, $4) RETURNING chunk_id`
+	query := `INSERT INTO document_chunks (user_id, document_id, chunk_content, chunk_index, content_hash)
+	          VALUES ($1, $2, $3, $4, $5) RETURNING chunk_id`
 
 	var chunksWithIDs []storemodels.Chunk
 
 	for _, chunk := range chunks {
 		var id uuid.UUID
+		chunk.ContentHash = storemodels.ContentHash(chunk.ChunkContent)
 		// Include chunk.UserID in the QueryRow function
-		err := tx.QueryRow(query, chunk.UserID, chunk.DocumentID, chunk.ChunkContent, chunk.ChunkIndex).Scan(&id)
+		err := tx.QueryRowContext(ctx, query, chunk.UserID, chunk.DocumentID, chunk.ChunkContent, chunk.ChunkIndex, chunk.ContentHash).Scan(&id)
 		if err != nil {
 			return nil, err
 		}
@@ -38,15 +42,15 @@ func (s *PostgreSQL) UploadChunks(chunks []storemodels.Chunk) ([]storemodels.Chu
 	return chunksWithIDs, nil
 }
 
-func (s *PostgreSQL) DeleteAllChunksByDocumentID(documentID uuid.UUID) error {
-	tx, err := s.db.Begin()
+func (s *PostgreSQL) DeleteAllChunksByDocumentID(ctx context.Context, documentID uuid.UUID) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
 	query := `DELETE FROM document_chunks WHERE document_id = $1`
-	_, err = tx.Exec(query, documentID)
+	_, err = tx.ExecContext(ctx, query, documentID)
 	if err != nil {
 		return err
 	}
@@ -54,13 +58,25 @@ func (s *PostgreSQL) DeleteAllChunksByDocumentID(documentID uuid.UUID) error {
 	return tx.Commit()
 }
 
-func (s *PostgreSQL) GetChunksOfDocument(document *storemodels.Document) ([]storemodels.Chunk, error) {
+// DeleteChunks deletes the given chunks, for example the chunks of a new content
+// that failed to be indexed.
+func (s *PostgreSQL) DeleteChunks(ctx context.Context, chunks []storemodels.Chunk) error {
+	chunkIDs := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		chunkIDs[i] = chunk.ChunkID.String()
+	}
+	query := `DELETE FROM document_chunks WHERE chunk_id = ANY($1::uuid[])`
+	_, err := s.db.ExecContext(ctx, query, pq.Array(chunkIDs))
+	return err
+}
+
+func (s *PostgreSQL) GetChunksOfDocument(ctx context.Context, document *storemodels.Document) ([]storemodels.Chunk, error) {
 	if document == nil {
 		return nil, errors.New("provided document is nil")
 	}
 	// Include user_id in the SELECT statement
-	query := `SELECT chunk_id, user_id, document_id, chunk_content, chunk_index FROM document_chunks WHERE user_id = $1 AND document_id = $2`
-	rows, err := s.db.Query(query, document.UserID, document.DocumentUUID)
+	query := `SELECT chunk_id, user_id, document_id, chunk_content, chunk_index, content_hash FROM document_chunks WHERE user_id = $1 AND document_id = $2`
+	rows, err := s.db.QueryContext(ctx, query, document.UserID, document.DocumentUUID)
 	if err != nil {
 		return nil, err
 	}
@@ -70,7 +86,7 @@ func (s *PostgreSQL) GetChunksOfDocument(document *storemodels.Document) ([]stor
 	for rows.Next() {
 		var chunk storemodels.Chunk
 		// Scan the user_id into the UserID field
-		err = rows.Scan(&chunk.ChunkID, &chunk.UserID, &chunk.DocumentID, &chunk.ChunkContent, &chunk.ChunkIndex)
+		err = rows.Scan(&chunk.ChunkID, &chunk.UserID, &chunk.DocumentID, &chunk.ChunkContent, &chunk.ChunkIndex, &chunk.ContentHash)
 		if err != nil {
 			return nil, err
 		}
@@ -80,11 +96,16 @@ func (s *PostgreSQL) GetChunksOfDocument(document *storemodels.Document) ([]stor
 	return chunks, nil
 }
 
-func (s *PostgreSQL) GetChunksOfDocumentByDocumentID(documentID uuid.UUID) ([]storemodels.Chunk, error) {
-	// Modify the SELECT statement to retrieve all fields of the chunks
-	query := `SELECT chunk_id, user_id, document_id, chunk_content, chunk_index 
-	          FROM document_chunks WHERE document_id = $1`
-	rows, err := s.db.Query(query, documentID)
+// GetChunksOfDocumentByDocumentID returns the chunks of the document in order,
+// with its workspace and metadata.
+func (s *PostgreSQL) GetChunksOfDocumentByDocumentID(ctx context.Context, documentID uuid.UUID) ([]storemodels.Chunk, error) {
+	query := `SELECT c.chunk_id, c.user_id, c.document_id, c.chunk_content, c.chunk_index, c.content_hash,
+	                 d.workspace_id, d.metadata
+	          FROM document_chunks c
+	          JOIN documents d ON d.document_id = c.document_id
+	          WHERE c.document_id = $1
+	          ORDER BY c.chunk_index`
+	rows, err := s.db.QueryContext(ctx, query, documentID)
 	if err != nil {
 		return nil, err
 	}
@@ -93,22 +114,31 @@ func (s *PostgreSQL) GetChunksOfDocumentByDocumentID(documentID uuid.UUID) ([]st
 	var chunks []storemodels.Chunk
 	for rows.Next() {
 		var chunk storemodels.Chunk
-		// Scan all the fields into the Chunk struct
-		err = rows.Scan(&chunk.ChunkID, &chunk.UserID, &chunk.DocumentID, &chunk.ChunkContent, &chunk.ChunkIndex)
+		var workspaceID sql.NullString
+		err = rows.Scan(&chunk.ChunkID, &chunk.UserID, &chunk.DocumentID, &chunk.ChunkContent, &chunk.ChunkIndex, &chunk.ContentHash,
+			&workspaceID, &chunk.Metadata)
 		if err != nil {
 			return nil, err
 		}
+		chunk.WorkspaceID = workspaceID.String
 		chunks = append(chunks, chunk)
 	}
 
 	return chunks, nil
 }
 
-func (s *PostgreSQL) GetChunkIDsOfDocumentByDocumentID(documentID uuid.UUID) ([]string, error) {
+func (s *PostgreSQL) CountChunksOfDocument(ctx context.Context, documentID uuid.UUID) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM document_chunks WHERE document_id = $1`
+	err := s.db.QueryRowContext(ctx, query, documentID).Scan(&count)
+	return count, err
+}
+
+func (s *PostgreSQL) GetChunkIDsOfDocumentByDocumentID(ctx context.Context, documentID uuid.UUID) ([]string, error) {
 	// Modify the SELECT statement to retrieve all fields of the chunks
 	query := `SELECT chunk_id
 	          FROM document_chunks WHERE document_id = $1`
-	rows, err := s.db.Query(query, documentID)
+	rows, err := s.db.QueryContext(ctx, query, documentID)
 	if err != nil {
 		return nil, err
 	}
@@ -127,3 +157,64 @@ func (s *PostgreSQL) GetChunkIDsOfDocumentByDocumentID(documentID uuid.UUID) ([]
 
 	return chunks, nil
 }
+
+// GetChunksForReindex returns up to limit chunks ordered by chunk id, starting
+// after the given chunk id, with the workspace and metadata of their document.
+// The ids of the documents in the trash are returned along with them.
+func (s *PostgreSQL) GetChunksForReindex(ctx context.Context, after uuid.UUID, limit int) ([]storemodels.Chunk, map[uuid.UUID]bool, error) {
+	query := `SELECT c.chunk_id, c.user_id, c.document_id, c.chunk_content, c.chunk_index, c.content_hash,
+	                 d.workspace_id, d.metadata, d.deleted_at IS NOT NULL
+	          FROM document_chunks c
+	          JOIN documents d ON d.document_id = c.document_id
+	          WHERE c.chunk_id > $1
+	          ORDER BY c.chunk_id
+	          LIMIT $2`
+	rows, err := s.db.QueryContext(ctx, query, after, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var chunks []storemodels.Chunk
+	deletedDocuments := make(map[uuid.UUID]bool)
+	for rows.Next() {
+		var chunk storemodels.Chunk
+		var workspaceID sql.NullString
+		var deleted bool
+		err = rows.Scan(&chunk.ChunkID, &chunk.UserID, &chunk.DocumentID, &chunk.ChunkContent, &chunk.ChunkIndex, &chunk.ContentHash,
+			&workspaceID, &chunk.Metadata, &deleted)
+		if err != nil {
+			return nil, nil, err
+		}
+		chunk.WorkspaceID = workspaceID.String
+		if deleted {
+			deletedDocuments[chunk.DocumentID] = true
+		}
+		chunks = append(chunks, chunk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return chunks, deletedDocuments, nil
+}
+
+// GetExistingChunkIDs returns which of the given chunk ids are still stored.
+func (s *PostgreSQL) GetExistingChunkIDs(ctx context.Context, chunkIDs []string) (map[string]bool, error) {
+	query := `SELECT chunk_id FROM document_chunks WHERE chunk_id = ANY($1::uuid[])`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(chunkIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var chunkID uuid.UUID
+		if err := rows.Scan(&chunkID); err != nil {
+			return nil, err
+		}
+		existing[chunkID.String()] = true
+	}
+	return existing, rows.Err()
+}
@@ -3,12 +3,17 @@
 package postgresqlclient
 
 import (
+	"context"
 	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/config"
+	"reflect"
 	"testing"
 )
 
 func TestChunkFunctions(t *testing.T) {
-	store, err := NewPostgreSQL()
+	ctx := context.Background()
+
+	store, err := NewPostgreSQL(config.NewServerConfig().PostgresqlURL)
 	if err != nil {
 		t.Fatalf("Failed to create test postgresqlclient: %v", err)
 	}
@@ -28,7 +33,7 @@ func TestChunkFunctions(t *testing.T) {
 		UpdatedAt:        1654012591514,
 	}
 
-	err = store.CreateUserInUsersTable(user)
+	err = store.CreateUserInUsersTable(ctx, user)
 	if err != nil {
 		t.Fatalf("Failed to create user: %v", err)
 	}
@@ -39,7 +44,7 @@ func TestChunkFunctions(t *testing.T) {
 		Content:      "test_content",
 	}
 
-	insertedDoc, err := store.UploadDocument(doc.UserID, doc.DocumentName, doc.Content)
+	insertedDoc, err := store.UploadDocument(ctx, doc.UserID, doc.DocumentName, doc.Content)
 	if err != nil {
 		t.Fatalf("Failed to upload test document: %v", err)
 	}
@@ -59,7 +64,7 @@ func TestChunkFunctions(t *testing.T) {
 		},
 	}
 
-	uploadedChunks, err := store.UploadChunks(chunks)
+	uploadedChunks, err := store.UploadChunks(ctx, chunks)
 	if err != nil {
 		t.Fatalf("Failed to upload chunks: %v", err)
 	}
@@ -69,28 +74,28 @@ func TestChunkFunctions(t *testing.T) {
 		t.Errorf("Expected chunk ID to be set, but got %s", uploadedChunk1.ChunkID.String())
 	}
 
-	err = store.DeleteAllChunksByDocumentID(insertedDoc.DocumentUUID)
+	err = store.DeleteAllChunksByDocumentID(ctx, insertedDoc.DocumentUUID)
 	if err != nil {
 		t.Fatalf("Failed to delete chunks by document ID: %v", err)
 	}
 
-	retrievedChunks, err := store.GetChunksOfDocument(insertedDoc)
+	retrievedChunks, err := store.GetChunksOfDocument(ctx, insertedDoc)
 	if err != nil {
 		t.Fatalf("Failed to retrieve chunks by document ID: %v", err)
 	}
 	if len(retrievedChunks) != 0 {
 		t.Errorf("Expected no chunks, but got %d", len(retrievedChunks))
 	}
-	retrievedChunksByDocumentID, err := store.GetChunksOfDocumentByDocumentID(insertedDoc.DocumentUUID)
+	retrievedChunksByDocumentID, err := store.GetChunksOfDocumentByDocumentID(ctx, insertedDoc.DocumentUUID)
 	if err != nil {
 		t.Fatalf("Failed to retrieve chunks by document ID: %v", err)
 	}
 	for i, chunk := range retrievedChunksByDocumentID {
-		if chunk != retrievedChunks[i] {
+		if !reflect.DeepEqual(chunk, retrievedChunks[i]) {
 			t.Errorf("Expected chunks to be equal, but got %v and %v", chunk, retrievedChunks[i])
 		}
 	}
-	retrievedChunkIDsOfDocumentByDocumentID, err := store.GetChunkIDsOfDocumentByDocumentID(insertedDoc.DocumentUUID)
+	retrievedChunkIDsOfDocumentByDocumentID, err := store.GetChunkIDsOfDocumentByDocumentID(ctx, insertedDoc.DocumentUUID)
 	if err != nil {
 		t.Fatalf("Failed to retrieve chunks by document ID: %v", err)
 	}
@@ -104,17 +109,17 @@ func TestChunkFunctions(t *testing.T) {
 	t.Cleanup(func() {
 		// Note, deleting user in users table will delete all associated records
 		// of documents and chunks.
-		err = store.DeleteAllChunksByDocumentID(insertedDoc.DocumentUUID)
+		err = store.DeleteAllChunksByDocumentID(ctx, insertedDoc.DocumentUUID)
 		if err != nil {
 			t.Errorf("Failed to delete test chunks: %v", err)
 		}
 
-		err = store.DeleteDocument(doc.UserID, doc.DocumentName)
+		err = store.DeleteDocument(ctx, doc.UserID, doc.DocumentName)
 		if err != nil {
 			t.Errorf("Failed to delete test document: %v", err)
 		}
 
-		err = store.DeleteUserInUsersTable(user.UserID)
+		err = store.DeleteUserInUsersTable(ctx, user.UserID)
 		if err != nil {
 			t.Errorf("Failed to delete test user: %v", err)
 		}
@@ -0,0 +1,135 @@
+package postgresqlclient
+
+import (
+	"context"
+	"database/sql"
+	"lucidify-api/data/store/storemodels"
+
+	"github.com/google/uuid"
+)
+
+const documentGrantColumns = `grant_id, document_id, grantee_user_id, link_token_hash, permission, created_by, created_at, expires_at`
+
+// activeGrant restricts a query on document_grants to grants that have not
+// expired.
+const activeGrant = `(expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)`
+
+// UpsertDocumentGrant stores a grant. A user grant replaces the existing
+// grant of the same user on the same document.
+func (s *PostgreSQL) UpsertDocumentGrant(ctx context.Context, grant *storemodels.DocumentGrant) error {
+	query := `INSERT INTO document_grants (document_id, grantee_user_id, link_token_hash, permission, created_by, expires_at)
+	          VALUES ($1, $2, $3, $4, $5, $6)
+	          ON CONFLICT (document_id, grantee_user_id) WHERE grantee_user_id IS NOT NULL
+	          DO UPDATE SET permission = EXCLUDED.permission, created_by = EXCLUDED.created_by,
+	                        created_at = CURRENT_TIMESTAMP, expires_at = EXCLUDED.expires_at
+	          RETURNING grant_id, created_at`
+	return s.db.QueryRowContext(ctx, query, grant.DocumentID, grant.GranteeUserID, grant.LinkTokenHash, grant.Permission, grant.CreatedBy, grant.ExpiresAt).
+		Scan(&grant.GrantID, &grant.CreatedAt)
+}
+
+// GetDocumentGrants returns the unexpired grants of a document.
+func (s *PostgreSQL) GetDocumentGrants(ctx context.Context, documentID uuid.UUID) ([]storemodels.DocumentGrant, error) {
+	query := `SELECT ` + documentGrantColumns + ` FROM document_grants
+	          WHERE document_id = $1 AND ` + activeGrant + `
+	          ORDER BY created_at`
+	return s.queryDocumentGrants(ctx, query, documentID)
+}
+
+// GetUserDocumentGrant returns sql.ErrNoRows if the user has no unexpired
+// grant on the document.
+func (s *PostgreSQL) GetUserDocumentGrant(ctx context.Context, documentID uuid.UUID, userID string) (*storemodels.DocumentGrant, error) {
+	query := `SELECT ` + documentGrantColumns + ` FROM document_grants
+	          WHERE document_id = $1 AND grantee_user_id = $2 AND ` + activeGrant
+	return scanDocumentGrant(s.db.QueryRowContext(ctx, query, documentID, userID))
+}
+
+// GetDocumentGrantByLinkTokenHash returns sql.ErrNoRows if there is no
+// unexpired link with the given token hash.
+func (s *PostgreSQL) GetDocumentGrantByLinkTokenHash(ctx context.Context, linkTokenHash string) (*storemodels.DocumentGrant, error) {
+	query := `SELECT ` + documentGrantColumns + ` FROM document_grants
+	          WHERE link_token_hash = $1 AND ` + activeGrant
+	return scanDocumentGrant(s.db.QueryRowContext(ctx, query, linkTokenHash))
+}
+
+// GetDocumentsSharedWithUser returns the documents, outside of the trash, that
+// the user has an unexpired grant on.
+func (s *PostgreSQL) GetDocumentsSharedWithUser(ctx context.Context, userID string) ([]storemodels.Document, error) {
+	query := `SELECT d.document_id, d.user_id, d.workspace_id, d.document_name, d.content, d.metadata, d.created_at, d.updated_at
+	          FROM documents d
+	          JOIN document_grants g ON g.document_id = d.document_id
+	          WHERE g.grantee_user_id = $1 AND d.deleted_at IS NULL AND ` + activeGrant
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []storemodels.Document
+	for rows.Next() {
+		var doc storemodels.Document
+		err := rows.Scan(&doc.DocumentUUID, &doc.UserID, &doc.WorkspaceID, &doc.DocumentName, &doc.Content, &doc.Metadata, &doc.CreatedAt, &doc.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, doc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return documents, nil
+}
+
+// DeleteDocumentGrant returns sql.ErrNoRows if the document has no such grant.
+func (s *PostgreSQL) DeleteDocumentGrant(ctx context.Context, documentID, grantID uuid.UUID) error {
+	query := `DELETE FROM document_grants WHERE grant_id = $1 AND document_id = $2`
+	result, err := s.db.ExecContext(ctx, query, grantID, documentID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *PostgreSQL) queryDocumentGrants(ctx context.Context, query string, args ...interface{}) ([]storemodels.DocumentGrant, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grants []storemodels.DocumentGrant
+	for rows.Next() {
+		grant, err := scanDocumentGrant(rows)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, *grant)
+	}
+	return grants, rows.Err()
+}
+
+func scanDocumentGrant(row rowScanner) (*storemodels.DocumentGrant, error) {
+	var grant storemodels.DocumentGrant
+	err := row.Scan(
+		&grant.GrantID,
+		&grant.DocumentID,
+		&grant.GranteeUserID,
+		&grant.LinkTokenHash,
+		&grant.Permission,
+		&grant.CreatedBy,
+		&grant.CreatedAt,
+		&grant.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
@@ -0,0 +1,103 @@
+package postgresqlclient
+
+import (
+	"context"
+	"fmt"
+	"lucidify-api/data/store/storemodels"
+	"strings"
+)
+
+var documentSortColumns = map[string]string{
+	storemodels.DocumentSortName:    "d.document_name",
+	storemodels.DocumentSortCreated: "d.created_at",
+	storemodels.DocumentSortUpdated: "d.updated_at",
+}
+
+// ListDocuments returns up to options.Limit documents outside of the trash,
+// starting after options.After, ordered by the sort key and then by id.
+func (s *PostgreSQL) ListDocuments(ctx context.Context, userID string, options storemodels.DocumentListOptions) ([]storemodels.DocumentListItem, error) {
+	sortColumn, ok := documentSortColumns[options.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort order %q", options.SortBy)
+	}
+
+	content := `''`
+	if options.IncludeContent {
+		content = `d.content`
+	}
+
+	var conditions []string
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions = append(conditions, `d.deleted_at IS NULL`)
+	if options.WorkspaceID != nil {
+		conditions = append(conditions, `d.workspace_id = `+arg(*options.WorkspaceID))
+	} else {
+		conditions = append(conditions, `d.user_id = `+arg(userID), `d.workspace_id IS NULL`)
+	}
+	if options.NamePrefix != "" {
+		conditions = append(conditions, `starts_with(d.document_name, `+arg(options.NamePrefix)+`)`)
+	}
+	if options.CollectionID != nil {
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM collection_documents cd
+		          JOIN collections c ON c.collection_id = cd.collection_id
+		          WHERE cd.document_id = d.document_id AND cd.collection_id = `+arg(*options.CollectionID)+` AND c.user_id = `+arg(userID)+`)`)
+	}
+	if options.CreatedAfter != nil {
+		conditions = append(conditions, `d.created_at >= `+arg(options.CreatedAfter.UTC()))
+	}
+	if options.CreatedBefore != nil {
+		conditions = append(conditions, `d.created_at < `+arg(options.CreatedBefore.UTC()))
+	}
+
+	metadataConditions, metadataArgs, err := metadataFilterSQL(options.MetadataFilters, len(args)+1)
+	if err != nil {
+		return nil, err
+	}
+	conditions = append(conditions, metadataConditions...)
+	args = append(args, metadataArgs...)
+
+	// Keyset pagination on the sort key, with the id breaking ties
+	order, comparison := "ASC", ">"
+	if options.Descending {
+		order, comparison = "DESC", "<"
+	}
+	if options.After != nil {
+		var after interface{} = options.After.Name
+		if options.SortBy != storemodels.DocumentSortName {
+			after = options.After.Time.UTC()
+		}
+		conditions = append(conditions, fmt.Sprintf(`(%s, d.document_id) %s (%s, %s)`,
+			sortColumn, comparison, arg(after), arg(options.After.DocumentID)))
+	}
+
+	query := `SELECT d.document_id, d.user_id, d.workspace_id, d.document_name, ` + content + `, d.metadata, d.revision,
+	                 octet_length(d.content), (SELECT COUNT(*) FROM document_chunks dc WHERE dc.document_id = d.document_id),
+	                 d.created_at, d.updated_at
+	          FROM documents d
+	          WHERE ` + strings.Join(conditions, ` AND `) + `
+	          ORDER BY ` + sortColumn + ` ` + order + `, d.document_id ` + order + `
+	          LIMIT ` + arg(options.Limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []storemodels.DocumentListItem
+	for rows.Next() {
+		var doc storemodels.DocumentListItem
+		err := rows.Scan(&doc.DocumentUUID, &doc.UserID, &doc.WorkspaceID, &doc.DocumentName, &doc.Content, &doc.Metadata, &doc.Revision,
+			&doc.Size, &doc.ChunkCount, &doc.CreatedAt, &doc.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, doc)
+	}
+	return documents, rows.Err()
+}
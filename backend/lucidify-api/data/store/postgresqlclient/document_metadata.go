@@ -0,0 +1,143 @@
+package postgresqlclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"lucidify-api/data/store/storemodels"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// UpdateDocumentMetadata replaces the metadata of the document. See
+// lockDocumentRevision for expectedRevision.
+func (s *PostgreSQL) UpdateDocumentMetadata(ctx context.Context, documentID uuid.UUID, metadata storemodels.DocumentMetadata, expectedRevision int64) error {
+	return s.UpdateDocumentFields(ctx, documentID, nil, &metadata, expectedRevision)
+}
+
+// UpdateDocumentFields renames the document and replaces its metadata, leaving
+// out those that are nil, in a single statement making a single revision. See
+// lockDocumentRevision for expectedRevision.
+func (s *PostgreSQL) UpdateDocumentFields(ctx context.Context, documentID uuid.UUID, name *string, metadata *storemodels.DocumentMetadata, expectedRevision int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockDocumentRevision(ctx, tx, documentID, expectedRevision); err != nil {
+		return err
+	}
+
+	assignments := []string{"revision = revision + 1", "updated_at = CURRENT_TIMESTAMP"}
+	args := []interface{}{documentID}
+	if name != nil {
+		args = append(args, *name)
+		assignments = append(assignments, fmt.Sprintf("document_name = $%d", len(args)))
+	}
+	if metadata != nil {
+		args = append(args, *metadata)
+		assignments = append(assignments, fmt.Sprintf("metadata = $%d", len(args)))
+	}
+	query := `UPDATE documents SET ` + strings.Join(assignments, ", ") + ` WHERE document_id = $1`
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FilterDocumentsByMetadata returns the documents outside of the trash that
+// match every filter, among the private documents of the user or, if
+// workspaceID is not nil, the documents of the workspace.
+func (s *PostgreSQL) FilterDocumentsByMetadata(ctx context.Context, userID string, workspaceID *string, metadataFilters []storemodels.MetadataFilter) ([]storemodels.Document, error) {
+	query := `SELECT document_id, user_id, workspace_id, document_name, content, metadata, created_at, updated_at
+	          FROM documents WHERE deleted_at IS NULL`
+	var args []interface{}
+	if workspaceID != nil {
+		query += ` AND workspace_id = $1`
+		args = append(args, *workspaceID)
+	} else {
+		query += ` AND user_id = $1 AND workspace_id IS NULL`
+		args = append(args, userID)
+	}
+
+	conditions, filterArgs, err := metadataFilterSQL(metadataFilters, len(args)+1)
+	if err != nil {
+		return nil, err
+	}
+	for _, condition := range conditions {
+		query += ` AND ` + condition
+	}
+	args = append(args, filterArgs...)
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []storemodels.Document
+	for rows.Next() {
+		var doc storemodels.Document
+		err := rows.Scan(&doc.DocumentUUID, &doc.UserID, &doc.WorkspaceID, &doc.DocumentName, &doc.Content, &doc.Metadata, &doc.CreatedAt, &doc.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, doc)
+	}
+	return documents, rows.Err()
+}
+
+var metadataRangeOperators = map[string]string{
+	storemodels.MetadataOpGreaterThan:        ">",
+	storemodels.MetadataOpGreaterThanOrEqual: ">=",
+	storemodels.MetadataOpLessThan:           "<",
+	storemodels.MetadataOpLessThanOrEqual:    "<=",
+}
+
+// metadataFilterSQL translates filters into conditions on the metadata column,
+// with placeholders numbered from firstArg. Values of the wrong type never
+// match rather than failing the query, hence the CASE guards around casts.
+func metadataFilterSQL(metadataFilters []storemodels.MetadataFilter, firstArg int) ([]string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+	for _, filter := range metadataFilters {
+		if err := filter.Validate(); err != nil {
+			return nil, nil, err
+		}
+		field := fmt.Sprintf("$%d", firstArg+len(args))
+		value := fmt.Sprintf("$%d", firstArg+len(args)+1)
+
+		switch filter.Operator {
+		case storemodels.MetadataOpEqual:
+			encoded, err := json.Marshal(filter.Value)
+			if err != nil {
+				return nil, nil, err
+			}
+			conditions = append(conditions, fmt.Sprintf(`metadata @> jsonb_build_object(%s::text, %s::jsonb)`, field, value))
+			args = append(args, filter.Field, string(encoded))
+		case storemodels.MetadataOpContains:
+			conditions = append(conditions, strings.NewReplacer("$f", field, "$v", value).Replace(
+				`((jsonb_typeof(metadata->$f::text) = 'array' AND metadata->$f::text ? $v::text) OR `+
+					`(jsonb_typeof(metadata->$f::text) = 'string' AND strpos(metadata->>$f::text, $v::text) > 0))`))
+			args = append(args, filter.Field, filter.Value)
+		default:
+			operator := metadataRangeOperators[filter.Operator]
+			if storemodels.MetadataValueKind(filter.Value) == storemodels.MetadataKindDate {
+				conditions = append(conditions, strings.NewReplacer("$f", field, "$v", value, "$op", operator).Replace(
+					`(CASE WHEN jsonb_typeof(metadata->$f::text) = 'string' AND metadata->>$f::text ~ '^\d{4}-\d{2}-\d{2}T' `+
+						`THEN (metadata->>$f::text)::timestamptz END) $op $v::timestamptz`))
+			} else {
+				conditions = append(conditions, strings.NewReplacer("$f", field, "$v", value, "$op", operator).Replace(
+					`(CASE WHEN jsonb_typeof(metadata->$f::text) = 'number' `+
+						`THEN (metadata->>$f::text)::numeric END) $op $v::numeric`))
+			}
+			args = append(args, filter.Field, filter.Value)
+		}
+	}
+	return conditions, args, nil
+}
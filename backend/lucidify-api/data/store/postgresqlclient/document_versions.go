@@ -0,0 +1,103 @@
+package postgresqlclient
+
+import (
+	"context"
+	"lucidify-api/data/store/storemodels"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ReplaceDocumentContent records the current content of the document as its
+// next version, replaces the content and deletes the chunks of the previous
+// content, all in one transaction. See lockDocumentRevision for
+// expectedRevision.
+func (s *PostgreSQL) ReplaceDocumentContent(ctx context.Context, documentID uuid.UUID, content, replacedBy string, expectedRevision int64, previousChunks []storemodels.Chunk) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The lock also gives concurrent replacements distinct versions
+	if err := lockDocumentRevision(ctx, tx, documentID, expectedRevision); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO document_versions (document_id, version, document_name, content, metadata, created_at, replaced_by)
+	          SELECT document_id,
+	                 COALESCE((SELECT MAX(version) FROM document_versions WHERE document_id = $1), 0) + 1,
+	                 document_name, content, metadata, updated_at, $2
+	          FROM documents WHERE document_id = $1`
+	_, err = tx.ExecContext(ctx, query, documentID, replacedBy)
+	if err != nil {
+		return err
+	}
+
+	query = `UPDATE documents SET content = $1, content_hash = $2, revision = revision + 1, updated_at = CURRENT_TIMESTAMP WHERE document_id = $3`
+	_, err = tx.ExecContext(ctx, query, content, storemodels.ContentHash(content), documentID)
+	if err != nil {
+		return err
+	}
+
+	chunkIDs := make([]string, len(previousChunks))
+	for i, chunk := range previousChunks {
+		chunkIDs[i] = chunk.ChunkID.String()
+	}
+	query = `DELETE FROM document_chunks WHERE document_id = $1 AND chunk_id = ANY($2::uuid[])`
+	_, err = tx.ExecContext(ctx, query, documentID, pq.Array(chunkIDs))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetDocumentVersions returns the versions of a document without their
+// content, newest first.
+func (s *PostgreSQL) GetDocumentVersions(ctx context.Context, documentID uuid.UUID) ([]storemodels.DocumentVersion, error) {
+	query := `SELECT document_id, version, document_name, '', metadata, octet_length(content), created_at, replaced_by, replaced_at
+	          FROM document_versions WHERE document_id = $1
+	          ORDER BY version DESC`
+	rows, err := s.db.QueryContext(ctx, query, documentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []storemodels.DocumentVersion
+	for rows.Next() {
+		version, err := scanDocumentVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, *version)
+	}
+	return versions, rows.Err()
+}
+
+// GetDocumentVersion returns sql.ErrNoRows if the document has no such version.
+func (s *PostgreSQL) GetDocumentVersion(ctx context.Context, documentID uuid.UUID, version int) (*storemodels.DocumentVersion, error) {
+	query := `SELECT document_id, version, document_name, content, metadata, octet_length(content), created_at, replaced_by, replaced_at
+	          FROM document_versions WHERE document_id = $1 AND version = $2`
+	return scanDocumentVersion(s.db.QueryRowContext(ctx, query, documentID, version))
+}
+
+func scanDocumentVersion(row rowScanner) (*storemodels.DocumentVersion, error) {
+	var version storemodels.DocumentVersion
+	err := row.Scan(
+		&version.DocumentID,
+		&version.Version,
+		&version.DocumentName,
+		&version.Content,
+		&version.Metadata,
+		&version.Size,
+		&version.CreatedAt,
+		&version.ReplacedBy,
+		&version.ReplacedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
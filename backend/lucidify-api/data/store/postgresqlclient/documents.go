@@ -1,28 +1,43 @@
 package postgresqlclient
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"lucidify-api/data/store/storemodels"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-func (s *PostgreSQL) UploadDocument(userID string, name, content string) (*storemodels.Document, error) {
+func (s *PostgreSQL) UploadDocument(ctx context.Context, userID string, name, content string) (*storemodels.Document, error) {
+	return s.UploadDocumentWithMetadata(ctx, userID, nil, name, content, nil)
+}
+
+// UploadWorkspaceDocument uploads a document shared with the members of a
+// workspace. userID is recorded as the uploader.
+func (s *PostgreSQL) UploadWorkspaceDocument(ctx context.Context, userID, workspaceID, name, content string) (*storemodels.Document, error) {
+	return s.UploadDocumentWithMetadata(ctx, userID, &workspaceID, name, content, nil)
+}
+
+// UploadDocumentWithMetadata uploads a private document, or a workspace
+// document if workspaceID is not nil.
+func (s *PostgreSQL) UploadDocumentWithMetadata(ctx context.Context, userID string, workspaceID *string, name, content string, metadata storemodels.DocumentMetadata) (*storemodels.Document, error) {
 	doc := &storemodels.Document{}
 
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	query := `INSERT INTO documents (user_id, document_name, content) 
-	          VALUES ($1, $2, $3) 
-	          RETURNING document_id, user_id, document_name, content, created_at, updated_at`
-	err = tx.QueryRow(query, userID, name, content).Scan(
-		&doc.DocumentUUID, &doc.UserID, &doc.DocumentName, &doc.Content, &doc.CreatedAt, &doc.UpdatedAt)
+	query := `INSERT INTO documents (user_id, workspace_id, document_name, content, content_hash, metadata) 
+	          VALUES ($1, $2, $3, $4, $5, $6) 
+	          RETURNING document_id, user_id, workspace_id, document_name, content, content_hash, metadata, revision, created_at, updated_at`
+	err = tx.QueryRowContext(ctx, query, userID, workspaceID, name, content, storemodels.ContentHash(content), metadata).Scan(
+		&doc.DocumentUUID, &doc.UserID, &doc.WorkspaceID, &doc.DocumentName, &doc.Content, &doc.ContentHash, &doc.Metadata, &doc.Revision, &doc.CreatedAt, &doc.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -35,30 +50,30 @@ func (s *PostgreSQL) UploadDocument(userID string, name, content string) (*store
 	return doc, nil
 }
 
-func (s *PostgreSQL) GetDocument(userID string, name string) (*storemodels.Document, error) {
+func (s *PostgreSQL) GetDocument(ctx context.Context, userID string, name string) (*storemodels.Document, error) {
 	doc := &storemodels.Document{}
-	query := `SELECT document_id, user_id, document_name, content, created_at, updated_at
+	query := `SELECT document_id, user_id, document_name, content, content_hash, revision, created_at, updated_at
 	          FROM documents
-	          WHERE user_id = $1 AND document_name = $2`
-	err := s.db.QueryRow(query, userID, name).Scan(
-		&doc.DocumentUUID, &doc.UserID, &doc.DocumentName, &doc.Content, &doc.CreatedAt, &doc.UpdatedAt)
+	          WHERE user_id = $1 AND document_name = $2 AND workspace_id IS NULL AND deleted_at IS NULL`
+	err := s.db.QueryRowContext(ctx, query, userID, name).Scan(
+		&doc.DocumentUUID, &doc.UserID, &doc.DocumentName, &doc.Content, &doc.ContentHash, &doc.Revision, &doc.CreatedAt, &doc.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return doc, nil
 }
 
-func (s *PostgreSQL) GetDocumentByUUID(documentUUID uuid.UUID) (*storemodels.Document, error) {
+func (s *PostgreSQL) GetDocumentByUUID(ctx context.Context, documentUUID uuid.UUID) (*storemodels.Document, error) {
 	doc := &storemodels.Document{}
-	query := `SELECT document_id, user_id, document_name, content, created_at, updated_at
+	query := `SELECT document_id, user_id, workspace_id, document_name, content, content_hash, metadata, revision, created_at, updated_at, deleted_at
 	          FROM documents
 	          WHERE document_id = $1`
-	err := s.db.QueryRow(query, documentUUID).Scan(
-		&doc.DocumentUUID, &doc.UserID, &doc.DocumentName, &doc.Content, &doc.CreatedAt, &doc.UpdatedAt)
+	err := s.db.QueryRowContext(ctx, query, documentUUID).Scan(
+		&doc.DocumentUUID, &doc.UserID, &doc.WorkspaceID, &doc.DocumentName, &doc.Content, &doc.ContentHash, &doc.Metadata, &doc.Revision, &doc.CreatedAt, &doc.UpdatedAt, &doc.DeletedAt)
 
 	// Handle the case where the query returns no rows
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("no document found with UUID: %s", documentUUID)
+		return nil, fmt.Errorf("no document found with UUID: %s: %w", documentUUID, sql.ErrNoRows)
 	} else if err != nil {
 		return nil, err
 	}
@@ -85,15 +100,15 @@ func (s *PostgreSQL) GetDocumentByUUID(documentUUID uuid.UUID) (*storemodels.Doc
 //		}
 //		return documents, nil
 //	}
-func (s *PostgreSQL) GetAllDocuments(userID string) ([]storemodels.Document, error) {
+func (s *PostgreSQL) GetAllDocuments(ctx context.Context, userID string) ([]storemodels.Document, error) {
 	if s.db == nil {
 		return nil, errors.New("database connection is nil")
 	}
 
 	var documents []storemodels.Document
-	query := `SELECT document_id, user_id, document_name, content, created_at, updated_at 
-	          FROM documents WHERE user_id = $1`
-	rows, err := s.db.Query(query, userID)
+	query := `SELECT document_id, user_id, document_name, content, metadata, created_at, updated_at 
+	          FROM documents WHERE user_id = $1 AND workspace_id IS NULL AND deleted_at IS NULL`
+	rows, err := s.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -101,7 +116,7 @@ func (s *PostgreSQL) GetAllDocuments(userID string) ([]storemodels.Document, err
 
 	for rows.Next() {
 		var doc storemodels.Document
-		err := rows.Scan(&doc.DocumentUUID, &doc.UserID, &doc.DocumentName, &doc.Content, &doc.CreatedAt, &doc.UpdatedAt)
+		err := rows.Scan(&doc.DocumentUUID, &doc.UserID, &doc.DocumentName, &doc.Content, &doc.Metadata, &doc.CreatedAt, &doc.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -123,7 +138,143 @@ func (s *PostgreSQL) GetAllDocuments(userID string) ([]storemodels.Document, err
 	return documents, nil
 }
 
-func (s *PostgreSQL) GetAllDocumentsIDs(userID string) ([]string, error) {
+// FindDocumentByContentHash returns the oldest document, outside of the trash,
+// with the given content hash among the private documents of the user, or the
+// documents of the workspace if workspaceID is not nil. It returns
+// sql.ErrNoRows if there is none.
+func (s *PostgreSQL) FindDocumentByContentHash(ctx context.Context, userID string, workspaceID *string, contentHash string) (*storemodels.Document, error) {
+	doc := &storemodels.Document{}
+	query := `SELECT document_id, user_id, workspace_id, document_name, content, content_hash, metadata, revision, created_at, updated_at
+	          FROM documents
+	          WHERE user_id = $1 AND workspace_id IS NULL AND content_hash = $2 AND deleted_at IS NULL
+	          ORDER BY created_at, document_id
+	          LIMIT 1`
+	args := []interface{}{userID, contentHash}
+	if workspaceID != nil {
+		query = `SELECT document_id, user_id, workspace_id, document_name, content, content_hash, metadata, revision, created_at, updated_at
+		         FROM documents
+		         WHERE workspace_id = $1 AND content_hash = $2 AND deleted_at IS NULL
+		         ORDER BY created_at, document_id
+		         LIMIT 1`
+		args = []interface{}{*workspaceID, contentHash}
+	}
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(
+		&doc.DocumentUUID, &doc.UserID, &doc.WorkspaceID, &doc.DocumentName, &doc.Content, &doc.ContentHash, &doc.Metadata, &doc.Revision, &doc.CreatedAt, &doc.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (s *PostgreSQL) GetWorkspaceDocuments(ctx context.Context, workspaceID string) ([]storemodels.Document, error) {
+	var documents []storemodels.Document
+	query := `SELECT document_id, user_id, workspace_id, document_name, content, metadata, created_at, updated_at
+	          FROM documents WHERE workspace_id = $1 AND deleted_at IS NULL`
+	rows, err := s.db.QueryContext(ctx, query, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var doc storemodels.Document
+		err := rows.Scan(&doc.DocumentUUID, &doc.UserID, &doc.WorkspaceID, &doc.DocumentName, &doc.Content, &doc.Metadata, &doc.CreatedAt, &doc.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, doc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return documents, nil
+}
+
+// GetWorkspaceDocumentIDs returns the ids of every document of the workspace,
+// including those in the trash.
+func (s *PostgreSQL) GetWorkspaceDocumentIDs(ctx context.Context, workspaceID string) ([]uuid.UUID, error) {
+	var documentIDs []uuid.UUID
+	query := `SELECT document_id FROM documents WHERE workspace_id = $1`
+	rows, err := s.db.QueryContext(ctx, query, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var documentID uuid.UUID
+		if err := rows.Scan(&documentID); err != nil {
+			return nil, err
+		}
+		documentIDs = append(documentIDs, documentID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return documentIDs, nil
+}
+
+// GetWorkspaceDocumentOwners returns the users who uploaded the documents of
+// the workspaces that are not in the trash.
+func (s *PostgreSQL) GetWorkspaceDocumentOwners(ctx context.Context, workspaceIDs []string) ([]string, error) {
+	var userIDs []string
+	query := `SELECT DISTINCT user_id FROM documents WHERE workspace_id = ANY($1) AND deleted_at IS NULL`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(workspaceIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+// GetDocumentOwners returns the uploader of each of the documents.
+func (s *PostgreSQL) GetDocumentOwners(ctx context.Context, documentIDs []uuid.UUID) (map[uuid.UUID]string, error) {
+	ids := make([]string, len(documentIDs))
+	for i, documentID := range documentIDs {
+		ids[i] = documentID.String()
+	}
+	query := `SELECT document_id, user_id FROM documents WHERE document_id = ANY($1::uuid[])`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	owners := make(map[uuid.UUID]string)
+	for rows.Next() {
+		var documentID uuid.UUID
+		var userID string
+		if err := rows.Scan(&documentID, &userID); err != nil {
+			return nil, err
+		}
+		owners[documentID] = userID
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return owners, nil
+}
+
+func (s *PostgreSQL) GetAllDocumentsIDs(ctx context.Context, userID string) ([]string, error) {
 	if s.db == nil {
 		return nil, errors.New("database connection is nil")
 	}
@@ -131,7 +282,7 @@ func (s *PostgreSQL) GetAllDocumentsIDs(userID string) ([]string, error) {
 	var documentsIDs []string
 	query := `SELECT document_id 
 	          FROM documents WHERE user_id = $1`
-	rows, err := s.db.Query(query, userID)
+	rows, err := s.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -161,15 +312,101 @@ func (s *PostgreSQL) GetAllDocumentsIDs(userID string) ([]string, error) {
 	return documentsIDs, nil
 }
 
-func (s *PostgreSQL) DeleteDocument(userID string, name string) error {
-	tx, err := s.db.Begin()
+// GetDocumentIDsByWorkspace returns the ids of every document uploaded by the
+// user, including those in the trash, by workspace. Private documents are
+// under the empty workspace id.
+func (s *PostgreSQL) GetDocumentIDsByWorkspace(ctx context.Context, userID string) (map[string][]uuid.UUID, error) {
+	query := `SELECT document_id, workspace_id FROM documents WHERE user_id = $1`
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	documentIDs := make(map[string][]uuid.UUID)
+	for rows.Next() {
+		var documentID uuid.UUID
+		var workspaceID sql.NullString
+		if err := rows.Scan(&documentID, &workspaceID); err != nil {
+			return nil, err
+		}
+		documentIDs[workspaceID.String] = append(documentIDs[workspaceID.String], documentID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return documentIDs, nil
+}
+
+// GetWorkspaceDocumentHeirs returns, for each workspace document uploaded by
+// the user, the member of its workspace who takes it over once the user is
+// deleted: the oldest owner, else the oldest editor, else the oldest viewer.
+// Documents of workspaces without any other active member are left out.
+func (s *PostgreSQL) GetWorkspaceDocumentHeirs(ctx context.Context, userID string) (map[uuid.UUID]string, error) {
+	query := `SELECT d.document_id, heir.user_id
+	          FROM documents d
+	          JOIN (SELECT DISTINCT ON (m.workspace_id) m.workspace_id, m.user_id
+	                FROM workspace_memberships m
+	                JOIN users u ON u.user_id = m.user_id AND u.deleted_at IS NULL
+	                WHERE m.user_id <> $1
+	                ORDER BY m.workspace_id,
+	                         CASE m.role WHEN 'owner' THEN 0 WHEN 'editor' THEN 1 ELSE 2 END,
+	                         m.created_at, m.user_id) heir ON heir.workspace_id = d.workspace_id
+	          WHERE d.user_id = $1`
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	heirs := make(map[uuid.UUID]string)
+	for rows.Next() {
+		var documentID uuid.UUID
+		var heirID string
+		if err := rows.Scan(&documentID, &heirID); err != nil {
+			return nil, err
+		}
+		heirs[documentID] = heirID
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return heirs, nil
+}
+
+// TransferDocuments makes each user of heirs the uploader of the document,
+// along with its chunks.
+func (s *PostgreSQL) TransferDocuments(ctx context.Context, heirs map[uuid.UUID]string) error {
+	if len(heirs) == 0 {
+		return nil
+	}
+	documentIDs := make([]string, 0, len(heirs))
+	userIDs := make([]string, 0, len(heirs))
+	for documentID, userID := range heirs {
+		documentIDs = append(documentIDs, documentID.String())
+		userIDs = append(userIDs, userID)
+	}
+	// The chunks follow through the ON UPDATE CASCADE of their foreign key
+	query := `UPDATE documents d SET user_id = t.user_id
+	          FROM unnest($1::uuid[], $2::text[]) AS t(document_id, user_id)
+	          WHERE d.document_id = t.document_id`
+	_, err := s.db.ExecContext(ctx, query, pq.Array(documentIDs), pq.Array(userIDs))
+	return err
+}
+
+func (s *PostgreSQL) DeleteDocument(ctx context.Context, userID string, name string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
 	query := `DELETE FROM documents WHERE user_id = $1 AND document_name = $2`
-	_, err = tx.Exec(query, userID, name)
+	_, err = tx.ExecContext(ctx, query, userID, name)
 	if err != nil {
 		return err
 	}
@@ -177,15 +414,15 @@ func (s *PostgreSQL) DeleteDocument(userID string, name string) error {
 	return tx.Commit()
 }
 
-func (s *PostgreSQL) DeleteDocumentByUUID(documentUUID uuid.UUID) error {
-	tx, err := s.db.Begin()
+func (s *PostgreSQL) DeleteDocumentByUUID(ctx context.Context, documentUUID uuid.UUID) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
 	query := `DELETE FROM documents WHERE document_id = $1`
-	_, err = tx.Exec(query, documentUUID.String())
+	_, err = tx.ExecContext(ctx, query, documentUUID.String())
 	if err != nil {
 		return err
 	}
@@ -193,16 +430,16 @@ func (s *PostgreSQL) DeleteDocumentByUUID(documentUUID uuid.UUID) error {
 	return tx.Commit()
 }
 
-func (s *PostgreSQL) UpdateDocumentContent(documentID uuid.UUID, newContent string) error {
-	tx, err := s.db.Begin()
+func (s *PostgreSQL) UpdateDocumentContent(ctx context.Context, documentID uuid.UUID, newContent string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
 	// Update the content using the document ID (UUID) in the WHERE clause
-	query := `UPDATE documents SET content = $1, updated_at = CURRENT_TIMESTAMP WHERE document_id = $2`
-	_, err = tx.Exec(query, newContent, documentID)
+	query := `UPDATE documents SET content = $1, content_hash = $2, revision = revision + 1, updated_at = CURRENT_TIMESTAMP WHERE document_id = $3`
+	_, err = tx.ExecContext(ctx, query, newContent, storemodels.ContentHash(newContent), documentID)
 	if err != nil {
 		return err
 	}
@@ -210,19 +447,123 @@ func (s *PostgreSQL) UpdateDocumentContent(documentID uuid.UUID, newContent stri
 	return tx.Commit()
 }
 
-func (s *PostgreSQL) UpdateDocumentName(documentID uuid.UUID, newDocumentName string) error {
-	tx, err := s.db.Begin()
+// UpdateDocumentName renames the document. See lockDocumentRevision for
+// expectedRevision.
+func (s *PostgreSQL) UpdateDocumentName(ctx context.Context, documentID uuid.UUID, newDocumentName string, expectedRevision int64) error {
+	return s.UpdateDocumentFields(ctx, documentID, &newDocumentName, nil, expectedRevision)
+}
+
+func (s *PostgreSQL) SoftDeleteDocument(ctx context.Context, documentUUID uuid.UUID) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Update the document_name using the document ID (UUID) in the WHERE clause
-	query := `UPDATE documents SET document_name = $1, updated_at = CURRENT_TIMESTAMP WHERE document_id = $2`
-	_, err = tx.Exec(query, newDocumentName, documentID)
+	query := `UPDATE documents SET deleted_at = CURRENT_TIMESTAMP WHERE document_id = $1 AND deleted_at IS NULL`
+	result, err := tx.ExecContext(ctx, query, documentUUID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no active document found with UUID: %s: %w", documentUUID, sql.ErrNoRows)
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgreSQL) RestoreDocument(ctx context.Context, documentUUID uuid.UUID) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Fails on the partial unique index if an active document has taken the name in the meantime
+	query := `UPDATE documents SET deleted_at = NULL WHERE document_id = $1 AND deleted_at IS NOT NULL`
+	result, err := tx.ExecContext(ctx, query, documentUUID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no trashed document found with UUID: %s: %w", documentUUID, sql.ErrNoRows)
+	}
 
 	return tx.Commit()
 }
+
+func (s *PostgreSQL) GetDeletedDocuments(ctx context.Context, userID string) ([]storemodels.Document, error) {
+	var documents []storemodels.Document
+	query := `SELECT document_id, user_id, workspace_id, document_name, content, metadata, created_at, updated_at, deleted_at
+	          FROM documents WHERE user_id = $1 AND workspace_id IS NULL AND deleted_at IS NOT NULL
+	          ORDER BY deleted_at DESC`
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var doc storemodels.Document
+		err := rows.Scan(&doc.DocumentUUID, &doc.UserID, &doc.WorkspaceID, &doc.DocumentName, &doc.Content, &doc.Metadata, &doc.CreatedAt, &doc.UpdatedAt, &doc.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, doc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return documents, nil
+}
+
+func (s *PostgreSQL) GetDocumentsDeletedBefore(ctx context.Context, cutoff time.Time) ([]storemodels.Document, error) {
+	var documents []storemodels.Document
+	query := `SELECT document_id, user_id, workspace_id, document_name, metadata, created_at, updated_at, deleted_at
+	          FROM documents WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+	rows, err := s.db.QueryContext(ctx, query, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var doc storemodels.Document
+		err := rows.Scan(&doc.DocumentUUID, &doc.UserID, &doc.WorkspaceID, &doc.DocumentName, &doc.Metadata, &doc.CreatedAt, &doc.UpdatedAt, &doc.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, doc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return documents, nil
+}
+
+// lockDocumentRevision locks the document until the end of the transaction.
+// It returns ErrRevisionMismatch if expectedRevision is not zero and is not the
+// revision of the document, and sql.ErrNoRows if there is no such document.
+func lockDocumentRevision(ctx context.Context, tx *sql.Tx, documentID uuid.UUID, expectedRevision int64) error {
+	var revision int64
+	query := `SELECT revision FROM documents WHERE document_id = $1 FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, documentID).Scan(&revision); err != nil {
+		return err
+	}
+	if expectedRevision != 0 && revision != expectedRevision {
+		return ErrRevisionMismatch
+	}
+	return nil
+}
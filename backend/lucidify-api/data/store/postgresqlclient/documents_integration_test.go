@@ -3,12 +3,17 @@
 package postgresqlclient
 
 import (
+	"context"
 	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/config"
 	"testing"
+	"time"
 )
 
 func TestStoreFunctions(t *testing.T) {
-	store, err := NewPostgreSQL()
+	ctx := context.Background()
+
+	store, err := NewPostgreSQL(config.NewServerConfig().PostgresqlURL)
 	if err != nil {
 		t.Errorf("Failed to create test postgresqlclient: %v", err)
 	}
@@ -28,20 +33,20 @@ func TestStoreFunctions(t *testing.T) {
 		UpdatedAt:        1654012591514,
 	}
 
-	err = store.CreateUserInUsersTable(user)
+	err = store.CreateUserInUsersTable(ctx, user)
 	if err != nil {
 		t.Errorf("Failed to create user: %v", err)
 	}
 
 	// Test UploadDocument
-	doc, err := store.UploadDocument("documents_integration_test_user_id", "test_doc", "test_content")
+	doc, err := store.UploadDocument(ctx, "documents_integration_test_user_id", "test_doc", "test_content")
 	if err != nil {
 		t.Errorf("Failed to upload document: %v", err)
 	}
 	t.Logf("Uploaded document: %+v", doc)
 
 	// Test GetDocument
-	docGet, err := store.GetDocument("documents_integration_test_user_id", "test_doc")
+	docGet, err := store.GetDocument(ctx, "documents_integration_test_user_id", "test_doc")
 	if err != nil {
 		t.Errorf("Failed to get document: %v", err)
 	}
@@ -51,7 +56,7 @@ func TestStoreFunctions(t *testing.T) {
 
 	// Test GetDocumentByUUID
 	documentUUID := doc.DocumentUUID
-	docByUUID, err := store.GetDocumentByUUID(documentUUID)
+	docByUUID, err := store.GetDocumentByUUID(ctx, documentUUID)
 	if err != nil {
 		t.Errorf("Failed to get document by UUID: %v", err)
 	}
@@ -64,13 +69,13 @@ func TestStoreFunctions(t *testing.T) {
 
 	// Test UpdateDocumentContent
 	newContent := "updated_document_content"
-	err = store.UpdateDocumentContent(documentUUID, newContent)
+	err = store.UpdateDocumentContent(ctx, documentUUID, newContent)
 	if err != nil {
 		t.Errorf("Failed to update document content: %v", err)
 	}
 
 	// Verify that the document content was updated
-	docWithUpdatedContent, err := store.GetDocumentByUUID(documentUUID)
+	docWithUpdatedContent, err := store.GetDocumentByUUID(ctx, documentUUID)
 	if err != nil {
 		t.Errorf("Failed to get document by UUID after updating content: %v", err)
 	}
@@ -80,13 +85,13 @@ func TestStoreFunctions(t *testing.T) {
 
 	// Test UpdateDocumentName
 	newDocumentName := "updated_doc_name"
-	err = store.UpdateDocumentName(documentUUID, newDocumentName)
+	err = store.UpdateDocumentName(ctx, documentUUID, newDocumentName, 0)
 	if err != nil {
 		t.Errorf("Failed to update document name: %v", err)
 	}
 
 	// Verify that the document content was updated
-	docWithUpdatedNameAndContent, err := store.GetDocumentByUUID(documentUUID)
+	docWithUpdatedNameAndContent, err := store.GetDocumentByUUID(ctx, documentUUID)
 	if err != nil {
 		t.Errorf("Failed to get document by UUID after updating content: %v", err)
 	}
@@ -96,7 +101,7 @@ func TestStoreFunctions(t *testing.T) {
 	}
 
 	// Test GetAllDocuments
-	docs, err := store.GetAllDocuments("documents_integration_test_user_id")
+	docs, err := store.GetAllDocuments(ctx, "documents_integration_test_user_id")
 	if err != nil {
 		t.Errorf("Failed to get all documents: %v", err)
 	}
@@ -105,28 +110,213 @@ func TestStoreFunctions(t *testing.T) {
 	}
 
 	// Test DeleteDocumentByUUID
-	err = store.DeleteDocumentByUUID(docWithUpdatedContent.DocumentUUID)
+	err = store.DeleteDocumentByUUID(ctx, docWithUpdatedContent.DocumentUUID)
 	if err != nil {
 		t.Errorf("Failed to delete document by UUID: %v", err)
 	}
 
 	// Verify that the document was deleted
-	docByUUID, err = store.GetDocumentByUUID(documentUUID)
+	docByUUID, err = store.GetDocumentByUUID(ctx, documentUUID)
 	if err == nil || docByUUID != nil {
 		t.Errorf("Document should have been deleted, but was still retrievable by UUID")
 	}
 
 	t.Cleanup(func() {
 		// Delete the test document
-		err = store.DeleteDocument("documents_integration_test_user_id", "test_doc")
+		err = store.DeleteDocument(ctx, "documents_integration_test_user_id", "test_doc")
 		if err != nil {
 			t.Errorf("Failed to delete test document: %v", err)
 		}
 
 		// Delete the test user
-		err = store.DeleteUserInUsersTable("documents_integration_test_user_id")
+		err = store.DeleteUserInUsersTable(ctx, "documents_integration_test_user_id")
+		if err != nil {
+			t.Errorf("Failed to delete test user: %v", err)
+		}
+	})
+}
+
+func TestSoftDeleteDocument(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := NewPostgreSQL(config.NewServerConfig().PostgresqlURL)
+	if err != nil {
+		t.Errorf("Failed to create test postgresqlclient: %v", err)
+	}
+
+	user := storemodels.User{
+		UserID:           "soft_delete_integration_test_user_id",
+		ExternalID:       "TestSoftDeleteDocumentExternalID",
+		Username:         "TestSoftDeleteDocumentUsername",
+		PasswordEnabled:  true,
+		Email:            "TestSoftDeleteDocument@example.com",
+		FirstName:        "TestSoftDeleteDocumentFirstName",
+		LastName:         "TestSoftDeleteDocumentLastName",
+		ImageURL:         "https://TestSoftDeleteDocument.com/image.jpg",
+		ProfileImageURL:  "https://TestSoftDeleteDocument.com/profile.jpg",
+		TwoFactorEnabled: false,
+		CreatedAt:        1654012591514,
+		UpdatedAt:        1654012591514,
+	}
+
+	err = store.CreateUserInUsersTable(ctx, user)
+	if err != nil {
+		t.Errorf("Failed to create user: %v", err)
+	}
+
+	doc, err := store.UploadDocument(ctx, user.UserID, "trashed_doc", "trashed_content")
+	if err != nil {
+		t.Fatalf("Failed to upload document: %v", err)
+	}
+
+	err = store.SoftDeleteDocument(ctx, doc.DocumentUUID)
+	if err != nil {
+		t.Errorf("Failed to soft delete document: %v", err)
+	}
+
+	// A trashed document is hidden from the regular lookups
+	_, err = store.GetDocument(ctx, user.UserID, "trashed_doc")
+	if err == nil {
+		t.Errorf("Trashed document should not be retrievable by name")
+	}
+	docs, err := store.GetAllDocuments(ctx, user.UserID)
+	if err != nil {
+		t.Errorf("Failed to get all documents: %v", err)
+	}
+	if len(docs) != 0 {
+		t.Errorf("Expected 0 active documents, got %d", len(docs))
+	}
+
+	// But it is listed in the trash and still retrievable by UUID
+	trashed, err := store.GetDeletedDocuments(ctx, user.UserID)
+	if err != nil {
+		t.Errorf("Failed to get deleted documents: %v", err)
+	}
+	if len(trashed) != 1 || trashed[0].DeletedAt == nil {
+		t.Errorf("Expected 1 trashed document, got %+v", trashed)
+	}
+	docByUUID, err := store.GetDocumentByUUID(ctx, doc.DocumentUUID)
+	if err != nil || docByUUID.DeletedAt == nil {
+		t.Errorf("Expected trashed document to be retrievable by UUID with DeletedAt set")
+	}
+
+	// The purger picks it up once the retention period has passed
+	expired, err := store.GetDocumentsDeletedBefore(ctx, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Errorf("Failed to get expired documents: %v", err)
+	}
+	found := false
+	for _, expiredDoc := range expired {
+		if expiredDoc.DocumentUUID == doc.DocumentUUID {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected trashed document to be returned as expired")
+	}
+
+	err = store.RestoreDocument(ctx, doc.DocumentUUID)
+	if err != nil {
+		t.Errorf("Failed to restore document: %v", err)
+	}
+	_, err = store.GetDocument(ctx, user.UserID, "trashed_doc")
+	if err != nil {
+		t.Errorf("Restored document should be retrievable by name: %v", err)
+	}
+
+	t.Cleanup(func() {
+		err = store.DeleteUserInUsersTable(ctx, user.UserID)
 		if err != nil {
 			t.Errorf("Failed to delete test user: %v", err)
 		}
 	})
 }
+
+func TestTransferWorkspaceDocuments(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := NewPostgreSQL(config.NewServerConfig().PostgresqlURL)
+	if err != nil {
+		t.Fatalf("Failed to create test postgresqlclient: %v", err)
+	}
+
+	users := []storemodels.User{
+		{UserID: "transfer_integration_test_uploader", Email: "TestTransferUploader@example.com"},
+		{UserID: "transfer_integration_test_viewer", Email: "TestTransferViewer@example.com"},
+		{UserID: "transfer_integration_test_owner", Email: "TestTransferOwner@example.com"},
+	}
+	for _, user := range users {
+		if err := store.CreateUserInUsersTable(ctx, user); err != nil {
+			t.Errorf("Failed to create user: %v", err)
+		}
+	}
+	uploader, viewer, owner := users[0].UserID, users[1].UserID, users[2].UserID
+
+	workspace := storemodels.Workspace{WorkspaceID: "transfer_integration_test_workspace", Name: "Transfer", Slug: "transfer-integration-test", CreatedBy: owner}
+	if err := store.UpsertWorkspace(ctx, workspace); err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	memberships := []storemodels.WorkspaceMembership{
+		{WorkspaceID: workspace.WorkspaceID, UserID: uploader, Role: storemodels.WorkspaceRoleEditor, CreatedAt: 1},
+		{WorkspaceID: workspace.WorkspaceID, UserID: viewer, Role: storemodels.WorkspaceRoleViewer, CreatedAt: 2},
+		{WorkspaceID: workspace.WorkspaceID, UserID: owner, Role: storemodels.WorkspaceRoleOwner, CreatedAt: 3},
+	}
+	for _, membership := range memberships {
+		if err := store.UpsertWorkspaceMembership(ctx, membership); err != nil {
+			t.Fatalf("Failed to add member: %v", err)
+		}
+	}
+
+	private, err := store.UploadDocument(ctx, uploader, "transfer_private_doc", "private content")
+	if err != nil {
+		t.Fatalf("Failed to upload document: %v", err)
+	}
+	shared, err := store.UploadWorkspaceDocument(ctx, uploader, workspace.WorkspaceID, "transfer_shared_doc", "shared content")
+	if err != nil {
+		t.Fatalf("Failed to upload document: %v", err)
+	}
+	_, err = store.UploadChunks(ctx, []storemodels.Chunk{{UserID: uploader, DocumentID: shared.DocumentUUID, ChunkContent: "shared content"}})
+	if err != nil {
+		t.Fatalf("Failed to upload chunks: %v", err)
+	}
+
+	// The owner takes over the shared document, the private one has no heir
+	heirs, err := store.GetWorkspaceDocumentHeirs(ctx, uploader)
+	if err != nil {
+		t.Fatalf("Failed to get heirs: %v", err)
+	}
+	if len(heirs) != 1 || heirs[shared.DocumentUUID] != owner {
+		t.Errorf("Expected the shared document to go to %s, got %v", owner, heirs)
+	}
+
+	if err := store.TransferDocuments(ctx, heirs); err != nil {
+		t.Fatalf("Failed to transfer documents: %v", err)
+	}
+	transferred, err := store.GetDocumentByUUID(ctx, shared.DocumentUUID)
+	if err != nil || transferred.UserID != owner {
+		t.Errorf("Expected the shared document to belong to %s: %v", owner, err)
+	}
+	chunks, err := store.GetChunksOfDocumentByDocumentID(ctx, shared.DocumentUUID)
+	if err != nil || len(chunks) != 1 || chunks[0].UserID != owner || chunks[0].WorkspaceID != workspace.WorkspaceID {
+		t.Errorf("Expected the chunks to follow their document: %+v, %v", chunks, err)
+	}
+
+	remaining, err := store.GetDocumentIDsByWorkspace(ctx, uploader)
+	if err != nil {
+		t.Errorf("Failed to get documents: %v", err)
+	}
+	if len(remaining) != 1 || len(remaining[""]) != 1 || remaining[""][0] != private.DocumentUUID {
+		t.Errorf("Expected only the private document to be left, got %v", remaining)
+	}
+
+	t.Cleanup(func() {
+		if err := store.DeleteWorkspace(ctx, workspace.WorkspaceID); err != nil {
+			t.Errorf("Failed to delete test workspace: %v", err)
+		}
+		for _, user := range users {
+			if err := store.DeleteUserInUsersTable(ctx, user.UserID); err != nil {
+				t.Errorf("Failed to delete test user: %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package postgresqlclient
+
+import (
+	"context"
+	"github.com/lib/pq"
+)
+
+// GetCachedEmbeddings returns the cached vectors of the given text hashes,
+// keyed by text hash. Hashes without a cached vector are missing from the map.
+func (s *PostgreSQL) GetCachedEmbeddings(ctx context.Context, model string, textHashes []string) (map[string][]float32, error) {
+	query := `SELECT text_hash, vector FROM embedding_cache WHERE model = $1 AND text_hash = ANY($2)`
+	rows, err := s.db.QueryContext(ctx, query, model, pq.Array(textHashes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vectors := make(map[string][]float32)
+	for rows.Next() {
+		var textHash string
+		var vector pq.Float32Array
+		if err := rows.Scan(&textHash, &vector); err != nil {
+			return nil, err
+		}
+		vectors[textHash] = vector
+	}
+	return vectors, rows.Err()
+}
+
+// StoreCachedEmbeddings caches the vectors, keyed by text hash. Vectors already
+// cached are left as they are.
+func (s *PostgreSQL) StoreCachedEmbeddings(ctx context.Context, model string, vectors map[string][]float32) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO embedding_cache (model, text_hash, vector) VALUES ($1, $2, $3)
+	          ON CONFLICT (model, text_hash) DO NOTHING`
+	for textHash, vector := range vectors {
+		_, err := tx.ExecContext(ctx, query, model, textHash, pq.Float32Array(vector))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteCachedEmbeddings removes the cached vectors of a model and returns how
+// many there were.
+func (s *PostgreSQL) DeleteCachedEmbeddings(ctx context.Context, model string) (int64, error) {
+	query := `DELETE FROM embedding_cache WHERE model = $1`
+	result, err := s.db.ExecContext(ctx, query, model)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
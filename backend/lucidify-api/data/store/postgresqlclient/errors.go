@@ -0,0 +1,21 @@
+package postgresqlclient
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// ErrRevisionMismatch is returned by conditional updates of a document that
+// has been changed since the expected revision.
+var ErrRevisionMismatch = errors.New("document revision does not match")
+
+// uniqueViolation is the SQLSTATE of a unique constraint violation.
+const uniqueViolation = "23505"
+
+// IsUniqueViolation reports whether err was caused by a unique constraint, such
+// as the one on the names of the active documents of a user or workspace.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
@@ -0,0 +1,119 @@
+package postgresqlclient
+
+import (
+	"context"
+	"database/sql/driver"
+	"lucidify-api/server/metrics"
+	"lucidify-api/server/tracing"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const packagePath = "lucidify-api/data/store/postgresqlclient."
+
+// instrumentedConnector opens pq connections whose statements are timed and
+// traced, so that every method of PostgreSQL is measured without wrapping each
+// of them.
+type instrumentedConnector struct {
+	*pq.Connector
+}
+
+func newInstrumentedConnector(postgresqlURL string) (*instrumentedConnector, error) {
+	connector, err := pq.NewConnector(postgresqlURL)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConnector{Connector: connector}, nil
+}
+
+func (c *instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{Conn: conn}, nil
+}
+
+// instrumentedConn times and traces the statements of a pq connection. pq implements
+// every optional interface of database/sql/driver used below.
+type instrumentedConn struct {
+	driver.Conn
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, done := observeStatement(ctx, query)
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	done(err)
+	return rows, err
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ctx, done := observeStatement(ctx, query)
+	result, err := c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+	done(err)
+	return result, err
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (c *instrumentedConn) IsValid() bool {
+	return c.Conn.(driver.Validator).IsValid()
+}
+
+// observeStatement times and traces the statement until the returned function
+// is called with its error. Its span is named after the calling method.
+func observeStatement(ctx context.Context, query string) (context.Context, func(error)) {
+	operation := callingOperation()
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "postgresql."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBStatement(query)),
+	)
+	return ctx, func(err error) {
+		metrics.PostgreSQLQueryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.PostgreSQLQueryErrors.WithLabelValues(operation).Inc()
+		}
+		tracing.End(span, err)
+	}
+}
+
+// callingOperation names the function of this package that ran the statement,
+// GetDocument for (*PostgreSQL).GetDocument.
+func callingOperation() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if name, ok := strings.CutPrefix(frame.Function, packagePath); ok && !strings.HasPrefix(name, "(*instrumentedConn)") {
+			name = strings.TrimPrefix(name, "(*PostgreSQL).")
+			// Closures are named after the function defining them
+			if i := strings.Index(name, ".func"); i > 0 {
+				name = name[:i]
+			}
+			return name
+		}
+		if !more {
+			return "other"
+		}
+	}
+}
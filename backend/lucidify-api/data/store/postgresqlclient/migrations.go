@@ -0,0 +1,288 @@
+package postgresqlclient
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"lucidify-api/db/migrations"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// The applied version is recorded in the schema_migrations table of
+// golang-migrate, so that databases migrated with its CLI keep their version.
+
+// migrationLockID is the key of the advisory lock held while migrating, so
+// that concurrent runners wait for each other.
+const migrationLockID = 7310486531
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a version of the schema. Down is empty for migrations that
+// cannot be reverted.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is the version the database is at and the migrations the
+// binary has that it lacks.
+type MigrationStatus struct {
+	Version       int   `json:"version"`
+	Dirty         bool  `json:"dirty"`
+	LatestVersion int   `json:"latest_version"`
+	Pending       []int `json:"pending"`
+}
+
+// Behind reports whether the database lacks migrations or was left dirty by a
+// failed migration.
+func (m *MigrationStatus) Behind() bool {
+	return m.Dirty || len(m.Pending) > 0
+}
+
+// Migrations returns the migrations embedded in the binary, by version.
+func Migrations() ([]Migration, error) {
+	return loadMigrations(migrations.FS)
+}
+
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has files with different names", version)
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	result := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up file", migration.Version)
+		}
+		result = append(result, *migration)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+	return result, nil
+}
+
+// LatestMigrationVersion returns the version the binary expects the database
+// to be at.
+func LatestMigrationVersion() (int, error) {
+	all, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(all) == 0 {
+		return 0, nil
+	}
+	return all[len(all)-1].Version, nil
+}
+
+func ensureMigrationsTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`)
+	return err
+}
+
+// appliedVersion returns the version recorded in schema_migrations, 0 if none
+// has been applied.
+func appliedVersion(ctx context.Context, conn *sql.Conn) (int, bool, error) {
+	var version int
+	var dirty bool
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+func (s *PostgreSQL) MigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	all, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := ensureMigrationsTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	version, dirty, err := appliedVersion(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{Version: version, Dirty: dirty, Pending: []int{}}
+	for _, migration := range all {
+		status.LatestVersion = migration.Version
+		if migration.Version > version {
+			status.Pending = append(status.Pending, migration.Version)
+		}
+	}
+	return status, nil
+}
+
+// MigrateUp applies every pending migration.
+func (s *PostgreSQL) MigrateUp(ctx context.Context) error {
+	latest, err := LatestMigrationVersion()
+	if err != nil {
+		return err
+	}
+	return s.MigrateTo(ctx, latest)
+}
+
+// MigrateDown reverts the last steps migrations.
+func (s *PostgreSQL) MigrateDown(ctx context.Context, steps int) error {
+	status, err := s.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+	all, err := Migrations()
+	if err != nil {
+		return err
+	}
+
+	target := status.Version
+	for i := len(all) - 1; i >= 0 && steps > 0; i-- {
+		if all[i].Version <= target {
+			target = 0
+			if i > 0 {
+				target = all[i-1].Version
+			}
+			steps--
+		}
+	}
+	return s.MigrateTo(ctx, target)
+}
+
+// MigrateTo applies or reverts migrations until the database is at the given
+// version, 0 reverting every migration. Each migration runs in a transaction
+// along with the update of its version, so that a failed migration leaves the
+// database at the previous version.
+func (s *PostgreSQL) MigrateTo(ctx context.Context, target int) error {
+	all, err := Migrations()
+	if err != nil {
+		return err
+	}
+	if target != 0 && !hasMigration(all, target) {
+		return fmt.Errorf("unknown migration version %d", target)
+	}
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	if err := ensureMigrationsTable(ctx, conn); err != nil {
+		return err
+	}
+	version, dirty, err := appliedVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("database is dirty at version %d, a previous migration failed halfway and must be fixed by hand", version)
+	}
+
+	for _, migration := range all {
+		if migration.Version <= version || migration.Version > target {
+			continue
+		}
+		if err := applyMigration(ctx, conn, migration.Up, migration.Version); err != nil {
+			return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		version = migration.Version
+	}
+
+	for i := len(all) - 1; i >= 0; i-- {
+		migration := all[i]
+		if migration.Version > version || migration.Version <= target {
+			continue
+		}
+		if migration.Down == "" {
+			return fmt.Errorf("migration %d_%s cannot be reverted", migration.Version, migration.Name)
+		}
+		previous := 0
+		if i > 0 {
+			previous = all[i-1].Version
+		}
+		if err := applyMigration(ctx, conn, migration.Down, previous); err != nil {
+			return fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		version = previous
+	}
+	return nil
+}
+
+func hasMigration(all []Migration, version int) bool {
+	for _, migration := range all {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// applyMigration runs the statements and records the resulting version, 0
+// clearing it.
+func applyMigration(ctx context.Context, conn *sql.Conn, statements string, version int) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `TRUNCATE schema_migrations`); err != nil {
+		return err
+	}
+	if version > 0 {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, version); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
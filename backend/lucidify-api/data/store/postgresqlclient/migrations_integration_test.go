@@ -0,0 +1,52 @@
+// //go:build integration
+// // +build integration
+package postgresqlclient
+
+import (
+	"context"
+	"lucidify-api/server/config"
+	"testing"
+)
+
+func TestIntegrationEmbeddedMigrations(t *testing.T) {
+	all, err := Migrations()
+	if err != nil {
+		t.Fatalf("Failed to load embedded migrations: %v", err)
+	}
+	if len(all) == 0 {
+		t.Fatal("Expected embedded migrations")
+	}
+	for i, migration := range all {
+		if migration.Version != i+1 {
+			t.Errorf("Expected migration %d to have version %d, got %d", i, i+1, migration.Version)
+		}
+		if migration.Up == "" {
+			t.Errorf("Expected migration %d to have an up file", migration.Version)
+		}
+	}
+}
+
+func TestIntegrationMigrationStatus(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := NewPostgreSQL(config.NewServerConfig().PostgresqlURL)
+	if err != nil {
+		t.Fatalf("Failed to create postgresqlclient: %v", err)
+	}
+	defer store.db.Close()
+
+	if err := store.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+	status, err := store.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	latest, err := LatestMigrationVersion()
+	if err != nil {
+		t.Fatalf("LatestMigrationVersion failed: %v", err)
+	}
+	if status.Behind() || status.Version != latest {
+		t.Errorf("Expected the database to be at version %d, got %+v", latest, status)
+	}
+}
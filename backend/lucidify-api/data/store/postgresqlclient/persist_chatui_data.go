@@ -1,6 +1,7 @@
 package postgresqlclient
 
 import (
+	"context"
 	"errors"
 	"log"
 	"strings"
@@ -19,7 +20,7 @@ func determineTableFromKey(key string) (string, error) {
 	}
 }
 
-func (s *PostgreSQL) SetData(userID, key, value string) error {
+func (s *PostgreSQL) SetData(ctx context.Context, userID, key, value string) error {
 	table, err := determineTableFromKey(key)
 	if err != nil {
 		return err
@@ -33,11 +34,11 @@ func (s *PostgreSQL) SetData(userID, key, value string) error {
 		DO UPDATE SET data = EXCLUDED.data
 	`
 
-	_, err = s.db.Exec(query, userID, value)
+	_, err = s.db.ExecContext(ctx, query, userID, value)
 	return err
 }
 
-func (s *PostgreSQL) GetData(userID, key string) (string, error) {
+func (s *PostgreSQL) GetData(ctx context.Context, userID, key string) (string, error) {
 	table, err := determineTableFromKey(key)
 	if err != nil {
 		return "", err
@@ -45,7 +46,7 @@ func (s *PostgreSQL) GetData(userID, key string) (string, error) {
 
 	var data string
 	query := `SELECT data FROM ` + table + ` WHERE user_id = $1`
-	err = s.db.QueryRow(query, userID).Scan(&data)
+	err = s.db.QueryRowContext(ctx, query, userID).Scan(&data)
 	if err != nil {
 		log.Println("Error fetching data:", err)
 		return "", err
@@ -55,14 +56,14 @@ func (s *PostgreSQL) GetData(userID, key string) (string, error) {
 	return data, nil
 }
 
-func (s *PostgreSQL) ClearConversations(userID string) error {
+func (s *PostgreSQL) ClearConversations(ctx context.Context, userID string) error {
 	query := `DELETE FROM conversation_history WHERE user_id = $1`
-	_, errDelConversationsHistory := s.db.Exec(query, userID)
+	_, errDelConversationsHistory := s.db.ExecContext(ctx, query, userID)
 	if errDelConversationsHistory != nil {
 		return errDelConversationsHistory
 	}
 	query2 := `DELETE FROM folders WHERE user_id = $1`
-	_, errDelFolders := s.db.Exec(query2, userID)
+	_, errDelFolders := s.db.ExecContext(ctx, query2, userID)
 	if errDelFolders != nil {
 		return errDelFolders
 	}
@@ -3,12 +3,16 @@
 package postgresqlclient
 
 import (
+	"context"
 	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/config"
 	"testing"
 )
 
 func TestPostgreSQLClientFunctions(t *testing.T) {
-	store, err := NewPostgreSQL()
+	ctx := context.Background()
+
+	store, err := NewPostgreSQL(config.NewServerConfig().PostgresqlURL)
 	if err != nil {
 		t.Errorf("Failed to create test postgresqlclient: %v", err)
 	}
@@ -28,7 +32,7 @@ func TestPostgreSQLClientFunctions(t *testing.T) {
 		UpdatedAt:        1654012591514,
 	}
 
-	err = store.CreateUserInUsersTable(user)
+	err = store.CreateUserInUsersTable(ctx, user)
 	if err != nil {
 		t.Errorf("Failed to create user: %v", err)
 	}
@@ -50,13 +54,13 @@ func TestPostgreSQLClientFunctions(t *testing.T) {
 		}
 
 		for _, test := range tests {
-			err := store.SetData(userID, test.key, test.value)
+			err := store.SetData(ctx, userID, test.key, test.value)
 			if (err != nil) != test.shouldError {
 				t.Errorf("Expected error: %v, got: %v for key: %s", test.shouldError, err, test.key)
 			}
 
 			if !test.shouldError {
-				data, err := store.GetData(userID, test.key)
+				data, err := store.GetData(ctx, userID, test.key)
 				if err != nil {
 					t.Errorf("Error fetching data for key: %s, err: %v", test.key, err)
 				}
@@ -69,29 +73,29 @@ func TestPostgreSQLClientFunctions(t *testing.T) {
 
 	t.Run("test clear conversations", func(t *testing.T) {
 		// Pre-setup: Insert some data to ensure clear functionality works.
-		err := store.SetData(userID, "conversationHistory_preClear", "testData")
+		err := store.SetData(ctx, userID, "conversationHistory_preClear", "testData")
 		if err != nil {
 			t.Errorf("Pre-setup failed for clear conversations test. Err: %v", err)
 			return
 		}
-		err = store.SetData(userID, "folders_preClear", "testData")
+		err = store.SetData(ctx, userID, "folders_preClear", "testData")
 		if err != nil {
 			t.Errorf("Pre-setup failed for clear conversations test. Err: %v", err)
 			return
 		}
 
-		err = store.ClearConversations(userID)
+		err = store.ClearConversations(ctx, userID)
 		if err != nil {
 			t.Errorf("Failed to clear conversations. Err: %v", err)
 			return
 		}
 
 		// Check if data is actually cleared.
-		data, err := store.GetData(userID, "conversationHistory_preClear")
+		data, err := store.GetData(ctx, userID, "conversationHistory_preClear")
 		if err == nil || data != "" {
 			t.Errorf("Data not cleared for key: conversationHistory_preClear")
 		}
-		data, err = store.GetData(userID, "folders_preClear")
+		data, err = store.GetData(ctx, userID, "folders_preClear")
 		if err == nil || data != "" {
 			t.Errorf("Data not cleared for key: folders_preClear")
 		}
@@ -99,12 +103,12 @@ func TestPostgreSQLClientFunctions(t *testing.T) {
 
 	t.Cleanup(func() {
 		// Delete the test user
-		err = store.DeleteUserInUsersTable("pgclient_integration_test_user_id")
+		err = store.DeleteUserInUsersTable(ctx, "pgclient_integration_test_user_id")
 		if err != nil {
 			t.Errorf("Failed to delete test user: %v", err)
 		}
 		// Cleanup inserted test data.
-		err := store.ClearConversations(userID)
+		err := store.ClearConversations(ctx, userID)
 		if err != nil {
 			t.Errorf("Cleanup failed. Unable to clear conversations. Err: %v", err)
 		}
@@ -1,28 +1,26 @@
 package postgresqlclient
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"lucidify-api/server/config"
-
-	_ "github.com/lib/pq"
+	"time"
 )
 
 type PostgreSQL struct {
 	db *sql.DB
 }
 
-func NewPostgreSQL() (*PostgreSQL, error) {
-	config := config.NewServerConfig()
-	postgresqlURL := config.PostgresqlURL
+func NewPostgreSQL(postgresqlURL string) (*PostgreSQL, error) {
 	if postgresqlURL == "" {
 		return nil, fmt.Errorf("POSTGRESQL_URL environment variable is not set")
 	}
 
-	db, err := sql.Open("postgres", postgresqlURL)
+	connector, err := newInstrumentedConnector(postgresqlURL)
 	if err != nil {
 		return nil, err
 	}
+	db := sql.OpenDB(connector)
 
 	if err := db.Ping(); err != nil {
 		return nil, err
@@ -30,3 +28,22 @@ func NewPostgreSQL() (*PostgreSQL, error) {
 
 	return &PostgreSQL{db: db}, nil
 }
+
+// Ping checks that PostgreSQL can be reached.
+func (s *PostgreSQL) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the pool, waiting for the queries in flight to finish.
+func (s *PostgreSQL) Close() error {
+	return s.db.Close()
+}
+
+// SetPoolLimits bounds the connections of the pool. As with database/sql, a
+// zero maximum of open connections or duration means no limit.
+func (s *PostgreSQL) SetPoolLimits(maxOpenConns int, maxIdleConns int, connMaxLifetime time.Duration, connMaxIdleTime time.Duration) {
+	s.db.SetMaxOpenConns(maxOpenConns)
+	s.db.SetMaxIdleConns(maxIdleConns)
+	s.db.SetConnMaxLifetime(connMaxLifetime)
+	s.db.SetConnMaxIdleTime(connMaxIdleTime)
+}
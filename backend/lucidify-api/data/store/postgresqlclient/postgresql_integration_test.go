@@ -4,11 +4,12 @@
 package postgresqlclient
 
 import (
+	"lucidify-api/server/config"
 	"testing"
 )
 
 func TestIntegrationNewStore(t *testing.T) {
-	store, err := NewPostgreSQL()
+	store, err := NewPostgreSQL(config.NewServerConfig().PostgresqlURL)
 	if err != nil {
 		t.Errorf("Failed to create postgresqlclient: %v", err)
 	}
@@ -0,0 +1,27 @@
+package postgresqlclient
+
+import (
+	"context"
+	"lucidify-api/data/store/storemodels"
+)
+
+// GetQueueDepths counts the webhook events left to process and the soft
+// deleted documents and users left to purge.
+func (s *PostgreSQL) GetQueueDepths(ctx context.Context) (*storemodels.QueueDepths, error) {
+	query := `SELECT
+	              (SELECT COUNT(*) FROM webhook_events WHERE status = 'pending'),
+	              (SELECT COUNT(*) FROM webhook_events WHERE status = 'dead'),
+	              (SELECT COUNT(*) FROM documents WHERE deleted_at IS NOT NULL),
+	              (SELECT COUNT(*) FROM users WHERE deleted_at IS NOT NULL)`
+	var depths storemodels.QueueDepths
+	err := s.db.QueryRowContext(ctx, query).Scan(
+		&depths.PendingWebhookEvents,
+		&depths.DeadWebhookEvents,
+		&depths.TrashedDocuments,
+		&depths.DeletedUsers,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &depths, nil
+}
@@ -3,18 +3,20 @@
 package postgresqlclient
 
 import (
+	"context"
 	"lucidify-api/data/store/storemodels"
+	"time"
 )
 
-func (s *PostgreSQL) CreateUserInUsersTable(user storemodels.User) error {
-	tx, err := s.db.Begin()
+func (s *PostgreSQL) CreateUserInUsersTable(ctx context.Context, user storemodels.User) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback() // Make sure it's idempotent
 
 	query := `INSERT INTO users (user_id, external_id, username, password_enabled, email, first_name, last_name, image_url, profile_image_url, two_factor_enabled, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
-	_, err = tx.Exec(query, user.UserID, user.ExternalID, user.Username, user.PasswordEnabled, user.Email, user.FirstName, user.LastName, user.ImageURL, user.ProfileImageURL, user.TwoFactorEnabled, user.CreatedAt, user.UpdatedAt)
+	_, err = tx.ExecContext(ctx, query, user.UserID, user.ExternalID, user.Username, user.PasswordEnabled, user.Email, user.FirstName, user.LastName, user.ImageURL, user.ProfileImageURL, user.TwoFactorEnabled, user.CreatedAt, user.UpdatedAt)
 	if err != nil {
 		return err
 	}
@@ -22,15 +24,34 @@ func (s *PostgreSQL) CreateUserInUsersTable(user storemodels.User) error {
 	return tx.Commit()
 }
 
-func (s *PostgreSQL) UpdateUserInUsersTable(user storemodels.User) error {
-	tx, err := s.db.Begin()
+// UpsertUserInUsersTable creates the user or overwrites an existing row with
+// the same user_id, unless the existing row is newer than the given user.
+func (s *PostgreSQL) UpsertUserInUsersTable(ctx context.Context, user storemodels.User) error {
+	query := `INSERT INTO users (user_id, external_id, username, password_enabled, email, first_name, last_name, image_url, profile_image_url, two_factor_enabled, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	          ON CONFLICT (user_id) DO UPDATE SET external_id = EXCLUDED.external_id, username = EXCLUDED.username, password_enabled = EXCLUDED.password_enabled, email = EXCLUDED.email, first_name = EXCLUDED.first_name, last_name = EXCLUDED.last_name, image_url = EXCLUDED.image_url, profile_image_url = EXCLUDED.profile_image_url, two_factor_enabled = EXCLUDED.two_factor_enabled, created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at
+	          WHERE users.updated_at IS NULL OR users.updated_at <= EXCLUDED.updated_at`
+	_, err := s.db.ExecContext(ctx, query, user.UserID, user.ExternalID, user.Username, user.PasswordEnabled, user.Email, user.FirstName, user.LastName, user.ImageURL, user.ProfileImageURL, user.TwoFactorEnabled, user.CreatedAt, user.UpdatedAt)
+	return err
+}
+
+// CreateUserInUsersTableIfNotExists creates the user unless a row with the same
+// user_id already exists, in which case the existing row is left untouched.
+func (s *PostgreSQL) CreateUserInUsersTableIfNotExists(ctx context.Context, user storemodels.User) error {
+	query := `INSERT INTO users (user_id, external_id, username, password_enabled, email, first_name, last_name, image_url, profile_image_url, two_factor_enabled, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	          ON CONFLICT (user_id) DO NOTHING`
+	_, err := s.db.ExecContext(ctx, query, user.UserID, user.ExternalID, user.Username, user.PasswordEnabled, user.Email, user.FirstName, user.LastName, user.ImageURL, user.ProfileImageURL, user.TwoFactorEnabled, user.CreatedAt, user.UpdatedAt)
+	return err
+}
+
+func (s *PostgreSQL) UpdateUserInUsersTable(ctx context.Context, user storemodels.User) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
 	query := `UPDATE users SET external_id = $2, username = $3, password_enabled = $4, email = $5, first_name = $6, last_name = $7, image_url = $8, profile_image_url = $9, two_factor_enabled = $10, updated_at = $11 WHERE user_id = $1`
-	_, err = tx.Exec(query, user.UserID, user.ExternalID, user.Username, user.PasswordEnabled, user.Email, user.FirstName, user.LastName, user.ImageURL, user.ProfileImageURL, user.TwoFactorEnabled, user.UpdatedAt)
+	_, err = tx.ExecContext(ctx, query, user.UserID, user.ExternalID, user.Username, user.PasswordEnabled, user.Email, user.FirstName, user.LastName, user.ImageURL, user.ProfileImageURL, user.TwoFactorEnabled, user.UpdatedAt)
 	if err != nil {
 		return err
 	}
@@ -38,9 +59,9 @@ func (s *PostgreSQL) UpdateUserInUsersTable(user storemodels.User) error {
 	return tx.Commit()
 }
 
-func (s *PostgreSQL) GetUserInUsersTable(userID string) (*storemodels.User, error) {
-	query := `SELECT user_id, external_id, username, password_enabled, email, first_name, last_name, image_url, profile_image_url, two_factor_enabled, created_at, updated_at FROM users WHERE user_id = $1`
-	row := s.db.QueryRow(query, userID)
+func (s *PostgreSQL) GetUserInUsersTable(ctx context.Context, userID string) (*storemodels.User, error) {
+	query := `SELECT user_id, external_id, username, password_enabled, email, first_name, last_name, image_url, profile_image_url, two_factor_enabled, created_at, updated_at FROM users WHERE user_id = $1 AND deleted_at IS NULL`
+	row := s.db.QueryRowContext(ctx, query, userID)
 	var user storemodels.User
 	err := row.Scan(&user.UserID, &user.ExternalID, &user.Username, &user.PasswordEnabled, &user.Email, &user.FirstName, &user.LastName, &user.ImageURL, &user.ProfileImageURL, &user.TwoFactorEnabled, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
@@ -49,18 +70,58 @@ func (s *PostgreSQL) GetUserInUsersTable(userID string) (*storemodels.User, erro
 	return &user, nil
 }
 
-func (s *PostgreSQL) DeleteUserInUsersTable(userID string) error {
-	tx, err := s.db.Begin()
+func (s *PostgreSQL) DeleteUserInUsersTable(ctx context.Context, userID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
 	query := `DELETE FROM users WHERE user_id = $1`
-	_, err = tx.Exec(query, userID)
+	_, err = tx.ExecContext(ctx, query, userID)
 	if err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
+
+func (s *PostgreSQL) SoftDeleteUserInUsersTable(ctx context.Context, userID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET deleted = TRUE, deleted_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND deleted_at IS NULL`
+	_, err = tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgreSQL) GetUserIDsDeletedBefore(ctx context.Context, cutoff time.Time) ([]string, error) {
+	query := `SELECT user_id FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+	rows, err := s.db.QueryContext(ctx, query, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
@@ -3,19 +3,22 @@
 package postgresqlclient
 
 import (
+	"context"
 	"fmt"
 	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/config"
 	"testing"
 	"time"
 )
 
 func checkIfUserInUsersTable(userID string, retries int) error {
-	store, err := NewPostgreSQL()
+	ctx := context.Background()
+	store, err := NewPostgreSQL(config.NewServerConfig().PostgresqlURL)
 	if err != nil {
 		return fmt.Errorf("Failed to create test postgresqlclient: %v", err)
 	}
 	for i := 0; i < retries; i++ {
-		_, err := store.GetUserInUsersTable(userID)
+		_, err := store.GetUserInUsersTable(ctx, userID)
 		if err == nil {
 			return nil
 		}
@@ -25,7 +28,9 @@ func checkIfUserInUsersTable(userID string, retries int) error {
 }
 
 func TestCreateUserInUsersTable(t *testing.T) {
-	store, err := NewPostgreSQL()
+	ctx := context.Background()
+
+	store, err := NewPostgreSQL(config.NewServerConfig().PostgresqlURL)
 	if err != nil {
 		t.Errorf("Failed to create test postgresqlclient: %v", err)
 	}
@@ -45,7 +50,7 @@ func TestCreateUserInUsersTable(t *testing.T) {
 		UpdatedAt:        1654012591514,
 	}
 
-	err = store.CreateUserInUsersTable(user)
+	err = store.CreateUserInUsersTable(ctx, user)
 	if err != nil {
 		t.Errorf("Failed to create user: %v", err)
 	}
@@ -58,16 +63,17 @@ func TestCreateUserInUsersTable(t *testing.T) {
 
 	// Register cleanup function
 	t.Cleanup(func() {
-		store.DeleteUserInUsersTable(user.UserID)
+		store.DeleteUserInUsersTable(ctx, user.UserID)
 	})
 }
 func checkUserHasExpectedFirstNameAndLastNameInUsersTable(userID string, retries int, expectedFirstName string, expectedLastName string) error {
-	db, err := NewPostgreSQL()
+	ctx := context.Background()
+	db, err := NewPostgreSQL(config.NewServerConfig().PostgresqlURL)
 	if err != nil {
 		return fmt.Errorf("Failed to create test postgresqlclient: %v", err)
 	}
 	for i := 0; i < retries; i++ {
-		user, err := db.GetUserInUsersTable(userID)
+		user, err := db.GetUserInUsersTable(ctx, userID)
 		if err == nil && user.FirstName == expectedFirstName && user.LastName == expectedLastName {
 			return nil
 		}
@@ -77,7 +83,9 @@ func checkUserHasExpectedFirstNameAndLastNameInUsersTable(userID string, retries
 }
 
 func TestUpdateUserInUsersTable(t *testing.T) {
-	store, err := NewPostgreSQL()
+	ctx := context.Background()
+
+	store, err := NewPostgreSQL(config.NewServerConfig().PostgresqlURL)
 	if err != nil {
 		t.Errorf("Failed to create test postgresqlclient: %v", err)
 	}
@@ -98,7 +106,7 @@ func TestUpdateUserInUsersTable(t *testing.T) {
 		UpdatedAt:        1654012591514,
 	}
 
-	err = store.CreateUserInUsersTable(user)
+	err = store.CreateUserInUsersTable(ctx, user)
 	if err != nil {
 		t.Errorf("Failed to create user for update test: %v", err)
 	}
@@ -106,7 +114,7 @@ func TestUpdateUserInUsersTable(t *testing.T) {
 	// Update the user
 	user.FirstName = "UpdatedFirstName"
 	user.LastName = "UpdatedLastName"
-	err = store.UpdateUserInUsersTable(user)
+	err = store.UpdateUserInUsersTable(ctx, user)
 	if err != nil {
 		t.Errorf("Failed to update user: %v", err)
 	}
@@ -119,13 +127,15 @@ func TestUpdateUserInUsersTable(t *testing.T) {
 
 	// Register cleanup function
 	t.Cleanup(func() {
-		store.DeleteUserInUsersTable(user.UserID)
+		store.DeleteUserInUsersTable(ctx, user.UserID)
 	})
 
 }
 
 func TestGetUserInUsersTable(t *testing.T) {
-	store, err := NewPostgreSQL()
+	ctx := context.Background()
+
+	store, err := NewPostgreSQL(config.NewServerConfig().PostgresqlURL)
 	if err != nil {
 		t.Errorf("Failed to create test postgresqlclient: %v", err)
 	}
@@ -146,13 +156,13 @@ func TestGetUserInUsersTable(t *testing.T) {
 		UpdatedAt:        1654012591514,
 	}
 
-	err = store.CreateUserInUsersTable(user)
+	err = store.CreateUserInUsersTable(ctx, user)
 	if err != nil {
 		t.Errorf("Failed to create user for get test: %v", err)
 	}
 
 	// Fetch the user
-	fetchedUser, err := store.GetUserInUsersTable(user.UserID)
+	fetchedUser, err := store.GetUserInUsersTable(ctx, user.UserID)
 	if err != nil {
 		t.Errorf("Failed to fetch user: %v", err)
 	}
@@ -163,12 +173,14 @@ func TestGetUserInUsersTable(t *testing.T) {
 
 	// Cleanup
 	t.Cleanup(func() {
-		store.DeleteUserInUsersTable(user.UserID)
+		store.DeleteUserInUsersTable(ctx, user.UserID)
 	})
 }
 
 func TestDeleteUserInUsersTable(t *testing.T) {
-	store, err := NewPostgreSQL()
+	ctx := context.Background()
+
+	store, err := NewPostgreSQL(config.NewServerConfig().PostgresqlURL)
 	if err != nil {
 		t.Errorf("Failed to create test postgresqlclient: %v", err)
 	}
@@ -189,13 +201,13 @@ func TestDeleteUserInUsersTable(t *testing.T) {
 		UpdatedAt:        1654012591514,
 	}
 
-	err = store.CreateUserInUsersTable(user)
+	err = store.CreateUserInUsersTable(ctx, user)
 	if err != nil {
 		t.Errorf("Failed to create user for delete test: %v", err)
 	}
 
 	// Delete the user
-	err = store.DeleteUserInUsersTable(user.UserID)
+	err = store.DeleteUserInUsersTable(ctx, user.UserID)
 	if err != nil {
 		t.Errorf("Failed to delete user: %v", err)
 	}
@@ -203,7 +215,7 @@ func TestDeleteUserInUsersTable(t *testing.T) {
 	// Check if the user has been deleted
 	var deleted bool
 	for i := 0; i < 3; i++ {
-		user, err := store.GetUserInUsersTable(user.UserID)
+		user, err := store.GetUserInUsersTable(ctx, user.UserID)
 		if user == nil || err != nil {
 			// If the user is not found, it means the user has been deleted
 			deleted = true
@@ -216,6 +228,66 @@ func TestDeleteUserInUsersTable(t *testing.T) {
 	}
 
 	t.Cleanup(func() {
-		store.DeleteUserInUsersTable(user.UserID)
+		store.DeleteUserInUsersTable(ctx, user.UserID)
+	})
+}
+
+func TestSoftDeleteUserInUsersTable(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := NewPostgreSQL(config.NewServerConfig().PostgresqlURL)
+	if err != nil {
+		t.Errorf("Failed to create test postgresqlclient: %v", err)
+	}
+
+	user := storemodels.User{
+		UserID:           "TestSoftDeleteUserInUsersTableUserID",
+		ExternalID:       "TestSoftDeleteUserInUsersTableExternalID",
+		Username:         "TestSoftDeleteUserInUsersTableUsername",
+		PasswordEnabled:  true,
+		Email:            "TestSoftDeleteUserInUsersTable@example.com",
+		FirstName:        "TestSoftDeleteUserInUsersTableFirstName",
+		LastName:         "TestSoftDeleteUserInUsersTableLastName",
+		ImageURL:         "https://TestSoftDeleteUserInUsersTable.com/image.jpg",
+		ProfileImageURL:  "https://TestSoftDeleteUserInUsersTable.com/profile.jpg",
+		TwoFactorEnabled: false,
+		CreatedAt:        1654012591514,
+		UpdatedAt:        1654012591514,
+	}
+
+	err = store.CreateUserInUsersTable(ctx, user)
+	if err != nil {
+		t.Errorf("Failed to create user: %v", err)
+	}
+
+	err = store.SoftDeleteUserInUsersTable(ctx, user.UserID)
+	if err != nil {
+		t.Errorf("Failed to soft delete user: %v", err)
+	}
+
+	_, err = store.GetUserInUsersTable(ctx, user.UserID)
+	if err == nil {
+		t.Errorf("Soft deleted user should not be retrievable")
+	}
+
+	userIDs, err := store.GetUserIDsDeletedBefore(ctx, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Errorf("Failed to get expired users: %v", err)
+	}
+	found := false
+	for _, userID := range userIDs {
+		if userID == user.UserID {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected soft deleted user to be returned as expired")
+	}
+
+	t.Cleanup(func() {
+		err := store.DeleteUserInUsersTable(ctx, user.UserID)
+		if err != nil {
+			t.Errorf("Failed to delete test user: %v", err)
+		}
 	})
 }
@@ -0,0 +1,157 @@
+package postgresqlclient
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"lucidify-api/data/store/storemodels"
+	"time"
+)
+
+// InsertWebhookEvent stores a received webhook event. It returns false without
+// an error when an event with the same svix id has already been stored.
+func (s *PostgreSQL) InsertWebhookEvent(ctx context.Context, svixID, eventType string, payload []byte) (bool, error) {
+	query := `INSERT INTO webhook_events (svix_id, event_type, payload)
+	          VALUES ($1, $2, $3)
+	          ON CONFLICT (svix_id) DO NOTHING`
+	// lib/pq sends []byte as bytea, so the payload is passed as text for the JSONB column
+	result, err := s.db.ExecContext(ctx, query, svixID, eventType, string(payload))
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// ClaimDueWebhookEvents returns up to limit pending events that are due and
+// pushes their next attempt back by lease, so that concurrent workers do not
+// pick up the same events. If a worker dies mid-processing the events become
+// due again once the lease expires.
+func (s *PostgreSQL) ClaimDueWebhookEvents(ctx context.Context, limit int, lease time.Duration) ([]storemodels.WebhookEvent, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE webhook_events
+	          SET attempts = attempts + 1,
+	              next_attempt_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second'
+	          WHERE svix_id IN (
+	              SELECT svix_id FROM webhook_events
+	              WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+	              ORDER BY received_at
+	              LIMIT $1
+	              FOR UPDATE SKIP LOCKED
+	          )
+	          RETURNING svix_id, event_type, payload, status, attempts, last_error, received_at, next_attempt_at, processed_at`
+	rows, err := tx.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := scanWebhookEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (s *PostgreSQL) MarkWebhookEventProcessed(ctx context.Context, svixID string) error {
+	query := `UPDATE webhook_events
+	          SET status = 'processed', last_error = NULL, processed_at = CURRENT_TIMESTAMP
+	          WHERE svix_id = $1`
+	_, err := s.db.ExecContext(ctx, query, svixID)
+	return err
+}
+
+// MarkWebhookEventFailed records a failed attempt. The event is retried at
+// nextAttemptAt, or moved to the dead letter state when dead is true.
+func (s *PostgreSQL) MarkWebhookEventFailed(ctx context.Context, svixID string, lastError string, nextAttemptAt time.Time, dead bool) error {
+	status := storemodels.WebhookEventStatusPending
+	if dead {
+		status = storemodels.WebhookEventStatusDead
+	}
+
+	query := `UPDATE webhook_events
+	          SET status = $2, last_error = $3, next_attempt_at = $4
+	          WHERE svix_id = $1`
+	_, err := s.db.ExecContext(ctx, query, svixID, status, lastError, nextAttemptAt)
+	return err
+}
+
+// ResetWebhookEvent puts an event back in the queue with a fresh retry budget,
+// regardless of its current status.
+func (s *PostgreSQL) ResetWebhookEvent(ctx context.Context, svixID string) error {
+	query := `UPDATE webhook_events
+	          SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, processed_at = NULL
+	          WHERE svix_id = $1`
+	result, err := s.db.ExecContext(ctx, query, svixID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no webhook event found with svix id: %s", svixID)
+	}
+	return nil
+}
+
+func (s *PostgreSQL) GetWebhookEvent(ctx context.Context, svixID string) (*storemodels.WebhookEvent, error) {
+	event := &storemodels.WebhookEvent{}
+	query := `SELECT svix_id, event_type, payload, status, attempts, last_error, received_at, next_attempt_at, processed_at
+	          FROM webhook_events WHERE svix_id = $1`
+	err := s.db.QueryRowContext(ctx, query, svixID).Scan(
+		&event.SvixID, &event.EventType, &event.Payload, &event.Status, &event.Attempts,
+		&event.LastError, &event.ReceivedAt, &event.NextAttemptAt, &event.ProcessedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no webhook event found with svix id: %s", svixID)
+	} else if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (s *PostgreSQL) GetDeadWebhookEvents(ctx context.Context) ([]storemodels.WebhookEvent, error) {
+	query := `SELECT svix_id, event_type, payload, status, attempts, last_error, received_at, next_attempt_at, processed_at
+	          FROM webhook_events WHERE status = 'dead'
+	          ORDER BY received_at`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return scanWebhookEvents(rows)
+}
+
+func scanWebhookEvents(rows *sql.Rows) ([]storemodels.WebhookEvent, error) {
+	defer rows.Close()
+
+	var events []storemodels.WebhookEvent
+	for rows.Next() {
+		var event storemodels.WebhookEvent
+		err := rows.Scan(
+			&event.SvixID, &event.EventType, &event.Payload, &event.Status, &event.Attempts,
+			&event.LastError, &event.ReceivedAt, &event.NextAttemptAt, &event.ProcessedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,103 @@
+package postgresqlclient
+
+import (
+	"context"
+	"lucidify-api/data/store/storemodels"
+)
+
+// UpsertWorkspace creates the workspace or updates it, unless the stored row
+// is newer than the given workspace.
+func (s *PostgreSQL) UpsertWorkspace(ctx context.Context, workspace storemodels.Workspace) error {
+	query := `INSERT INTO workspaces (workspace_id, name, slug, created_by, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6)
+	          ON CONFLICT (workspace_id) DO UPDATE SET name = EXCLUDED.name, slug = EXCLUDED.slug, updated_at = EXCLUDED.updated_at
+	          WHERE workspaces.updated_at IS NULL OR workspaces.updated_at <= EXCLUDED.updated_at`
+	_, err := s.db.ExecContext(ctx, query, workspace.WorkspaceID, workspace.Name, workspace.Slug, workspace.CreatedBy, workspace.CreatedAt, workspace.UpdatedAt)
+	return err
+}
+
+func (s *PostgreSQL) GetWorkspace(ctx context.Context, workspaceID string) (*storemodels.Workspace, error) {
+	var workspace storemodels.Workspace
+	query := `SELECT workspace_id, name, COALESCE(slug, ''), COALESCE(created_by, ''), COALESCE(created_at, 0), COALESCE(updated_at, 0)
+	          FROM workspaces WHERE workspace_id = $1`
+	err := s.db.QueryRowContext(ctx, query, workspaceID).Scan(
+		&workspace.WorkspaceID, &workspace.Name, &workspace.Slug, &workspace.CreatedBy, &workspace.CreatedAt, &workspace.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &workspace, nil
+}
+
+// DeleteWorkspace deletes the workspace together with its memberships and
+// documents. The chunks of the documents must be removed from Weaviate first.
+func (s *PostgreSQL) DeleteWorkspace(ctx context.Context, workspaceID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM workspaces WHERE workspace_id = $1`
+	_, err = tx.ExecContext(ctx, query, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgreSQL) UpsertWorkspaceMembership(ctx context.Context, membership storemodels.WorkspaceMembership) error {
+	query := `INSERT INTO workspace_memberships (workspace_id, user_id, role, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5)
+	          ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = EXCLUDED.role, updated_at = EXCLUDED.updated_at
+	          WHERE workspace_memberships.updated_at IS NULL OR workspace_memberships.updated_at <= EXCLUDED.updated_at`
+	_, err := s.db.ExecContext(ctx, query, membership.WorkspaceID, membership.UserID, membership.Role, membership.CreatedAt, membership.UpdatedAt)
+	return err
+}
+
+func (s *PostgreSQL) DeleteWorkspaceMembership(ctx context.Context, workspaceID, userID string) error {
+	query := `DELETE FROM workspace_memberships WHERE workspace_id = $1 AND user_id = $2`
+	_, err := s.db.ExecContext(ctx, query, workspaceID, userID)
+	return err
+}
+
+// GetWorkspaceMembership returns sql.ErrNoRows if the user is not a member of
+// the workspace.
+func (s *PostgreSQL) GetWorkspaceMembership(ctx context.Context, workspaceID, userID string) (*storemodels.WorkspaceMembership, error) {
+	var membership storemodels.WorkspaceMembership
+	query := `SELECT workspace_id, user_id, role, COALESCE(created_at, 0), COALESCE(updated_at, 0)
+	          FROM workspace_memberships WHERE workspace_id = $1 AND user_id = $2`
+	err := s.db.QueryRowContext(ctx, query, workspaceID, userID).Scan(
+		&membership.WorkspaceID, &membership.UserID, &membership.Role, &membership.CreatedAt, &membership.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+func (s *PostgreSQL) GetWorkspaceMembershipsOfUser(ctx context.Context, userID string) ([]storemodels.WorkspaceMembership, error) {
+	query := `SELECT workspace_id, user_id, role, COALESCE(created_at, 0), COALESCE(updated_at, 0)
+	          FROM workspace_memberships WHERE user_id = $1
+	          ORDER BY workspace_id`
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memberships []storemodels.WorkspaceMembership
+	for rows.Next() {
+		var membership storemodels.WorkspaceMembership
+		err := rows.Scan(&membership.WorkspaceID, &membership.UserID, &membership.Role, &membership.CreatedAt, &membership.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, membership)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return memberships, nil
+}
@@ -0,0 +1,29 @@
+package storemodels
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes that can be granted to an API key.
+const (
+	ScopeDocumentsRead  = "documents:read"
+	ScopeDocumentsWrite = "documents:write"
+	ScopeChat           = "chat"
+)
+
+var ValidScopes = []string{ScopeDocumentsRead, ScopeDocumentsWrite, ScopeChat}
+
+type APIKey struct {
+	APIKeyID   uuid.UUID  `db:"api_key_id"`
+	UserID     string     `db:"user_id"`
+	Name       string     `db:"name"`
+	KeyPrefix  string     `db:"key_prefix"`
+	KeyHash    string     `db:"key_hash" json:"-"`
+	Scopes     []string   `db:"scopes"`
+	CreatedAt  time.Time  `db:"created_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+	ExpiresAt  *time.Time `db:"expires_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+}
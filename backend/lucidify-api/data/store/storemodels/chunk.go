@@ -5,9 +5,12 @@ import (
 )
 
 type Chunk struct {
-	ChunkID      uuid.UUID `db:"chunk_id"`
-	UserID       string    `db:"user_id"`
-	DocumentID   uuid.UUID `db:"document_id"`
-	ChunkContent string    `db:"chunk_content"`
-	ChunkIndex   int       `db:"chunk_index"`
+	ChunkID      uuid.UUID        `db:"chunk_id"`
+	UserID       string           `db:"user_id"`
+	WorkspaceID  string           // Only stored in Weaviate, empty for private documents
+	DocumentID   uuid.UUID        `db:"document_id"`
+	ChunkContent string           `db:"chunk_content"`
+	ChunkIndex   int              `db:"chunk_index"`
+	ContentHash  string           `db:"content_hash"`
+	Metadata     DocumentMetadata // Only stored in Weaviate, copied from the document
 }
@@ -7,6 +7,7 @@ import (
 type ChunkFromVectorSearch struct {
 	ChunkID      uuid.UUID `db:"chunk_id"`
 	UserID       string    `db:"user_id"`
+	WorkspaceID  string
 	DocumentID   uuid.UUID `db:"document_id"`
 	ChunkContent string    `db:"chunk_content"`
 	ChunkIndex   int       `db:"chunk_index"`
@@ -0,0 +1,18 @@
+package storemodels
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Collection is a named set of documents of a user, used to tag documents and
+// to restrict chat and search to a subset of them.
+type Collection struct {
+	CollectionID  uuid.UUID `db:"collection_id"`
+	UserID        string    `db:"user_id"`
+	Name          string    `db:"name"`
+	DocumentCount int       `db:"document_count"`
+	CreatedAt     time.Time `db:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at"`
+}
@@ -0,0 +1,13 @@
+package storemodels
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// ContentHash returns the hex encoded SHA-256 of the content of a document or
+// chunk. Identical contents have the same hash.
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
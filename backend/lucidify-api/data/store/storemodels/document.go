@@ -7,10 +7,15 @@ import (
 )
 
 type Document struct {
-	DocumentUUID uuid.UUID `db:"id"`
-	UserID       string    `db:"user_id"`
-	DocumentName string    `db:"document_name"`
-	Content      string    `db:"content"`
-	CreatedAt    time.Time `db:"created_at"`
-	UpdatedAt    time.Time `db:"updated_at"`
+	DocumentUUID uuid.UUID        `db:"id"`
+	UserID       string           `db:"user_id"`
+	WorkspaceID  *string          `db:"workspace_id"`
+	DocumentName string           `db:"document_name"`
+	Content      string           `db:"content"`
+	ContentHash  string           `db:"content_hash"`
+	Metadata     DocumentMetadata `db:"metadata"`
+	Revision     int64            `db:"revision"`
+	CreatedAt    time.Time        `db:"created_at"`
+	UpdatedAt    time.Time        `db:"updated_at"`
+	DeletedAt    *time.Time       `db:"deleted_at"`
 }
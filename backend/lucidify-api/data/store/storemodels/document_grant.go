@@ -0,0 +1,27 @@
+package storemodels
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Permissions of a document grant. Write lets the grantee change the document
+// but, unlike its owner, not delete it permanently or share it further.
+const (
+	GrantPermissionRead  = "read"
+	GrantPermissionWrite = "write"
+)
+
+// DocumentGrant gives access to a document either to GranteeUserID or, for
+// sharing links, to anyone with the link token.
+type DocumentGrant struct {
+	GrantID       uuid.UUID  `db:"grant_id"`
+	DocumentID    uuid.UUID  `db:"document_id"`
+	GranteeUserID *string    `db:"grantee_user_id"`
+	LinkTokenHash *string    `db:"link_token_hash" json:"-"`
+	Permission    string     `db:"permission"`
+	CreatedBy     string     `db:"created_by"`
+	CreatedAt     time.Time  `db:"created_at"`
+	ExpiresAt     *time.Time `db:"expires_at"`
+}
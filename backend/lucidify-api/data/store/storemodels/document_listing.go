@@ -0,0 +1,63 @@
+package storemodels
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sort orders of document listings.
+const (
+	DocumentSortName    = "name"
+	DocumentSortCreated = "created"
+	DocumentSortUpdated = "updated"
+)
+
+// DocumentListOptions select, sort and paginate the documents of a listing.
+// Listings cover the private documents of the user, or the documents of the
+// workspace when WorkspaceID is set.
+type DocumentListOptions struct {
+	WorkspaceID     *string
+	SortBy          string
+	Descending      bool
+	NamePrefix      string
+	CollectionID    *uuid.UUID
+	CreatedAfter    *time.Time
+	CreatedBefore   *time.Time
+	MetadataFilters []MetadataFilter
+	IncludeContent  bool
+	Limit           int
+	// After is the position of the last document of the previous page
+	After *DocumentCursor
+}
+
+// DocumentCursor is the position of a document in a listing: the value of the
+// sort key, either Name or Time, and the document id to break ties.
+type DocumentCursor struct {
+	Name       string    `json:"name,omitempty"`
+	Time       time.Time `json:"time,omitempty"`
+	DocumentID uuid.UUID `json:"id"`
+}
+
+// DocumentListItem is a document as listed, with its size in bytes and number
+// of chunks. Content is left empty unless requested. DeletedAt is only set when
+// a single document in the trash is described.
+type DocumentListItem struct {
+	DocumentUUID uuid.UUID
+	UserID       string
+	WorkspaceID  *string
+	DocumentName string
+	Content      string `json:",omitempty"`
+	Metadata     DocumentMetadata
+	Revision     int64
+	Size         int
+	ChunkCount   int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    *time.Time `json:",omitempty"`
+}
+
+type DocumentPage struct {
+	Documents  []DocumentListItem `json:"documents"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
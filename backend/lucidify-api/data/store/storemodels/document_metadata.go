@@ -0,0 +1,143 @@
+package storemodels
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// DocumentMetadata holds user-defined fields of a document. Values are
+// strings, numbers, booleans, RFC 3339 dates given as strings, or lists of
+// strings.
+type DocumentMetadata map[string]interface{}
+
+// Kinds of metadata values. They double as the Weaviate data types the values
+// are stored with.
+const (
+	MetadataKindText      = "text"
+	MetadataKindTextArray = "text[]"
+	MetadataKindNumber    = "number"
+	MetadataKindBoolean   = "boolean"
+	MetadataKindDate      = "date"
+)
+
+// MaxMetadataFields bounds the number of fields of a document, as each field
+// becomes a property of the Weaviate class.
+const MaxMetadataFields = 32
+
+var metadataFieldPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,63}$`)
+
+// MetadataValueKind returns the kind of a metadata value, or an empty string
+// if the value is not supported.
+func MetadataValueKind(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		if _, err := time.Parse(time.RFC3339, v); err == nil {
+			return MetadataKindDate
+		}
+		return MetadataKindText
+	case float64, int, int64:
+		return MetadataKindNumber
+	case bool:
+		return MetadataKindBoolean
+	case []string:
+		return MetadataKindTextArray
+	case []interface{}:
+		for _, item := range v {
+			if _, ok := item.(string); !ok {
+				return ""
+			}
+		}
+		return MetadataKindTextArray
+	}
+	return ""
+}
+
+func (m DocumentMetadata) Validate() error {
+	if len(m) > MaxMetadataFields {
+		return fmt.Errorf("metadata has more than %d fields", MaxMetadataFields)
+	}
+	for field, value := range m {
+		if !metadataFieldPattern.MatchString(field) {
+			return fmt.Errorf("invalid metadata field name %q", field)
+		}
+		if MetadataValueKind(value) == "" {
+			return fmt.Errorf("unsupported value for metadata field %q", field)
+		}
+	}
+	return nil
+}
+
+// Value stores the metadata as JSONB. It is passed as a string, as lib/pq
+// sends byte slices as bytea.
+func (m DocumentMetadata) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (m *DocumentMetadata) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*m = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into DocumentMetadata", src)
+	}
+	return json.Unmarshal(data, m)
+}
+
+// Operators of a metadata filter.
+const (
+	MetadataOpEqual              = "eq"
+	MetadataOpGreaterThan        = "gt"
+	MetadataOpGreaterThanOrEqual = "gte"
+	MetadataOpLessThan           = "lt"
+	MetadataOpLessThanOrEqual    = "lte"
+	MetadataOpContains           = "contains"
+)
+
+// MetadataFilter matches documents on a metadata field. Equality applies to
+// any value but lists, ranges to numbers and dates, and contains to a
+// substring of a text field or an item of a list field.
+type MetadataFilter struct {
+	Field    string      `json:"field"`
+	Operator string      `json:"op"`
+	Value    interface{} `json:"value"`
+}
+
+func (f MetadataFilter) Validate() error {
+	if !metadataFieldPattern.MatchString(f.Field) {
+		return fmt.Errorf("invalid metadata field name %q", f.Field)
+	}
+	kind := MetadataValueKind(f.Value)
+	switch f.Operator {
+	case MetadataOpEqual:
+		if kind == "" || kind == MetadataKindTextArray {
+			return fmt.Errorf("%s on %q needs a string, number or boolean", f.Operator, f.Field)
+		}
+	case MetadataOpGreaterThan, MetadataOpGreaterThanOrEqual, MetadataOpLessThan, MetadataOpLessThanOrEqual:
+		if kind != MetadataKindNumber && kind != MetadataKindDate {
+			return fmt.Errorf("%s on %q needs a number or a date", f.Operator, f.Field)
+		}
+	case MetadataOpContains:
+		if kind != MetadataKindText && kind != MetadataKindDate {
+			return fmt.Errorf("%s on %q needs a string", f.Operator, f.Field)
+		}
+	default:
+		return fmt.Errorf("unknown metadata filter operator %q", f.Operator)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package storemodels
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DocumentVersion is a previous content of a document, as it was when it got
+// replaced. CreatedAt is the time of the last update of the document before
+// ReplacedAt. Content is left empty in listings of versions.
+type DocumentVersion struct {
+	DocumentID   uuid.UUID        `db:"document_id"`
+	Version      int              `db:"version"`
+	DocumentName string           `db:"document_name"`
+	Content      string           `db:"content" json:",omitempty"`
+	Metadata     DocumentMetadata `db:"metadata"`
+	Size         int
+	CreatedAt    time.Time `db:"created_at"`
+	ReplacedBy   *string   `db:"replaced_by"`
+	ReplacedAt   time.Time `db:"replaced_at"`
+}
@@ -0,0 +1,9 @@
+package storemodels
+
+// QueueDepths are the numbers of rows waiting for a background worker.
+type QueueDepths struct {
+	PendingWebhookEvents int `json:"pending_webhook_events"`
+	DeadWebhookEvents    int `json:"dead_webhook_events"`
+	TrashedDocuments     int `json:"trashed_documents"`
+	DeletedUsers         int `json:"deleted_users"`
+}
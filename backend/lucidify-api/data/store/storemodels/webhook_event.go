@@ -0,0 +1,24 @@
+package storemodels
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	WebhookEventStatusPending   = "pending"
+	WebhookEventStatusProcessed = "processed"
+	WebhookEventStatusDead      = "dead"
+)
+
+type WebhookEvent struct {
+	SvixID        string          `db:"svix_id"`
+	EventType     string          `db:"event_type"`
+	Payload       json.RawMessage `db:"payload"`
+	Status        string          `db:"status"`
+	Attempts      int             `db:"attempts"`
+	LastError     *string         `db:"last_error"`
+	ReceivedAt    time.Time       `db:"received_at"`
+	NextAttemptAt time.Time       `db:"next_attempt_at"`
+	ProcessedAt   *time.Time      `db:"processed_at"`
+}
@@ -0,0 +1,27 @@
+package storemodels
+
+// Roles of a workspace member, from most to least privileged. Owners can
+// permanently delete documents, editors can change them and viewers can only
+// read them.
+const (
+	WorkspaceRoleOwner  = "owner"
+	WorkspaceRoleEditor = "editor"
+	WorkspaceRoleViewer = "viewer"
+)
+
+type Workspace struct {
+	WorkspaceID string `db:"workspace_id"`
+	Name        string `db:"name"`
+	Slug        string `db:"slug"`
+	CreatedBy   string `db:"created_by"`
+	CreatedAt   int64  `db:"created_at"`
+	UpdatedAt   int64  `db:"updated_at"`
+}
+
+type WorkspaceMembership struct {
+	WorkspaceID string `db:"workspace_id"`
+	UserID      string `db:"user_id"`
+	Role        string `db:"role"`
+	CreatedAt   int64  `db:"created_at"`
+	UpdatedAt   int64  `db:"updated_at"`
+}
@@ -0,0 +1,162 @@
+package weaviateclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"lucidify-api/data/store/storemodels"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/google/uuid"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// batchSize bounds the number of objects created in one batch request.
+const batchSize = 100
+
+// maxBatchAttempts bounds the attempts to create the objects of a batch. Each
+// retry only sends the objects that failed.
+const maxBatchAttempts = 3
+
+// BatchError lists the chunks that could not be stored after every attempt,
+// with the error Weaviate reported for each of them.
+type BatchError struct {
+	Failures map[string]string // Chunk id to error message
+}
+
+func (e *BatchError) Error() string {
+	messages := make([]string, 0, len(e.Failures))
+	for chunkID, message := range e.Failures {
+		messages = append(messages, chunkID+": "+message)
+	}
+	return fmt.Sprintf("failed to upload %d chunks: %s", len(e.Failures), strings.Join(messages, "; "))
+}
+
+// uploadChunkObjects creates the chunks in the class with the batch API, in
+// their tenants if the class is multi-tenant. Creating an object
+// that already exists replaces it, so that failed objects can be retried.
+// Chunks of the deletedDocuments are flagged as trashed.
+func (w *WeaviateClientImpl) uploadChunkObjects(ctx context.Context, className string, chunks []storemodels.Chunk, vectors map[string][]float32, deletedDocuments map[uuid.UUID]bool) error {
+	if err := w.ensureTenants(ctx, className, chunkOwners(className, chunks)); err != nil {
+		return err
+	}
+	multiTenant, err := w.isMultiTenant(ctx, className)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(chunks); start += batchSize {
+		end := start + batchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+
+		objects := make([]*models.Object, end-start)
+		for i, chunk := range chunks[start:end] {
+			objects[i] = &models.Object{
+				Class:      className,
+				ID:         strfmt.UUID(chunk.ChunkID.String()),
+				Properties: chunkProperties(chunk, deletedDocuments[chunk.DocumentID]),
+				Vector:     vectors[chunk.ContentHash],
+			}
+			if multiTenant {
+				objects[i].Tenant = TenantName(chunkOwner(className, chunk))
+			}
+		}
+		if err := w.uploadObjectsWithRetry(ctx, objects); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *WeaviateClientImpl) uploadObjectsWithRetry(ctx context.Context, objects []*models.Object) error {
+	failures := make(map[string]string)
+	for attempt := 1; attempt <= maxBatchAttempts; attempt++ {
+		if attempt > 1 {
+			log.Printf("Retrying %d chunks that failed in a Weaviate batch", len(objects))
+			time.Sleep(time.Duration(attempt-1) * 500 * time.Millisecond)
+		}
+
+		responses, err := w.client.Batch().ObjectsBatcher().
+			WithObjects(objects...).
+			Do(ctx)
+		if err != nil {
+			// The whole request failed, retry every object
+			for _, object := range objects {
+				failures[object.ID.String()] = err.Error()
+			}
+			continue
+		}
+
+		failures = make(map[string]string)
+		var failed []*models.Object
+		for i, response := range responses {
+			message := batchObjectError(response)
+			if message == "" {
+				continue
+			}
+			failures[response.ID.String()] = message
+			if i < len(objects) {
+				failed = append(failed, objects[i])
+			}
+		}
+		if len(failures) == 0 {
+			return nil
+		}
+		objects = failed
+	}
+	return &BatchError{Failures: failures}
+}
+
+func batchObjectError(response models.ObjectsGetResponse) string {
+	if response.Result == nil || response.Result.Errors == nil {
+		return ""
+	}
+	var messages []string
+	for _, item := range response.Result.Errors.Error {
+		if item != nil {
+			messages = append(messages, item.Message)
+		}
+	}
+	return strings.Join(messages, ", ")
+}
+
+// deleteChunksWhere deletes every chunk of the tenant matching the filter.
+// Weaviate deletes at most QUERY_MAXIMUM_RESULTS objects per request, so
+// requests are repeated while the limit is reached.
+func (w *WeaviateClientImpl) deleteChunksWhere(ctx context.Context, className string, tenant string, where *filters.WhereBuilder) error {
+	for {
+		response, err := w.client.Batch().ObjectsBatchDeleter().
+			WithClassName(className).
+			WithTenant(tenant).
+			WithWhere(where).
+			WithOutput("minimal").
+			Do(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete chunks: %w", err)
+		}
+		if response == nil || response.Results == nil {
+			return errors.New("failed to delete chunks: empty response")
+		}
+
+		results := response.Results
+		if results.Failed > 0 {
+			message := ""
+			for _, object := range results.Objects {
+				if object != nil && object.Errors != nil && len(object.Errors.Error) > 0 {
+					message = object.Errors.Error[0].Message
+					break
+				}
+			}
+			return fmt.Errorf("failed to delete %d of %d chunks: %s", results.Failed, results.Matches, message)
+		}
+		if results.Successful == 0 || results.Matches < results.Limit {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package weaviateclient
+
+import (
+	"context"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/metrics"
+	"lucidify-api/server/tracing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate"
+)
+
+// InstrumentedWeaviateClient traces each call to the WeaviateClient it wraps
+// and records its latency and errors, along with the hits and the distances of
+// the searches.
+type InstrumentedWeaviateClient struct {
+	next WeaviateClient
+}
+
+func NewInstrumentedWeaviateClient(next WeaviateClient) WeaviateClient {
+	return &InstrumentedWeaviateClient{next: next}
+}
+
+// observe times and traces the operation until the returned function is
+// called with its error.
+func observe(ctx context.Context, operation string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "weaviate."+operation)
+	return ctx, func(err error) {
+		metrics.WeaviateRequestDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.WeaviateRequestErrors.WithLabelValues(operation).Inc()
+		}
+		tracing.End(span, err)
+	}
+}
+
+func (i *InstrumentedWeaviateClient) GetWeaviateClient() *weaviate.Client {
+	return i.next.GetWeaviateClient()
+}
+
+func (i *InstrumentedWeaviateClient) UploadChunk(ctx context.Context, chunk storemodels.Chunk) (err error) {
+	ctx, done := observe(ctx, "UploadChunk")
+	defer func() { done(err) }()
+	return i.next.UploadChunk(ctx, chunk)
+}
+
+func (i *InstrumentedWeaviateClient) UploadChunks(ctx context.Context, chunks []storemodels.Chunk) (err error) {
+	ctx, done := observe(ctx, "UploadChunks")
+	defer func() { done(err) }()
+	return i.next.UploadChunks(ctx, chunks)
+}
+
+func (i *InstrumentedWeaviateClient) DeleteChunk(ctx context.Context, chunkID uuid.UUID) (err error) {
+	ctx, done := observe(ctx, "DeleteChunk")
+	defer func() { done(err) }()
+	return i.next.DeleteChunk(ctx, chunkID)
+}
+
+func (i *InstrumentedWeaviateClient) DeleteChunks(ctx context.Context, chunks []storemodels.Chunk) (err error) {
+	ctx, done := observe(ctx, "DeleteChunks")
+	defer func() { done(err) }()
+	return i.next.DeleteChunks(ctx, chunks)
+}
+
+func (i *InstrumentedWeaviateClient) DeleteChunksOfDocuments(ctx context.Context, userID, workspaceID string, documentIDs []uuid.UUID) (err error) {
+	ctx, done := observe(ctx, "DeleteChunksOfDocuments")
+	defer func() { done(err) }()
+	return i.next.DeleteChunksOfDocuments(ctx, userID, workspaceID, documentIDs)
+}
+
+func (i *InstrumentedWeaviateClient) DeleteChunksOfUser(ctx context.Context, userID string) (err error) {
+	ctx, done := observe(ctx, "DeleteChunksOfUser")
+	defer func() { done(err) }()
+	return i.next.DeleteChunksOfUser(ctx, userID)
+}
+
+func (i *InstrumentedWeaviateClient) CreateTenant(ctx context.Context, userID string) (err error) {
+	ctx, done := observe(ctx, "CreateTenant")
+	defer func() { done(err) }()
+	return i.next.CreateTenant(ctx, userID)
+}
+
+func (i *InstrumentedWeaviateClient) DeleteTenant(ctx context.Context, userID string) (err error) {
+	ctx, done := observe(ctx, "DeleteTenant")
+	defer func() { done(err) }()
+	return i.next.DeleteTenant(ctx, userID)
+}
+
+func (i *InstrumentedWeaviateClient) SetChunksDeleted(ctx context.Context, chunks []storemodels.Chunk, deleted bool) (err error) {
+	ctx, done := observe(ctx, "SetChunksDeleted")
+	defer func() { done(err) }()
+	return i.next.SetChunksDeleted(ctx, chunks, deleted)
+}
+
+func (i *InstrumentedWeaviateClient) ReplaceChunksMetadata(ctx context.Context, chunks []storemodels.Chunk, deleted bool) (err error) {
+	ctx, done := observe(ctx, "ReplaceChunksMetadata")
+	defer func() { done(err) }()
+	return i.next.ReplaceChunksMetadata(ctx, chunks, deleted)
+}
+
+func (i *InstrumentedWeaviateClient) GetChunks(ctx context.Context, chunksFromPostgresql []storemodels.Chunk) (_ []storemodels.Chunk, err error) {
+	ctx, done := observe(ctx, "GetChunks")
+	defer func() { done(err) }()
+	return i.next.GetChunks(ctx, chunksFromPostgresql)
+}
+
+func (i *InstrumentedWeaviateClient) SearchDocumentsByText(ctx context.Context, limit int, scope SearchScope, concepts []string) (_ []storemodels.ChunkFromVectorSearch, err error) {
+	ctx, done := observe(ctx, "SearchDocumentsByText")
+	defer func() { done(err) }()
+	chunks, err := i.next.SearchDocumentsByText(ctx, limit, scope, concepts)
+	if err != nil {
+		return chunks, err
+	}
+
+	metrics.RetrievalHits.Observe(float64(len(chunks)))
+	for _, chunk := range chunks {
+		metrics.RetrievalDistance.Observe(chunk.Distance)
+	}
+	return chunks, nil
+}
+
+func (i *InstrumentedWeaviateClient) SchemaState(ctx context.Context) (_ SchemaState, err error) {
+	ctx, done := observe(ctx, "SchemaState")
+	defer func() { done(err) }()
+	return i.next.SchemaState(ctx)
+}
+
+func (i *InstrumentedWeaviateClient) BeginSchemaMigration(ctx context.Context, version int) (_ SchemaState, err error) {
+	ctx, done := observe(ctx, "BeginSchemaMigration")
+	defer func() { done(err) }()
+	return i.next.BeginSchemaMigration(ctx, version)
+}
+
+func (i *InstrumentedWeaviateClient) BackfillSchemaVersion(ctx context.Context, version int, chunks []storemodels.Chunk, deletedDocuments map[uuid.UUID]bool) (err error) {
+	ctx, done := observe(ctx, "BackfillSchemaVersion")
+	defer func() { done(err) }()
+	return i.next.BackfillSchemaVersion(ctx, version, chunks, deletedDocuments)
+}
+
+func (i *InstrumentedWeaviateClient) SchemaVersionTenants(ctx context.Context, version int) (_ []string, err error) {
+	ctx, done := observe(ctx, "SchemaVersionTenants")
+	defer func() { done(err) }()
+	return i.next.SchemaVersionTenants(ctx, version)
+}
+
+func (i *InstrumentedWeaviateClient) SchemaVersionChunkIDs(ctx context.Context, version int, tenant string, after string, limit int) (_ []string, err error) {
+	ctx, done := observe(ctx, "SchemaVersionChunkIDs")
+	defer func() { done(err) }()
+	return i.next.SchemaVersionChunkIDs(ctx, version, tenant, after, limit)
+}
+
+func (i *InstrumentedWeaviateClient) DeleteSchemaVersionChunks(ctx context.Context, version int, tenant string, chunkIDs []string) (err error) {
+	ctx, done := observe(ctx, "DeleteSchemaVersionChunks")
+	defer func() { done(err) }()
+	return i.next.DeleteSchemaVersionChunks(ctx, version, tenant, chunkIDs)
+}
+
+func (i *InstrumentedWeaviateClient) ActivateSchemaVersion(ctx context.Context, version int) (err error) {
+	ctx, done := observe(ctx, "ActivateSchemaVersion")
+	defer func() { done(err) }()
+	return i.next.ActivateSchemaVersion(ctx, version)
+}
+
+func (i *InstrumentedWeaviateClient) AbortSchemaMigration(ctx context.Context) (err error) {
+	ctx, done := observe(ctx, "AbortSchemaMigration")
+	defer func() { done(err) }()
+	return i.next.AbortSchemaMigration(ctx)
+}
+
+func (i *InstrumentedWeaviateClient) DropSchemaVersion(ctx context.Context, version int) (_ bool, err error) {
+	ctx, done := observe(ctx, "DropSchemaVersion")
+	defer func() { done(err) }()
+	return i.next.DropSchemaVersion(ctx, version)
+}
+
+func (i *InstrumentedWeaviateClient) Ready(ctx context.Context) (err error) {
+	ctx, done := observe(ctx, "Ready")
+	defer func() { done(err) }()
+	return i.next.Ready(ctx)
+}
+
+func (i *InstrumentedWeaviateClient) Close() {
+	i.next.Close()
+}
@@ -0,0 +1,164 @@
+package weaviateclient
+
+import (
+	"context"
+	"fmt"
+	"lucidify-api/data/store/storemodels"
+	"time"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// metadataPropertyPrefix namespaces the properties holding document metadata,
+// so that user-defined fields cannot clash with the properties of the class.
+const metadataPropertyPrefix = "meta_"
+
+func metadataPropertyName(field string) string {
+	return metadataPropertyPrefix + field
+}
+
+// metadataDataTypes returns the data type of each property of the class.
+func metadataDataTypes(ctx context.Context, client *weaviate.Client, className string) (map[string]string, error) {
+	class, err := client.Schema().ClassGetter().WithClassName(className).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dataTypes := make(map[string]string, len(class.Properties))
+	for _, property := range class.Properties {
+		if len(property.DataType) > 0 {
+			dataTypes[property.Name] = property.DataType[0]
+		}
+	}
+	return dataTypes, nil
+}
+
+// ensureMetadataProperties adds a property for each metadata field the class
+// does not have yet. A field keeps the data type of its first value across all
+// documents, so values of another kind are rejected.
+func ensureMetadataProperties(ctx context.Context, client *weaviate.Client, className string, metadata storemodels.DocumentMetadata) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+	dataTypes, err := metadataDataTypes(ctx, client, className)
+	if err != nil {
+		return err
+	}
+
+	for field, value := range metadata {
+		kind := storemodels.MetadataValueKind(value)
+		name := metadataPropertyName(field)
+		if dataType, ok := dataTypes[name]; ok {
+			if dataType != kind {
+				return fmt.Errorf("metadata field %q must be of type %s, not %s", field, dataType, kind)
+			}
+			continue
+		}
+
+		property := &models.Property{
+			DataType:    []string{kind},
+			Description: fmt.Sprintf("Metadata field %s of the document", field),
+			Name:        name,
+			// Embeddings only represent the content of the chunk
+			ModuleConfig: map[string]interface{}{
+				"text2vec-openai": map[string]interface{}{"skip": true},
+			},
+		}
+		if kind == storemodels.MetadataKindText || kind == storemodels.MetadataKindTextArray {
+			property.Tokenization = models.PropertyTokenizationField
+		}
+		err := client.Schema().PropertyCreator().
+			WithClassName(className).
+			WithProperty(property).
+			Do(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to add metadata field %q: %w", field, err)
+		}
+		dataTypes[name] = kind
+	}
+	return nil
+}
+
+var metadataOperators = map[string]filters.WhereOperator{
+	storemodels.MetadataOpEqual:              filters.Equal,
+	storemodels.MetadataOpGreaterThan:        filters.GreaterThan,
+	storemodels.MetadataOpGreaterThanOrEqual: filters.GreaterThanEqual,
+	storemodels.MetadataOpLessThan:           filters.LessThan,
+	storemodels.MetadataOpLessThanOrEqual:    filters.LessThanEqual,
+}
+
+// metadataWhereFilters translates metadata filters into where filters. It
+// returns false if a filter cannot match any chunk, because no document has
+// the field or the field holds values of another type.
+func metadataWhereFilters(ctx context.Context, client *weaviate.Client, className string, metadataFilters []storemodels.MetadataFilter) ([]*filters.WhereBuilder, bool, error) {
+	dataTypes, err := metadataDataTypes(ctx, client, className)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var whereFilters []*filters.WhereBuilder
+	for _, filter := range metadataFilters {
+		if err := filter.Validate(); err != nil {
+			return nil, false, err
+		}
+		name := metadataPropertyName(filter.Field)
+		dataType, ok := dataTypes[name]
+		if !ok {
+			return nil, false, nil
+		}
+		where := filters.Where().WithPath([]string{name})
+
+		if filter.Operator == storemodels.MetadataOpContains {
+			text := filter.Value.(string)
+			switch dataType {
+			case storemodels.MetadataKindTextArray:
+				where = where.WithOperator(filters.ContainsAny).WithValueText(text)
+			case storemodels.MetadataKindText:
+				where = where.WithOperator(filters.Like).WithValueText("*" + text + "*")
+			default:
+				return nil, false, nil
+			}
+			whereFilters = append(whereFilters, where)
+			continue
+		}
+
+		where = where.WithOperator(metadataOperators[filter.Operator])
+		value := filter.Value
+		switch number := value.(type) {
+		case int:
+			value = float64(number)
+		case int64:
+			value = float64(number)
+		}
+		switch value := value.(type) {
+		case string:
+			switch dataType {
+			case storemodels.MetadataKindText:
+				where = where.WithValueText(value)
+			case storemodels.MetadataKindDate:
+				date, err := time.Parse(time.RFC3339, value)
+				if err != nil {
+					return nil, false, nil
+				}
+				where = where.WithValueDate(date)
+			default:
+				return nil, false, nil
+			}
+		case float64:
+			if dataType != storemodels.MetadataKindNumber {
+				return nil, false, nil
+			}
+			where = where.WithValueNumber(value)
+		case bool:
+			if dataType != storemodels.MetadataKindBoolean {
+				return nil, false, nil
+			}
+			where = where.WithValueBoolean(value)
+		default:
+			return nil, false, fmt.Errorf("unsupported value for metadata field %q", filter.Field)
+		}
+		whereFilters = append(whereFilters, where)
+	}
+	return whereFilters, true, nil
+}
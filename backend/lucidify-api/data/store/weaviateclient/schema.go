@@ -0,0 +1,454 @@
+package weaviateclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"lucidify-api/data/store/storemodels"
+	"sync"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/google/uuid"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// The chunks are stored in one class per schema version. The Documents alias
+// designates the class that searches read from, and during a migration the
+// class being built, which receives every write as well. The alias is stored
+// as a single object of the VectorSchema class, so that replacing the object
+// switches it atomically.
+const (
+	documentsAlias   = "Documents"
+	schemaStateClass = "VectorSchema"
+)
+
+// SchemaStateTTL is how long a client keeps using the schema state it loaded.
+// A migration waits for longer than this before backfilling a class, so that
+// every client writes to it by then.
+const SchemaStateTTL = 10 * time.Second
+
+var schemaStateID = uuid.NewSHA1(uuid.NameSpaceOID, []byte(schemaStateClass+"/"+documentsAlias))
+
+// SchemaState is the schema version the Documents alias points to, and the
+// version being migrated to, if any.
+type SchemaState struct {
+	Version       int       `json:"version"`
+	ClassName     string    `json:"class_name"`
+	NextVersion   int       `json:"next_version,omitempty"`
+	NextClassName string    `json:"next_class_name,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Migrating reports whether a migration to a new version is in progress.
+func (s SchemaState) Migrating() bool {
+	return s.NextVersion != 0
+}
+
+// SchemaMigrator migrates the chunks to a new schema version: it creates the
+// class of the version, which receives every write from then on, backfills it
+// with the existing chunks and finally points the alias to it.
+type SchemaMigrator interface {
+	SchemaState(ctx context.Context) (SchemaState, error)
+	BeginSchemaMigration(ctx context.Context, version int) (SchemaState, error)
+	BackfillSchemaVersion(ctx context.Context, version int, chunks []storemodels.Chunk, deletedDocuments map[uuid.UUID]bool) error
+	SchemaVersionTenants(ctx context.Context, version int) ([]string, error)
+	SchemaVersionChunkIDs(ctx context.Context, version int, tenant string, after string, limit int) ([]string, error)
+	DeleteSchemaVersionChunks(ctx context.Context, version int, tenant string, chunkIDs []string) error
+	ActivateSchemaVersion(ctx context.Context, version int) error
+	AbortSchemaMigration(ctx context.Context) error
+	DropSchemaVersion(ctx context.Context, version int) (bool, error)
+}
+
+// ClassNameForVersion returns the class holding the chunks of a schema
+// version. Version 1 is the class created before schemas were versioned.
+func ClassNameForVersion(version int) string {
+	if version == 1 {
+		return documentsAlias
+	}
+	return fmt.Sprintf("%sV%d", documentsAlias, version)
+}
+
+// schemaCache holds the schema state for SchemaStateTTL.
+type schemaCache struct {
+	mu       sync.Mutex
+	state    SchemaState
+	loadedAt time.Time
+}
+
+func (c *schemaCache) set(state SchemaState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state = state
+	c.loadedAt = time.Now()
+}
+
+// currentSchema returns the cached schema state, reloading it once it has
+// expired. The previous state is kept if Weaviate cannot be reached.
+func (w *WeaviateClientImpl) currentSchema(ctx context.Context) SchemaState {
+	w.schema.mu.Lock()
+	state, loadedAt := w.schema.state, w.schema.loadedAt
+	w.schema.mu.Unlock()
+	if time.Since(loadedAt) < SchemaStateTTL {
+		return state
+	}
+
+	loaded, found, err := loadSchemaState(ctx, w.client)
+	if err != nil || !found {
+		log.Printf("Failed to reload the vector schema state, keeping version %d: %v", state.Version, err)
+		return state
+	}
+	w.schema.set(loaded)
+	return loaded
+}
+
+// readClass returns the class searches and fetches read from.
+func (w *WeaviateClientImpl) readClass(ctx context.Context) string {
+	return w.currentSchema(ctx).ClassName
+}
+
+// forEachWriteClass applies a write to the active class and, during a
+// migration, to the class being built. A failure on the latter is only
+// logged: the migration is not activated until it has been backfilled, and
+// writes made before the backfill are copied by it.
+func (w *WeaviateClientImpl) forEachWriteClass(ctx context.Context, write func(className string) error) error {
+	state := w.currentSchema(ctx)
+	if err := write(state.ClassName); err != nil {
+		return err
+	}
+	if state.Migrating() {
+		if err := write(state.NextClassName); err != nil {
+			log.Printf("Failed to write to vector schema version %d: %v", state.NextVersion, err)
+		}
+	}
+	return nil
+}
+
+// initSchema returns the schema state, recording version 1 on first start.
+// The Documents class created before schemas were versioned becomes version 1.
+func initSchema(ctx context.Context, client *weaviate.Client) (SchemaState, error) {
+	if !classExists(ctx, client, schemaStateClass) {
+		err := client.Schema().ClassCreator().WithClass(schemaStateClassDefinition()).Do(ctx)
+		if err != nil && !classExists(ctx, client, schemaStateClass) {
+			return SchemaState{}, fmt.Errorf("failed to create the %s class: %w", schemaStateClass, err)
+		}
+	}
+
+	state, found, err := loadSchemaState(ctx, client)
+	if err != nil {
+		return SchemaState{}, err
+	}
+	if found {
+		return state, nil
+	}
+
+	className := ClassNameForVersion(1)
+	if classExists(ctx, client, className) {
+		for _, property := range []*models.Property{deletedProperty(), workspaceIDProperty(), contentHashProperty()} {
+			if err := ensureProperty(ctx, client, className, property); err != nil {
+				return SchemaState{}, err
+			}
+		}
+	} else if err := createSchemaClass(ctx, client, 1); err != nil {
+		return SchemaState{}, err
+	}
+
+	state = SchemaState{Version: 1, ClassName: className, UpdatedAt: time.Now().UTC()}
+	if err := saveSchemaState(ctx, client, state); err != nil {
+		return SchemaState{}, err
+	}
+	log.Printf("Initialized the vector schema at version 1")
+	return state, nil
+}
+
+func schemaStateClassDefinition() *models.Class {
+	text := func(name string) *models.Property {
+		return &models.Property{DataType: []string{"text"}, Name: name, Tokenization: models.PropertyTokenizationField}
+	}
+	number := func(name string) *models.Property {
+		return &models.Property{DataType: []string{"int"}, Name: name}
+	}
+	return &models.Class{
+		Class:       schemaStateClass,
+		Description: "The class each vector store alias points to",
+		Vectorizer:  "none",
+		Properties: []*models.Property{
+			text("alias"),
+			number("version"),
+			text("className"),
+			number("nextVersion"),
+			text("nextClassName"),
+			{DataType: []string{"date"}, Name: "updatedAt"},
+		},
+	}
+}
+
+// createSchemaClass creates the class of a schema version.
+func createSchemaClass(ctx context.Context, client *weaviate.Client, version int) error {
+	migration, ok := schemaMigration(version)
+	if !ok {
+		return fmt.Errorf("unknown vector schema version %d", version)
+	}
+	class := migration.Class(ClassNameForVersion(version))
+	if err := client.Schema().ClassCreator().WithClass(class).Do(ctx); err != nil {
+		return fmt.Errorf("failed to create the class of vector schema version %d: %w", version, err)
+	}
+	return nil
+}
+
+func loadSchemaState(ctx context.Context, client *weaviate.Client) (SchemaState, bool, error) {
+	exists, err := client.Data().Checker().
+		WithClassName(schemaStateClass).
+		WithID(schemaStateID.String()).
+		Do(ctx)
+	if err != nil {
+		return SchemaState{}, false, fmt.Errorf("failed to check the vector schema state: %w", err)
+	}
+	if !exists {
+		return SchemaState{}, false, nil
+	}
+
+	objects, err := client.Data().ObjectsGetter().
+		WithClassName(schemaStateClass).
+		WithID(schemaStateID.String()).
+		Do(ctx)
+	if err != nil {
+		return SchemaState{}, false, fmt.Errorf("failed to load the vector schema state: %w", err)
+	}
+	if len(objects) == 0 {
+		return SchemaState{}, false, nil
+	}
+
+	properties, ok := objects[0].Properties.(map[string]interface{})
+	if !ok {
+		return SchemaState{}, false, errors.New("unexpected format for the vector schema state")
+	}
+	version, _ := properties["version"].(float64)
+	className, _ := properties["className"].(string)
+	nextVersion, _ := properties["nextVersion"].(float64)
+	nextClassName, _ := properties["nextClassName"].(string)
+	updatedAt, _ := properties["updatedAt"].(string)
+	if version == 0 || className == "" {
+		return SchemaState{}, false, errors.New("the vector schema state has no version")
+	}
+
+	state := SchemaState{
+		Version:       int(version),
+		ClassName:     className,
+		NextVersion:   int(nextVersion),
+		NextClassName: nextClassName,
+	}
+	state.UpdatedAt, _ = time.Parse(time.RFC3339Nano, updatedAt)
+	return state, true, nil
+}
+
+// saveSchemaState replaces the schema state object in a single request.
+func saveSchemaState(ctx context.Context, client *weaviate.Client, state SchemaState) error {
+	object := &models.Object{
+		Class: schemaStateClass,
+		ID:    strfmt.UUID(schemaStateID.String()),
+		Properties: map[string]interface{}{
+			"alias":         documentsAlias,
+			"version":       state.Version,
+			"className":     state.ClassName,
+			"nextVersion":   state.NextVersion,
+			"nextClassName": state.NextClassName,
+			"updatedAt":     state.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		},
+	}
+	responses, err := client.Batch().ObjectsBatcher().
+		WithObjects(object).
+		Do(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to save the vector schema state: %w", err)
+	}
+	for _, response := range responses {
+		if message := batchObjectError(response); message != "" {
+			return fmt.Errorf("failed to save the vector schema state: %s", message)
+		}
+	}
+	return nil
+}
+
+func (w *WeaviateClientImpl) SchemaState(ctx context.Context) (SchemaState, error) {
+	state, found, err := loadSchemaState(ctx, w.client)
+	if err != nil {
+		return SchemaState{}, err
+	}
+	if !found {
+		return SchemaState{}, errors.New("the vector schema state is missing")
+	}
+	return state, nil
+}
+
+// BeginSchemaMigration creates the class of the version and has every client
+// write to it, once their schema state expires. Beginning the migration that
+// is already in progress resumes it.
+func (w *WeaviateClientImpl) BeginSchemaMigration(ctx context.Context, version int) (SchemaState, error) {
+	state, err := w.SchemaState(ctx)
+	if err != nil {
+		return SchemaState{}, err
+	}
+	if state.Migrating() && state.NextVersion != version {
+		return SchemaState{}, fmt.Errorf("a migration to vector schema version %d is in progress", state.NextVersion)
+	}
+	if version <= state.Version {
+		return SchemaState{}, fmt.Errorf("vector schema version %d is not newer than the active version %d", version, state.Version)
+	}
+
+	className := ClassNameForVersion(version)
+	if !classExists(ctx, w.client, className) {
+		if err := createSchemaClass(ctx, w.client, version); err != nil {
+			return SchemaState{}, err
+		}
+	}
+	if state.Migrating() {
+		return state, nil
+	}
+
+	state.NextVersion = version
+	state.NextClassName = className
+	state.UpdatedAt = time.Now().UTC()
+	if err := saveSchemaState(ctx, w.client, state); err != nil {
+		return SchemaState{}, err
+	}
+	w.schema.set(state)
+	return state, nil
+}
+
+// BackfillSchemaVersion copies the chunks into the class of the version.
+// Chunks that are already there are replaced.
+func (w *WeaviateClientImpl) BackfillSchemaVersion(ctx context.Context, version int, chunks []storemodels.Chunk, deletedDocuments map[uuid.UUID]bool) error {
+	className := ClassNameForVersion(version)
+	chunks = withContentHashes(chunks)
+
+	if err := ensureChunksMetadataProperties(ctx, w.client, className, chunks); err != nil {
+		return err
+	}
+
+	// Without an embedder, the class vectorizes the chunks with its own module
+	var vectors map[string][]float32
+	if w.embedder != nil {
+		var err error
+		if vectors, err = w.chunkVectors(ctx, className, chunks); err != nil {
+			return err
+		}
+	}
+	return w.uploadChunkObjects(ctx, className, chunks, vectors, deletedDocuments)
+}
+
+// SchemaVersionTenants lists the tenants of the class of the version, or a
+// single empty tenant if it is not multi-tenant.
+func (w *WeaviateClientImpl) SchemaVersionTenants(ctx context.Context, version int) ([]string, error) {
+	return w.classTenants(ctx, ClassNameForVersion(version))
+}
+
+// SchemaVersionChunkIDs lists the ids of up to limit chunks of the tenant in
+// the class of the version, starting after the given id, or from the start if
+// it is empty.
+func (w *WeaviateClientImpl) SchemaVersionChunkIDs(ctx context.Context, version int, tenant string, after string, limit int) ([]string, error) {
+	className := ClassNameForVersion(version)
+	query := w.client.GraphQL().Get().
+		WithClassName(className).
+		WithTenant(tenant).
+		WithFields(graphql.Field{Name: "_additional", Fields: []graphql.Field{{Name: "id"}}}).
+		WithLimit(limit)
+	if after != "" {
+		query = query.WithAfter(after)
+	}
+	result, err := query.Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the chunks of vector schema version %d: %w", version, err)
+	}
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("failed to list the chunks of vector schema version %d: %s", version, result.Errors[0].Message)
+	}
+
+	get, _ := result.Data["Get"].(map[string]interface{})
+	objects, _ := get[className].([]interface{})
+	chunkIDs := make([]string, 0, len(objects))
+	for _, object := range objects {
+		properties, _ := object.(map[string]interface{})
+		additional, _ := properties["_additional"].(map[string]interface{})
+		if id, ok := additional["id"].(string); ok {
+			chunkIDs = append(chunkIDs, id)
+		}
+	}
+	return chunkIDs, nil
+}
+
+// DeleteSchemaVersionChunks deletes chunks of the tenant from the class of the
+// version only.
+func (w *WeaviateClientImpl) DeleteSchemaVersionChunks(ctx context.Context, version int, tenant string, chunkIDs []string) error {
+	if len(chunkIDs) == 0 {
+		return nil
+	}
+	return w.deleteChunksWhere(ctx, ClassNameForVersion(version), tenant, chunkIDsFilter(chunkIDs))
+}
+
+// ActivateSchemaVersion points the alias to the class of the version being
+// migrated to. The class of the previous version is kept until it is dropped.
+func (w *WeaviateClientImpl) ActivateSchemaVersion(ctx context.Context, version int) error {
+	state, err := w.SchemaState(ctx)
+	if err != nil {
+		return err
+	}
+	if state.NextVersion != version {
+		return fmt.Errorf("no migration to vector schema version %d is in progress", version)
+	}
+
+	state = SchemaState{Version: version, ClassName: state.NextClassName, UpdatedAt: time.Now().UTC()}
+	if err := saveSchemaState(ctx, w.client, state); err != nil {
+		return err
+	}
+	w.schema.set(state)
+	return nil
+}
+
+// AbortSchemaMigration stops writing to the class being migrated to. The
+// class is kept until it is dropped.
+func (w *WeaviateClientImpl) AbortSchemaMigration(ctx context.Context) error {
+	state, err := w.SchemaState(ctx)
+	if err != nil {
+		return err
+	}
+	if !state.Migrating() {
+		return errors.New("no vector schema migration is in progress")
+	}
+
+	state.NextVersion = 0
+	state.NextClassName = ""
+	state.UpdatedAt = time.Now().UTC()
+	if err := saveSchemaState(ctx, w.client, state); err != nil {
+		return err
+	}
+	w.schema.set(state)
+	return nil
+}
+
+// DropSchemaVersion deletes the class of a version that is neither active nor
+// being migrated to. It reports whether the class existed.
+func (w *WeaviateClientImpl) DropSchemaVersion(ctx context.Context, version int) (bool, error) {
+	state, err := w.SchemaState(ctx)
+	if err != nil {
+		return false, err
+	}
+	if version == state.Version || version == state.NextVersion {
+		return false, fmt.Errorf("vector schema version %d is in use", version)
+	}
+
+	className := ClassNameForVersion(version)
+	if !classExists(ctx, w.client, className) {
+		return false, nil
+	}
+	if err := w.client.Schema().ClassDeleter().WithClassName(className).Do(ctx); err != nil {
+		return false, fmt.Errorf("failed to drop vector schema version %d: %w", version, err)
+	}
+	w.tenancy.mu.Lock()
+	delete(w.tenancy.classes, className)
+	w.tenancy.mu.Unlock()
+	return true, nil
+}
@@ -0,0 +1,128 @@
+// //go:build integration
+// // +build integration
+package weaviateclient
+
+import (
+	"context"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/config"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSchemaMigration(t *testing.T) {
+	ctx := context.Background()
+
+	weaviateClient, err := NewWeaviateClientTest(config.NewServerConfig().OPENAI_API_KEY)
+	if err != nil {
+		t.Fatalf("failed to create weaviate client: %v", err)
+	}
+
+	initial, err := weaviateClient.SchemaState(ctx)
+	if err != nil {
+		t.Fatalf("SchemaState failed: %v", err)
+	}
+	if initial.Migrating() {
+		t.Fatalf("expected no migration in progress, got version %d", initial.NextVersion)
+	}
+
+	documentID := uuid.New()
+	userID := uuid.New().String()
+
+	// Migrate to a single-tenant copy of the first version
+	version := LatestSchemaVersion() + 1
+	migrations := SchemaMigrations
+	SchemaMigrations = append(append([]SchemaMigration{}, migrations...), SchemaMigration{
+		Version:     version,
+		Description: "Test migration",
+		Class:       documentsClassV1,
+	})
+	defer func() {
+		SchemaMigrations = migrations
+		if err := saveSchemaState(ctx, weaviateClient.GetWeaviateClient(), initial); err != nil {
+			t.Logf("teardown failed to restore the schema state: %v", err)
+		}
+		weaviateClient.(*WeaviateClientImpl).schema.set(initial)
+		if err := weaviateClient.DeleteChunksOfDocuments(ctx, userID, "", []uuid.UUID{documentID}); err != nil {
+			t.Logf("teardown failed to delete chunks: %v", err)
+		}
+		if _, err := weaviateClient.DropSchemaVersion(ctx, version); err != nil {
+			t.Logf("teardown failed to drop version %d: %v", version, err)
+		}
+	}()
+
+	state, err := weaviateClient.BeginSchemaMigration(ctx, version)
+	if err != nil {
+		t.Fatalf("BeginSchemaMigration failed: %v", err)
+	}
+	if state.NextVersion != version || state.NextClassName != ClassNameForVersion(version) {
+		t.Errorf("expected a migration to version %d, got %+v", version, state)
+	}
+	if _, err := weaviateClient.BeginSchemaMigration(ctx, version+1); err == nil {
+		t.Errorf("BeginSchemaMigration should have failed while a migration is in progress")
+	}
+
+	backfilled := storemodels.Chunk{
+		ChunkID:      uuid.New(),
+		UserID:       userID,
+		DocumentID:   documentID,
+		ChunkContent: "Backfilled chunk content",
+		ChunkIndex:   0,
+	}
+	written := storemodels.Chunk{
+		ChunkID:      uuid.New(),
+		UserID:       userID,
+		DocumentID:   documentID,
+		ChunkContent: "Chunk content written during the migration",
+		ChunkIndex:   1,
+	}
+
+	if err := weaviateClient.BackfillSchemaVersion(ctx, version, []storemodels.Chunk{backfilled}, nil); err != nil {
+		t.Fatalf("BackfillSchemaVersion failed: %v", err)
+	}
+	// Writes go to both versions during the migration
+	if err := weaviateClient.UploadChunks(ctx, []storemodels.Chunk{written}); err != nil {
+		t.Fatalf("UploadChunks failed: %v", err)
+	}
+
+	chunkIDs, err := weaviateClient.SchemaVersionChunkIDs(ctx, version, "", "", 10)
+	if err != nil {
+		t.Fatalf("SchemaVersionChunkIDs failed: %v", err)
+	}
+	if len(chunkIDs) != 2 {
+		t.Errorf("expected 2 chunks in version %d, got %d", version, len(chunkIDs))
+	}
+
+	if err := weaviateClient.ActivateSchemaVersion(ctx, version); err != nil {
+		t.Fatalf("ActivateSchemaVersion failed: %v", err)
+	}
+	state, err = weaviateClient.SchemaState(ctx)
+	if err != nil {
+		t.Fatalf("SchemaState failed: %v", err)
+	}
+	if state.Version != version || state.Migrating() {
+		t.Errorf("expected version %d to be active, got %+v", version, state)
+	}
+
+	chunks, err := weaviateClient.GetChunks(ctx, []storemodels.Chunk{backfilled, written})
+	if err != nil {
+		t.Fatalf("GetChunks failed after the migration: %v", err)
+	}
+	if chunks[0].ChunkContent != backfilled.ChunkContent || chunks[1].ChunkContent != written.ChunkContent {
+		t.Errorf("unexpected chunks after the migration: %+v", chunks)
+	}
+}
+
+func TestTenantName(t *testing.T) {
+	if name := TenantName("user_2NNEqL2nrIRdJ194ndJqAHwEfxC"); name != "user_2NNEqL2nrIRdJ194ndJqAHwEfxC" {
+		t.Errorf("expected a valid user id to be kept, got %s", name)
+	}
+	name := TenantName("auth0|64f1c2")
+	if name == "auth0|64f1c2" || !tenantNamePattern.MatchString(name) {
+		t.Errorf("expected an invalid user id to be hashed, got %s", name)
+	}
+	if TenantName("auth0|64f1c2") != name {
+		t.Errorf("expected the tenant name to be stable")
+	}
+}
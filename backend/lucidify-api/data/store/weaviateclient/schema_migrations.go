@@ -0,0 +1,108 @@
+package weaviateclient
+
+import (
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// SchemaMigration defines the class of a schema version. To change the class,
+// append a migration with the next version rather than editing an existing
+// one, and run it with cmd/vectorschema.
+type SchemaMigration struct {
+	Version     int
+	Description string
+	Class       func(className string) *models.Class
+	// WorkspaceTenants keeps the chunks of workspace documents in the tenant
+	// of their workspace rather than of their uploader, so that they outlive
+	// the uploader.
+	WorkspaceTenants bool
+}
+
+// SchemaMigrations lists every schema version in order.
+var SchemaMigrations = []SchemaMigration{
+	{
+		Version:     1,
+		Description: "Chunks vectorized with text2vec-openai",
+		Class:       documentsClassV1,
+	},
+	{
+		Version:     2,
+		Description: "Multi-tenant class with one tenant per user",
+		Class:       documentsClassV2,
+	},
+	{
+		Version:          3,
+		Description:      "Workspace documents in the tenant of their workspace",
+		Class:            documentsClassV2,
+		WorkspaceTenants: true,
+	},
+}
+
+// LatestSchemaVersion returns the version of the last migration.
+func LatestSchemaVersion() int {
+	return SchemaMigrations[len(SchemaMigrations)-1].Version
+}
+
+// workspaceTenants reports whether the class of a schema version keeps the
+// chunks of workspace documents in the tenant of their workspace.
+func workspaceTenants(className string) bool {
+	for _, migration := range SchemaMigrations {
+		if ClassNameForVersion(migration.Version) == className {
+			return migration.WorkspaceTenants
+		}
+	}
+	return false
+}
+
+func schemaMigration(version int) (SchemaMigration, bool) {
+	for _, migration := range SchemaMigrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return SchemaMigration{}, false
+}
+
+func documentsClassV1(className string) *models.Class {
+	return &models.Class{
+		Class:       className,
+		Description: "A document with associated metadata",
+		Vectorizer:  "text2vec-openai",
+		Properties: []*models.Property{
+			{
+				DataType:    []string{"string"},
+				Description: "Unique identifier of the document",
+				Name:        "documentId",
+			},
+			{
+				DataType:    []string{"string"},
+				Description: "User identifier associated with the document",
+				Name:        "userId",
+			},
+			{
+				DataType:    []string{"string"},
+				Description: "Unique identifier of the chunk within the document",
+				Name:        "chunkId",
+			},
+			{
+				DataType:    []string{"text"},
+				Description: "A chunk of the document content",
+				Name:        "chunkContent",
+			},
+			{
+				DataType:    []string{"int"},
+				Description: "Index of the chunk in the document",
+				Name:        "chunkIndex",
+			},
+			deletedProperty(),
+			workspaceIDProperty(),
+			contentHashProperty(),
+		},
+	}
+}
+
+// documentsClassV2 isolates the chunks of each user in a tenant.
+func documentsClassV2(className string) *models.Class {
+	class := documentsClassV1(className)
+	class.MultiTenancyConfig = &models.MultiTenancyConfig{Enabled: true}
+	return class
+}
@@ -0,0 +1,278 @@
+package weaviateclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"lucidify-api/data/store/storemodels"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// With multi-tenancy, the chunks of the documents uploaded by a user, private
+// or shared, are stored in the tenant of the user, so that a query can only
+// return chunks of the tenants it names. Whether a class is multi-tenant is
+// set when it is created, see SchemaMigrations. From version 3, the chunks of
+// workspace documents are stored in the tenant of their workspace instead.
+//
+// Tenants are named after their owner, the id of a user or, for a workspace,
+// the id prefixed with workspaceOwnerPrefix.
+
+const workspaceOwnerPrefix = "workspace-"
+
+var tenantNamePattern = regexp.MustCompile(`^[A-Za-z0-9\-_]{1,64}$`)
+
+// TenantName returns the tenant of an owner, a user or a workspace, see
+// chunkOwner. Owners that are not valid tenant names are replaced by their
+// hash.
+func TenantName(owner string) string {
+	if tenantNamePattern.MatchString(owner) {
+		return owner
+	}
+	hash := sha256.Sum256([]byte(owner))
+	return hex.EncodeToString(hash[:])
+}
+
+// classTenancy is whether a class is multi-tenant and, if so, the tenants it
+// is known to have.
+type classTenancy struct {
+	multiTenant bool
+	tenants     map[string]bool
+	loadedAt    time.Time
+}
+
+type tenancyCache struct {
+	mu      sync.Mutex
+	classes map[string]*classTenancy
+}
+
+// loadClassTenancy returns the cached tenancy of the class. The caller must
+// hold the lock.
+func (w *WeaviateClientImpl) loadClassTenancy(ctx context.Context, className string) (*classTenancy, error) {
+	if w.tenancy.classes == nil {
+		w.tenancy.classes = make(map[string]*classTenancy)
+	}
+	if tenancy, ok := w.tenancy.classes[className]; ok {
+		return tenancy, nil
+	}
+
+	class, err := w.client.Schema().ClassGetter().WithClassName(className).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get class %s: %w", className, err)
+	}
+	tenancy := &classTenancy{
+		multiTenant: class.MultiTenancyConfig != nil && class.MultiTenancyConfig.Enabled,
+	}
+	if tenancy.multiTenant {
+		if err := w.refreshTenants(ctx, className, tenancy); err != nil {
+			return nil, err
+		}
+	}
+	w.tenancy.classes[className] = tenancy
+	return tenancy, nil
+}
+
+func (w *WeaviateClientImpl) refreshTenants(ctx context.Context, className string, tenancy *classTenancy) error {
+	tenants, err := w.client.Schema().TenantsGetter().WithClassName(className).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get the tenants of class %s: %w", className, err)
+	}
+	tenancy.tenants = make(map[string]bool, len(tenants))
+	for _, tenant := range tenants {
+		tenancy.tenants[tenant.Name] = true
+	}
+	tenancy.loadedAt = time.Now()
+	return nil
+}
+
+func (w *WeaviateClientImpl) isMultiTenant(ctx context.Context, className string) (bool, error) {
+	w.tenancy.mu.Lock()
+	defer w.tenancy.mu.Unlock()
+	tenancy, err := w.loadClassTenancy(ctx, className)
+	if err != nil {
+		return false, err
+	}
+	return tenancy.multiTenant, nil
+}
+
+// workspaceOwner returns the owner of the tenant of a workspace.
+func workspaceOwner(workspaceID string) string {
+	return workspaceOwnerPrefix + workspaceID
+}
+
+// chunkOwner returns the owner of the tenant holding the chunk in the class:
+// its workspace if the class has workspace tenants and the chunk is of a
+// workspace document, else its uploader.
+func chunkOwner(className string, chunk storemodels.Chunk) string {
+	if chunk.WorkspaceID != "" && workspaceTenants(className) {
+		return workspaceOwner(chunk.WorkspaceID)
+	}
+	return chunk.UserID
+}
+
+func chunkOwners(className string, chunks []storemodels.Chunk) []string {
+	owners := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		owners[i] = chunkOwner(className, chunk)
+	}
+	return owners
+}
+
+// chunkTenant returns the tenant of the chunks of an owner in the class, or an
+// empty tenant if the class is not multi-tenant.
+func (w *WeaviateClientImpl) chunkTenant(ctx context.Context, className string, userID string) (string, error) {
+	multiTenant, err := w.isMultiTenant(ctx, className)
+	if err != nil || !multiTenant {
+		return "", err
+	}
+	return TenantName(userID), nil
+}
+
+// ensureTenants creates the tenants of the users the class does not have yet.
+// It does nothing if the class is not multi-tenant.
+func (w *WeaviateClientImpl) ensureTenants(ctx context.Context, className string, userIDs []string) error {
+	w.tenancy.mu.Lock()
+	defer w.tenancy.mu.Unlock()
+	tenancy, err := w.loadClassTenancy(ctx, className)
+	if err != nil || !tenancy.multiTenant {
+		return err
+	}
+
+	missing := make(map[string]bool)
+	for _, userID := range userIDs {
+		if name := TenantName(userID); !tenancy.tenants[name] {
+			missing[name] = true
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	tenants := make([]models.Tenant, 0, len(missing))
+	for name := range missing {
+		tenants = append(tenants, models.Tenant{Name: name})
+	}
+
+	createErr := w.client.Schema().TenantsCreator().
+		WithClassName(className).
+		WithTenants(tenants...).
+		Do(ctx)
+	if createErr == nil {
+		for name := range missing {
+			tenancy.tenants[name] = true
+		}
+		return nil
+	}
+
+	// Another client may have created some of them
+	if err := w.refreshTenants(ctx, className, tenancy); err != nil {
+		return err
+	}
+	for name := range missing {
+		if !tenancy.tenants[name] {
+			return fmt.Errorf("failed to create tenant %s: %w", name, createErr)
+		}
+	}
+	return nil
+}
+
+// existingTenants returns the tenants of the users that the class has. The
+// tenants are reloaded when one is unknown, at most once per SchemaStateTTL.
+func (w *WeaviateClientImpl) existingTenants(ctx context.Context, className string, userIDs []string) ([]string, error) {
+	w.tenancy.mu.Lock()
+	defer w.tenancy.mu.Unlock()
+	tenancy, err := w.loadClassTenancy(ctx, className)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(userIDs))
+	seen := make(map[string]bool)
+	for _, userID := range userIDs {
+		name := TenantName(userID)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if !tenancy.tenants[name] && time.Since(tenancy.loadedAt) >= SchemaStateTTL {
+			if err := w.refreshTenants(ctx, className, tenancy); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	existing := make([]string, 0, len(names))
+	for _, name := range names {
+		if tenancy.tenants[name] {
+			existing = append(existing, name)
+		}
+	}
+	return existing, nil
+}
+
+// CreateTenant creates the tenant of the user in the classes written to. It
+// does nothing for classes that are not multi-tenant.
+func (w *WeaviateClientImpl) CreateTenant(ctx context.Context, userID string) error {
+	return w.forEachWriteClass(ctx, func(className string) error {
+		return w.ensureTenants(ctx, className, []string{userID})
+	})
+}
+
+// DeleteTenant deletes the tenant of the user, along with every chunk in it,
+// from the classes written to.
+func (w *WeaviateClientImpl) DeleteTenant(ctx context.Context, userID string) error {
+	return w.forEachWriteClass(ctx, func(className string) error {
+		w.tenancy.mu.Lock()
+		defer w.tenancy.mu.Unlock()
+		tenancy, err := w.loadClassTenancy(ctx, className)
+		if err != nil || !tenancy.multiTenant {
+			return err
+		}
+		name := TenantName(userID)
+		if err := w.refreshTenants(ctx, className, tenancy); err != nil {
+			return err
+		}
+		if !tenancy.tenants[name] {
+			return nil
+		}
+		err = w.client.Schema().TenantsDeleter().
+			WithClassName(className).
+			WithTenants(name).
+			Do(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete tenant %s: %w", name, err)
+		}
+		delete(tenancy.tenants, name)
+		return nil
+	})
+}
+
+// classTenants returns every tenant of the class, or a single empty tenant if
+// the class is not multi-tenant.
+func (w *WeaviateClientImpl) classTenants(ctx context.Context, className string) ([]string, error) {
+	w.tenancy.mu.Lock()
+	defer w.tenancy.mu.Unlock()
+	tenancy, err := w.loadClassTenancy(ctx, className)
+	if err != nil {
+		return nil, err
+	}
+	if !tenancy.multiTenant {
+		return []string{""}, nil
+	}
+	if err := w.refreshTenants(ctx, className, tenancy); err != nil {
+		return nil, err
+	}
+	tenants := make([]string, 0, len(tenancy.tenants))
+	for name := range tenancy.tenants {
+		tenants = append(tenants, name)
+	}
+	sort.Strings(tenants)
+	return tenants, nil
+}
@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"log"
 	"lucidify-api/data/store/storemodels"
-	"lucidify-api/server/config"
+	"lucidify-api/server/tracing"
+	"net/http"
+	"sort"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate"
@@ -17,20 +20,40 @@ import (
 
 type WeaviateClient interface {
 	GetWeaviateClient() *weaviate.Client
-	UploadChunk(storemodels.Chunk) error
-	UploadChunks([]storemodels.Chunk) error
-	DeleteChunk(chunkID uuid.UUID) error
-	DeleteChunks([]storemodels.Chunk) error
-	GetChunks(chunksFromPostgresql []storemodels.Chunk) ([]storemodels.Chunk, error)
-	SearchDocumentsByText(limit int, userID string, concepts []string) ([]storemodels.ChunkFromVectorSearch, error)
+	UploadChunk(ctx context.Context, chunk storemodels.Chunk) error
+	UploadChunks(ctx context.Context, chunks []storemodels.Chunk) error
+	DeleteChunk(ctx context.Context, chunkID uuid.UUID) error
+	DeleteChunks(ctx context.Context, chunks []storemodels.Chunk) error
+	DeleteChunksOfDocuments(ctx context.Context, userID, workspaceID string, documentIDs []uuid.UUID) error
+	DeleteChunksOfUser(ctx context.Context, userID string) error
+	CreateTenant(ctx context.Context, userID string) error
+	DeleteTenant(ctx context.Context, userID string) error
+	SetChunksDeleted(ctx context.Context, chunks []storemodels.Chunk, deleted bool) error
+	ReplaceChunksMetadata(ctx context.Context, chunks []storemodels.Chunk, deleted bool) error
+	GetChunks(ctx context.Context, chunksFromPostgresql []storemodels.Chunk) ([]storemodels.Chunk, error)
+	SearchDocumentsByText(ctx context.Context, limit int, scope SearchScope, concepts []string) ([]storemodels.ChunkFromVectorSearch, error)
+	SchemaMigrator
+	Ready(ctx context.Context) error
+	Close()
 }
 
+// Embedder computes the vectors of chunks and search concepts.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// WeaviateClientImpl vectorizes chunks and search concepts with the embedder.
+// Without one, Weaviate vectorizes them with the module of the class.
 type WeaviateClientImpl struct {
-	client *weaviate.Client
+	client     *weaviate.Client
+	httpClient *http.Client
+	embedder   Embedder
+	schema     schemaCache
+	tenancy    tenancyCache
 }
 
-func classExists(client *weaviate.Client, className string) bool {
-	schema, err := client.Schema().ClassGetter().WithClassName(className).Do(context.Background())
+func classExists(ctx context.Context, client *weaviate.Client, className string) bool {
+	schema, err := client.Schema().ClassGetter().WithClassName(className).Do(ctx)
 	if err != nil {
 		return false
 	}
@@ -38,14 +61,16 @@ func classExists(client *weaviate.Client, className string) bool {
 	return true
 }
 
-func NewWeaviateClient() (WeaviateClient, error) {
-	config := config.NewServerConfig()
+// NewWeaviateClient connects to Weaviate at the host, with requests timing out
+// after the timeout unless it is zero.
+func NewWeaviateClient(host string, scheme string, timeout time.Duration, openAIAPIKey string, embedder Embedder) (WeaviateClient, error) {
 	cfg := weaviate.Config{
-		Host:   "weaviate:8080",
-		Scheme: "http",
+		Host:   host,
+		Scheme: scheme,
 		Headers: map[string]string{
-			"X-OpenAI-Api-Key": config.OPENAI_API_KEY,
+			"X-OpenAI-Api-Key": openAIAPIKey,
 		},
+		ConnectionClient: &http.Client{Timeout: timeout, Transport: tracing.Transport(nil)},
 	}
 	client, err := weaviate.NewClient(cfg)
 	if err != nil {
@@ -55,21 +80,24 @@ func NewWeaviateClient() (WeaviateClient, error) {
 		return nil, errors.New("client is nil after initialization")
 	}
 
-	if !classExists(client, "Documents") {
-		createWeaviateDocumentsClass(client)
+	state, err := initSchema(context.Background(), client)
+	if err != nil {
+		return nil, err
 	}
 
-	return &WeaviateClientImpl{client: client}, nil
+	w := &WeaviateClientImpl{client: client, httpClient: cfg.ConnectionClient, embedder: embedder}
+	w.schema.set(state)
+	return w, nil
 }
 
-func NewWeaviateClientTest() (WeaviateClient, error) {
-	config := config.NewServerConfig()
+func NewWeaviateClientTest(openAIAPIKey string) (WeaviateClient, error) {
 	cfg := weaviate.Config{
 		Host:   "localhost:8090",
 		Scheme: "http",
 		Headers: map[string]string{
-			"X-OpenAI-Api-Key": config.OPENAI_API_KEY,
+			"X-OpenAI-Api-Key": openAIAPIKey,
 		},
+		ConnectionClient: &http.Client{Transport: tracing.Transport(nil)},
 	}
 	client, err := weaviate.NewClient(cfg)
 	if err != nil {
@@ -79,98 +107,305 @@ func NewWeaviateClientTest() (WeaviateClient, error) {
 		return nil, errors.New("client is nil after initialization")
 	}
 
-	if !classExists(client, "Documents") {
-		createWeaviateDocumentsClass(client)
+	state, err := initSchema(context.Background(), client)
+	if err != nil {
+		return nil, err
 	}
 
-	return &WeaviateClientImpl{client: client}, nil
+	w := &WeaviateClientImpl{client: client, httpClient: cfg.ConnectionClient}
+	w.schema.set(state)
+	return w, nil
 }
 
 func (w *WeaviateClientImpl) GetWeaviateClient() *weaviate.Client {
 	return w.client
 }
 
-func createWeaviateDocumentsClass(client *weaviate.Client) {
-	if client == nil {
-		log.Println("Client is nil in createWeaviateDocumentsClass")
-		return
-	}
-
-	// Check if the class already exists
-	if classExists(client, "Documents") {
-		log.Println("Class 'Documents' already exists")
-		return
-	}
-
-	classObj := &models.Class{
-		Class:       "Documents",
-		Description: "A document with associated metadata",
-		Vectorizer:  "text2vec-openai",
-		Properties: []*models.Property{
-			{
-				DataType:    []string{"string"},
-				Description: "Unique identifier of the document",
-				Name:        "documentId",
-			},
-			{
-				DataType:    []string{"string"},
-				Description: "User identifier associated with the document",
-				Name:        "userId",
-			},
-			{
-				DataType:    []string{"string"},
-				Description: "Unique identifier of the chunk within the document",
-				Name:        "chunkId",
-			},
-			{
-				DataType:    []string{"text"},
-				Description: "A chunk of the document content",
-				Name:        "chunkContent",
-			},
-			{
-				DataType:    []string{"int"},
-				Description: "Index of the chunk in the document",
-				Name:        "chunkIndex",
-			},
+// Ready checks that Weaviate is ready to serve requests.
+func (w *WeaviateClientImpl) Ready(ctx context.Context) error {
+	ready, err := w.client.Misc().ReadyChecker().Do(ctx)
+	if err != nil {
+		return err
+	}
+	if !ready {
+		return errors.New("weaviate is not ready")
+	}
+	return nil
+}
+
+// Close closes the idle connections to Weaviate. It should only be called once
+// no request is in flight.
+func (w *WeaviateClientImpl) Close() {
+	w.httpClient.CloseIdleConnections()
+}
+
+func deletedProperty() *models.Property {
+	return &models.Property{
+		DataType:    []string{"boolean"},
+		Description: "Whether the document of the chunk is in the trash",
+		Name:        "deleted",
+	}
+}
+
+func workspaceIDProperty() *models.Property {
+	return &models.Property{
+		DataType:     []string{"text"},
+		Description:  "Workspace the document is shared with, empty for private documents",
+		Name:         "workspaceId",
+		Tokenization: models.PropertyTokenizationField,
+	}
+}
+
+// contentHashProperty holds the hash of chunkContent. It is left out of the
+// vectorization, so that chunks with the same content hash have the same
+// vector.
+func contentHashProperty() *models.Property {
+	return &models.Property{
+		DataType:     []string{"text"},
+		Description:  "SHA-256 of the chunk content",
+		Name:         "contentHash",
+		Tokenization: models.PropertyTokenizationField,
+		ModuleConfig: map[string]interface{}{
+			"text2vec-openai": map[string]interface{}{"skip": true},
 		},
 	}
+}
 
-	err := client.Schema().ClassCreator().WithClass(classObj).Do(context.Background())
+// ensureProperty adds a property to a class created before the property
+// existed. Objects created before then have no value for it.
+func ensureProperty(ctx context.Context, client *weaviate.Client, className string, property *models.Property) error {
+	class, err := client.Schema().ClassGetter().WithClassName(className).Do(ctx)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	for _, existing := range class.Properties {
+		if existing.Name == property.Name {
+			return nil
+		}
 	}
+	return client.Schema().PropertyCreator().
+		WithClassName(className).
+		WithProperty(property).
+		Do(ctx)
 }
 
-func (w *WeaviateClientImpl) UploadChunks(chunks []storemodels.Chunk) error {
-	for _, chunk := range chunks {
-		err := w.UploadChunk(chunk)
+func (w *WeaviateClientImpl) UploadChunks(ctx context.Context, chunks []storemodels.Chunk) error {
+	chunks = withContentHashes(chunks)
+
+	return w.forEachWriteClass(ctx, func(className string) error {
+		if err := ensureChunksMetadataProperties(ctx, w.client, className, chunks); err != nil {
+			return err
+		}
+		if err := w.ensureTenants(ctx, className, chunkOwners(className, chunks)); err != nil {
+			return err
+		}
+		vectors, err := w.chunkVectors(ctx, className, chunks)
 		if err != nil {
 			return err
 		}
+		return w.uploadChunkObjects(ctx, className, chunks, vectors, nil)
+	})
+}
+
+// ensureChunksMetadataProperties adds the metadata properties of the chunks to
+// the class. Chunks of a document share its metadata.
+func ensureChunksMetadataProperties(ctx context.Context, client *weaviate.Client, className string, chunks []storemodels.Chunk) error {
+	ensured := make(map[uuid.UUID]bool)
+	for _, chunk := range chunks {
+		if ensured[chunk.DocumentID] {
+			continue
+		}
+		if err := ensureMetadataProperties(ctx, client, className, chunk.Metadata); err != nil {
+			return err
+		}
+		ensured[chunk.DocumentID] = true
+	}
+	return nil
+}
+
+// withContentHashes returns a copy of the chunks where missing content hashes
+// are filled in.
+func withContentHashes(chunks []storemodels.Chunk) []storemodels.Chunk {
+	hashed := make([]storemodels.Chunk, len(chunks))
+	for i, chunk := range chunks {
+		if chunk.ContentHash == "" {
+			chunk.ContentHash = storemodels.ContentHash(chunk.ChunkContent)
+		}
+		hashed[i] = chunk
+	}
+	return hashed
+}
+
+// chunkVectors returns the vectors of the chunks by content hash, from the
+// embedder, or else from chunks of the class with the same content.
+func (w *WeaviateClientImpl) chunkVectors(ctx context.Context, className string, chunks []storemodels.Chunk) (map[string][]float32, error) {
+	if w.embedder == nil {
+		return w.vectorsByContentHash(ctx, className, chunks)
+	}
+
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.ChunkContent
+	}
+	embeddings, err := w.embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed chunks: %w", err)
+	}
+	vectors := make(map[string][]float32)
+	for i, chunk := range chunks {
+		vectors[chunk.ContentHash] = embeddings[i]
+	}
+	return vectors, nil
+}
+
+// vectorsByContentHash returns the vectors of stored chunks with the same
+// content as the given chunks, so that they are not vectorized again. Chunks
+// without a stored counterpart are missing from the map. The stored chunks are
+// looked up with a query per tenant and batch of content hashes.
+func (w *WeaviateClientImpl) vectorsByContentHash(ctx context.Context, className string, chunks []storemodels.Chunk) (map[string][]float32, error) {
+	hashesByTenant := make(map[string][]string)
+	searched := make(map[string]bool)
+	for _, chunk := range chunks {
+		if chunk.ContentHash == "" || searched[chunk.ContentHash] {
+			continue
+		}
+		searched[chunk.ContentHash] = true
+
+		tenant, err := w.chunkTenant(ctx, className, chunkOwner(className, chunk))
+		if err != nil {
+			return nil, err
+		}
+		hashesByTenant[tenant] = append(hashesByTenant[tenant], chunk.ContentHash)
+	}
+
+	vectors := make(map[string][]float32)
+	for tenant, hashes := range hashesByTenant {
+		for start := 0; start < len(hashes); start += batchSize {
+			end := start + batchSize
+			if end > len(hashes) {
+				end = len(hashes)
+			}
+			if err := w.findVectors(ctx, className, tenant, hashes[start:end], vectors); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return vectors, nil
+}
+
+// findVectors adds the vectors of the chunks of the tenant with the given
+// content hashes to vectors. Chunks sharing their content can fill the limit
+// of a query, so the hashes left are looked up again until none are found.
+func (w *WeaviateClientImpl) findVectors(ctx context.Context, className string, tenant string, hashes []string, vectors map[string][]float32) error {
+	for len(hashes) > 0 {
+		result, err := w.client.GraphQL().Get().
+			WithClassName(className).
+			WithTenant(tenant).
+			WithFields(
+				graphql.Field{Name: "contentHash"},
+				graphql.Field{Name: "_additional", Fields: []graphql.Field{{Name: "vector"}}},
+			).
+			WithWhere(filters.Where().
+				WithPath([]string{"contentHash"}).
+				WithOperator(filters.ContainsAny).
+				WithValueText(hashes...)).
+			WithLimit(len(hashes)).
+			Do(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to find the vectors of %d chunks: %w", len(hashes), err)
+		}
+		if len(result.Errors) > 0 {
+			return fmt.Errorf("failed to find the vectors of %d chunks: %s", len(hashes), result.Errors[0].Message)
+		}
+
+		get, _ := result.Data["Get"].(map[string]interface{})
+		objects, _ := get[className].([]interface{})
+		for _, object := range objects {
+			properties, _ := object.(map[string]interface{})
+			hash, _ := properties["contentHash"].(string)
+			if vector := objectVector(properties); hash != "" && vector != nil {
+				vectors[hash] = vector
+			}
+		}
+		if len(objects) < len(hashes) {
+			return nil
+		}
+
+		var missing []string
+		for _, hash := range hashes {
+			if _, ok := vectors[hash]; !ok {
+				missing = append(missing, hash)
+			}
+		}
+		if len(missing) == len(hashes) {
+			return nil
+		}
+		hashes = missing
 	}
 	return nil
 }
 
-func (w *WeaviateClientImpl) UploadChunk(chunk storemodels.Chunk) error {
+// objectVector extracts the vector of an object returned by a Get query, or
+// returns nil if it has none.
+func objectVector(object map[string]interface{}) []float32 {
+	additional, _ := object["_additional"].(map[string]interface{})
+	values, _ := additional["vector"].([]interface{})
+	if len(values) == 0 {
+		return nil
+	}
+	vector := make([]float32, len(values))
+	for i, value := range values {
+		number, ok := value.(float64)
+		if !ok {
+			return nil
+		}
+		vector[i] = float32(number)
+	}
+	return vector
+}
+
+func (w *WeaviateClientImpl) UploadChunk(ctx context.Context, chunk storemodels.Chunk) error {
 	if w.client == nil {
 		return errors.New("Weaviate client is not initialized")
 	}
+	chunk = withContentHashes([]storemodels.Chunk{chunk})[0]
+	return w.forEachWriteClass(ctx, func(className string) error {
+		if err := ensureMetadataProperties(ctx, w.client, className, chunk.Metadata); err != nil {
+			return err
+		}
+		if err := w.ensureTenants(ctx, className, []string{chunkOwner(className, chunk)}); err != nil {
+			return err
+		}
+		vectors, err := w.chunkVectors(ctx, className, []storemodels.Chunk{chunk})
+		if err != nil {
+			return err
+		}
+		return w.uploadChunk(ctx, className, chunk, vectors[chunk.ContentHash])
+	})
+}
 
-	// Convert the chunk to a format suitable for Weaviate
-	chunkData := map[string]interface{}{
-		"documentId":   chunk.DocumentID.String(),
-		"userId":       chunk.UserID,
-		"chunkId":      chunk.ChunkID.String(), // Convert UUID to string
-		"chunkContent": chunk.ChunkContent,
-		"chunkIndex":   chunk.ChunkIndex,
+// uploadChunk stores the chunk with the given vector, or has Weaviate vectorize
+// it if the vector is nil.
+func (w *WeaviateClientImpl) uploadChunk(ctx context.Context, className string, chunk storemodels.Chunk, vector []float32) error {
+	if w.client == nil {
+		return errors.New("Weaviate client is not initialized")
+	}
+
+	tenant, err := w.chunkTenant(ctx, className, chunkOwner(className, chunk))
+	if err != nil {
+		return err
 	}
 
 	// Use the Weaviate client to upload the chunk
-	_, err := w.client.Data().Creator().
+	creator := w.client.Data().Creator().
 		WithID(chunk.ChunkID.String()).
-		WithClassName("Documents").
-		WithProperties(chunkData).
-		Do(context.Background())
+		WithClassName(className).
+		WithTenant(tenant).
+		WithProperties(chunkProperties(chunk, false))
+	if vector != nil {
+		creator = creator.WithVector(vector)
+	}
+	_, err = creator.Do(ctx)
 
 	if err != nil {
 		return fmt.Errorf("failed to upload chunk: %w", err)
@@ -179,79 +414,366 @@ func (w *WeaviateClientImpl) UploadChunk(chunk storemodels.Chunk) error {
 	return nil
 }
 
-func (w *WeaviateClientImpl) DeleteChunk(chunkID uuid.UUID) error {
-	err := w.client.Data().Deleter().
-		WithClassName("Documents").
-		WithID(chunkID.String()).
-		Do(context.Background())
+// chunkProperties converts the chunk to a format suitable for Weaviate
+func chunkProperties(chunk storemodels.Chunk, deleted bool) map[string]interface{} {
+	// Chunks of shared documents are found through their workspace only, so
+	// that the uploader loses access along with their membership
+	userID := chunk.UserID
+	if chunk.WorkspaceID != "" {
+		userID = ""
+	}
 
-	return err
+	properties := map[string]interface{}{
+		"documentId":   chunk.DocumentID.String(),
+		"userId":       userID,
+		"workspaceId":  chunk.WorkspaceID,
+		"chunkId":      chunk.ChunkID.String(), // Convert UUID to string
+		"chunkContent": chunk.ChunkContent,
+		"chunkIndex":   chunk.ChunkIndex,
+		"contentHash":  chunk.ContentHash,
+		"deleted":      deleted,
+	}
+	for field, value := range chunk.Metadata {
+		properties[metadataPropertyName(field)] = value
+	}
+	return properties
 }
 
-func (w *WeaviateClientImpl) DeleteChunks(chunks []storemodels.Chunk) error {
-	for _, chunk := range chunks {
-		err := w.DeleteChunk(chunk.ChunkID)
+// ReplaceChunksMetadata rewrites the chunks with their new metadata. The
+// chunks are replaced as a whole, so that fields removed from the metadata are
+// removed from the chunks as well. They are written in batches along with
+// their vectors, which replacing an object would drop otherwise.
+func (w *WeaviateClientImpl) ReplaceChunksMetadata(ctx context.Context, chunks []storemodels.Chunk, deleted bool) error {
+	chunks = withContentHashes(chunks)
+	deletedDocuments := make(map[uuid.UUID]bool)
+	if deleted {
+		for _, chunk := range chunks {
+			deletedDocuments[chunk.DocumentID] = true
+		}
+	}
+
+	return w.forEachWriteClass(ctx, func(className string) error {
+		if err := ensureChunksMetadataProperties(ctx, w.client, className, chunks); err != nil {
+			return err
+		}
+		vectors, err := w.chunkVectors(ctx, className, chunks)
 		if err != nil {
 			return err
 		}
+		return w.uploadChunkObjects(ctx, className, chunks, vectors, deletedDocuments)
+	})
+}
+
+// DeleteChunk deletes a chunk by id. With multi-tenancy, the tenant of the
+// chunk is unknown, so DeleteChunks must be used instead.
+func (w *WeaviateClientImpl) DeleteChunk(ctx context.Context, chunkID uuid.UUID) error {
+	return w.forEachWriteClass(ctx, func(className string) error {
+		if err := w.requireSingleTenant(ctx, className); err != nil {
+			return err
+		}
+		return w.client.Data().Deleter().
+			WithClassName(className).
+			WithID(chunkID.String()).
+			Do(ctx)
+	})
+}
+
+// requireSingleTenant fails for multi-tenant classes, for deletes that do not
+// know the tenant of the chunks.
+func (w *WeaviateClientImpl) requireSingleTenant(ctx context.Context, className string) error {
+	multiTenant, err := w.isMultiTenant(ctx, className)
+	if err != nil {
+		return err
+	}
+	if multiTenant {
+		return fmt.Errorf("class %s is multi-tenant, chunks must be deleted with their user", className)
 	}
 	return nil
 }
 
-func (w *WeaviateClientImpl) DeleteChunksByChunkIDs(chunkIDs []string) error {
-	for _, chunkID := range chunkIDs {
-		err := w.DeleteChunk(uuid.MustParse(chunkID))
+// DeleteChunks deletes the chunks in a single batch request per tenant.
+func (w *WeaviateClientImpl) DeleteChunks(ctx context.Context, chunks []storemodels.Chunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	return w.forEachWriteClass(ctx, func(className string) error {
+		multiTenant, err := w.isMultiTenant(ctx, className)
 		if err != nil {
 			return err
 		}
+		if !multiTenant {
+			chunkIDs := make([]string, len(chunks))
+			for i, chunk := range chunks {
+				chunkIDs[i] = chunk.ChunkID.String()
+			}
+			return w.deleteChunksWhere(ctx, className, "", chunkIDsFilter(chunkIDs))
+		}
+		chunkIDsByOwner := make(map[string][]string)
+		for _, chunk := range chunks {
+			owner := chunkOwner(className, chunk)
+			chunkIDsByOwner[owner] = append(chunkIDsByOwner[owner], chunk.ChunkID.String())
+		}
+		for owner, chunkIDs := range chunkIDsByOwner {
+			if err := w.deleteUserChunksWhere(ctx, className, owner, chunkIDsFilter(chunkIDs)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteChunksOfDocuments deletes every chunk of the documents uploaded by the
+// user to the workspace, or of their private documents if workspaceID is
+// empty, whether or not it is still recorded in PostgreSQL.
+func (w *WeaviateClientImpl) DeleteChunksOfDocuments(ctx context.Context, userID, workspaceID string, documentIDs []uuid.UUID) error {
+	if len(documentIDs) == 0 {
+		return nil
 	}
-	return nil
+	return w.forEachWriteClass(ctx, func(className string) error {
+		owner := chunkOwner(className, storemodels.Chunk{UserID: userID, WorkspaceID: workspaceID})
+		return w.deleteUserChunksWhere(ctx, className, owner, documentIDsFilter(documentIDs))
+	})
 }
 
-func (w *WeaviateClientImpl) GetChunks(chunksFromPostgresql []storemodels.Chunk) ([]storemodels.Chunk, error) {
-	var chunksFromWeaviate []storemodels.Chunk
-	for _, chunk := range chunksFromPostgresql {
-		objects, err := w.client.Data().ObjectsGetter().
-			WithClassName("Documents").
-			WithID(chunk.ChunkID.String()).
-			Do(context.Background())
+// deleteUserChunksWhere deletes the chunks matching the filter, within the
+// tenant of the owner if the class is multi-tenant.
+func (w *WeaviateClientImpl) deleteUserChunksWhere(ctx context.Context, className string, owner string, where *filters.WhereBuilder) error {
+	tenant, err := w.chunkTenant(ctx, className, owner)
+	if err != nil {
+		return err
+	}
+	if tenant != "" {
+		existing, err := w.existingTenants(ctx, className, []string{owner})
+		if err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			return nil
+		}
+	}
+	return w.deleteChunksWhere(ctx, className, tenant, where)
+}
+
+// DeleteChunksOfUser deletes every chunk of the private documents of the user.
+// Chunks of workspace documents are not attributed to their uploader and must
+// be deleted by document.
+func (w *WeaviateClientImpl) DeleteChunksOfUser(ctx context.Context, userID string) error {
+	if userID == "" {
+		return errors.New("user id is required")
+	}
+	where := filters.Where().
+		WithPath([]string{"userId"}).
+		WithOperator(filters.Equal).
+		WithValueText(userID)
+	return w.forEachWriteClass(ctx, func(className string) error {
+		return w.deleteUserChunksWhere(ctx, className, userID, where)
+	})
+}
+
+// SetChunksDeleted flags the chunks as trashed or restored. Trashed chunks are
+// excluded from SearchDocumentsByText. The chunks are written again in batches
+// along with their stored vectors, and a BatchError lists those that could not
+// be flagged after every attempt.
+func (w *WeaviateClientImpl) SetChunksDeleted(ctx context.Context, chunks []storemodels.Chunk, deleted bool) error {
+	chunks = withContentHashes(chunks)
+	deletedDocuments := make(map[uuid.UUID]bool)
+	if deleted {
+		for _, chunk := range chunks {
+			deletedDocuments[chunk.DocumentID] = true
+		}
+	}
+
+	return w.forEachWriteClass(ctx, func(className string) error {
+		if err := ensureChunksMetadataProperties(ctx, w.client, className, chunks); err != nil {
+			return err
+		}
+		vectors, err := w.storedChunkVectors(ctx, className, chunks)
+		if err != nil {
+			return err
+		}
+		return w.uploadChunkObjects(ctx, className, chunks, vectors, deletedDocuments)
+	})
+}
+
+// storedChunkVectors is chunkVectors for chunks already in the class, whose
+// stored vectors are reused. Only the chunks missing from the class are
+// embedded.
+func (w *WeaviateClientImpl) storedChunkVectors(ctx context.Context, className string, chunks []storemodels.Chunk) (map[string][]float32, error) {
+	vectors, err := w.vectorsByContentHash(ctx, className, chunks)
+	if err != nil {
+		return nil, err
+	}
+	var missing []storemodels.Chunk
+	for _, chunk := range chunks {
+		if _, ok := vectors[chunk.ContentHash]; !ok {
+			missing = append(missing, chunk)
+		}
+	}
+	if len(missing) == 0 || w.embedder == nil {
+		return vectors, nil
+	}
+
+	embedded, err := w.chunkVectors(ctx, className, missing)
+	if err != nil {
+		return nil, err
+	}
+	for hash, vector := range embedded {
+		vectors[hash] = vector
+	}
+	return vectors, nil
+}
+
+func (w *WeaviateClientImpl) DeleteChunksByChunkIDs(ctx context.Context, chunkIDs []string) error {
+	if len(chunkIDs) == 0 {
+		return nil
+	}
+	return w.forEachWriteClass(ctx, func(className string) error {
+		if err := w.requireSingleTenant(ctx, className); err != nil {
+			return err
+		}
+		return w.deleteChunksWhere(ctx, className, "", chunkIDsFilter(chunkIDs))
+	})
+}
 
+// GetChunks fetches the chunks from Weaviate in a single query per user, in
+// the order of the given chunks. It fails if any of them is missing.
+func (w *WeaviateClientImpl) GetChunks(ctx context.Context, chunksFromPostgresql []storemodels.Chunk) ([]storemodels.Chunk, error) {
+	if len(chunksFromPostgresql) == 0 {
+		return nil, nil
+	}
+	chunkIDs := make([]string, len(chunksFromPostgresql))
+	chunkIDsByTenant := make(map[string][]string)
+	className := w.readClass(ctx)
+	for i, chunk := range chunksFromPostgresql {
+		chunkIDs[i] = chunk.ChunkID.String()
+		tenant, err := w.chunkTenant(ctx, className, chunkOwner(className, chunk))
 		if err != nil {
 			return nil, err
 		}
+		chunkIDsByTenant[tenant] = append(chunkIDsByTenant[tenant], chunkIDs[i])
+	}
+
+	chunksByID := make(map[string]storemodels.Chunk)
+	for tenant, tenantChunkIDs := range chunkIDsByTenant {
+		if err := w.getChunks(ctx, className, tenant, tenantChunkIDs, chunksByID); err != nil {
+			return nil, err
+		}
+	}
 
-		if len(objects) == 0 {
-			return nil, fmt.Errorf("no object found for chunk ID: %s", chunk.ChunkID.String())
+	chunksFromWeaviate := make([]storemodels.Chunk, 0, len(chunkIDs))
+	for _, chunkID := range chunkIDs {
+		chunk, ok := chunksByID[chunkID]
+		if !ok {
+			return nil, fmt.Errorf("no object found for chunk ID: %s", chunkID)
 		}
+		chunksFromWeaviate = append(chunksFromWeaviate, chunk)
+	}
+	return chunksFromWeaviate, nil
+}
 
-		fmt.Printf("objects: %+v\n", objects[0])
+// getChunks adds the chunks of the tenant with the given ids to chunksByID.
+func (w *WeaviateClientImpl) getChunks(ctx context.Context, className string, tenant string, chunkIDs []string, chunksByID map[string]storemodels.Chunk) error {
+	result, err := w.client.GraphQL().Get().
+		WithClassName(className).
+		WithTenant(tenant).
+		WithFields(
+			graphql.Field{Name: "documentId"},
+			graphql.Field{Name: "userId"},
+			graphql.Field{Name: "workspaceId"},
+			graphql.Field{Name: "chunkId"},
+			graphql.Field{Name: "chunkContent"},
+			graphql.Field{Name: "chunkIndex"},
+			graphql.Field{Name: "contentHash"},
+		).
+		WithWhere(chunkIDsFilter(chunkIDs)).
+		WithLimit(len(chunkIDs)).
+		Do(ctx)
+	if err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("failed to get chunks: %s", result.Errors[0].Message)
+	}
 
-		// Extract properties from the first object
-		properties := objects[0].Properties.(map[string]interface{})
+	getData, ok := result.Data["Get"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("unexpected format for 'Get' data")
+	}
+	objects, _ := getData[className].([]interface{})
+
+	for _, object := range objects {
+		properties, ok := object.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("unexpected format for chunk data")
+		}
 
 		chunkIndexValue, ok := properties["chunkIndex"].(float64)
 		if !ok {
-			return nil, fmt.Errorf("chunkIndex is not a float64 or is missing")
+			return fmt.Errorf("chunkIndex is not a float64 or is missing")
 		}
 
-		// Map the properties to your storemodels.Chunk struct
-		singleChunkFromWeaviate := storemodels.Chunk{
-			ChunkID:      uuid.MustParse(properties["chunkId"].(string)),
-			UserID:       properties["userId"].(string),
-			DocumentID:   uuid.MustParse(properties["documentId"].(string)),
-			ChunkContent: properties["chunkContent"].(string),
-			ChunkIndex:   int(chunkIndexValue),
+		// userId, workspaceId and contentHash are missing rather than empty for
+		// some chunks
+		userID, _ := properties["userId"].(string)
+		workspaceID, _ := properties["workspaceId"].(string)
+		contentHash, _ := properties["contentHash"].(string)
+		chunkID, _ := properties["chunkId"].(string)
+		documentID, _ := properties["documentId"].(string)
+		chunkContent, _ := properties["chunkContent"].(string)
+
+		chunkUUID, err := uuid.Parse(chunkID)
+		if err != nil {
+			return fmt.Errorf("invalid chunkId %q: %w", chunkID, err)
+		}
+		documentUUID, err := uuid.Parse(documentID)
+		if err != nil {
+			return fmt.Errorf("invalid documentId %q: %w", documentID, err)
 		}
 
-		chunksFromWeaviate = append(chunksFromWeaviate, singleChunkFromWeaviate)
+		chunksByID[chunkID] = storemodels.Chunk{
+			ChunkID:      chunkUUID,
+			UserID:       userID,
+			WorkspaceID:  workspaceID,
+			DocumentID:   documentUUID,
+			ChunkContent: chunkContent,
+			ChunkIndex:   int(chunkIndexValue),
+			ContentHash:  contentHash,
+		}
 	}
-	return chunksFromWeaviate, nil
+	return nil
 }
 
-func (w *WeaviateClientImpl) SearchDocumentsByText(limit int, userID string, concepts []string) ([]storemodels.ChunkFromVectorSearch, error) {
-	className := "Documents"
+// SearchScope selects the chunks a search may return: those of the private
+// documents of the user, of the documents of the given workspaces, and of the
+// given documents, which have been shared with the user. When
+// RestrictToDocumentIDs is not nil, only chunks of those documents within the
+// scope are returned. MetadataFilters further restrict the chunks to those
+// whose document metadata matches every filter.
+type SearchScope struct {
+	UserID                string
+	WorkspaceIDs          []string
+	DocumentIDs           []uuid.UUID
+	RestrictToDocumentIDs []uuid.UUID
+	MetadataFilters       []storemodels.MetadataFilter
+	// OwnerUserIDs are the uploaders of the workspace and shared documents.
+	// With multi-tenancy, only their tenants, the user's and those of the
+	// workspaces are searched.
+	OwnerUserIDs []string
+}
+
+// SearchDocumentsByText searches the documents within the scope, leaving out
+// documents in the trash. With multi-tenancy, each tenant of the scope is
+// searched and the closest chunks among them are returned.
+func (w *WeaviateClientImpl) SearchDocumentsByText(ctx context.Context, limit int, scope SearchScope, concepts []string) ([]storemodels.ChunkFromVectorSearch, error) {
+	if scope.RestrictToDocumentIDs != nil && len(scope.RestrictToDocumentIDs) == 0 {
+		return nil, nil
+	}
+
+	className := w.readClass(ctx)
 
 	documentId := graphql.Field{Name: "documentId"}
+	userId := graphql.Field{Name: "userId"}
+	workspaceId := graphql.Field{Name: "workspaceId"}
 	chunkId := graphql.Field{Name: "chunkId"}
 	chunkContent := graphql.Field{Name: "chunkContent"}
 	chunkIndex := graphql.Field{Name: "chunkIndex"}
@@ -277,26 +799,125 @@ func (w *WeaviateClientImpl) SearchDocumentsByText(limit int, userID string, con
 	// WithMoveTo(moveTo).
 	// WithMoveAwayFrom(moveAwayFrom)
 
-	// Creating the where filter
-	whereFilter := filters.Where().
-		WithPath([]string{"userId"}).
-		WithOperator(filters.Equal).
-		WithValueText(userID)
+	scopeFilters := []*filters.WhereBuilder{
+		filters.Where().
+			WithPath([]string{"userId"}).
+			WithOperator(filters.Equal).
+			WithValueText(scope.UserID),
+	}
+	if len(scope.WorkspaceIDs) > 0 {
+		scopeFilters = append(scopeFilters, filters.Where().
+			WithPath([]string{"workspaceId"}).
+			WithOperator(filters.ContainsAny).
+			WithValueText(scope.WorkspaceIDs...))
+	}
+	if len(scope.DocumentIDs) > 0 {
+		scopeFilters = append(scopeFilters, documentIDsFilter(scope.DocumentIDs))
+	}
+	ownerFilter := scopeFilters[0]
+	if len(scopeFilters) > 1 {
+		ownerFilter = filters.Where().
+			WithOperator(filters.Or).
+			WithOperands(scopeFilters)
+	}
 
-	ctx := context.Background()
+	// Creating the where filter. Chunks created before soft delete have no
+	// deleted property, so NotEqual is used rather than Equal false.
+	conditions := []*filters.WhereBuilder{
+		ownerFilter,
+		filters.Where().
+			WithPath([]string{"deleted"}).
+			WithOperator(filters.NotEqual).
+			WithValueBoolean(true),
+	}
+	if scope.RestrictToDocumentIDs != nil {
+		conditions = append(conditions, documentIDsFilter(scope.RestrictToDocumentIDs))
+	}
+	if len(scope.MetadataFilters) > 0 {
+		metadataConditions, ok, err := metadataWhereFilters(ctx, w.client, className, scope.MetadataFilters)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			// A filter cannot match any chunk
+			return nil, nil
+		}
+		conditions = append(conditions, metadataConditions...)
+	}
+	whereFilter := filters.Where().
+		WithOperator(filters.And).
+		WithOperands(conditions)
 
-	result, err := w.client.GraphQL().Get().
-		WithClassName(className).
-		WithFields(documentId, chunkId, chunkContent, chunkIndex, _additional).
-		WithNearText(nearText).
-		WithLimit(limit).
-		WithWhere(whereFilter).
-		Do(ctx)
+	var vector []float32
+	if w.embedder != nil {
+		var err error
+		if vector, err = w.conceptsVector(ctx, concepts); err != nil {
+			return nil, err
+		}
+	}
 
+	tenants := []string{""}
+	multiTenant, err := w.isMultiTenant(ctx, className)
 	if err != nil {
 		return nil, err
 	}
+	if multiTenant {
+		owners := append([]string{scope.UserID}, scope.OwnerUserIDs...)
+		if workspaceTenants(className) {
+			for _, workspaceID := range scope.WorkspaceIDs {
+				owners = append(owners, workspaceOwner(workspaceID))
+			}
+		}
+		tenants, err = w.existingTenants(ctx, className, owners)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var chunks []storemodels.ChunkFromVectorSearch
+	for _, tenant := range tenants {
+		query := w.client.GraphQL().Get().
+			WithClassName(className).
+			WithTenant(tenant).
+			WithFields(documentId, userId, workspaceId, chunkId, chunkContent, chunkIndex, _additional).
+			WithLimit(limit).
+			WithWhere(whereFilter)
+		if vector != nil {
+			query = query.WithNearVector(w.client.GraphQL().NearVectorArgBuilder().
+				WithVector(vector).
+				WithDistance(distance))
+		} else {
+			query = query.WithNearText(nearText)
+		}
+		result, err := query.Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Errors) > 0 {
+			return nil, fmt.Errorf("failed to search chunks: %s", result.Errors[0].Message)
+		}
+
+		tenantChunks, err := searchResultChunks(result, className)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, tenantChunks...)
+	}
+
+	if len(tenants) > 1 {
+		sort.SliceStable(chunks, func(i, j int) bool {
+			return chunks[i].Distance < chunks[j].Distance
+		})
+		if len(chunks) > limit {
+			chunks = chunks[:limit]
+		}
+	}
+	return chunks, nil
+}
 
+// searchResultChunks converts the search results. Chunks of workspace documents
+// are stored without their uploader, so their UserID is left empty.
+func searchResultChunks(result *models.GraphQLResponse, className string) ([]storemodels.ChunkFromVectorSearch, error) {
 	var chunks []storemodels.ChunkFromVectorSearch
 
 	if result != nil && result.Data != nil {
@@ -305,9 +926,9 @@ func (w *WeaviateClientImpl) SearchDocumentsByText(limit int, userID string, con
 			return nil, fmt.Errorf("unexpected format for 'Get' data")
 		}
 
-		unprocessedChunks, ok := getData["Documents"].([]interface{})
+		unprocessedChunks, ok := getData[className].([]interface{})
 		if !ok {
-			return nil, fmt.Errorf("unexpected format for 'Documents' data")
+			return nil, fmt.Errorf("unexpected format for '%s' data", className)
 		}
 
 		for _, chunk := range unprocessedChunks {
@@ -318,6 +939,8 @@ func (w *WeaviateClientImpl) SearchDocumentsByText(limit int, userID string, con
 
 			// documentName := docMap["documentName"].(string)
 			documentId := docMap["documentId"].(string)
+			userId, _ := docMap["userId"].(string)
+			workspaceId, _ := docMap["workspaceId"].(string)
 			chunkId := docMap["chunkId"].(string)
 			chunkContent := docMap["chunkContent"].(string)
 			chunkIndex := docMap["chunkIndex"].(float64)
@@ -327,7 +950,8 @@ func (w *WeaviateClientImpl) SearchDocumentsByText(limit int, userID string, con
 
 			chunkFromVectorSearch := storemodels.ChunkFromVectorSearch{
 				ChunkID:      uuid.MustParse(chunkId),
-				UserID:       userID,
+				UserID:       userId,
+				WorkspaceID:  workspaceId,
 				DocumentID:   uuid.MustParse(documentId),
 				ChunkContent: chunkContent,
 				ChunkIndex:   int(chunkIndex),
@@ -340,3 +964,44 @@ func (w *WeaviateClientImpl) SearchDocumentsByText(limit int, userID string, con
 
 	return chunks, nil
 }
+
+// conceptsVector embeds the concepts of a search and, like nearText, combines
+// them into their mean vector.
+func (w *WeaviateClientImpl) conceptsVector(ctx context.Context, concepts []string) ([]float32, error) {
+	if len(concepts) == 0 {
+		return nil, errors.New("no concepts to search for")
+	}
+	vectors, err := w.embedder.Embed(ctx, concepts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed search concepts: %w", err)
+	}
+	mean := make([]float32, len(vectors[0]))
+	for _, vector := range vectors {
+		if len(vector) != len(mean) {
+			return nil, errors.New("search concepts have vectors of different dimensions")
+		}
+		for i, value := range vector {
+			mean[i] += value / float32(len(vectors))
+		}
+	}
+	return mean, nil
+}
+
+// chunkIDsFilter matches the chunks by their object id, which is the chunk id.
+func chunkIDsFilter(chunkIDs []string) *filters.WhereBuilder {
+	return filters.Where().
+		WithPath([]string{"id"}).
+		WithOperator(filters.ContainsAny).
+		WithValueText(chunkIDs...)
+}
+
+func documentIDsFilter(documentIDs []uuid.UUID) *filters.WhereBuilder {
+	values := make([]string, len(documentIDs))
+	for i, documentID := range documentIDs {
+		values[i] = documentID.String()
+	}
+	return filters.Where().
+		WithPath([]string{"documentId"}).
+		WithOperator(filters.ContainsAny).
+		WithValueText(values...)
+}
@@ -3,15 +3,19 @@
 package weaviateclient
 
 import (
+	"context"
 	"fmt"
 	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/config"
 	"testing"
 
 	"github.com/google/uuid"
 )
 
 func TestUploadDeleteChunk(t *testing.T) {
-	weaviateClient, err := NewWeaviateClientTest()
+	ctx := context.Background()
+
+	weaviateClient, err := NewWeaviateClientTest(config.NewServerConfig().OPENAI_API_KEY)
 	if err != nil {
 		t.Errorf("failed to create weaviate client: %v", err)
 	}
@@ -26,35 +30,37 @@ func TestUploadDeleteChunk(t *testing.T) {
 		ChunkIndex:   0,
 	}
 	defer func() {
-		if err := weaviateClient.DeleteChunk(chunk.ChunkID); err != nil {
+		if err := weaviateClient.DeleteChunk(ctx, chunk.ChunkID); err != nil {
 			t.Logf("teardown failed. Potentially already deleted: %v", err)
 		}
 	}()
 
-	err = weaviateClient.UploadChunk(chunk)
+	err = weaviateClient.UploadChunk(ctx, chunk)
 	if err != nil {
 		t.Errorf("UploadChunk failed: %v", err)
 	}
-	err = weaviateClient.UploadChunk(chunk)
+	err = weaviateClient.UploadChunk(ctx, chunk)
 	if err == nil {
 		t.Errorf("UploadChunk should have failed due to duplication: %v", err)
 	}
-	err = weaviateClient.DeleteChunk(chunk.ChunkID)
+	err = weaviateClient.DeleteChunk(ctx, chunk.ChunkID)
 	if err != nil {
 		t.Errorf("DeleteAllChunksByDocumentID failed: %v", err)
 	}
-	err = weaviateClient.UploadChunk(chunk)
+	err = weaviateClient.UploadChunk(ctx, chunk)
 	if err != nil {
 		t.Errorf("re-UploadChunk failed proceeding DeleteChunk: %v", err)
 	}
-	err = weaviateClient.DeleteChunk(chunk.ChunkID)
+	err = weaviateClient.DeleteChunk(ctx, chunk.ChunkID)
 	if err != nil {
 		t.Errorf("DeleteAllChunksByDocumentID failed: %v", err)
 	}
 }
 
 func TestUploadDeleteChunks(t *testing.T) {
-	weaviateClient, err := NewWeaviateClientTest()
+	ctx := context.Background()
+
+	weaviateClient, err := NewWeaviateClientTest(config.NewServerConfig().OPENAI_API_KEY)
 	if err != nil {
 		t.Fatalf("failed to create weaviate client: %v", err)
 	}
@@ -64,7 +70,7 @@ func TestUploadDeleteChunks(t *testing.T) {
 	var chunks []storemodels.Chunk
 
 	defer func() {
-		if err := weaviateClient.DeleteChunks(chunks); err != nil {
+		if err := weaviateClient.DeleteChunks(ctx, chunks); err != nil {
 			t.Logf("teardown failed, potentially chunks already deleted: %v", err)
 		}
 	}()
@@ -88,19 +94,20 @@ func TestUploadDeleteChunks(t *testing.T) {
 	}
 	chunks = append(chunks, chunk1)
 
-	err = weaviateClient.UploadChunks(chunks)
+	err = weaviateClient.UploadChunks(ctx, chunks)
 	if err != nil {
 		t.Errorf("UploadChunks failed: %v", err)
 	}
-	err = weaviateClient.UploadChunks(chunks)
-	if err == nil {
-		t.Errorf("UploadChunks should have failed due to duplication: %v", err)
+	// Batch uploads replace existing chunks, so that failures can be retried
+	err = weaviateClient.UploadChunks(ctx, chunks)
+	if err != nil {
+		t.Errorf("UploadChunks should replace existing chunks: %v", err)
 	}
-	err = weaviateClient.DeleteChunks(chunks)
+	err = weaviateClient.DeleteChunks(ctx, chunks)
 	if err != nil {
 		t.Errorf("DeleteAllChunksByDocumentID failed: %v", err)
 	}
-	chunks, err = weaviateClient.GetChunks(chunks)
+	chunks, err = weaviateClient.GetChunks(ctx, chunks)
 	if err == nil || len(chunks) != 0 {
 		t.Errorf("GetChunks should return 0 chunks. returned chunks: %v", len(chunks))
 	}
@@ -108,7 +115,9 @@ func TestUploadDeleteChunks(t *testing.T) {
 }
 
 func TestDeleteChunks(t *testing.T) {
-	weaviateClient, err := NewWeaviateClientTest()
+	ctx := context.Background()
+
+	weaviateClient, err := NewWeaviateClientTest(config.NewServerConfig().OPENAI_API_KEY)
 	if err != nil {
 		t.Fatalf("failed to create weaviate client: %v", err)
 	}
@@ -118,7 +127,7 @@ func TestDeleteChunks(t *testing.T) {
 	var chunks []storemodels.Chunk
 
 	defer func() {
-		if err := weaviateClient.DeleteChunks(chunks); err != nil {
+		if err := weaviateClient.DeleteChunks(ctx, chunks); err != nil {
 			t.Logf("teardown failed, potentially chunks already deleted: %v", err)
 		}
 	}()
@@ -143,29 +152,75 @@ func TestDeleteChunks(t *testing.T) {
 	}
 	chunks = append(chunks, chunk1)
 
-	err = weaviateClient.UploadChunks(chunks)
+	err = weaviateClient.UploadChunks(ctx, chunks)
 	if err != nil {
 		t.Errorf("UploadChunks failed: %v", err)
 	}
-	err = weaviateClient.UploadChunks(chunks)
-	if err == nil {
-		t.Errorf("UploadChunks should have failed due to duplication: %v", err)
+	// Batch uploads replace existing chunks, so that failures can be retried
+	err = weaviateClient.UploadChunks(ctx, chunks)
+	if err != nil {
+		t.Errorf("UploadChunks should replace existing chunks: %v", err)
 	}
-	err = weaviateClient.DeleteChunks(chunks)
+	err = weaviateClient.DeleteChunksOfDocuments(ctx, userID, "", []uuid.UUID{documentID})
 	if err != nil {
-		t.Errorf("DeleteAllChunksByDocumentID failed: %v", err)
+		t.Errorf("DeleteChunksOfDocuments failed: %v", err)
 	}
-	chunks, err = weaviateClient.GetChunks(chunks)
+	chunks, err = weaviateClient.GetChunks(ctx, chunks)
 	if err == nil || len(chunks) != 0 {
 		t.Errorf("GetChunks should return 0 chunks. returned chunks: %v", len(chunks))
 	}
 
-	err = weaviateClient.UploadChunks(chunks)
+	err = weaviateClient.UploadChunks(ctx, chunks)
 	if err != nil {
 		t.Errorf("re-UploadChunks failed proceeding DeleteChunk: %v", err)
 	}
 }
 
+func TestDeleteChunksOfUser(t *testing.T) {
+	ctx := context.Background()
+
+	weaviateClient, err := NewWeaviateClientTest(config.NewServerConfig().OPENAI_API_KEY)
+	if err != nil {
+		t.Fatalf("failed to create weaviate client: %v", err)
+	}
+
+	userID := uuid.New().String()
+	var chunks []storemodels.Chunk
+	for i := 0; i < 3; i++ {
+		chunks = append(chunks, storemodels.Chunk{
+			ChunkID:      uuid.New(),
+			UserID:       userID,
+			DocumentID:   uuid.New(),
+			ChunkContent: fmt.Sprintf("Chunk %d of the user", i),
+			ChunkIndex:   0,
+		})
+	}
+	defer weaviateClient.DeleteChunks(ctx, chunks)
+
+	err = weaviateClient.UploadChunks(ctx, chunks)
+	if err != nil {
+		t.Fatalf("UploadChunks failed: %v", err)
+	}
+	fetched, err := weaviateClient.GetChunks(ctx, chunks)
+	if err != nil || len(fetched) != len(chunks) {
+		t.Fatalf("GetChunks should return %d chunks, got %d: %v", len(chunks), len(fetched), err)
+	}
+	for i, chunk := range fetched {
+		if chunk.ChunkID != chunks[i].ChunkID || chunk.ChunkContent != chunks[i].ChunkContent {
+			t.Errorf("GetChunks should return the chunks in order, got %+v for %+v", chunk, chunks[i])
+		}
+	}
+
+	err = weaviateClient.DeleteChunksOfUser(ctx, userID)
+	if err != nil {
+		t.Fatalf("DeleteChunksOfUser failed: %v", err)
+	}
+	_, err = weaviateClient.GetChunks(ctx, chunks)
+	if err == nil {
+		t.Errorf("GetChunks should fail for deleted chunks")
+	}
+}
+
 func getTestChunks() []storemodels.Chunk {
 	documentID := uuid.New()
 	userID := uuid.New()
@@ -328,7 +383,9 @@ func getTestChunks() []storemodels.Chunk {
 }
 
 func TestSearchDocumentsByText(t *testing.T) {
-	weaviateClient, err := NewWeaviateClientTest()
+	ctx := context.Background()
+
+	weaviateClient, err := NewWeaviateClientTest(config.NewServerConfig().OPENAI_API_KEY)
 	if err != nil {
 		t.Fatalf("failed to create weaviate client: %v", err)
 	}
@@ -340,13 +397,13 @@ func TestSearchDocumentsByText(t *testing.T) {
 	if err != nil {
 		t.Errorf("setup failed: %v", err)
 	}
-	err = weaviateClient.UploadChunks(testChunks)
+	err = weaviateClient.UploadChunks(ctx, testChunks)
 	if err != nil {
 		t.Errorf("UploadChunks failed: %v", err)
 	}
 
 	defer func() {
-		if err := weaviateClient.DeleteChunks(testChunks); err != nil {
+		if err := weaviateClient.DeleteChunks(ctx, testChunks); err != nil {
 			t.Logf("teardown failed, potentially chunks already deleted: %v", err)
 		}
 	}()
@@ -356,7 +413,7 @@ func TestSearchDocumentsByText(t *testing.T) {
 
 	concepts := []string{"small animal that goes meow sometimes"}
 
-	result, err := weaviateClient.SearchDocumentsByText(top_k, userID, concepts)
+	result, err := weaviateClient.SearchDocumentsByText(ctx, top_k, SearchScope{UserID: userID}, concepts)
 	if err != nil {
 		t.Errorf("SearchDocumentsByText failed: %v", err)
 	}
@@ -372,7 +429,7 @@ func TestSearchDocumentsByText(t *testing.T) {
 	secondUserID := testChunks[5].UserID
 	concepts = []string{"small animal that goes meow sometimes"}
 
-	result, err = weaviateClient.SearchDocumentsByText(top_k, secondUserID, concepts)
+	result, err = weaviateClient.SearchDocumentsByText(ctx, top_k, SearchScope{UserID: secondUserID}, concepts)
 	if err != nil {
 		t.Errorf("SearchDocumentsByText failed: %v", err)
 	}
@@ -386,3 +443,150 @@ func TestSearchDocumentsByText(t *testing.T) {
 		fmt.Printf("Chunk: %+v\n", chunk)
 	}
 }
+
+func TestSearchDocumentsByTextInWorkspace(t *testing.T) {
+	ctx := context.Background()
+
+	weaviateClient, err := NewWeaviateClientTest(config.NewServerConfig().OPENAI_API_KEY)
+	if err != nil {
+		t.Fatalf("failed to create weaviate client: %v", err)
+	}
+
+	workspaceID := "org_" + uuid.New().String()
+	uploaderID := uuid.New().String()
+	memberID := uuid.New().String()
+
+	sharedChunk := storemodels.Chunk{
+		ChunkID:      uuid.New(),
+		UserID:       uploaderID,
+		WorkspaceID:  workspaceID,
+		DocumentID:   uuid.New(),
+		ChunkContent: "Cats are small animals that go meow.",
+		ChunkIndex:   0,
+	}
+	err = weaviateClient.UploadChunk(ctx, sharedChunk)
+	if err != nil {
+		t.Fatalf("UploadChunk failed: %v", err)
+	}
+	defer weaviateClient.DeleteChunk(ctx, sharedChunk.ChunkID)
+
+	concepts := []string{"small animal that goes meow sometimes"}
+
+	result, err := weaviateClient.SearchDocumentsByText(ctx, 3, SearchScope{UserID: memberID, WorkspaceIDs: []string{workspaceID}}, concepts)
+	if err != nil {
+		t.Fatalf("SearchDocumentsByText failed: %v", err)
+	}
+	if len(result) != 1 || result[0].ChunkID != sharedChunk.ChunkID || result[0].WorkspaceID != workspaceID {
+		t.Errorf("Expected the shared chunk to be found through the workspace, got %+v", result)
+	}
+
+	// The uploader only reaches shared chunks through a workspace membership
+	result, err = weaviateClient.SearchDocumentsByText(ctx, 3, SearchScope{UserID: uploaderID}, concepts)
+	if err != nil {
+		t.Fatalf("SearchDocumentsByText failed: %v", err)
+	}
+	if len(result) != 0 {
+		t.Errorf("Expected no results outside of the workspace, got %+v", result)
+	}
+}
+
+func TestSearchDocumentsByTextSharedDocument(t *testing.T) {
+	ctx := context.Background()
+
+	weaviateClient, err := NewWeaviateClientTest(config.NewServerConfig().OPENAI_API_KEY)
+	if err != nil {
+		t.Fatalf("failed to create weaviate client: %v", err)
+	}
+
+	ownerID := uuid.New().String()
+	granteeID := uuid.New().String()
+
+	sharedChunk := storemodels.Chunk{
+		ChunkID:      uuid.New(),
+		UserID:       ownerID,
+		DocumentID:   uuid.New(),
+		ChunkContent: "Cats are small animals that go meow.",
+		ChunkIndex:   0,
+	}
+	err = weaviateClient.UploadChunk(ctx, sharedChunk)
+	if err != nil {
+		t.Fatalf("UploadChunk failed: %v", err)
+	}
+	defer weaviateClient.DeleteChunk(ctx, sharedChunk.ChunkID)
+
+	concepts := []string{"small animal that goes meow sometimes"}
+
+	result, err := weaviateClient.SearchDocumentsByText(ctx, 3, SearchScope{UserID: granteeID}, concepts)
+	if err != nil {
+		t.Fatalf("SearchDocumentsByText failed: %v", err)
+	}
+	if len(result) != 0 {
+		t.Errorf("Expected no results without the shared document, got %+v", result)
+	}
+
+	scope := SearchScope{UserID: granteeID, DocumentIDs: []uuid.UUID{sharedChunk.DocumentID}}
+	result, err = weaviateClient.SearchDocumentsByText(ctx, 3, scope, concepts)
+	if err != nil {
+		t.Fatalf("SearchDocumentsByText failed: %v", err)
+	}
+	if len(result) != 1 || result[0].ChunkID != sharedChunk.ChunkID {
+		t.Errorf("Expected the shared chunk to be found, got %+v", result)
+	}
+}
+
+func TestSearchDocumentsByTextWithMetadataFilters(t *testing.T) {
+	ctx := context.Background()
+
+	weaviateClient, err := NewWeaviateClientTest(config.NewServerConfig().OPENAI_API_KEY)
+	if err != nil {
+		t.Fatalf("failed to create weaviate client: %v", err)
+	}
+
+	userID := uuid.New().String()
+	chunk := storemodels.Chunk{
+		ChunkID:      uuid.New(),
+		UserID:       userID,
+		DocumentID:   uuid.New(),
+		ChunkContent: "Cats are small animals that go meow.",
+		ChunkIndex:   0,
+		Metadata: storemodels.DocumentMetadata{
+			"author": "Ada",
+			"pages":  float64(12),
+		},
+	}
+	err = weaviateClient.UploadChunk(ctx, chunk)
+	if err != nil {
+		t.Fatalf("UploadChunk failed: %v", err)
+	}
+	defer weaviateClient.DeleteChunk(ctx, chunk.ChunkID)
+
+	concepts := []string{"small animal that goes meow sometimes"}
+	search := func(metadataFilters ...storemodels.MetadataFilter) []storemodels.ChunkFromVectorSearch {
+		result, err := weaviateClient.SearchDocumentsByText(ctx, 3, SearchScope{UserID: userID, MetadataFilters: metadataFilters}, concepts)
+		if err != nil {
+			t.Fatalf("SearchDocumentsByText failed: %v", err)
+		}
+		return result
+	}
+
+	if result := search(storemodels.MetadataFilter{Field: "author", Operator: storemodels.MetadataOpEqual, Value: "Ada"}); len(result) != 1 {
+		t.Errorf("Expected the chunk to match on author, got %+v", result)
+	}
+	if result := search(storemodels.MetadataFilter{Field: "pages", Operator: storemodels.MetadataOpGreaterThanOrEqual, Value: float64(20)}); len(result) != 0 {
+		t.Errorf("Expected no chunk with at least 20 pages, got %+v", result)
+	}
+	if result := search(storemodels.MetadataFilter{Field: "unknownField", Operator: storemodels.MetadataOpEqual, Value: "x"}); len(result) != 0 {
+		t.Errorf("Expected no chunk for an unknown field, got %+v", result)
+	}
+
+	err = weaviateClient.UploadChunk(ctx, storemodels.Chunk{
+		ChunkID:      uuid.New(),
+		UserID:       userID,
+		DocumentID:   uuid.New(),
+		ChunkContent: "Dogs bark.",
+		Metadata:     storemodels.DocumentMetadata{"pages": "twelve"},
+	})
+	if err == nil {
+		t.Errorf("Expected an error for a metadata value of another type")
+	}
+}
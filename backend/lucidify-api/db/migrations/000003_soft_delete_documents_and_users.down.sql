@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_users_deleted_at;
+DROP INDEX IF EXISTS idx_documents_deleted_at;
+DROP INDEX IF EXISTS idx_documents_user_id_document_name_active;
+
+-- Trashed documents are removed so the original unique constraint can be restored
+DELETE FROM documents WHERE deleted_at IS NOT NULL;
+ALTER TABLE documents ADD CONSTRAINT documents_user_id_document_name_key UNIQUE (user_id, document_name);
+
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE documents DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,11 @@
+-- Soft delete: rows stay in place with deleted_at set until the purger removes them
+ALTER TABLE documents ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
+
+-- A trashed document must not block uploading a new document with the same name
+ALTER TABLE documents DROP CONSTRAINT IF EXISTS documents_user_id_document_name_key;
+CREATE UNIQUE INDEX idx_documents_user_id_document_name_active ON documents(user_id, document_name) WHERE deleted_at IS NULL;
+
+-- The purger looks up expired rows by deleted_at
+CREATE INDEX idx_documents_deleted_at ON documents(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
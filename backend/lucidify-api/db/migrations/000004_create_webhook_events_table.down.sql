@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_webhook_events_dead;
+DROP INDEX IF EXISTS idx_webhook_events_pending;
+DROP TABLE IF EXISTS webhook_events;
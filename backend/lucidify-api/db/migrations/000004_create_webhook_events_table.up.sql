@@ -0,0 +1,17 @@
+-- Every verified Svix delivery is stored before it is processed, keyed by the
+-- svix-id header so redeliveries of the same event are ignored.
+CREATE TABLE webhook_events (
+    svix_id VARCHAR(255) PRIMARY KEY,
+    event_type VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(32) NOT NULL DEFAULT 'pending', -- pending, processed or dead
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    received_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    next_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP
+);
+
+-- The worker polls for pending events that are due
+CREATE INDEX idx_webhook_events_pending ON webhook_events(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_events_dead ON webhook_events(received_at) WHERE status = 'dead';
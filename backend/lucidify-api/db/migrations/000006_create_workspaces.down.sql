@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_documents_workspace_id_document_name_active;
+DROP INDEX IF EXISTS idx_documents_user_id_document_name_active;
+
+-- Shared documents cannot be represented once workspaces are gone
+DELETE FROM documents WHERE workspace_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_documents_user_id_document_name_active ON documents(user_id, document_name) WHERE deleted_at IS NULL;
+
+DROP INDEX IF EXISTS idx_documents_workspace_id;
+ALTER TABLE documents DROP COLUMN IF EXISTS workspace_id;
+
+DROP INDEX IF EXISTS idx_workspace_memberships_user_id;
+DROP TABLE IF EXISTS workspace_memberships;
+DROP TABLE IF EXISTS workspaces;
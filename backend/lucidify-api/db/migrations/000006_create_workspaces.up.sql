@@ -0,0 +1,30 @@
+-- Workspaces mirror Clerk organizations and share their documents between
+-- members. Documents without a workspace stay private to their user.
+CREATE TABLE workspaces (
+    workspace_id VARCHAR(255) PRIMARY KEY, -- The Clerk organization id
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(255),
+    created_by VARCHAR(255),
+    created_at BIGINT,
+    updated_at BIGINT
+);
+
+CREATE TABLE workspace_memberships (
+    workspace_id VARCHAR(255) NOT NULL REFERENCES workspaces(workspace_id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    role VARCHAR(32) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    created_at BIGINT,
+    updated_at BIGINT,
+    PRIMARY KEY (workspace_id, user_id)
+);
+
+CREATE INDEX idx_workspace_memberships_user_id ON workspace_memberships(user_id);
+
+-- user_id remains the uploader of a workspace document
+ALTER TABLE documents ADD COLUMN workspace_id VARCHAR(255) REFERENCES workspaces(workspace_id) ON DELETE CASCADE;
+CREATE INDEX idx_documents_workspace_id ON documents(workspace_id) WHERE workspace_id IS NOT NULL;
+
+-- Names are unique per user for private documents and per workspace for shared ones
+DROP INDEX IF EXISTS idx_documents_user_id_document_name_active;
+CREATE UNIQUE INDEX idx_documents_user_id_document_name_active ON documents(user_id, document_name) WHERE deleted_at IS NULL AND workspace_id IS NULL;
+CREATE UNIQUE INDEX idx_documents_workspace_id_document_name_active ON documents(workspace_id, document_name) WHERE deleted_at IS NULL AND workspace_id IS NOT NULL;
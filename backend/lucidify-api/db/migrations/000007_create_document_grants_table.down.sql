@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_document_grants_grantee_user_id;
+DROP INDEX IF EXISTS idx_document_grants_document_id_grantee_user_id;
+DROP INDEX IF EXISTS idx_document_grants_document_id;
+DROP TABLE IF EXISTS document_grants;
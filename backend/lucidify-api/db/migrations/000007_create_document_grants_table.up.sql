@@ -0,0 +1,20 @@
+-- Grants give access to a single document, either to another user or to
+-- anyone holding a link token. Only the SHA-256 hash of a link token is stored.
+CREATE TABLE document_grants (
+    grant_id UUID DEFAULT uuid_generate_v4() PRIMARY KEY,
+    document_id UUID NOT NULL REFERENCES documents(document_id) ON DELETE CASCADE,
+    grantee_user_id VARCHAR(255) REFERENCES users(user_id) ON DELETE CASCADE,
+    link_token_hash CHAR(64) UNIQUE,
+    permission VARCHAR(16) NOT NULL CHECK (permission IN ('read', 'write')),
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP,
+    CHECK ((grantee_user_id IS NULL) <> (link_token_hash IS NULL)),
+    -- Links are read-only
+    CHECK (link_token_hash IS NULL OR permission = 'read')
+);
+
+CREATE INDEX idx_document_grants_document_id ON document_grants(document_id);
+-- Sharing a document with a user again replaces the previous grant
+CREATE UNIQUE INDEX idx_document_grants_document_id_grantee_user_id ON document_grants(document_id, grantee_user_id) WHERE grantee_user_id IS NOT NULL;
+CREATE INDEX idx_document_grants_grantee_user_id ON document_grants(grantee_user_id) WHERE grantee_user_id IS NOT NULL;
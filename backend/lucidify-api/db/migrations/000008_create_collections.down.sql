@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_collection_documents_document_id;
+DROP TABLE IF EXISTS collection_documents;
+DROP TABLE IF EXISTS collections;
@@ -0,0 +1,19 @@
+-- Collections are user-defined tags. A document may be in any number of the
+-- user's collections, and a collection may hold any document the user can read.
+CREATE TABLE collections (
+    collection_id UUID DEFAULT uuid_generate_v4() PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE collection_documents (
+    collection_id UUID NOT NULL REFERENCES collections(collection_id) ON DELETE CASCADE,
+    document_id UUID NOT NULL REFERENCES documents(document_id) ON DELETE CASCADE,
+    added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (collection_id, document_id)
+);
+
+CREATE INDEX idx_collection_documents_document_id ON collection_documents(document_id);
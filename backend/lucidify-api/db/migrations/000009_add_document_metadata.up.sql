@@ -0,0 +1,4 @@
+-- User-defined metadata of a document, such as its source URL, author or date.
+-- It is copied onto the chunks in Weaviate so that retrieval can filter on it.
+ALTER TABLE documents ADD COLUMN metadata JSONB NOT NULL DEFAULT '{}';
+CREATE INDEX idx_documents_metadata ON documents USING GIN (metadata);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_documents_workspace_id_updated_at_active;
+DROP INDEX IF EXISTS idx_documents_workspace_id_created_at_active;
+DROP INDEX IF EXISTS idx_documents_workspace_id_name_active;
+DROP INDEX IF EXISTS idx_documents_user_id_updated_at_active;
+DROP INDEX IF EXISTS idx_documents_user_id_created_at_active;
+DROP INDEX IF EXISTS idx_documents_user_id_name_active;
@@ -0,0 +1,7 @@
+-- Keyset pagination of document listings, for each sort order
+CREATE INDEX idx_documents_user_id_name_active ON documents(user_id, document_name, document_id) WHERE workspace_id IS NULL AND deleted_at IS NULL;
+CREATE INDEX idx_documents_user_id_created_at_active ON documents(user_id, created_at, document_id) WHERE workspace_id IS NULL AND deleted_at IS NULL;
+CREATE INDEX idx_documents_user_id_updated_at_active ON documents(user_id, updated_at, document_id) WHERE workspace_id IS NULL AND deleted_at IS NULL;
+CREATE INDEX idx_documents_workspace_id_name_active ON documents(workspace_id, document_name, document_id) WHERE workspace_id IS NOT NULL AND deleted_at IS NULL;
+CREATE INDEX idx_documents_workspace_id_created_at_active ON documents(workspace_id, created_at, document_id) WHERE workspace_id IS NOT NULL AND deleted_at IS NULL;
+CREATE INDEX idx_documents_workspace_id_updated_at_active ON documents(workspace_id, updated_at, document_id) WHERE workspace_id IS NOT NULL AND deleted_at IS NULL;
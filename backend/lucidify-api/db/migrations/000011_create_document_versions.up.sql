@@ -0,0 +1,14 @@
+-- Previous contents of documents. A version is recorded each time the content
+-- of a document is replaced, numbered from 1 for each document.
+CREATE TABLE document_versions (
+    document_id UUID NOT NULL REFERENCES documents(document_id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    document_name VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    metadata JSONB NOT NULL DEFAULT '{}',
+    -- When the content was written, and when and by whom it was replaced
+    created_at TIMESTAMP NOT NULL,
+    replaced_by VARCHAR(255) REFERENCES users(user_id) ON DELETE SET NULL,
+    replaced_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (document_id, version)
+);
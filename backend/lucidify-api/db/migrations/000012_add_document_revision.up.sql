@@ -0,0 +1,3 @@
+-- Incremented by every change of the name, content or metadata of a document,
+-- to detect concurrent edits
+ALTER TABLE documents ADD COLUMN revision BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_documents_workspace_id_content_hash_active;
+DROP INDEX IF EXISTS idx_documents_user_id_content_hash_active;
+ALTER TABLE documents DROP COLUMN IF EXISTS content_hash;
+ALTER TABLE document_chunks DROP COLUMN IF EXISTS content_hash;
@@ -0,0 +1,11 @@
+-- SHA-256 of the content, in hex, to find duplicate documents and to reuse the
+-- embedding of identical chunks
+ALTER TABLE documents ADD COLUMN content_hash CHAR(64);
+UPDATE documents SET content_hash = encode(sha256(convert_to(content, 'UTF8')), 'hex');
+ALTER TABLE documents ALTER COLUMN content_hash SET NOT NULL;
+CREATE INDEX idx_documents_user_id_content_hash_active ON documents(user_id, content_hash) WHERE workspace_id IS NULL AND deleted_at IS NULL;
+CREATE INDEX idx_documents_workspace_id_content_hash_active ON documents(workspace_id, content_hash) WHERE workspace_id IS NOT NULL AND deleted_at IS NULL;
+
+ALTER TABLE document_chunks ADD COLUMN content_hash CHAR(64);
+UPDATE document_chunks SET content_hash = encode(sha256(convert_to(chunk_content, 'UTF8')), 'hex');
+ALTER TABLE document_chunks ALTER COLUMN content_hash SET NOT NULL;
@@ -0,0 +1,9 @@
+-- Embeddings by model and SHA-256 of the embedded text, so that the same text
+-- is only sent to the embedding model once
+CREATE TABLE embedding_cache (
+    model VARCHAR(255) NOT NULL,
+    text_hash CHAR(64) NOT NULL,
+    vector REAL[] NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (model, text_hash)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE document_chunks DROP CONSTRAINT document_chunks_user_id_document_id_fkey;
+ALTER TABLE document_chunks ADD CONSTRAINT document_chunks_user_id_document_id_fkey
+    FOREIGN KEY (user_id, document_id) REFERENCES documents(user_id, document_id) ON DELETE CASCADE;
@@ -0,0 +1,5 @@
+-- Chunks follow their document when it is handed over to another user, as
+-- the workspace documents of a deleted user are
+ALTER TABLE document_chunks DROP CONSTRAINT document_chunks_user_id_document_id_fkey;
+ALTER TABLE document_chunks ADD CONSTRAINT document_chunks_user_id_document_id_fkey
+    FOREIGN KEY (user_id, document_id) REFERENCES documents(user_id, document_id) ON UPDATE CASCADE ON DELETE CASCADE;
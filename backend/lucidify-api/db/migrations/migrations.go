@@ -0,0 +1,9 @@
+// Package migrations embeds the SQL migrations of the PostgreSQL schema, so
+// that the binary knows the schema version it expects. Files follow the
+// naming of golang-migrate: 000001_name.up.sql and 000001_name.down.sql.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
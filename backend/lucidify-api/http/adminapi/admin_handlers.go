@@ -0,0 +1,105 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"lucidify-api/service/embeddingservice"
+	"lucidify-api/service/webhookservice"
+	"net/http"
+)
+
+func DeadWebhookEventsHandler(webhookService webhookservice.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		events, err := webhookService.GetDeadEvents(ctx)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to get dead webhook events", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		encoder := json.NewEncoder(w)
+		err = encoder.Encode(events)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to encode webhook events as JSON", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func ReplayWebhookEventHandler(webhookService webhookservice.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var reqBody map[string]string
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&reqBody)
+		if err != nil || reqBody["svix_id"] == "" {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		err = webhookService.ReplayEvent(ctx, reqBody["svix_id"])
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to replay webhook event", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// EmbeddingCacheHandler returns the hit and miss counters of the embedding
+// cache on GET, and purges the cached embeddings of the model given in the
+// model query parameter on DELETE.
+func EmbeddingCacheHandler(embeddingService embeddingservice.EmbeddingService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+
+			encoder := json.NewEncoder(w)
+			err := encoder.Encode(embeddingService.Stats())
+			if err != nil {
+				http.Error(w, "Internal server error. Unable to encode embedding cache stats as JSON", http.StatusInternalServerError)
+				return
+			}
+		case http.MethodDelete:
+			model := r.URL.Query().Get("model")
+			if model == "" {
+				http.Error(w, "Bad request. model is required", http.StatusBadRequest)
+				return
+			}
+
+			purged, err := embeddingService.PurgeModel(ctx, model)
+			if err != nil {
+				http.Error(w, "Internal server error. Unable to purge embedding cache", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+
+			encoder := json.NewEncoder(w)
+			err = encoder.Encode(map[string]interface{}{"model": model, "purged": purged})
+			if err != nil {
+				http.Error(w, "Internal server error. Unable to encode purge result as JSON", http.StatusInternalServerError)
+				return
+			}
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package adminapi
+
+import (
+	"lucidify-api/server/config"
+	"lucidify-api/server/middleware"
+	"lucidify-api/service/embeddingservice"
+	"lucidify-api/service/webhookservice"
+	"net/http"
+)
+
+func SetupRoutes(config *config.ServerConfig, mux *http.ServeMux, webhookService webhookservice.WebhookService, embeddingService embeddingservice.EmbeddingService) *http.ServeMux {
+	mux = SetupDeadWebhookEventsHandler(config, mux, webhookService)
+	mux = SetupReplayWebhookEventHandler(config, mux, webhookService)
+	mux = SetupEmbeddingCacheHandler(config, mux, embeddingService)
+
+	return mux
+}
+
+func SetupDeadWebhookEventsHandler(config *config.ServerConfig, mux *http.ServeMux, webhookService webhookservice.WebhookService) *http.ServeMux {
+	handler := DeadWebhookEventsHandler(webhookService)
+
+	handler = middleware.AdminAuthenticationMiddleware(config)(handler)
+	handler = middleware.Logging(handler)
+
+	mux.HandleFunc("/admin/webhook_events/dead", handler)
+
+	return mux
+}
+
+func SetupReplayWebhookEventHandler(config *config.ServerConfig, mux *http.ServeMux, webhookService webhookservice.WebhookService) *http.ServeMux {
+	handler := ReplayWebhookEventHandler(webhookService)
+
+	handler = middleware.AdminAuthenticationMiddleware(config)(handler)
+	handler = middleware.Logging(handler)
+
+	mux.HandleFunc("/admin/webhook_events/replay", handler)
+
+	return mux
+}
+
+func SetupEmbeddingCacheHandler(config *config.ServerConfig, mux *http.ServeMux, embeddingService embeddingservice.EmbeddingService) *http.ServeMux {
+	handler := EmbeddingCacheHandler(embeddingService)
+
+	handler = middleware.AdminAuthenticationMiddleware(config)(handler)
+	handler = middleware.Logging(handler)
+
+	mux.HandleFunc("/admin/embedding_cache", handler)
+
+	return mux
+}
@@ -0,0 +1,69 @@
+// Package apierror writes the error responses of the versioned API. Every
+// error has the same JSON body, so clients can handle them in one place:
+//
+//	{"error": {"code": "not_found", "message": "document not found"}}
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Codes of the errors, stable across releases unlike the messages.
+const (
+	CodeBadRequest         = "bad_request"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeConflict           = "conflict"
+	CodePreconditionFailed = "precondition_failed"
+	CodeInternal           = "internal_error"
+)
+
+type Envelope struct {
+	Error Error `json:"error"`
+}
+
+// Error describes what went wrong. Details are specific to the code, for
+// example the current state of a resource that failed a precondition.
+type Error struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Write responds with the given status and an error envelope.
+func Write(w http.ResponseWriter, status int, code, message string) {
+	WriteDetails(w, status, code, message, nil)
+}
+
+func WriteDetails(w http.ResponseWriter, status int, code, message string, details interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Envelope{Error: Error{Code: code, Message: message, Details: details}})
+}
+
+func BadRequest(w http.ResponseWriter, message string) {
+	Write(w, http.StatusBadRequest, CodeBadRequest, message)
+}
+
+func NotFound(w http.ResponseWriter, message string) {
+	Write(w, http.StatusNotFound, CodeNotFound, message)
+}
+
+// MethodNotAllowed lists the allowed methods in the Allow header, as required
+// for a 405.
+func MethodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	for _, method := range allowed {
+		w.Header().Add("Allow", method)
+	}
+	Write(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+}
+
+// Internal hides the cause of the error from the client, it should be logged
+// by the caller instead.
+func Internal(w http.ResponseWriter, message string) {
+	Write(w, http.StatusInternalServerError, CodeInternal, message)
+}
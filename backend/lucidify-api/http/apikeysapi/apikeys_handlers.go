@@ -0,0 +1,154 @@
+package apikeysapi
+
+import (
+	"encoding/json"
+	"errors"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/auth"
+	"lucidify-api/service/apikeyservice"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type createAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type createAPIKeyResponse struct {
+	Key    string              `json:"key"`
+	APIKey *storemodels.APIKey `json:"api_key"`
+}
+
+// sessionPrincipal returns the principal of a request made with a browser
+// session. API keys cannot be used to manage API keys, so that a leaked key
+// cannot be used to mint new ones.
+func sessionPrincipal(w http.ResponseWriter, r *http.Request) (*auth.Principal, bool) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return nil, false
+	}
+	if principal.APIKeyID != "" {
+		http.Error(w, "Forbidden. API keys cannot be managed with an API key", http.StatusForbidden)
+		return nil, false
+	}
+	return principal, true
+}
+
+func CreateAPIKeyHandler(apiKeyService apikeyservice.APIKeyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		principal, ok := sessionPrincipal(w, r)
+		if !ok {
+			return
+		}
+
+		var reqBody createAPIKeyRequest
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&reqBody)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		key, apiKey, err := apiKeyService.CreateAPIKey(ctx, principal.UserID, reqBody.Name, reqBody.Scopes, reqBody.ExpiresAt)
+		if err != nil {
+			http.Error(w, "Bad request. "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+
+		encoder := json.NewEncoder(w)
+		err = encoder.Encode(createAPIKeyResponse{Key: key, APIKey: apiKey})
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to encode api key as JSON", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func GetAPIKeysHandler(apiKeyService apikeyservice.APIKeyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		principal, ok := sessionPrincipal(w, r)
+		if !ok {
+			return
+		}
+
+		apiKeys, err := apiKeyService.GetAPIKeys(ctx, principal.UserID)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to get api keys", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		encoder := json.NewEncoder(w)
+		err = encoder.Encode(apiKeys)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to encode api keys as JSON", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func RevokeAPIKeyHandler(apiKeyService apikeyservice.APIKeyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		principal, ok := sessionPrincipal(w, r)
+		if !ok {
+			return
+		}
+
+		var reqBody map[string]string
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&reqBody)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		apiKeyID, err := uuid.Parse(reqBody["api_key_id"])
+		if err != nil {
+			http.Error(w, "Bad request. Invalid api_key_id", http.StatusBadRequest)
+			return
+		}
+
+		err = apiKeyService.RevokeAPIKey(ctx, principal.UserID, apiKeyID)
+		if errors.Is(err, apikeyservice.ErrAPIKeyNotFound) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to revoke api key", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,30 @@
+package apikeysapi
+
+import (
+	"lucidify-api/server/auth"
+	"lucidify-api/server/config"
+	"lucidify-api/server/middleware"
+	"lucidify-api/service/apikeyservice"
+	"lucidify-api/service/userservice"
+	"net/http"
+)
+
+func SetupRoutes(config *config.ServerConfig, mux *http.ServeMux, apiKeyService apikeyservice.APIKeyService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+	mux = setupHandler(config, mux, "/api_keys/create", CreateAPIKeyHandler(apiKeyService), userService, authenticator)
+	mux = setupHandler(config, mux, "/api_keys/list", GetAPIKeysHandler(apiKeyService), userService, authenticator)
+	mux = setupHandler(config, mux, "/api_keys/revoke", RevokeAPIKeyHandler(apiKeyService), userService, authenticator)
+
+	return mux
+}
+
+func setupHandler(config *config.ServerConfig, mux *http.ServeMux, pattern string, handler http.HandlerFunc, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+
+	mux.Handle(pattern, authenticate(handler))
+
+	return mux
+}
@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/auth"
 	"lucidify-api/service/chatservice"
 	"net/http"
 
-	"github.com/clerkinc/clerk-sdk-go/clerk"
+	"github.com/google/uuid"
 )
 
 type ServerResponse struct {
@@ -30,13 +32,40 @@ type Message struct {
 	Content string `json:"content"`
 }
 
+// RetrievalRequest holds the fields of chat and search requests that select
+// the documents to search.
+type RetrievalRequest struct {
+	IncludeShared bool                         `json:"include_shared"`
+	CollectionID  *uuid.UUID                   `json:"collection_id"`
+	DocumentIDs   []uuid.UUID                  `json:"document_ids"`
+	Filters       []storemodels.MetadataFilter `json:"filters"`
+}
+
+func (r RetrievalRequest) validate() error {
+	for _, filter := range r.Filters {
+		if err := filter.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r RetrievalRequest) options() chatservice.RetrievalOptions {
+	return chatservice.RetrievalOptions{
+		IncludeShared:   r.IncludeShared,
+		CollectionID:    r.CollectionID,
+		DocumentIDs:     r.DocumentIDs,
+		MetadataFilters: r.Filters,
+	}
+}
+
 type ChatResponse struct {
 	Status  string      `json:"status"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"` // Use this field to include any data in the case of success
 }
 
-func ChatHandler(clerkInstance clerk.Client, cvs chatservice.ChatVectorService) http.HandlerFunc {
+func ChatHandler(cvs chatservice.ChatVectorService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -46,31 +75,31 @@ func ChatHandler(clerkInstance clerk.Client, cvs chatservice.ChatVectorService)
 		// How to get currently active user id from clerk
 		ctx := r.Context()
 
-		sessClaims, ok := ctx.Value(clerk.ActiveSessionClaims).(*clerk.SessionClaims)
+		principal, ok := auth.PrincipalFromContext(ctx)
 		if !ok {
 			w.WriteHeader(http.StatusUnauthorized)
 			w.Write([]byte("Unauthorized"))
 			return
 		}
 
-		user, err := clerkInstance.Users().Read(sessClaims.Claims.Subject)
-		if err != nil {
-			panic(err)
-		}
-
 		var reqBody struct {
 			Messages []Message `json:"messages"`
+			RetrievalRequest
 		}
 
 		decoder := json.NewDecoder(r.Body)
-		err = decoder.Decode(&reqBody)
-		if err != nil {
+		err := decoder.Decode(&reqBody)
+		if err != nil || len(reqBody.Messages) == 0 {
 			http.Error(w, "Bad request", http.StatusBadRequest)
 			return
 		}
+		if err := reqBody.validate(); err != nil {
+			http.Error(w, "Bad request. "+err.Error(), http.StatusBadRequest)
+			return
+		}
 
 		log.Printf("User prompt: %s\n", reqBody.Messages)
-		// systemPromptFromVecSearch, err := cvs.ConstructSystemMessage(reqBody.Messages[len(reqBody.Messages)-1].Content, user.ID)
+		// systemPromptFromVecSearch, err := cvs.ConstructSystemMessage(reqBody.Messages[len(reqBody.Messages)-1].Content, principal.UserID)
 		// if err != nil {
 		// 	http.Error(w, "Internal server error", http.StatusInternalServerError)
 		// 	return
@@ -92,7 +121,7 @@ func ChatHandler(clerkInstance clerk.Client, cvs chatservice.ChatVectorService)
 		// Create a response object
 		response := ChatResponse{}
 
-		systemPromptFromVecSearch, err := cvs.ConstructSystemMessage(reqBody.Messages[len(reqBody.Messages)-1].Content, user.ID)
+		systemPromptFromVecSearch, err := cvs.ConstructSystemMessage(ctx, reqBody.Messages[len(reqBody.Messages)-1].Content, principal.UserID, reqBody.options())
 		if err != nil {
 			// Handle the failure by setting the response fields accordingly
 			response.Status = "fail"
@@ -120,3 +149,63 @@ func ChatHandler(clerkInstance clerk.Client, cvs chatservice.ChatVectorService)
 		}
 	}
 }
+
+// SearchHandler returns defaultLimit chunks unless the request asks for
+// another number, up to maxLimit.
+func SearchHandler(cvs chatservice.ChatVectorService, defaultLimit int, maxLimit int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := r.Context()
+
+		principal, ok := auth.PrincipalFromContext(ctx)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+			return
+		}
+
+		var reqBody struct {
+			Query string `json:"query"`
+			Limit int    `json:"limit"`
+			RetrievalRequest
+		}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&reqBody)
+		if err != nil || reqBody.Query == "" {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		if err := reqBody.validate(); err != nil {
+			http.Error(w, "Bad request. "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if reqBody.Limit <= 0 {
+			reqBody.Limit = defaultLimit
+		}
+		if reqBody.Limit > maxLimit {
+			reqBody.Limit = maxLimit
+		}
+
+		results, err := cvs.Search(ctx, reqBody.Query, principal.UserID, reqBody.Limit, reqBody.options())
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to search documents", http.StatusInternalServerError)
+			return
+		}
+
+		response := ChatResponse{
+			Status:  "success",
+			Message: "Search completed successfully",
+			Data:    results,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Internal server error. Unable to encode search results as JSON", http.StatusInternalServerError)
+		}
+	}
+}
@@ -1,21 +1,24 @@
 package chatapi
 
 import (
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/auth"
 	"lucidify-api/server/config"
 	"lucidify-api/server/middleware"
 	"lucidify-api/service/chatservice"
+	"lucidify-api/service/userservice"
 	"net/http"
-
-	"github.com/clerkinc/clerk-sdk-go/clerk"
 )
 
 func SetupRoutes(
 	config *config.ServerConfig,
 	mux *http.ServeMux,
 	cvs chatservice.ChatVectorService,
-	clerkInstance clerk.Client) *http.ServeMux {
+	userService userservice.UserService,
+	authenticator auth.Authenticator) *http.ServeMux {
 
-	mux = SetupChatHandler(config, mux, cvs, clerkInstance)
+	mux = SetupChatHandler(config, mux, cvs, userService, authenticator)
+	mux = SetupSearchHandler(config, mux, cvs, userService, authenticator)
 
 	return mux
 }
@@ -26,16 +29,39 @@ func SetupChatHandler(
 	config *config.ServerConfig,
 	mux *http.ServeMux,
 	cvs chatservice.ChatVectorService,
-	clerkInstance clerk.Client) *http.ServeMux {
+	userService userservice.UserService,
+	authenticator auth.Authenticator) *http.ServeMux {
 
-	handler := ChatHandler(clerkInstance, cvs)
+	handler := ChatHandler(cvs)
 
-	injectActiveSession := clerk.WithSession(clerkInstance)
+	authenticate := auth.Middleware(authenticator)
 
 	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeChat)(handler)
 
-	mux.Handle("/api/chat/vector-search", injectActiveSession(handler))
+	mux.Handle("/api/chat/vector-search", authenticate(handler))
 	// mux.Handle("/api/sync", handler)
 
 	return mux
 }
+
+func SetupSearchHandler(
+	config *config.ServerConfig,
+	mux *http.ServeMux,
+	cvs chatservice.ChatVectorService,
+	userService userservice.UserService,
+	authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := SearchHandler(cvs, config.SearchDefaultLimit, config.SearchMaxLimit)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsRead)(handler)
+
+	mux.Handle("/api/search", authenticate(handler))
+
+	return mux
+}
@@ -4,6 +4,7 @@ package chatapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"log"
@@ -15,17 +16,20 @@ import (
 	"lucidify-api/data/store/postgresqlclient"
 	"lucidify-api/data/store/storemodels"
 	"lucidify-api/data/store/weaviateclient"
+	"lucidify-api/server/auth"
+	"lucidify-api/server/auth/authtest"
 	"lucidify-api/server/config"
 	"lucidify-api/service/chatservice"
-	"lucidify-api/service/clerkservice"
+	"lucidify-api/service/collectionservice"
 	"lucidify-api/service/documentservice"
 	"lucidify-api/service/userservice"
+	"lucidify-api/service/workspaceservice"
 
-	"github.com/clerkinc/clerk-sdk-go/clerk"
 	"github.com/sashabaranov/go-openai"
 )
 
 func createTestUserInDb(cfg *config.ServerConfig, db *postgresqlclient.PostgreSQL) error {
+	ctx := context.Background()
 	// the user id registered by the jwt token must exist in the local database
 	user := storemodels.User{
 		UserID:           cfg.TestUserID,
@@ -42,7 +46,7 @@ func createTestUserInDb(cfg *config.ServerConfig, db *postgresqlclient.PostgreSQ
 		UpdatedAt:        1654012591514,
 	}
 
-	weaviate, err := weaviateclient.NewWeaviateClientTest()
+	weaviate, err := weaviateclient.NewWeaviateClientTest(config.NewServerConfig().OPENAI_API_KEY)
 	if err != nil {
 		log.Fatalf("Failed to create WeaviateClient: %v", err)
 	}
@@ -51,21 +55,21 @@ func createTestUserInDb(cfg *config.ServerConfig, db *postgresqlclient.PostgreSQ
 		log.Fatalf("Failed to create UserService: %v", err)
 	}
 
-	err = userService.DeleteUser(user.UserID)
+	err = userService.DeleteUser(ctx, user.UserID)
 	if err != nil {
 		log.Fatalf("Failed to delete user: %v", err)
 	}
-	if !userService.HasUserBeenDeleted(user.UserID, 3) {
+	if !userService.HasUserBeenDeleted(ctx, user.UserID, 3) {
 		log.Fatalf("Failed to delete user: %v", err)
 	}
 
-	err = userService.CreateUser(user)
+	err = userService.CreateUser(ctx, user)
 	if err != nil {
 		log.Fatalf("Failed to create user: %v", err)
 	}
 
 	// Check if the user exists
-	_, err = userService.GetUserWithRetries(user.UserID, 3)
+	_, err = userService.GetUserWithRetries(ctx, user.UserID, 3)
 	if err != nil {
 		log.Fatalf("User not found after creation: %v", err)
 	}
@@ -74,32 +78,46 @@ func createTestUserInDb(cfg *config.ServerConfig, db *postgresqlclient.PostgreSQ
 }
 
 type TestSetup struct {
-	Config        *config.ServerConfig
-	PostgresqlDB  *postgresqlclient.PostgreSQL
-	ClerkInstance clerk.Client
-	Weaviate      weaviateclient.WeaviateClient
-	DocService    documentservice.DocumentService
+	Config          *config.ServerConfig
+	PostgresqlDB    *postgresqlclient.PostgreSQL
+	Authenticator   auth.Authenticator
+	JWTSessionToken string
+	Weaviate        weaviateclient.WeaviateClient
+	DocService      documentservice.DocumentService
+	UserService     userservice.UserService
 }
 
 func SetupTestEnvironment(t *testing.T) *TestSetup {
 	cfg := config.NewServerConfig()
 
-	postgresqlDB, err := postgresqlclient.NewPostgreSQL()
+	postgresqlDB, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
 	if err != nil {
 		t.Fatalf("Failed to create test postgresqlclient: %v", err)
 	}
 
-	clerkInstance, err := clerkservice.NewClerkClient()
+	// Sessions are signed by a local test issuer instead of a Clerk tenant
+	issuer, err := authtest.NewIssuer()
 	if err != nil {
-		t.Fatalf("Failed to create Clerk client: %v", err)
+		t.Fatalf("Failed to create test token issuer: %v", err)
 	}
+	t.Cleanup(issuer.Close)
 
-	weaviate, err := weaviateclient.NewWeaviateClientTest()
+	jwtSessionToken, err := issuer.Token(cfg.TestUserID)
+	if err != nil {
+		t.Fatalf("Failed to create session token: %v", err)
+	}
+
+	weaviate, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
 	if err != nil {
 		t.Fatalf("Failed to create WeaviateClient: %v", err)
 	}
 
-	docService := documentservice.NewDocumentService(postgresqlDB, weaviate)
+	docService := documentservice.NewDocumentService(postgresqlDB, weaviate, documentservice.ChunkerOptions{URL: cfg.AI_API_URL, APIKey: cfg.X_AI_API_KEY})
+
+	userService, err := userservice.NewUserService(postgresqlDB, weaviate)
+	if err != nil {
+		t.Fatalf("Failed to create UserService: %v", err)
+	}
 
 	err = createTestUserInDb(cfg, postgresqlDB)
 	if err != nil {
@@ -107,29 +125,35 @@ func SetupTestEnvironment(t *testing.T) *TestSetup {
 	}
 
 	return &TestSetup{
-		Config:        cfg,
-		PostgresqlDB:  postgresqlDB,
-		ClerkInstance: clerkInstance,
-		Weaviate:      weaviate,
-		DocService:    docService,
+		Config:          cfg,
+		PostgresqlDB:    postgresqlDB,
+		Authenticator:   issuer.Authenticator(),
+		JWTSessionToken: jwtSessionToken,
+		Weaviate:        weaviate,
+		DocService:      docService,
+		UserService:     userService,
 	}
 }
 
 func TestChatHandlerIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 	openaiClient := openai.NewClient(cfg.OPENAI_API_KEY)
 	documentService := setup.DocService
-	chatVectorService := chatservice.NewChatVectorService(setup.Weaviate, openaiClient, documentService)
+	workspaceService := workspaceservice.NewWorkspaceService(setup.PostgresqlDB, documentService)
+	collectionService := collectionservice.NewCollectionService(setup.PostgresqlDB, documentService)
+	chatVectorService := chatservice.NewChatVectorService(setup.Weaviate, openaiClient, documentService, workspaceService, collectionService, cfg.ChatContextChunks)
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, chatVectorService, clerkInstance)
+	SetupRoutes(cfg, mux, chatVectorService, setup.UserService, authenticator)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	catDoc, err := setup.DocService.UploadDocument(cfg.TestUserID, "Cat Knowledge",
+	catDoc, err := setup.DocService.UploadDocument(ctx, cfg.TestUserID, "Cat Knowledge",
 		`Cats, with their graceful movements and independent nature,
 		have been revered and adored by many civilizations throughout history. In
 		ancient Egypt, they were considered sacred and were even associated with
@@ -170,7 +194,7 @@ func TestChatHandlerIntegration(t *testing.T) {
 		ingrained and serve as a reminder that beneath their cuddly exterior lies a
 		skilled predator, honed by millions of years of evolution.`)
 
-	dogDoc, err := setup.DocService.UploadDocument(cfg.TestUserID, "Dog Knowledge",
+	dogDoc, err := setup.DocService.UploadDocument(ctx, cfg.TestUserID, "Dog Knowledge",
 		`Introduction to Dogs: Dogs, often referred to as "man's best friend," have been
 		companions to humans for thousands of years. Originating from wild wolves,
 		these loyal creatures have been domesticated and bred for various roles
@@ -215,19 +239,19 @@ func TestChatHandlerIntegration(t *testing.T) {
 		showcases the incredible bond that has existed between our two species for
 		millennia.`)
 
-	if _, err := setup.DocService.GetDocument(cfg.TestUserID, "Dog Knowledge"); err != nil {
+	if _, err := setup.DocService.GetDocument(ctx, cfg.TestUserID, "Dog Knowledge"); err != nil {
 		t.Fatalf("Failed to get dog document: %v", err)
 	}
-	if _, err := setup.DocService.GetDocumentByID(cfg.TestUserID, dogDoc.DocumentUUID); err != nil {
+	if _, err := setup.DocService.GetDocumentByID(ctx, cfg.TestUserID, dogDoc.DocumentUUID); err != nil {
 		t.Fatalf("Failed to get dog document: %v", err)
 	}
-	cat, err := setup.DocService.GetDocumentByID(cfg.TestUserID, catDoc.DocumentUUID)
+	cat, err := setup.DocService.GetDocumentByID(ctx, cfg.TestUserID, catDoc.DocumentUUID)
 	if cat == nil || err != nil {
 		t.Fatalf("Failed to get cat document: %v", err)
 	}
 
-	// Obtain a JWT token from Clerk
-	jwtToken := cfg.TestJWTSessionToken
+	// Obtain a JWT token from the test issuer
+	jwtToken := setup.JWTSessionToken
 
 	// Construct a message
 	messages := []Message{
@@ -298,6 +322,6 @@ func TestChatHandlerIntegration(t *testing.T) {
 		if err != nil {
 			log.Fatalf("Failed to create UserService: %v", err)
 		}
-		userService.DeleteUser(cfg.TestUserID)
+		userService.DeleteUser(ctx, cfg.TestUserID)
 	})
 }
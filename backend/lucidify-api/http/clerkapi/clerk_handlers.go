@@ -1,110 +1,42 @@
 package clerkapi
 
 import (
-	"encoding/json"
+	"errors"
+	"io"
 	"log"
-	"lucidify-api/data/store/postgresqlclient"
-	"lucidify-api/data/store/storemodels"
-	"lucidify-api/service/userservice"
+	"lucidify-api/service/webhookservice"
 	"net/http"
 )
 
-type ClerkEvent struct {
-	Data   map[string]interface{} `json:"data"`
-	Object string                 `json:"object"`
-	Type   string                 `json:"type"`
-}
-
-func getStringFromMap(m map[string]interface{}, key string) string {
-	if val, ok := m[key]; ok {
-		if strVal, ok := val.(string); ok {
-			return strVal
-		}
-	}
-	return ""
-}
-
-func getBoolFromMap(m map[string]interface{}, key string) bool {
-	if val, ok := m[key]; ok {
-		if boolVal, ok := val.(bool); ok {
-			return boolVal
-		}
-	}
-	return false
-}
-
-func getInt64FromMap(m map[string]interface{}, key string) int64 {
-	if val, ok := m[key]; ok {
-		if int64Val, ok := val.(int64); ok {
-			return int64Val
-		}
-	}
-	return 0
-}
-
-func ClerkHandler(db *postgresqlclient.PostgreSQL, userService userservice.UserService) http.HandlerFunc {
+// ClerkHandler stores the verified event and acknowledges it. Processing
+// happens in the webhook worker, so a failure there is retried instead of lost.
+// If the event cannot be stored a 500 is returned so that Svix redelivers it,
+// whereas an invalid event is rejected with a 400.
+func ClerkHandler(webhookService webhookservice.WebhookService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		var event ClerkEvent
-		decoder := json.NewDecoder(r.Body)
-		err := decoder.Decode(&event)
+		payload, err := io.ReadAll(r.Body)
 		if err != nil {
 			http.Error(w, "Bad request", http.StatusBadRequest)
 			return
 		}
 
-		switch event.Type {
-		case "user.created":
-			user := storemodels.User{
-				UserID:           getStringFromMap(event.Data, "id"),
-				ExternalID:       getStringFromMap(event.Data, "external_id"),
-				Username:         getStringFromMap(event.Data, "username"),
-				PasswordEnabled:  getBoolFromMap(event.Data, "password_enabled"),
-				Email:            event.Data["email_addresses"].([]interface{})[0].(map[string]interface{})["email_address"].(string),
-				FirstName:        getStringFromMap(event.Data, "first_name"),
-				LastName:         getStringFromMap(event.Data, "last_name"),
-				ImageURL:         getStringFromMap(event.Data, "image_url"),
-				ProfileImageURL:  getStringFromMap(event.Data, "profile_image_url"),
-				TwoFactorEnabled: event.Data["two_factor_enabled"].(bool),
-				CreatedAt:        getInt64FromMap(event.Data, "created_at"),
-				UpdatedAt:        getInt64FromMap(event.Data, "updated_at"),
-			}
-
-			err := userService.CreateUser(user)
-			if err != nil {
-				log.Printf("Error creating user: %v", err)
-			}
-		case "user.updated":
-			user := storemodels.User{
-				UserID:           getStringFromMap(event.Data, "id"),
-				ExternalID:       getStringFromMap(event.Data, "external_id"),
-				Username:         getStringFromMap(event.Data, "username"),
-				PasswordEnabled:  getBoolFromMap(event.Data, "password_enabled"),
-				Email:            event.Data["email_addresses"].([]interface{})[0].(map[string]interface{})["email_address"].(string),
-				FirstName:        getStringFromMap(event.Data, "first_name"),
-				LastName:         getStringFromMap(event.Data, "last_name"),
-				ImageURL:         getStringFromMap(event.Data, "image_url"),
-				ProfileImageURL:  getStringFromMap(event.Data, "profile_image_url"),
-				TwoFactorEnabled: event.Data["two_factor_enabled"].(bool),
-				CreatedAt:        getInt64FromMap(event.Data, "created_at"),
-				UpdatedAt:        getInt64FromMap(event.Data, "updated_at"),
-			}
-
-			err := userService.UpdateUser(user)
-			if err != nil {
-				log.Printf("Error updating user: %v", err)
-			}
-		case "user.deleted":
-			err := userService.DeleteUser(event.Data["id"].(string))
-			if err != nil {
-				log.Printf("Error deleting user: %v", err)
-			}
-		default:
-			log.Printf("Unhandled event type: %s", event.Type)
+		err = webhookService.RecordClerkEvent(ctx, r.Header.Get("svix-id"), payload)
+		if errors.Is(err, webhookservice.ErrInvalidEvent) {
+			log.Printf("Rejecting webhook event: %v", err)
+			http.Error(w, "Bad request. "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Error recording webhook event: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
 		}
 
 		w.WriteHeader(http.StatusOK)
@@ -3,6 +3,7 @@
 package clerkapi
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"lucidify-api/data/store/postgresqlclient"
@@ -15,6 +16,8 @@ import (
 )
 
 func TestIntegration_clerk_handlers(t *testing.T) {
+	ctx := context.Background()
+
 	testconfig := config.NewServerConfig()
 	clerkSecretKey := testconfig.ClerkSecretKey
 	testEmail := fmt.Sprintf("clerk_handler_integration_%d@example.com", time.Now().UnixNano())
@@ -27,12 +30,12 @@ func TestIntegration_clerk_handlers(t *testing.T) {
 		t.Errorf("User not created in Clerk. Reason: %v", err)
 	}
 
-	weaviate, err := weaviateclient.NewWeaviateClientTest()
+	weaviate, err := weaviateclient.NewWeaviateClientTest(testconfig.OPENAI_API_KEY)
 	if err != nil {
 		t.Errorf("Failed to create WeaviateClient: %v", err)
 	}
 
-	postgre, err := postgresqlclient.NewPostgreSQL()
+	postgre, err := postgresqlclient.NewPostgreSQL(testconfig.PostgresqlURL)
 	if err != nil {
 		t.Errorf("Failed to create PostgreSQLClient: %v", err)
 	}
@@ -47,12 +50,12 @@ func TestIntegration_clerk_handlers(t *testing.T) {
 		if err != nil {
 			t.Errorf("Failed to delete test user in clerk: %v\n", err)
 		}
-		if userService.HasUserBeenDeleted(userID, 10) {
+		if userService.HasUserBeenDeleted(ctx, userID, 10) {
 			t.Errorf("Failed to delete test user in users table: %v\n", err)
 		}
 	})
 
-	_, err = userService.GetUserWithRetries(userID, 10)
+	_, err = userService.GetUserWithRetries(ctx, userID, 10)
 	if err != nil {
 		t.Errorf("User not found after creation: %v", err)
 	}
@@ -68,7 +71,7 @@ func TestIntegration_clerk_handlers(t *testing.T) {
 	go func() {
 		// This is now running in a separate goroutine.
 		for i := 0; i < 10; i++ {
-			user, err := userService.GetUser(userID)
+			user, err := userService.GetUser(ctx, userID)
 			if err == nil && user.FirstName == updatedFirstName && user.LastName == updatedLastName {
 				resultChan <- true
 				return
@@ -1,15 +1,14 @@
 package clerkapi
 
 import (
-	"lucidify-api/data/store/postgresqlclient"
 	"lucidify-api/server/config"
 	"lucidify-api/server/middleware"
-	"lucidify-api/service/userservice"
+	"lucidify-api/service/webhookservice"
 	"net/http"
 )
 
-func SetupRoutes(storeInstance *postgresqlclient.PostgreSQL, userService userservice.UserService, config *config.ServerConfig, mux *http.ServeMux) *http.ServeMux {
-	handler := ClerkHandler(storeInstance, userService)
+func SetupRoutes(webhookService webhookservice.WebhookService, config *config.ServerConfig, mux *http.ServeMux) *http.ServeMux {
+	handler := ClerkHandler(webhookService)
 
 	handler = middleware.ClerkWebhooksAuthenticationMiddleware(config)(handler)
 	handler = middleware.Logging(handler)
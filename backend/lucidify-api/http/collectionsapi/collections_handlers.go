@@ -0,0 +1,237 @@
+package collectionsapi
+
+import (
+	"encoding/json"
+	"errors"
+	"lucidify-api/server/auth"
+	"lucidify-api/service/collectionservice"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// collectionRequest is the body of the requests changing a collection. Only
+// the fields each request needs are read.
+type collectionRequest struct {
+	CollectionID uuid.UUID `json:"collectionID"`
+	DocumentID   uuid.UUID `json:"documentID"`
+	Name         string    `json:"name"`
+}
+
+// writeCollectionError responds with the status matching an error of the
+// collection service.
+func writeCollectionError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, collectionservice.ErrCollectionNotFound),
+		errors.Is(err, collectionservice.ErrDocumentNotInCollection):
+		http.Error(w, "Not found. "+err.Error(), http.StatusNotFound)
+	case errors.Is(err, collectionservice.ErrCollectionNameRequired):
+		http.Error(w, "Bad request. "+err.Error(), http.StatusBadRequest)
+	case errors.Is(err, collectionservice.ErrDocumentNotAccessibleToUser):
+		http.Error(w, "Forbidden. "+err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, "Internal server error. Unable to "+action, http.StatusInternalServerError)
+	}
+}
+
+// decodeCollectionRequest reads the principal and the body of a request
+// changing a collection, and responds with an error if either is missing.
+func decodeCollectionRequest(w http.ResponseWriter, r *http.Request, method string) (*auth.Principal, *collectionRequest, bool) {
+	if r.Method != method {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, nil, false
+	}
+
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+		return nil, nil, false
+	}
+
+	var reqBody collectionRequest
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqBody)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	return principal, &reqBody, true
+}
+
+func CreateCollectionHandler(collectionService collectionservice.CollectionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		principal, reqBody, ok := decodeCollectionRequest(w, r, http.MethodPost)
+		if !ok {
+			return
+		}
+
+		collection, err := collectionService.CreateCollection(ctx, principal.UserID, reqBody.Name)
+		if err != nil {
+			writeCollectionError(w, err, "create collection")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+
+		encoder := json.NewEncoder(w)
+		err = encoder.Encode(collection)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to encode collection as JSON", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func GetCollectionsHandler(collectionService collectionservice.CollectionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+			return
+		}
+
+		collections, err := collectionService.GetCollections(ctx, principal.UserID)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to get collections", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		encoder := json.NewEncoder(w)
+		err = encoder.Encode(collections)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to encode collections as JSON", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func RenameCollectionHandler(collectionService collectionservice.CollectionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		principal, reqBody, ok := decodeCollectionRequest(w, r, http.MethodPost)
+		if !ok {
+			return
+		}
+
+		err := collectionService.RenameCollection(ctx, principal.UserID, reqBody.CollectionID, reqBody.Name)
+		if err != nil {
+			writeCollectionError(w, err, "rename collection")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+	}
+}
+
+func DeleteCollectionHandler(collectionService collectionservice.CollectionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		principal, reqBody, ok := decodeCollectionRequest(w, r, http.MethodDelete)
+		if !ok {
+			return
+		}
+
+		err := collectionService.DeleteCollection(ctx, principal.UserID, reqBody.CollectionID)
+		if err != nil {
+			writeCollectionError(w, err, "delete collection")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+	}
+}
+
+func AddDocumentHandler(collectionService collectionservice.CollectionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		principal, reqBody, ok := decodeCollectionRequest(w, r, http.MethodPost)
+		if !ok {
+			return
+		}
+
+		err := collectionService.AddDocument(ctx, principal.UserID, reqBody.CollectionID, reqBody.DocumentID)
+		if err != nil {
+			writeCollectionError(w, err, "add document to collection")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+	}
+}
+
+func RemoveDocumentHandler(collectionService collectionservice.CollectionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		principal, reqBody, ok := decodeCollectionRequest(w, r, http.MethodPost)
+		if !ok {
+			return
+		}
+
+		err := collectionService.RemoveDocument(ctx, principal.UserID, reqBody.CollectionID, reqBody.DocumentID)
+		if err != nil {
+			writeCollectionError(w, err, "remove document from collection")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+	}
+}
+
+// GetCollectionDocumentsHandler lists the documents tagged with a collection.
+func GetCollectionDocumentsHandler(collectionService collectionservice.CollectionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+			return
+		}
+
+		collectionID, err := uuid.Parse(r.URL.Query().Get("collectionID"))
+		if err != nil {
+			http.Error(w, "Bad request. Invalid collectionID", http.StatusBadRequest)
+			return
+		}
+
+		documents, err := collectionService.GetCollectionDocuments(ctx, principal.UserID, collectionID)
+		if err != nil {
+			writeCollectionError(w, err, "get collection documents")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		encoder := json.NewEncoder(w)
+		err = encoder.Encode(documents)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to encode documents as JSON", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package collectionsapi
+
+import (
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/auth"
+	"lucidify-api/server/config"
+	"lucidify-api/server/middleware"
+	"lucidify-api/service/collectionservice"
+	"lucidify-api/service/userservice"
+	"net/http"
+)
+
+func SetupRoutes(config *config.ServerConfig, mux *http.ServeMux, collectionService collectionservice.CollectionService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+	mux = SetupCreateCollectionHandler(config, mux, collectionService, userService, authenticator)
+	mux = SetupGetCollectionsHandler(config, mux, collectionService, userService, authenticator)
+	mux = SetupRenameCollectionHandler(config, mux, collectionService, userService, authenticator)
+	mux = SetupDeleteCollectionHandler(config, mux, collectionService, userService, authenticator)
+	mux = SetupAddDocumentHandler(config, mux, collectionService, userService, authenticator)
+	mux = SetupRemoveDocumentHandler(config, mux, collectionService, userService, authenticator)
+	mux = SetupGetCollectionDocumentsHandler(config, mux, collectionService, userService, authenticator)
+
+	return mux
+}
+
+func SetupCreateCollectionHandler(config *config.ServerConfig, mux *http.ServeMux, collectionService collectionservice.CollectionService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := CreateCollectionHandler(collectionService)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsWrite)(handler)
+
+	mux.Handle("/collections/create", authenticate(handler))
+
+	return mux
+}
+
+func SetupGetCollectionsHandler(config *config.ServerConfig, mux *http.ServeMux, collectionService collectionservice.CollectionService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := GetCollectionsHandler(collectionService)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsRead)(handler)
+
+	mux.Handle("/collections/list", authenticate(handler))
+
+	return mux
+}
+
+func SetupRenameCollectionHandler(config *config.ServerConfig, mux *http.ServeMux, collectionService collectionservice.CollectionService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := RenameCollectionHandler(collectionService)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsWrite)(handler)
+
+	mux.Handle("/collections/rename", authenticate(handler))
+
+	return mux
+}
+
+func SetupDeleteCollectionHandler(config *config.ServerConfig, mux *http.ServeMux, collectionService collectionservice.CollectionService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := DeleteCollectionHandler(collectionService)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsWrite)(handler)
+
+	mux.Handle("/collections/delete", authenticate(handler))
+
+	return mux
+}
+
+func SetupAddDocumentHandler(config *config.ServerConfig, mux *http.ServeMux, collectionService collectionservice.CollectionService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := AddDocumentHandler(collectionService)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsWrite)(handler)
+
+	mux.Handle("/collections/add_document", authenticate(handler))
+
+	return mux
+}
+
+func SetupRemoveDocumentHandler(config *config.ServerConfig, mux *http.ServeMux, collectionService collectionservice.CollectionService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := RemoveDocumentHandler(collectionService)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsWrite)(handler)
+
+	mux.Handle("/collections/remove_document", authenticate(handler))
+
+	return mux
+}
+
+func SetupGetCollectionDocumentsHandler(config *config.ServerConfig, mux *http.ServeMux, collectionService collectionservice.CollectionService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := GetCollectionDocumentsHandler(collectionService)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsRead)(handler)
+
+	mux.Handle("/collections/documents", authenticate(handler))
+
+	return mux
+}
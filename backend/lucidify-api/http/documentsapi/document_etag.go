@@ -0,0 +1,53 @@
+package documentsapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"lucidify-api/service/documentservice"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// documentETag is the ETag of a document, and of its content, at a revision.
+func documentETag(revision int64) string {
+	return `"` + strconv.FormatInt(revision, 10) + `"`
+}
+
+// parseIfMatch returns the revision the If-Match header of the request
+// requires, or documentservice.AnyRevision if there is no such header.
+func parseIfMatch(r *http.Request) (int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return documentservice.AnyRevision, nil
+	}
+
+	if len(header) < 3 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, fmt.Errorf("If-Match must be a single ETag of the document")
+	}
+	revision, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil || revision <= 0 {
+		return 0, fmt.Errorf("If-Match must be a single ETag of the document")
+	}
+	return revision, nil
+}
+
+// writeDocumentModified responds to a stale If-Match with the current document
+// and its ETag, so the client can merge its changes and retry.
+func writeDocumentModified(ctx context.Context, w http.ResponseWriter, documentService documentservice.DocumentService, userID string, documentID uuid.UUID) {
+	document, err := documentService.GetDocumentInfo(ctx, userID, documentID)
+	if err != nil {
+		http.Error(w, "Precondition failed. "+documentservice.ErrDocumentModified.Error(), http.StatusPreconditionFailed)
+		return
+	}
+
+	w.Header().Set("ETag", documentETag(document.Revision))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+
+	encoder := json.NewEncoder(w)
+	encoder.Encode(document)
+}
@@ -0,0 +1,278 @@
+package documentsapi
+
+import (
+	"encoding/json"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/auth"
+	"lucidify-api/service/documentservice"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type shareDocumentRequest struct {
+	DocumentID    string     `json:"documentID"`
+	GranteeUserID string     `json:"grantee_user_id"`
+	Permission    string     `json:"permission"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+}
+
+type createDocumentLinkRequest struct {
+	DocumentID string     `json:"documentID"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+type createDocumentLinkResponse struct {
+	Token string                     `json:"token"`
+	Grant *storemodels.DocumentGrant `json:"grant"`
+}
+
+func DocumentsShareDocumentHandler(documentService documentservice.DocumentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := r.Context()
+
+		principal, ok := auth.PrincipalFromContext(ctx)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+			return
+		}
+
+		var reqBody shareDocumentRequest
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&reqBody)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		documentID, err := uuid.Parse(reqBody.DocumentID)
+		if err != nil {
+			http.Error(w, "Bad request. Invalid documentID", http.StatusBadRequest)
+			return
+		}
+
+		grant, err := documentService.ShareDocument(ctx, principal.UserID, documentID, reqBody.GranteeUserID, reqBody.Permission, reqBody.ExpiresAt)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to share document", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+
+		encoder := json.NewEncoder(w)
+		err = encoder.Encode(grant)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to encode grant as JSON", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func DocumentsCreateDocumentLinkHandler(documentService documentservice.DocumentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := r.Context()
+
+		principal, ok := auth.PrincipalFromContext(ctx)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+			return
+		}
+
+		var reqBody createDocumentLinkRequest
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&reqBody)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		documentID, err := uuid.Parse(reqBody.DocumentID)
+		if err != nil {
+			http.Error(w, "Bad request. Invalid documentID", http.StatusBadRequest)
+			return
+		}
+
+		token, grant, err := documentService.CreateDocumentLink(ctx, principal.UserID, documentID, reqBody.ExpiresAt)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to create document link", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+
+		encoder := json.NewEncoder(w)
+		err = encoder.Encode(createDocumentLinkResponse{Token: token, Grant: grant})
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to encode document link as JSON", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func DocumentsGetDocumentGrantsHandler(documentService documentservice.DocumentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := r.Context()
+
+		principal, ok := auth.PrincipalFromContext(ctx)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+			return
+		}
+
+		documentID, err := uuid.Parse(r.URL.Query().Get("documentID"))
+		if err != nil {
+			http.Error(w, "Bad request. Invalid documentID", http.StatusBadRequest)
+			return
+		}
+
+		grants, err := documentService.GetDocumentGrants(ctx, principal.UserID, documentID)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to get document grants", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		encoder := json.NewEncoder(w)
+		err = encoder.Encode(grants)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to encode document grants as JSON", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func DocumentsRevokeDocumentGrantHandler(documentService documentservice.DocumentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := r.Context()
+
+		principal, ok := auth.PrincipalFromContext(ctx)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+			return
+		}
+
+		var reqBody map[string]string
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&reqBody)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		documentID, err := uuid.Parse(reqBody["documentID"])
+		if err != nil {
+			http.Error(w, "Bad request. Invalid documentID", http.StatusBadRequest)
+			return
+		}
+		grantID, err := uuid.Parse(reqBody["grantID"])
+		if err != nil {
+			http.Error(w, "Bad request. Invalid grantID", http.StatusBadRequest)
+			return
+		}
+
+		err = documentService.RevokeDocumentGrant(ctx, principal.UserID, documentID, grantID)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to revoke document grant", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+	}
+}
+
+func DocumentsGetSharedDocumentsHandler(documentService documentservice.DocumentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := r.Context()
+
+		principal, ok := auth.PrincipalFromContext(ctx)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+			return
+		}
+
+		documents, err := documentService.GetSharedDocuments(ctx, principal.UserID)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to get shared documents", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		encoder := json.NewEncoder(w)
+		err = encoder.Encode(documents)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to encode documents as JSON", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// SharedDocumentHandler serves the document behind a sharing link. The link
+// token is the only credential, so the route is not authenticated.
+func SharedDocumentHandler(documentService documentservice.DocumentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			http.Error(w, "Bad request. Missing token", http.StatusBadRequest)
+			return
+		}
+
+		document, err := documentService.GetDocumentByLinkToken(ctx, token)
+		if err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		// Links may be revoked at any time
+		w.Header().Set("Cache-Control", "no-store")
+
+		encoder := json.NewEncoder(w)
+		err = encoder.Encode(document)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to encode document as JSON", http.StatusInternalServerError)
+			return
+		}
+	}
+}
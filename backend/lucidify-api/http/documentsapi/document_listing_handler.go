@@ -0,0 +1,155 @@
+package documentsapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/auth"
+	"lucidify-api/service/documentservice"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DocumentsListHandler lists documents a page at a time. The query parameters
+// are:
+//
+//	cursor          next_cursor of the previous page
+//	limit           page size, up to documentservice.MaxDocumentPageSize
+//	sort            name, created (default) or updated
+//	order           asc (default) or desc
+//	name_prefix     only documents whose name starts with the prefix
+//	collection_id   only documents of the collection
+//	created_after   only documents created at or after the RFC 3339 time
+//	created_before  only documents created before the RFC 3339 time
+//	filters         JSON array of metadata filters
+//	workspace_id    list the documents of the workspace instead
+//	view            summary (default) leaves out the content, full includes it
+func DocumentsListHandler(documentService documentservice.DocumentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := r.Context()
+
+		principal, ok := auth.PrincipalFromContext(ctx)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+			return
+		}
+
+		query := r.URL.Query()
+		options, err := parseDocumentListOptions(query)
+		if err != nil {
+			http.Error(w, "Bad request. "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		page, err := documentService.ListDocuments(ctx, principal.UserID, options, query.Get("cursor"))
+		if errors.Is(err, documentservice.ErrInvalidCursor) {
+			http.Error(w, "Bad request. "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, documentservice.ErrWorkspaceAccessDenied) {
+			http.Error(w, "Forbidden. "+err.Error(), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to list documents: %v", err)
+			http.Error(w, "Internal server error. Unable to list documents", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		encoder := json.NewEncoder(w)
+		err = encoder.Encode(page)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to encode documents as JSON", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func parseDocumentListOptions(query url.Values) (storemodels.DocumentListOptions, error) {
+	options := storemodels.DocumentListOptions{
+		SortBy:     query.Get("sort"),
+		NamePrefix: query.Get("name_prefix"),
+	}
+
+	switch options.SortBy {
+	case "", storemodels.DocumentSortName, storemodels.DocumentSortCreated, storemodels.DocumentSortUpdated:
+	default:
+		return options, fmt.Errorf("invalid sort, expected name, created or updated")
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return options, fmt.Errorf("invalid limit")
+		}
+		options.Limit = n
+	}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		options.Descending = true
+	default:
+		return options, fmt.Errorf("invalid order, expected asc or desc")
+	}
+
+	switch query.Get("view") {
+	case "", "summary":
+	case "full":
+		options.IncludeContent = true
+	default:
+		return options, fmt.Errorf("invalid view, expected summary or full")
+	}
+
+	if workspaceID := query.Get("workspace_id"); workspaceID != "" {
+		options.WorkspaceID = &workspaceID
+	}
+
+	if collectionID := query.Get("collection_id"); collectionID != "" {
+		id, err := uuid.Parse(collectionID)
+		if err != nil {
+			return options, fmt.Errorf("invalid collection_id")
+		}
+		options.CollectionID = &id
+	}
+
+	for name, target := range map[string]**time.Time{
+		"created_after":  &options.CreatedAfter,
+		"created_before": &options.CreatedBefore,
+	} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return options, fmt.Errorf("invalid %s, expected an RFC 3339 time", name)
+			}
+			*target = &t
+		}
+	}
+
+	if filters := query.Get("filters"); filters != "" {
+		if err := json.Unmarshal([]byte(filters), &options.MetadataFilters); err != nil {
+			return options, fmt.Errorf("invalid filters")
+		}
+		for _, filter := range options.MetadataFilters {
+			if err := filter.Validate(); err != nil {
+				return options, err
+			}
+		}
+	}
+
+	return options, nil
+}
@@ -2,15 +2,16 @@ package documentsapi
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/auth"
 	"lucidify-api/service/documentservice"
 	"net/http"
 
-	"github.com/clerkinc/clerk-sdk-go/clerk"
 	"github.com/google/uuid"
 )
 
-func DocumentsUploadHandler(documentService documentservice.DocumentService, clerkInstance clerk.Client) http.HandlerFunc {
+func DocumentsUploadHandler(documentService documentservice.DocumentService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -19,31 +20,44 @@ func DocumentsUploadHandler(documentService documentservice.DocumentService, cle
 
 		ctx := r.Context()
 
-		sessClaims, ok := ctx.Value(clerk.ActiveSessionClaims).(*clerk.SessionClaims)
+		principal, ok := auth.PrincipalFromContext(ctx)
 		if !ok {
 			w.WriteHeader(http.StatusUnauthorized)
 			w.Write([]byte("Unauthorized"))
 			return
 		}
 
-		user, err := clerkInstance.Users().Read(sessClaims.Claims.Subject)
-		if err != nil {
-			panic(err)
-		}
+		w.Write([]byte(principal.UserID))
 
-		w.Write([]byte(*&user.ID))
-
-		var reqBody map[string]string
+		var reqBody struct {
+			DocumentName string                       `json:"document_name"`
+			Content      string                       `json:"content"`
+			WorkspaceID  string                       `json:"workspace_id"`
+			Metadata     storemodels.DocumentMetadata `json:"metadata"`
+			OnDuplicate  string                       `json:"on_duplicate"`
+		}
 		decoder := json.NewDecoder(r.Body)
-		err = decoder.Decode(&reqBody)
+		err := decoder.Decode(&reqBody)
 		if err != nil {
 			http.Error(w, "Bad request", http.StatusBadRequest)
 			return
 		}
-		document_name := reqBody["document_name"]
-		content := reqBody["content"]
+		if err := reqBody.Metadata.Validate(); err != nil {
+			http.Error(w, "Bad request. "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		policy, err := documentservice.ParseDuplicatePolicy(reqBody.OnDuplicate)
+		if err != nil {
+			http.Error(w, "Bad request. "+err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		_, err = documentService.UploadDocument(user.ID, document_name, content)
+		// Documents are private unless a workspace to share them with is given
+		_, _, err = documentService.UploadDocumentDeduplicated(ctx, principal.UserID, reqBody.WorkspaceID, reqBody.DocumentName, reqBody.Content, reqBody.Metadata, policy)
+		if errors.Is(err, documentservice.ErrDuplicateDocument) {
+			http.Error(w, "Conflict. "+err.Error(), http.StatusConflict)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
@@ -53,7 +67,7 @@ func DocumentsUploadHandler(documentService documentservice.DocumentService, cle
 	}
 }
 
-func DocumentsGetDocumentHandler(documentService documentservice.DocumentService, clerkInstance clerk.Client) http.HandlerFunc {
+func DocumentsGetDocumentHandler(documentService documentservice.DocumentService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -62,28 +76,23 @@ func DocumentsGetDocumentHandler(documentService documentservice.DocumentService
 
 		ctx := r.Context()
 
-		sessClaims, ok := ctx.Value(clerk.ActiveSessionClaims).(*clerk.SessionClaims)
+		principal, ok := auth.PrincipalFromContext(ctx)
 		if !ok {
 			w.WriteHeader(http.StatusUnauthorized)
 			w.Write([]byte("Unauthorized"))
 			return
 		}
 
-		user, err := clerkInstance.Users().Read(sessClaims.Claims.Subject)
-		if err != nil {
-			panic(err)
-		}
-
 		var reqBody map[string]string
 		decoder := json.NewDecoder(r.Body)
-		err = decoder.Decode(&reqBody)
+		err := decoder.Decode(&reqBody)
 		if err != nil {
 			http.Error(w, "Bad request", http.StatusBadRequest)
 			return
 		}
 		document_name := reqBody["document_name"]
 
-		document, err := documentService.GetDocument(user.ID, document_name)
+		document, err := documentService.GetDocument(ctx, principal.UserID, document_name)
 		if err != nil {
 			http.Error(w, "Internal server error. Unable to get document", http.StatusInternalServerError)
 			return
@@ -91,6 +100,7 @@ func DocumentsGetDocumentHandler(documentService documentservice.DocumentService
 
 		// Set the Content-Type to application/json
 		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", documentETag(document.Revision))
 
 		// Encode the document as JSON and write it to the response writer
 		encoder := json.NewEncoder(w)
@@ -102,7 +112,7 @@ func DocumentsGetDocumentHandler(documentService documentservice.DocumentService
 	}
 }
 
-func DocumentsGetAllDocumentsHandler(documentService documentservice.DocumentService, clerkInstance clerk.Client) http.HandlerFunc {
+func DocumentsGetAllDocumentsHandler(documentService documentservice.DocumentService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -111,19 +121,20 @@ func DocumentsGetAllDocumentsHandler(documentService documentservice.DocumentSer
 
 		ctx := r.Context()
 
-		sessClaims, ok := ctx.Value(clerk.ActiveSessionClaims).(*clerk.SessionClaims)
+		principal, ok := auth.PrincipalFromContext(ctx)
 		if !ok {
 			w.WriteHeader(http.StatusUnauthorized)
 			w.Write([]byte("Unauthorized"))
 			return
 		}
 
-		user, err := clerkInstance.Users().Read(sessClaims.Claims.Subject)
-		if err != nil {
-			panic(err)
+		var document []storemodels.Document
+		var err error
+		if workspaceID := r.URL.Query().Get("workspace_id"); workspaceID != "" {
+			document, err = documentService.GetWorkspaceDocuments(ctx, principal.UserID, workspaceID)
+		} else {
+			document, err = documentService.GetAllDocuments(ctx, principal.UserID)
 		}
-
-		document, err := documentService.GetAllDocuments(user.ID)
 		if err != nil {
 			http.Error(w, "Internal server error. Unable to get document", http.StatusInternalServerError)
 			return
@@ -142,7 +153,7 @@ func DocumentsGetAllDocumentsHandler(documentService documentservice.DocumentSer
 	}
 }
 
-func DocumentsDeleteDocumentHandler(documentService documentservice.DocumentService, clerkInstance clerk.Client) http.HandlerFunc {
+func DocumentsDeleteDocumentHandler(documentService documentservice.DocumentService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodDelete {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -151,28 +162,23 @@ func DocumentsDeleteDocumentHandler(documentService documentservice.DocumentServ
 
 		ctx := r.Context()
 
-		sessClaims, ok := ctx.Value(clerk.ActiveSessionClaims).(*clerk.SessionClaims)
+		principal, ok := auth.PrincipalFromContext(ctx)
 		if !ok {
 			w.WriteHeader(http.StatusUnauthorized)
 			w.Write([]byte("Unauthorized"))
 			return
 		}
 
-		user, err := clerkInstance.Users().Read(sessClaims.Claims.Subject)
-		if err != nil {
-			panic(err)
-		}
-
 		var reqBody map[string]string
 		decoder := json.NewDecoder(r.Body)
-		err = decoder.Decode(&reqBody)
+		err := decoder.Decode(&reqBody)
 		if err != nil {
 			http.Error(w, "Bad request", http.StatusBadRequest)
 			return
 		}
 		documentID := reqBody["documentID"]
 
-		err = documentService.DeleteDocument(user.ID, uuid.MustParse(documentID))
+		err = documentService.DeleteDocument(ctx, principal.UserID, uuid.MustParse(documentID))
 		if err != nil {
 			http.Error(w, "Internal server error. Unable to delete document", http.StatusInternalServerError)
 			return
@@ -182,7 +188,7 @@ func DocumentsDeleteDocumentHandler(documentService documentservice.DocumentServ
 	}
 }
 
-func DocumentsUpdateDocumentNameHandler(documentService documentservice.DocumentService, clerkInstance clerk.Client) http.HandlerFunc {
+func DocumentsUpdateDocumentNameHandler(documentService documentservice.DocumentService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPut {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -191,32 +197,92 @@ func DocumentsUpdateDocumentNameHandler(documentService documentservice.Document
 
 		ctx := r.Context()
 
-		sessClaims, ok := ctx.Value(clerk.ActiveSessionClaims).(*clerk.SessionClaims)
+		principal, ok := auth.PrincipalFromContext(ctx)
 		if !ok {
 			w.WriteHeader(http.StatusUnauthorized)
 			w.Write([]byte("Unauthorized"))
 			return
 		}
 
-		user, err := clerkInstance.Users().Read(sessClaims.Claims.Subject)
+		var reqBody map[string]string
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&reqBody)
 		if err != nil {
-			panic(err)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		documentID, err := uuid.Parse(reqBody["documentID"])
+		if err != nil {
+			http.Error(w, "Bad request. Invalid documentID", http.StatusBadRequest)
+			return
+		}
+		newDocumentName := reqBody["new_document_name"]
+
+		// Stale writes are rejected if the client sends the ETag it read
+		expectedRevision, err := parseIfMatch(r)
+		if err != nil {
+			http.Error(w, "Bad request. "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = documentService.UpdateDocumentName(ctx, principal.UserID, documentID, newDocumentName, expectedRevision)
+		if errors.Is(err, documentservice.ErrDocumentModified) {
+			writeDocumentModified(ctx, w, documentService, principal.UserID, documentID)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to update document", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+	}
+}
+
+func DocumentsUpdateDocumentContentHandler(documentService documentservice.DocumentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := r.Context()
+
+		principal, ok := auth.PrincipalFromContext(ctx)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+			return
 		}
 
 		var reqBody map[string]string
 		decoder := json.NewDecoder(r.Body)
-		err = decoder.Decode(&reqBody)
+		err := decoder.Decode(&reqBody)
 		if err != nil {
 			http.Error(w, "Bad request", http.StatusBadRequest)
 			return
 		}
 
-		documentID := reqBody["documentID"]
-		newDocumentName := reqBody["new_document_name"]
+		documentID, err := uuid.Parse(reqBody["documentID"])
+		if err != nil {
+			http.Error(w, "Bad request. Invalid documentID", http.StatusBadRequest)
+			return
+		}
+		newDocumentContent := reqBody["new_document_content"]
 
-		fmt.Println(documentID)
+		// Stale writes are rejected if the client sends the ETag it read
+		expectedRevision, err := parseIfMatch(r)
+		if err != nil {
+			http.Error(w, "Bad request. "+err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		err = documentService.UpdateDocumentName(user.ID, uuid.MustParse(documentID), newDocumentName)
+		err = documentService.UpdateDocumentContent(ctx, principal.UserID, documentID, newDocumentContent, expectedRevision)
+		if errors.Is(err, documentservice.ErrDocumentModified) {
+			writeDocumentModified(ctx, w, documentService, principal.UserID, documentID)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Internal server error. Unable to update document", http.StatusInternalServerError)
 			return
@@ -226,46 +292,236 @@ func DocumentsUpdateDocumentNameHandler(documentService documentservice.Document
 	}
 }
 
-func DocumentsUpdateDocumentContentHandler(documentService documentservice.DocumentService, clerkInstance clerk.Client) http.HandlerFunc {
+func DocumentsGetTrashHandler(documentService documentservice.DocumentService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPut {
+		if r.Method != http.MethodGet {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
 		ctx := r.Context()
 
-		sessClaims, ok := ctx.Value(clerk.ActiveSessionClaims).(*clerk.SessionClaims)
+		principal, ok := auth.PrincipalFromContext(ctx)
 		if !ok {
 			w.WriteHeader(http.StatusUnauthorized)
 			w.Write([]byte("Unauthorized"))
 			return
 		}
 
-		user, err := clerkInstance.Users().Read(sessClaims.Claims.Subject)
+		documents, err := documentService.GetDeletedDocuments(ctx, principal.UserID)
 		if err != nil {
-			panic(err)
+			http.Error(w, "Internal server error. Unable to get trashed documents", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		encoder := json.NewEncoder(w)
+		err = encoder.Encode(documents)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to encode documents as JSON", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func DocumentsRestoreDocumentHandler(documentService documentservice.DocumentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := r.Context()
+
+		principal, ok := auth.PrincipalFromContext(ctx)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+			return
 		}
 
 		var reqBody map[string]string
 		decoder := json.NewDecoder(r.Body)
-		err = decoder.Decode(&reqBody)
+		err := decoder.Decode(&reqBody)
 		if err != nil {
 			http.Error(w, "Bad request", http.StatusBadRequest)
 			return
 		}
 
-		documentID := reqBody["documentID"]
-		newDocumentContent := reqBody["new_document_content"]
+		documentID, err := uuid.Parse(reqBody["documentID"])
+		if err != nil {
+			http.Error(w, "Bad request. Invalid documentID", http.StatusBadRequest)
+			return
+		}
+
+		err = documentService.RestoreDocument(ctx, principal.UserID, documentID)
+		switch {
+		case errors.Is(err, documentservice.ErrDocumentNotFound):
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		case errors.Is(err, documentservice.ErrDocumentAccessDenied),
+			errors.Is(err, documentservice.ErrWorkspaceAccessDenied):
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		case errors.Is(err, documentservice.ErrDocumentNotInTrash),
+			errors.Is(err, documentservice.ErrDocumentNameConflict):
+			http.Error(w, "Conflict. "+err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, "Internal server error. Unable to restore document", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+	}
+}
+
+func DocumentsPurgeDocumentHandler(documentService documentservice.DocumentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
 
-		fmt.Println(documentID)
+		ctx := r.Context()
 
-		err = documentService.UpdateDocumentContent(user.ID, uuid.MustParse(documentID), newDocumentContent)
+		principal, ok := auth.PrincipalFromContext(ctx)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+			return
+		}
+
+		var reqBody map[string]string
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&reqBody)
 		if err != nil {
-			http.Error(w, "Internal server error. Unable to update document", http.StatusInternalServerError)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		documentID, err := uuid.Parse(reqBody["documentID"])
+		if err != nil {
+			http.Error(w, "Bad request. Invalid documentID", http.StatusBadRequest)
+			return
+		}
+
+		err = documentService.PurgeDocument(ctx, principal.UserID, documentID)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to purge document", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+	}
+}
+
+func DocumentsUpdateDocumentMetadataHandler(documentService documentservice.DocumentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := r.Context()
+
+		principal, ok := auth.PrincipalFromContext(ctx)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+			return
+		}
+
+		var reqBody struct {
+			DocumentID string                       `json:"documentID"`
+			Metadata   storemodels.DocumentMetadata `json:"metadata"`
+		}
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&reqBody)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		if err := reqBody.Metadata.Validate(); err != nil {
+			http.Error(w, "Bad request. "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		documentID, err := uuid.Parse(reqBody.DocumentID)
+		if err != nil {
+			http.Error(w, "Bad request. Invalid documentID", http.StatusBadRequest)
+			return
+		}
+
+		expectedRevision, err := parseIfMatch(r)
+		if err != nil {
+			http.Error(w, "Bad request. "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = documentService.UpdateDocumentMetadata(ctx, principal.UserID, documentID, reqBody.Metadata, expectedRevision)
+		if errors.Is(err, documentservice.ErrDocumentModified) {
+			writeDocumentModified(ctx, w, documentService, principal.UserID, documentID)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to update document metadata", http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 	}
 }
+
+// DocumentsFilterDocumentsHandler lists the documents whose metadata matches
+// the filters of the request.
+func DocumentsFilterDocumentsHandler(documentService documentservice.DocumentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := r.Context()
+
+		principal, ok := auth.PrincipalFromContext(ctx)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+			return
+		}
+
+		var reqBody struct {
+			WorkspaceID string                       `json:"workspace_id"`
+			Filters     []storemodels.MetadataFilter `json:"filters"`
+		}
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&reqBody)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		for _, filter := range reqBody.Filters {
+			if err := filter.Validate(); err != nil {
+				http.Error(w, "Bad request. "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		documents, err := documentService.FilterDocuments(ctx, principal.UserID, reqBody.WorkspaceID, reqBody.Filters)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to filter documents", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		encoder := json.NewEncoder(w)
+		err = encoder.Encode(documents)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to encode documents as JSON", http.StatusInternalServerError)
+			return
+		}
+	}
+}
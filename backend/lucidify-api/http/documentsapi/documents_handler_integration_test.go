@@ -4,26 +4,27 @@ package documentsapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"log"
 	"lucidify-api/data/store/postgresqlclient"
 	"lucidify-api/data/store/storemodels"
 	"lucidify-api/data/store/weaviateclient"
+	"lucidify-api/server/auth"
+	"lucidify-api/server/auth/authtest"
 	"lucidify-api/server/config"
-	"lucidify-api/service/clerkservice"
 	"lucidify-api/service/documentservice"
 	"lucidify-api/service/userservice"
 	"net/http"
 	"net/http/httptest"
 	"testing"
-
-	"github.com/clerkinc/clerk-sdk-go/clerk"
 )
 
 func createTestUserInDb() error {
+	ctx := context.Background()
 	testconfig := config.NewServerConfig()
-	db, err := postgresqlclient.NewPostgreSQL()
+	db, err := postgresqlclient.NewPostgreSQL(testconfig.PostgresqlURL)
 
 	// the user id registered by the jwt token must exist in the local database
 	user := storemodels.User{
@@ -41,7 +42,7 @@ func createTestUserInDb() error {
 		UpdatedAt:        1654012591514,
 	}
 
-	weaviate, err := weaviateclient.NewWeaviateClientTest()
+	weaviate, err := weaviateclient.NewWeaviateClientTest(testconfig.OPENAI_API_KEY)
 	if err != nil {
 		log.Fatalf("Failed to create WeaviateClient: %v", err)
 	}
@@ -50,21 +51,21 @@ func createTestUserInDb() error {
 		log.Fatalf("Failed to create UserService: %v", err)
 	}
 
-	err = userService.DeleteUser(user.UserID)
+	err = userService.DeleteUser(ctx, user.UserID)
 	if err != nil {
 		log.Fatalf("Failed to delete user: %v", err)
 	}
-	if !userService.HasUserBeenDeleted(user.UserID, 3) {
+	if !userService.HasUserBeenDeleted(ctx, user.UserID, 3) {
 		log.Fatalf("Failed to delete user: %v", err)
 	}
 
-	err = db.CreateUserInUsersTable(user)
+	err = db.CreateUserInUsersTable(ctx, user)
 	if err != nil {
 		log.Fatalf("Failed to create user: %v", err)
 	}
 
 	// Check if the user exists
-	_, err = userService.GetUserWithRetries(user.UserID, 3)
+	_, err = userService.GetUserWithRetries(ctx, user.UserID, 3)
 	if err != nil {
 		log.Fatalf("User not found after creation: %v", err)
 	}
@@ -73,7 +74,9 @@ func createTestUserInDb() error {
 }
 
 func createASecondTestUserInDb() string {
-	db, err := postgresqlclient.NewPostgreSQL()
+	ctx := context.Background()
+	cfg := config.NewServerConfig()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
 
 	user := storemodels.User{
 		UserID:           "userid_testuserid2",
@@ -90,7 +93,7 @@ func createASecondTestUserInDb() string {
 		UpdatedAt:        1654012591514,
 	}
 
-	weaviate, err := weaviateclient.NewWeaviateClientTest()
+	weaviate, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
 	if err != nil {
 		log.Fatalf("Failed to create WeaviateClient: %v", err)
 	}
@@ -99,20 +102,20 @@ func createASecondTestUserInDb() string {
 		log.Fatalf("Failed to create UserService: %v", err)
 	}
 
-	err = userService.DeleteUser(user.UserID)
+	err = userService.DeleteUser(ctx, user.UserID)
 	if err != nil {
 		log.Fatalf("Failed to delete user: %v", err)
 	}
-	if !userService.HasUserBeenDeleted(user.UserID, 3) {
+	if !userService.HasUserBeenDeleted(ctx, user.UserID, 3) {
 		log.Fatalf("Failed to delete user: %v", err)
 	}
 
-	err = db.CreateUserInUsersTable(user)
+	err = db.CreateUserInUsersTable(ctx, user)
 	if err != nil {
 		log.Fatalf("Failed to create user: %v", err)
 	}
 
-	_, err = userService.GetUserWithRetries(user.UserID, 3)
+	_, err = userService.GetUserWithRetries(ctx, user.UserID, 3)
 	if err != nil {
 		log.Fatalf("User not found after creation: %v", err)
 	}
@@ -123,25 +126,34 @@ func createASecondTestUserInDb() string {
 type TestSetup struct {
 	Config          *config.ServerConfig
 	PostgresqlDB    *postgresqlclient.PostgreSQL
-	ClerkInstance   clerk.Client
+	Authenticator   auth.Authenticator
+	JWTSessionToken string
 	WeaviateDB      weaviateclient.WeaviateClient
 	DocumentService documentservice.DocumentService
+	UserService     userservice.UserService
 }
 
 func SetupTestEnvironment(t *testing.T) *TestSetup {
 	cfg := config.NewServerConfig()
 
-	postgresqlDB, err := postgresqlclient.NewPostgreSQL()
+	postgresqlDB, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
 	if err != nil {
 		t.Fatalf("Failed to create test postgresqlclient: %v", err)
 	}
 
-	clerkInstance, err := clerkservice.NewClerkClient()
+	// Sessions are signed by a local test issuer instead of a Clerk tenant
+	issuer, err := authtest.NewIssuer()
+	if err != nil {
+		t.Fatalf("Failed to create test token issuer: %v", err)
+	}
+	t.Cleanup(issuer.Close)
+
+	jwtSessionToken, err := issuer.Token(cfg.TestUserID)
 	if err != nil {
-		t.Fatalf("Failed to create Clerk client: %v", err)
+		t.Fatalf("Failed to create session token: %v", err)
 	}
 
-	weaviateDB, err := weaviateclient.NewWeaviateClientTest()
+	weaviateDB, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
 	if err != nil {
 		t.Fatalf("Failed to create Weaviate client: %v", err)
 	}
@@ -151,32 +163,41 @@ func SetupTestEnvironment(t *testing.T) *TestSetup {
 		t.Fatalf("Failed to create test user in db: %v", err)
 	}
 
-	documentService := documentservice.NewDocumentService(postgresqlDB, weaviateDB)
+	documentService := documentservice.NewDocumentService(postgresqlDB, weaviateDB, documentservice.ChunkerOptions{URL: cfg.AI_API_URL, APIKey: cfg.X_AI_API_KEY})
+
+	userService, err := userservice.NewUserService(postgresqlDB, weaviateDB)
+	if err != nil {
+		t.Fatalf("Failed to create UserService: %v", err)
+	}
 
 	return &TestSetup{
 		Config:          cfg,
 		PostgresqlDB:    postgresqlDB,
-		ClerkInstance:   clerkInstance,
+		Authenticator:   issuer.Authenticator(),
+		JWTSessionToken: jwtSessionToken,
 		WeaviateDB:      weaviateDB,
 		DocumentService: documentService,
+		UserService:     userService,
 	}
 }
 
 func TestDocumentsUploadHandlerIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
 	documentService := setup.DocumentService
 	postgresqlDB := setup.PostgresqlDB
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, documentService, clerkInstance)
+	SetupRoutes(cfg, mux, documentService, setup.UserService, authenticator)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	// Obtain a JWT token from Clerk
-	jwtToken := cfg.TestJWTSessionToken
+	// Obtain a JWT token from the test issuer
+	jwtToken := setup.JWTSessionToken
 
 	// Send a POST request to the server with the JWT token
 	document := map[string]string{
@@ -198,7 +219,7 @@ func TestDocumentsUploadHandlerIntegration(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
-	documentFromDb, err := postgresqlDB.GetDocument(cfg.TestUserID, "Test Document")
+	documentFromDb, err := postgresqlDB.GetDocument(ctx, cfg.TestUserID, "Test Document")
 	if err != nil {
 		t.Errorf("Failed to get document: %v", err)
 	}
@@ -212,26 +233,28 @@ func TestDocumentsUploadHandlerIntegration(t *testing.T) {
 	t.Cleanup(func() {
 		testconfig := config.NewServerConfig()
 		UserID := testconfig.TestUserID
-		postgresqlDB.DeleteUserInUsersTable(UserID)
-		postgresqlDB.DeleteDocument(UserID, "Test Document")
+		postgresqlDB.DeleteUserInUsersTable(ctx, UserID)
+		postgresqlDB.DeleteDocument(ctx, UserID, "Test Document")
 	})
 }
 
 func TestDocumentsUploadHandlerUnauthorizedIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
 	documentService := setup.DocumentService
 	postgresqlDB := setup.PostgresqlDB
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, documentService, clerkInstance)
+	SetupRoutes(cfg, mux, documentService, setup.UserService, authenticator)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	// Obtain a JWT token from Clerk
-	jwtToken := cfg.TestJWTSessionToken + "invalid"
+	// Obtain a JWT token from the test issuer
+	jwtToken := setup.JWTSessionToken + "invalid"
 
 	// Send a POST request to the server with the JWT token
 	document := map[string]string{
@@ -257,32 +280,34 @@ func TestDocumentsUploadHandlerUnauthorizedIntegration(t *testing.T) {
 	t.Cleanup(func() {
 		testconfig := config.NewServerConfig()
 		UserID := testconfig.TestUserID
-		postgresqlDB.DeleteUserInUsersTable(UserID)
-		postgresqlDB.DeleteDocument(UserID, "Test Document")
+		postgresqlDB.DeleteUserInUsersTable(ctx, UserID)
+		postgresqlDB.DeleteDocument(ctx, UserID, "Test Document")
 	})
 }
 
 func TestDocumentsGetDocumentHandlerIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
 	documentService := setup.DocumentService
 	postgresqlDB := setup.PostgresqlDB
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, documentService, clerkInstance)
+	SetupRoutes(cfg, mux, documentService, setup.UserService, authenticator)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	jwtToken := cfg.TestJWTSessionToken
+	jwtToken := setup.JWTSessionToken
 
 	document := map[string]string{
 		"document_name": "Test Document",
 		"content":       "Test Content",
 	}
 
-	postgresqlDB.UploadDocument(cfg.TestUserID, "Test Document", "Test Content")
+	postgresqlDB.UploadDocument(ctx, cfg.TestUserID, "Test Document", "Test Content")
 
 	body, _ := json.Marshal(document)
 	req, _ := http.NewRequest(http.MethodGet, server.URL+"/documents/getdocument", bytes.NewBuffer(body))
@@ -322,32 +347,34 @@ func TestDocumentsGetDocumentHandlerIntegration(t *testing.T) {
 	t.Cleanup(func() {
 		testconfig := config.NewServerConfig()
 		UserID := testconfig.TestUserID
-		postgresqlDB.DeleteUserInUsersTable(UserID)
-		postgresqlDB.DeleteDocument(UserID, "Test Document")
+		postgresqlDB.DeleteUserInUsersTable(ctx, UserID)
+		postgresqlDB.DeleteDocument(ctx, UserID, "Test Document")
 	})
 }
 
 func TestDocumentsGetDocumentHandlerUnauthorizedIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
 	documentService := setup.DocumentService
 	postgresqlDB := setup.PostgresqlDB
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, documentService, clerkInstance)
+	SetupRoutes(cfg, mux, documentService, setup.UserService, authenticator)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	jwtToken := cfg.TestJWTSessionToken + "invalid"
+	jwtToken := setup.JWTSessionToken + "invalid"
 
 	document := map[string]string{
 		"document_name": "Test Document",
 		"content":       "Test Content",
 	}
 
-	postgresqlDB.UploadDocument(cfg.TestUserID, "Test Document", "Test Content")
+	postgresqlDB.UploadDocument(ctx, cfg.TestUserID, "Test Document", "Test Content")
 
 	body, _ := json.Marshal(document)
 	req, _ := http.NewRequest(http.MethodGet, server.URL+"/documents/getdocument", bytes.NewBuffer(body))
@@ -368,29 +395,31 @@ func TestDocumentsGetDocumentHandlerUnauthorizedIntegration(t *testing.T) {
 	t.Cleanup(func() {
 		testconfig := config.NewServerConfig()
 		UserID := testconfig.TestUserID
-		postgresqlDB.DeleteUserInUsersTable(UserID)
-		postgresqlDB.DeleteDocument(UserID, "Test Document")
+		postgresqlDB.DeleteUserInUsersTable(ctx, UserID)
+		postgresqlDB.DeleteDocument(ctx, UserID, "Test Document")
 	})
 }
 
 func TestDocumentsGetAllDocumentsHandlerIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
 	documentService := setup.DocumentService
 	postgresqlDB := setup.PostgresqlDB
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, documentService, clerkInstance)
+	SetupRoutes(cfg, mux, documentService, setup.UserService, authenticator)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	jwtToken := cfg.TestJWTSessionToken
+	jwtToken := setup.JWTSessionToken
 
-	postgresqlDB.UploadDocument(cfg.TestUserID, "Test Document", "Test Content")
-	postgresqlDB.UploadDocument(cfg.TestUserID, "Test Document 2", "Test Content 2")
-	postgresqlDB.UploadDocument(cfg.TestUserID, "Test Document 3", "Test Content 3")
+	postgresqlDB.UploadDocument(ctx, cfg.TestUserID, "Test Document", "Test Content")
+	postgresqlDB.UploadDocument(ctx, cfg.TestUserID, "Test Document 2", "Test Content 2")
+	postgresqlDB.UploadDocument(ctx, cfg.TestUserID, "Test Document 3", "Test Content 3")
 
 	req, _ := http.NewRequest(http.MethodGet, server.URL+"/documents/get_all_documents", nil)
 	req.Header.Set("Authorization", "Bearer "+jwtToken)
@@ -435,28 +464,30 @@ func TestDocumentsGetAllDocumentsHandlerIntegration(t *testing.T) {
 	t.Cleanup(func() {
 		testconfig := config.NewServerConfig()
 		UserID := testconfig.TestUserID
-		postgresqlDB.DeleteUserInUsersTable(UserID)
+		postgresqlDB.DeleteUserInUsersTable(ctx, UserID)
 	})
 }
 
 func TestDocumentsGetAllDocumentsHandlerUnauthorizedIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
 	documentService := setup.DocumentService
 	postgresqlDB := setup.PostgresqlDB
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, documentService, clerkInstance)
+	SetupRoutes(cfg, mux, documentService, setup.UserService, authenticator)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	jwtToken := cfg.TestJWTSessionToken + " invalid"
+	jwtToken := setup.JWTSessionToken + " invalid"
 
-	postgresqlDB.UploadDocument(cfg.TestUserID, "Test Document", "Test Content")
-	postgresqlDB.UploadDocument(cfg.TestUserID, "Test Document 2", "Test Content 2")
-	postgresqlDB.UploadDocument(cfg.TestUserID, "Test Document 3", "Test Content 3")
+	postgresqlDB.UploadDocument(ctx, cfg.TestUserID, "Test Document", "Test Content")
+	postgresqlDB.UploadDocument(ctx, cfg.TestUserID, "Test Document 2", "Test Content 2")
+	postgresqlDB.UploadDocument(ctx, cfg.TestUserID, "Test Document 3", "Test Content 3")
 
 	req, _ := http.NewRequest(http.MethodGet, server.URL+"/documents/get_all_documents", nil)
 	req.Header.Set("Authorization", "Bearer "+jwtToken)
@@ -476,30 +507,32 @@ func TestDocumentsGetAllDocumentsHandlerUnauthorizedIntegration(t *testing.T) {
 	t.Cleanup(func() {
 		testconfig := config.NewServerConfig()
 		UserID := testconfig.TestUserID
-		postgresqlDB.DeleteUserInUsersTable(UserID)
+		postgresqlDB.DeleteUserInUsersTable(ctx, UserID)
 	})
 }
 
 func TestDocumentsGetAllDocumentsHandlerUnauthenticatedOtherUserIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
 	documentService := setup.DocumentService
 	postgresqlDB := setup.PostgresqlDB
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 
 	UserID2 := createASecondTestUserInDb()
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, documentService, clerkInstance)
+	SetupRoutes(cfg, mux, documentService, setup.UserService, authenticator)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	jwtToken := cfg.TestJWTSessionToken
+	jwtToken := setup.JWTSessionToken
 
-	postgresqlDB.UploadDocument(UserID2, "Test Document", "Test Content")
-	postgresqlDB.UploadDocument(UserID2, "Test Document 2", "Test Content 2")
-	postgresqlDB.UploadDocument(UserID2, "Test Document 3", "Test Content 3")
+	postgresqlDB.UploadDocument(ctx, UserID2, "Test Document", "Test Content")
+	postgresqlDB.UploadDocument(ctx, UserID2, "Test Document 2", "Test Content 2")
+	postgresqlDB.UploadDocument(ctx, UserID2, "Test Document 3", "Test Content 3")
 
 	req, _ := http.NewRequest(http.MethodGet, server.URL+"/documents/get_all_documents", nil)
 	req.Header.Set("Authorization", "Bearer "+jwtToken)
@@ -541,33 +574,35 @@ func TestDocumentsGetAllDocumentsHandlerUnauthenticatedOtherUserIntegration(t *t
 	}
 	// Cleanup the database
 	t.Cleanup(func() {
-		postgresqlDB.DeleteUserInUsersTable(cfg.TestUserID)
-		postgresqlDB.DeleteUserInUsersTable(UserID2)
-		postgresqlDB.DeleteDocument(UserID2, "Test Document")
-		postgresqlDB.DeleteDocument(UserID2, "Test Document 2")
-		postgresqlDB.DeleteDocument(UserID2, "Test Document 3")
+		postgresqlDB.DeleteUserInUsersTable(ctx, cfg.TestUserID)
+		postgresqlDB.DeleteUserInUsersTable(ctx, UserID2)
+		postgresqlDB.DeleteDocument(ctx, UserID2, "Test Document")
+		postgresqlDB.DeleteDocument(ctx, UserID2, "Test Document 2")
+		postgresqlDB.DeleteDocument(ctx, UserID2, "Test Document 3")
 	})
 }
 
 func TestDocumentsDeleteDocumentHandlerIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
 	documentService := setup.DocumentService
 	postgresqlDB := setup.PostgresqlDB
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 
 	createTestUserInDb()
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, documentService, clerkInstance)
+	SetupRoutes(cfg, mux, documentService, setup.UserService, authenticator)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	// Obtain a JWT token from Clerk
-	jwtToken := cfg.TestJWTSessionToken
+	// Obtain a JWT token from the test issuer
+	jwtToken := setup.JWTSessionToken
 
-	document, err := postgresqlDB.UploadDocument(cfg.TestUserID, "Test Document", "Test Content")
+	document, err := postgresqlDB.UploadDocument(ctx, cfg.TestUserID, "Test Document", "Test Content")
 	if err != nil {
 		t.Errorf("Failed to upload document: %v", err)
 	}
@@ -591,7 +626,7 @@ func TestDocumentsDeleteDocumentHandlerIntegration(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
-	_, err = postgresqlDB.GetDocument(cfg.TestUserID, "Test Document")
+	_, err = postgresqlDB.GetDocument(ctx, cfg.TestUserID, "Test Document")
 	if err == nil {
 		t.Errorf("Should have failed to get document: %v", err)
 	}
@@ -600,30 +635,32 @@ func TestDocumentsDeleteDocumentHandlerIntegration(t *testing.T) {
 	t.Cleanup(func() {
 		testconfig := config.NewServerConfig()
 		UserID := testconfig.TestUserID
-		postgresqlDB.DeleteUserInUsersTable(UserID)
-		postgresqlDB.DeleteDocument(UserID, "Test Document")
+		postgresqlDB.DeleteUserInUsersTable(ctx, UserID)
+		postgresqlDB.DeleteDocument(ctx, UserID, "Test Document")
 	})
 }
 
 func TestDocumentsDeleteDocumentHandlerNotMyDocumentIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
 	documentService := setup.DocumentService
 	postgresqlDB := setup.PostgresqlDB
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 
 	createASecondTestUserInDb()
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, documentService, clerkInstance)
+	SetupRoutes(cfg, mux, documentService, setup.UserService, authenticator)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	// Obtain a JWT token from Clerk
-	jwtToken := cfg.TestJWTSessionToken
+	// Obtain a JWT token from the test issuer
+	jwtToken := setup.JWTSessionToken
 
-	document, err := postgresqlDB.UploadDocument("userid_testuserid2", "Test Document", "Test Content")
+	document, err := postgresqlDB.UploadDocument(ctx, "userid_testuserid2", "Test Document", "Test Content")
 	if err != nil {
 		t.Errorf("Failed to upload document: %v", err)
 	}
@@ -647,7 +684,7 @@ func TestDocumentsDeleteDocumentHandlerNotMyDocumentIntegration(t *testing.T) {
 		t.Errorf("Expected status code to not be ok. %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
-	_, err = postgresqlDB.GetDocument("userid_testuserid2", "Test Document")
+	_, err = postgresqlDB.GetDocument(ctx, "userid_testuserid2", "Test Document")
 	if err != nil {
 		t.Errorf("Should have not failed to get document as document should have not been deleted: %v", err)
 	}
@@ -655,18 +692,20 @@ func TestDocumentsDeleteDocumentHandlerNotMyDocumentIntegration(t *testing.T) {
 	// Cleanup the database
 	t.Cleanup(func() {
 		UserID := cfg.TestUserID
-		postgresqlDB.DeleteUserInUsersTable(UserID)
+		postgresqlDB.DeleteUserInUsersTable(ctx, UserID)
 		SecondUserID := "userid_testuserid2"
-		postgresqlDB.DeleteUserInUsersTable(SecondUserID)
+		postgresqlDB.DeleteUserInUsersTable(ctx, SecondUserID)
 	})
 }
 
 func TestDocumentsDeleteDocumentHandlerUnauthenticatedIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
 	documentService := setup.DocumentService
 	postgresqlDB := setup.PostgresqlDB
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 
 	err := createTestUserInDb()
 	if err != nil {
@@ -675,14 +714,14 @@ func TestDocumentsDeleteDocumentHandlerUnauthenticatedIntegration(t *testing.T)
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, documentService, clerkInstance)
+	SetupRoutes(cfg, mux, documentService, setup.UserService, authenticator)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	// Obtain a JWT token from Clerk
-	jwtToken := cfg.TestJWTSessionToken + "invalid"
+	// Obtain a JWT token from the test issuer
+	jwtToken := setup.JWTSessionToken + "invalid"
 
-	_, err = postgresqlDB.UploadDocument(cfg.TestUserID, "Test Document", "Test Content")
+	_, err = postgresqlDB.UploadDocument(ctx, cfg.TestUserID, "Test Document", "Test Content")
 	if err != nil {
 		t.Errorf("Failed to upload document: %v", err)
 	}
@@ -706,7 +745,7 @@ func TestDocumentsDeleteDocumentHandlerUnauthenticatedIntegration(t *testing.T)
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
-	documentFromDb, err := postgresqlDB.GetDocument(cfg.TestUserID, "Test Document")
+	documentFromDb, err := postgresqlDB.GetDocument(ctx, cfg.TestUserID, "Test Document")
 	if err != nil {
 		t.Errorf("Failed to get document: %v", err)
 	}
@@ -720,17 +759,19 @@ func TestDocumentsDeleteDocumentHandlerUnauthenticatedIntegration(t *testing.T)
 	t.Cleanup(func() {
 		testconfig := config.NewServerConfig()
 		UserID := testconfig.TestUserID
-		postgresqlDB.DeleteUserInUsersTable(UserID)
-		postgresqlDB.DeleteDocument(UserID, "Test Document")
+		postgresqlDB.DeleteUserInUsersTable(ctx, UserID)
+		postgresqlDB.DeleteDocument(ctx, UserID, "Test Document")
 	})
 }
 
 func TestDocumentsUpdateDocumentNameHandlerIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
 	documentService := setup.DocumentService
 	postgresqlDB := setup.PostgresqlDB
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 
 	err := createTestUserInDb()
 	if err != nil {
@@ -739,13 +780,13 @@ func TestDocumentsUpdateDocumentNameHandlerIntegration(t *testing.T) {
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, documentService, clerkInstance)
+	SetupRoutes(cfg, mux, documentService, setup.UserService, authenticator)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	jwtToken := cfg.TestJWTSessionToken
+	jwtToken := setup.JWTSessionToken
 
-	documentFromUpload, err := documentService.UploadDocument(cfg.TestUserID, "Test Document", "Test Content")
+	documentFromUpload, err := documentService.UploadDocument(ctx, cfg.TestUserID, "Test Document", "Test Content")
 
 	document := map[string]string{
 		"documentID":        documentFromUpload.DocumentUUID.String(),
@@ -766,7 +807,7 @@ func TestDocumentsUpdateDocumentNameHandlerIntegration(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
-	document_response, err := documentService.GetDocument(cfg.TestUserID, "Test Document Updated")
+	document_response, err := documentService.GetDocument(ctx, cfg.TestUserID, "Test Document Updated")
 	if err != nil {
 		t.Errorf("Failed to get document: %v", err)
 	}
@@ -796,7 +837,7 @@ func TestDocumentsUpdateDocumentNameHandlerIntegration(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
-	document_response_updated_content, err := documentService.GetDocument(cfg.TestUserID, "Test Document Updated")
+	document_response_updated_content, err := documentService.GetDocument(ctx, cfg.TestUserID, "Test Document Updated")
 	if err != nil {
 		t.Errorf("Failed to get document: %v", err)
 	}
@@ -810,16 +851,18 @@ func TestDocumentsUpdateDocumentNameHandlerIntegration(t *testing.T) {
 	// Cleanup the database
 	t.Cleanup(func() {
 		UserID := cfg.TestUserID
-		postgresqlDB.DeleteUserInUsersTable(UserID)
+		postgresqlDB.DeleteUserInUsersTable(ctx, UserID)
 	})
 }
 
 func TestDocumentsUpdateDocumentHandlerUnauthenticatedIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
 	documentService := setup.DocumentService
 	postgresqlDB := setup.PostgresqlDB
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 
 	err := createTestUserInDb()
 	if err != nil {
@@ -828,13 +871,13 @@ func TestDocumentsUpdateDocumentHandlerUnauthenticatedIntegration(t *testing.T)
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, documentService, clerkInstance)
+	SetupRoutes(cfg, mux, documentService, setup.UserService, authenticator)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	jwtToken := cfg.TestJWTSessionToken + "invalid"
+	jwtToken := setup.JWTSessionToken + "invalid"
 
-	documentFromUpload, err := documentService.UploadDocument(cfg.TestUserID, "Test Document", "Test Content")
+	documentFromUpload, err := documentService.UploadDocument(ctx, cfg.TestUserID, "Test Document", "Test Content")
 
 	document := map[string]string{
 		"documentID":        documentFromUpload.DocumentUUID.String(),
@@ -855,7 +898,7 @@ func TestDocumentsUpdateDocumentHandlerUnauthenticatedIntegration(t *testing.T)
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
-	_, err = documentService.GetDocument(cfg.TestUserID, "Test Document")
+	_, err = documentService.GetDocument(ctx, cfg.TestUserID, "Test Document")
 	if err != nil {
 		t.Errorf("Failed to get document: %v", err)
 	}
@@ -879,11 +922,11 @@ func TestDocumentsUpdateDocumentHandlerUnauthenticatedIntegration(t *testing.T)
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
-	document_response_updated_content, err := documentService.GetDocument(cfg.TestUserID, "Test Document Updated")
+	document_response_updated_content, err := documentService.GetDocument(ctx, cfg.TestUserID, "Test Document Updated")
 	if err == nil {
 		t.Errorf("Should have failed to get document: %v", err)
 	}
-	document_response_updated_content, err = documentService.GetDocument(cfg.TestUserID, "Test Document")
+	document_response_updated_content, err = documentService.GetDocument(ctx, cfg.TestUserID, "Test Document")
 	if document_response_updated_content.Content == "Test Content Updated" {
 		t.Errorf("Expected document content %s, got %s", "Test Content", document_response_updated_content.Content)
 	}
@@ -891,16 +934,18 @@ func TestDocumentsUpdateDocumentHandlerUnauthenticatedIntegration(t *testing.T)
 	// Cleanup the database
 	t.Cleanup(func() {
 		UserID := cfg.TestUserID
-		postgresqlDB.DeleteUserInUsersTable(UserID)
+		postgresqlDB.DeleteUserInUsersTable(ctx, UserID)
 	})
 }
 
 func TestDocumentsUpdateDocumentNotMyDocumentHandlerIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
 	documentService := setup.DocumentService
 	postgresqlDB := setup.PostgresqlDB
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 
 	err := createTestUserInDb()
 	if err != nil {
@@ -909,13 +954,13 @@ func TestDocumentsUpdateDocumentNotMyDocumentHandlerIntegration(t *testing.T) {
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, documentService, clerkInstance)
+	SetupRoutes(cfg, mux, documentService, setup.UserService, authenticator)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	jwtToken := cfg.TestJWTSessionToken
+	jwtToken := setup.JWTSessionToken
 
-	documentFromUpload, err := documentService.UploadDocument(cfg.TestUserID, "Test Document", "Test Content")
+	documentFromUpload, err := documentService.UploadDocument(ctx, cfg.TestUserID, "Test Document", "Test Content")
 
 	document := map[string]string{
 		"documentID":        documentFromUpload.DocumentUUID.String(),
@@ -936,7 +981,7 @@ func TestDocumentsUpdateDocumentNotMyDocumentHandlerIntegration(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
-	document_response, err := documentService.GetDocument(cfg.TestUserID, "Test Document Updated")
+	document_response, err := documentService.GetDocument(ctx, cfg.TestUserID, "Test Document Updated")
 	if err != nil {
 		t.Errorf("Failed to get document: %v", err)
 	}
@@ -966,7 +1011,7 @@ func TestDocumentsUpdateDocumentNotMyDocumentHandlerIntegration(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
-	document_response_updated_content, err := documentService.GetDocument(cfg.TestUserID, "Test Document Updated")
+	document_response_updated_content, err := documentService.GetDocument(ctx, cfg.TestUserID, "Test Document Updated")
 	if err != nil {
 		t.Errorf("Failed to get document: %v", err)
 	}
@@ -980,6 +1025,6 @@ func TestDocumentsUpdateDocumentNotMyDocumentHandlerIntegration(t *testing.T) {
 	// Cleanup the database
 	t.Cleanup(func() {
 		UserID := cfg.TestUserID
-		postgresqlDB.DeleteUserInUsersTable(UserID)
+		postgresqlDB.DeleteUserInUsersTable(ctx, UserID)
 	})
 }
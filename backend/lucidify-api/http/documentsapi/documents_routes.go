@@ -1,99 +1,346 @@
 package documentsapi
 
 import (
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/auth"
 	"lucidify-api/server/config"
 	"lucidify-api/server/middleware"
 	"lucidify-api/service/documentservice"
+	"lucidify-api/service/userservice"
 	"net/http"
-
-	"github.com/clerkinc/clerk-sdk-go/clerk"
 )
 
-func SetupRoutes(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, client clerk.Client) *http.ServeMux {
-	mux = SetupDocumentsUploadHandler(config, mux, documentService, client)
-	mux = SetupDocumentsGetDocumentHandler(config, mux, documentService, client)
-	mux = SetupDocumentsGetAllDocumentHandler(config, mux, documentService, client)
-	mux = SetupDocumentsDeleteDocumentHandler(config, mux, documentService, client)
-	mux = SetupDocumentsUpdateDocumentNameHandler(config, mux, documentService, client)
-	mux = SetupDocumentsUpdateDocumentContentHandler(config, mux, documentService, client)
+func SetupRoutes(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+	mux = SetupDocumentsUploadHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsGetDocumentHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsGetAllDocumentHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsListHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsDeleteDocumentHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsUpdateDocumentNameHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsUpdateDocumentContentHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsGetTrashHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsRestoreDocumentHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsPurgeDocumentHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsUpdateDocumentMetadataHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsFilterDocumentsHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsShareDocumentHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsCreateDocumentLinkHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsGetDocumentGrantsHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsRevokeDocumentGrantHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsGetSharedDocumentsHandler(config, mux, documentService, userService, authenticator)
+	mux = SetupSharedDocumentHandler(mux, documentService)
+	mux = SetupDocumentsV1Handler(config, mux, documentService, userService, authenticator)
+	mux = SetupDocumentsByIDHandler(config, mux, documentService, userService, authenticator)
+
+	return mux
+}
+
+func SetupDocumentsUploadHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := DocumentsUploadHandler(documentService)
+	handler = middleware.Deprecated("/v1/documents")(handler)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsWrite)(handler)
+
+	mux.Handle("/documents/upload", authenticate(handler))
+
+	return mux
+}
+
+func SetupDocumentsGetDocumentHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := DocumentsGetDocumentHandler(documentService)
+	handler = middleware.Deprecated("/v1/documents/{id}")(handler)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsRead)(handler)
+
+	mux.Handle("/documents/getdocument", authenticate(handler))
+
+	return mux
+}
+
+func SetupDocumentsGetAllDocumentHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := DocumentsGetAllDocumentsHandler(documentService)
+	handler = middleware.Deprecated("/v1/documents")(handler)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsRead)(handler)
+
+	mux.Handle("/documents/get_all_documents", authenticate(handler))
+
+	return mux
+}
+
+func SetupDocumentsListHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := DocumentsListHandler(documentService)
+	handler = middleware.Deprecated("/v1/documents")(handler)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsRead)(handler)
+
+	mux.Handle("/documents", authenticate(handler))
+
+	return mux
+}
+
+func SetupDocumentsDeleteDocumentHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := DocumentsDeleteDocumentHandler(documentService)
+	handler = middleware.Deprecated("/v1/documents/{id}")(handler)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsWrite)(handler)
+
+	mux.Handle("/documents/deletedocument", authenticate(handler))
+
+	return mux
+}
+
+func SetupDocumentsUpdateDocumentNameHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := DocumentsUpdateDocumentNameHandler(documentService)
+	handler = middleware.Deprecated("/v1/documents/{id}")(handler)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsWrite)(handler)
+
+	mux.Handle("/documents/update_document_name", authenticate(handler))
+
+	return mux
+}
+
+func SetupDocumentsUpdateDocumentContentHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := DocumentsUpdateDocumentContentHandler(documentService)
+	handler = middleware.Deprecated("/v1/documents/{id}/content")(handler)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsWrite)(handler)
+
+	mux.Handle("/documents/update_document_content", authenticate(handler))
+
+	return mux
+}
+
+func SetupDocumentsGetTrashHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := DocumentsGetTrashHandler(documentService)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsRead)(handler)
+
+	mux.Handle("/documents/trash", authenticate(handler))
+
+	return mux
+}
+
+func SetupDocumentsRestoreDocumentHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := DocumentsRestoreDocumentHandler(documentService)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsWrite)(handler)
+
+	mux.Handle("/documents/restore_document", authenticate(handler))
+
+	return mux
+}
+
+func SetupDocumentsPurgeDocumentHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := DocumentsPurgeDocumentHandler(documentService)
+	handler = middleware.Deprecated("/v1/documents/{id}")(handler)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsWrite)(handler)
+
+	mux.Handle("/documents/purge_document", authenticate(handler))
 
 	return mux
 }
 
-func SetupDocumentsUploadHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, client clerk.Client) *http.ServeMux {
+func SetupDocumentsUpdateDocumentMetadataHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
 
-	handler := DocumentsUploadHandler(documentService, client)
+	handler := DocumentsUpdateDocumentMetadataHandler(documentService)
+	handler = middleware.Deprecated("/v1/documents/{id}")(handler)
 
-	injectActiveSession := clerk.WithSession(client)
+	authenticate := auth.Middleware(authenticator)
 
 	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsWrite)(handler)
 
-	mux.Handle("/documents/upload", injectActiveSession(handler))
+	mux.Handle("/documents/update_document_metadata", authenticate(handler))
 
 	return mux
 }
 
-func SetupDocumentsGetDocumentHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, client clerk.Client) *http.ServeMux {
+func SetupDocumentsFilterDocumentsHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
 
-	handler := DocumentsGetDocumentHandler(documentService, client)
+	handler := DocumentsFilterDocumentsHandler(documentService)
 
-	injectActiveSession := clerk.WithSession(client)
+	authenticate := auth.Middleware(authenticator)
 
 	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsRead)(handler)
 
-	mux.Handle("/documents/getdocument", injectActiveSession(handler))
+	mux.Handle("/documents/filter", authenticate(handler))
 
 	return mux
 }
 
-func SetupDocumentsGetAllDocumentHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, client clerk.Client) *http.ServeMux {
+func SetupDocumentsShareDocumentHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
 
-	handler := DocumentsGetAllDocumentsHandler(documentService, client)
+	handler := DocumentsShareDocumentHandler(documentService)
 
-	injectActiveSession := clerk.WithSession(client)
+	authenticate := auth.Middleware(authenticator)
 
 	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsWrite)(handler)
 
-	mux.Handle("/documents/get_all_documents", injectActiveSession(handler))
+	mux.Handle("/documents/share", authenticate(handler))
 
 	return mux
 }
 
-func SetupDocumentsDeleteDocumentHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, client clerk.Client) *http.ServeMux {
+func SetupDocumentsCreateDocumentLinkHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := DocumentsCreateDocumentLinkHandler(documentService)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsWrite)(handler)
+
+	mux.Handle("/documents/share_link", authenticate(handler))
+
+	return mux
+}
+
+func SetupDocumentsGetDocumentGrantsHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := DocumentsGetDocumentGrantsHandler(documentService)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsRead)(handler)
+
+	mux.Handle("/documents/grants", authenticate(handler))
+
+	return mux
+}
+
+func SetupDocumentsRevokeDocumentGrantHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := DocumentsRevokeDocumentGrantHandler(documentService)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsWrite)(handler)
+
+	mux.Handle("/documents/revoke_grant", authenticate(handler))
+
+	return mux
+}
+
+func SetupDocumentsGetSharedDocumentsHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := DocumentsGetSharedDocumentsHandler(documentService)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsRead)(handler)
+
+	mux.Handle("/documents/shared", authenticate(handler))
+
+	return mux
+}
 
-	handler := DocumentsDeleteDocumentHandler(documentService, client)
+// SetupSharedDocumentHandler registers the public route of sharing links.
+func SetupSharedDocumentHandler(mux *http.ServeMux, documentService documentservice.DocumentService) *http.ServeMux {
 
-	injectActiveSession := clerk.WithSession(client)
+	handler := SharedDocumentHandler(documentService)
 
 	handler = middleware.Logging(handler)
 
-	mux.Handle("/documents/deletedocument", injectActiveSession(handler))
+	mux.Handle("/shared/document", handler)
 
 	return mux
 }
 
-func SetupDocumentsUpdateDocumentNameHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, client clerk.Client) *http.ServeMux {
+// SetupDocumentsV1Handler registers the document resource. The handler checks
+// the scope itself since it depends on the method.
+func SetupDocumentsV1Handler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
 
-	handler := DocumentsUpdateDocumentNameHandler(documentService, client)
+	handler := DocumentsV1Handler(documentService)
 
-	injectActiveSession := clerk.WithSession(client)
+	authenticate := auth.Middleware(authenticator)
 
 	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
 
-	mux.Handle("/documents/update_document_name", injectActiveSession(handler))
+	mux.Handle(documentsV1Path, authenticate(handler))
+	mux.Handle(documentsV1Path+"/", authenticate(handler))
 
 	return mux
 }
 
-func SetupDocumentsUpdateDocumentContentHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, client clerk.Client) *http.ServeMux {
+// SetupDocumentsByIDHandler registers the routes below /documents/ that take
+// the document ID in the path. The other routes below /documents/ are longer
+// patterns and take precedence.
+func SetupDocumentsByIDHandler(config *config.ServerConfig, mux *http.ServeMux, documentService documentservice.DocumentService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
 
-	handler := DocumentsUpdateDocumentContentHandler(documentService, client)
+	handler := DocumentsByIDHandler(documentService)
 
-	injectActiveSession := clerk.WithSession(client)
+	authenticate := auth.Middleware(authenticator)
 
 	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
 
-	mux.Handle("/documents/update_document_content", injectActiveSession(handler))
+	mux.Handle(documentsPath+"/", authenticate(handler))
 
 	return mux
 }
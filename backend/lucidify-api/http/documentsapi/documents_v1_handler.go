@@ -0,0 +1,537 @@
+package documentsapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/http/apierror"
+	"lucidify-api/server/auth"
+	"lucidify-api/service/documentservice"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	documentsPath   = "/documents"
+	documentsV1Path = "/v1/documents"
+)
+
+// documentV1Request is a request on the document resource, with the principal
+// and the parts of the path that identify what it is about.
+type documentV1Request struct {
+	principal  *auth.Principal
+	documentID uuid.UUID
+	version    int
+}
+
+type documentV1Route func(http.ResponseWriter, *http.Request, documentV1Request)
+
+// DocumentsV1Handler serves the document resource:
+//
+//	GET    /v1/documents                          list documents, see DocumentsListHandler
+//	POST   /v1/documents                          create a document
+//	GET    /v1/documents/{id}                     describe a document, with its content if ?view=full
+//	PATCH  /v1/documents/{id}                     rename it or replace its metadata
+//	DELETE /v1/documents/{id}                     move it to the trash, or purge it with ?permanent=true
+//	GET    /v1/documents/{id}/content             download its content, see getDocumentContentV1
+//	PUT    /v1/documents/{id}/content             replace its content with the request body
+//	GET    /v1/documents/{id}/chunks              list its chunks
+//	GET    /v1/documents/{id}/versions            list its previous versions
+//	GET    /v1/documents/{id}/versions/{version}  get a previous version
+//
+// GET and HEAD requests need the documents:read scope and the others
+// documents:write. Errors are written as an apierror envelope.
+func DocumentsV1Handler(documentService documentservice.DocumentService) http.HandlerFunc {
+	return documentsResourceHandler(documentsV1Path, map[string]map[string]documentV1Route{
+		"": {
+			http.MethodGet:  listDocumentsV1(documentService),
+			http.MethodPost: createDocumentV1(documentService),
+		},
+		"document": {
+			http.MethodGet:    getDocumentV1(documentService),
+			http.MethodHead:   getDocumentV1(documentService),
+			http.MethodPatch:  patchDocumentV1(documentService),
+			http.MethodDelete: deleteDocumentV1(documentService),
+		},
+		"content": {
+			http.MethodGet:  getDocumentContentV1(documentService),
+			http.MethodHead: getDocumentContentV1(documentService),
+			http.MethodPut:  putDocumentContentV1(documentService),
+		},
+		"chunks": {
+			http.MethodGet: getDocumentChunksV1(documentService),
+		},
+		"versions": {
+			http.MethodGet: getDocumentVersionsV1(documentService),
+		},
+		"version": {
+			http.MethodGet: getDocumentVersionV1(documentService),
+		},
+	})
+}
+
+// DocumentsByIDHandler serves the read-only document routes of the unversioned
+// API, for clients that cannot send a body with a GET:
+//
+//	GET /documents/{id}          describe a document, as GET /v1/documents/{id}
+//	GET /documents/{id}/content  download its content, as GET /v1/documents/{id}/content
+func DocumentsByIDHandler(documentService documentservice.DocumentService) http.HandlerFunc {
+	return documentsResourceHandler(documentsPath, map[string]map[string]documentV1Route{
+		"document": {
+			http.MethodGet:  getDocumentV1(documentService),
+			http.MethodHead: getDocumentV1(documentService),
+		},
+		"content": {
+			http.MethodGet:  getDocumentContentV1(documentService),
+			http.MethodHead: getDocumentContentV1(documentService),
+		},
+	})
+}
+
+// documentsResourceHandler dispatches the requests below basePath to the routes
+// keyed by parseDocumentV1Path and by method.
+func documentsResourceHandler(basePath string, routes map[string]map[string]documentV1Route) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			apierror.Write(w, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		request, route, ok := parseDocumentV1Path(basePath, r.URL.Path)
+		if !ok {
+			apierror.NotFound(w, "Not found")
+			return
+		}
+		request.principal = principal
+
+		handlers, ok := routes[route]
+		if !ok {
+			apierror.NotFound(w, "Not found")
+			return
+		}
+		handler, ok := handlers[r.Method]
+		if !ok {
+			allowed := make([]string, 0, len(handlers))
+			for method := range handlers {
+				allowed = append(allowed, method)
+			}
+			sort.Strings(allowed)
+			apierror.MethodNotAllowed(w, allowed...)
+			return
+		}
+
+		scope := storemodels.ScopeDocumentsWrite
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			scope = storemodels.ScopeDocumentsRead
+		}
+		if !principal.HasScope(scope) {
+			apierror.Write(w, http.StatusForbidden, apierror.CodeForbidden, "The API key is missing the "+scope+" scope")
+			return
+		}
+
+		handler(w, r, request)
+	}
+}
+
+// parseDocumentV1Path splits a path below basePath into the route key of
+// documentsResourceHandler and the identifiers it contains.
+func parseDocumentV1Path(basePath, path string) (documentV1Request, string, bool) {
+	var request documentV1Request
+
+	rest := strings.Trim(strings.TrimPrefix(path, basePath), "/")
+	if rest == "" {
+		return request, "", true
+	}
+
+	segments := strings.Split(rest, "/")
+	documentID, err := uuid.Parse(segments[0])
+	if err != nil {
+		return request, "", false
+	}
+	request.documentID = documentID
+
+	switch {
+	case len(segments) == 1:
+		return request, "document", true
+	case len(segments) == 2 && (segments[1] == "content" || segments[1] == "chunks" || segments[1] == "versions"):
+		return request, segments[1], true
+	case len(segments) == 3 && segments[1] == "versions":
+		version, err := strconv.Atoi(segments[2])
+		if err != nil || version <= 0 {
+			return request, "", false
+		}
+		request.version = version
+		return request, "version", true
+	}
+	return request, "", false
+}
+
+// writeDocumentV1Error responds with the status matching an error of the
+// document service.
+func writeDocumentV1Error(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, documentservice.ErrDocumentNotFound),
+		errors.Is(err, documentservice.ErrDocumentVersionNotFound):
+		apierror.NotFound(w, err.Error())
+	case errors.Is(err, documentservice.ErrDocumentAccessDenied),
+		errors.Is(err, documentservice.ErrWorkspaceAccessDenied):
+		apierror.Write(w, http.StatusForbidden, apierror.CodeForbidden, err.Error())
+	case errors.Is(err, documentservice.ErrDocumentNameConflict),
+		errors.Is(err, documentservice.ErrDocumentNotInTrash):
+		apierror.Write(w, http.StatusConflict, apierror.CodeConflict, err.Error())
+	case errors.Is(err, documentservice.ErrDocumentModified):
+		apierror.Write(w, http.StatusPreconditionFailed, apierror.CodePreconditionFailed, err.Error())
+	case errors.Is(err, documentservice.ErrInvalidCursor):
+		apierror.BadRequest(w, err.Error())
+	default:
+		log.Printf("Failed to %s: %v", action, err)
+		apierror.Internal(w, "Unable to "+action)
+	}
+}
+
+// writeDocumentV1UpdateError is writeDocumentV1Error for updates, which
+// respond to a stale If-Match with the current document and its ETag.
+func writeDocumentV1UpdateError(ctx context.Context, w http.ResponseWriter, err error, action string, documentService documentservice.DocumentService, request documentV1Request) {
+	if !errors.Is(err, documentservice.ErrDocumentModified) {
+		writeDocumentV1Error(w, err, action)
+		return
+	}
+
+	document, err := documentService.GetDocumentInfo(ctx, request.principal.UserID, request.documentID)
+	if err != nil {
+		writeDocumentV1Error(w, err, "get document")
+		return
+	}
+	w.Header().Set("ETag", documentETag(document.Revision))
+	apierror.WriteDetails(w, http.StatusPreconditionFailed, apierror.CodePreconditionFailed, documentservice.ErrDocumentModified.Error(), document)
+}
+
+func writeDocumentV1JSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(value); err != nil {
+		log.Printf("Failed to encode response as JSON: %v", err)
+	}
+}
+
+func listDocumentsV1(documentService documentservice.DocumentService) documentV1Route {
+	return func(w http.ResponseWriter, r *http.Request, request documentV1Request) {
+		ctx := r.Context()
+
+		query := r.URL.Query()
+		options, err := parseDocumentListOptions(query)
+		if err != nil {
+			apierror.BadRequest(w, err.Error())
+			return
+		}
+
+		page, err := documentService.ListDocuments(ctx, request.principal.UserID, options, query.Get("cursor"))
+		if err != nil {
+			writeDocumentV1Error(w, err, "list documents")
+			return
+		}
+
+		writeDocumentV1JSON(w, http.StatusOK, page)
+	}
+}
+
+func createDocumentV1(documentService documentservice.DocumentService) documentV1Route {
+	return func(w http.ResponseWriter, r *http.Request, request documentV1Request) {
+		ctx := r.Context()
+
+		var reqBody struct {
+			DocumentName string                       `json:"document_name"`
+			Content      string                       `json:"content"`
+			WorkspaceID  string                       `json:"workspace_id"`
+			Metadata     storemodels.DocumentMetadata `json:"metadata"`
+			OnDuplicate  string                       `json:"on_duplicate"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			apierror.BadRequest(w, "Invalid JSON body")
+			return
+		}
+		if strings.TrimSpace(reqBody.DocumentName) == "" {
+			apierror.BadRequest(w, "document_name is required")
+			return
+		}
+		if err := reqBody.Metadata.Validate(); err != nil {
+			apierror.BadRequest(w, err.Error())
+			return
+		}
+		policy, err := documentservice.ParseDuplicatePolicy(reqBody.OnDuplicate)
+		if err != nil {
+			apierror.BadRequest(w, err.Error())
+			return
+		}
+
+		document, created, err := documentService.UploadDocumentDeduplicated(ctx, request.principal.UserID, reqBody.WorkspaceID, reqBody.DocumentName, reqBody.Content, reqBody.Metadata, policy)
+		if errors.Is(err, documentservice.ErrDuplicateDocument) {
+			apierror.WriteDetails(w, http.StatusConflict, apierror.CodeConflict, err.Error(), map[string]interface{}{
+				"document_id":   document.DocumentUUID,
+				"document_name": document.DocumentName,
+			})
+			return
+		}
+		if err != nil {
+			writeDocumentV1Error(w, err, "create document")
+			return
+		}
+
+		// An existing document with the same content is returned as is
+		status := http.StatusCreated
+		if !created {
+			status = http.StatusOK
+		}
+		w.Header().Set("Location", documentsV1Path+"/"+document.DocumentUUID.String())
+		w.Header().Set("ETag", documentETag(document.Revision))
+		writeDocumentV1JSON(w, status, document)
+	}
+}
+
+// getDocumentV1 describes the document, with its size and number of chunks.
+// The content is left out unless the view query parameter is full. The ETag
+// is the one to send back in the If-Match header of updates.
+func getDocumentV1(documentService documentservice.DocumentService) documentV1Route {
+	return func(w http.ResponseWriter, r *http.Request, request documentV1Request) {
+		ctx := r.Context()
+
+		var document interface{}
+		var revision int64
+		switch r.URL.Query().Get("view") {
+		case "", "summary":
+			info, err := documentService.GetDocumentInfo(ctx, request.principal.UserID, request.documentID)
+			if err != nil {
+				writeDocumentV1Error(w, err, "get document")
+				return
+			}
+			document, revision = info, info.Revision
+		case "full":
+			full, err := documentService.GetDocumentByID(ctx, request.principal.UserID, request.documentID)
+			if err != nil {
+				writeDocumentV1Error(w, err, "get document")
+				return
+			}
+			document, revision = full, full.Revision
+		default:
+			apierror.BadRequest(w, "Invalid view, expected summary or full")
+			return
+		}
+
+		w.Header().Set("ETag", documentETag(revision))
+		writeDocumentV1JSON(w, http.StatusOK, document)
+	}
+}
+
+// patchDocumentV1 changes the fields present in the body as a single revision.
+// Metadata is replaced as a whole. With an If-Match header, the changes only
+// apply to that revision.
+func patchDocumentV1(documentService documentservice.DocumentService) documentV1Route {
+	return func(w http.ResponseWriter, r *http.Request, request documentV1Request) {
+		ctx := r.Context()
+
+		expectedRevision, err := parseIfMatch(r)
+		if err != nil {
+			apierror.BadRequest(w, err.Error())
+			return
+		}
+
+		var reqBody struct {
+			DocumentName *string                       `json:"document_name"`
+			Metadata     *storemodels.DocumentMetadata `json:"metadata"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			apierror.BadRequest(w, "Invalid JSON body")
+			return
+		}
+		if reqBody.DocumentName == nil && reqBody.Metadata == nil {
+			apierror.BadRequest(w, "Nothing to update, expected document_name or metadata")
+			return
+		}
+		if reqBody.DocumentName != nil && strings.TrimSpace(*reqBody.DocumentName) == "" {
+			apierror.BadRequest(w, "document_name must not be empty")
+			return
+		}
+		if reqBody.Metadata != nil {
+			if err := reqBody.Metadata.Validate(); err != nil {
+				apierror.BadRequest(w, err.Error())
+				return
+			}
+		}
+
+		userID := request.principal.UserID
+		err = documentService.UpdateDocument(ctx, userID, request.documentID, reqBody.DocumentName, reqBody.Metadata, expectedRevision)
+		if err != nil {
+			writeDocumentV1UpdateError(ctx, w, err, "update document", documentService, request)
+			return
+		}
+
+		document, err := documentService.GetDocumentInfo(ctx, userID, request.documentID)
+		if err != nil {
+			writeDocumentV1Error(w, err, "get document")
+			return
+		}
+
+		w.Header().Set("ETag", documentETag(document.Revision))
+		writeDocumentV1JSON(w, http.StatusOK, document)
+	}
+}
+
+func deleteDocumentV1(documentService documentservice.DocumentService) documentV1Route {
+	return func(w http.ResponseWriter, r *http.Request, request documentV1Request) {
+		ctx := r.Context()
+
+		permanent, err := parseOptionalBool(r.URL.Query().Get("permanent"))
+		if err != nil {
+			apierror.BadRequest(w, "Invalid permanent, expected true or false")
+			return
+		}
+
+		if permanent {
+			err = documentService.PurgeDocument(ctx, request.principal.UserID, request.documentID)
+		} else {
+			err = documentService.DeleteDocument(ctx, request.principal.UserID, request.documentID)
+		}
+		if err != nil {
+			writeDocumentV1Error(w, err, "delete document")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// getDocumentContentV1 sends the content of the document as a text file named
+// after the document. The ETag changes with every revision of the document, so
+// clients can revalidate with If-None-Match, resume downloads with Range and
+// If-Range, and update the content with If-Match.
+func getDocumentContentV1(documentService documentservice.DocumentService) documentV1Route {
+	return func(w http.ResponseWriter, r *http.Request, request documentV1Request) {
+		ctx := r.Context()
+
+		document, err := documentService.GetDocumentByID(ctx, request.principal.UserID, request.documentID)
+		if err != nil {
+			writeDocumentV1Error(w, err, "get document")
+			return
+		}
+
+		filename := contentFilename(document.DocumentName)
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+		if disposition == "" {
+			disposition = "attachment"
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", disposition)
+		w.Header().Set("ETag", documentETag(document.Revision))
+		w.Header().Set("Cache-Control", "private, no-cache")
+		http.ServeContent(w, r, filename, document.UpdatedAt, strings.NewReader(document.Content))
+	}
+}
+
+// contentFilename turns a document name into a file name, adding a .txt
+// extension to names without one.
+func contentFilename(documentName string) string {
+	filename := strings.NewReplacer("/", "_", "\\", "_").Replace(documentName)
+	if path.Ext(filename) == "" {
+		filename += ".txt"
+	}
+	return filename
+}
+
+// putDocumentContentV1 replaces the content of the document with the request
+// body, taken as plain text, and responds with the ETag of the new revision.
+func putDocumentContentV1(documentService documentservice.DocumentService) documentV1Route {
+	return func(w http.ResponseWriter, r *http.Request, request documentV1Request) {
+		ctx := r.Context()
+
+		expectedRevision, err := parseIfMatch(r)
+		if err != nil {
+			apierror.BadRequest(w, err.Error())
+			return
+		}
+
+		content, err := io.ReadAll(r.Body)
+		if err != nil {
+			apierror.BadRequest(w, "Unable to read the request body")
+			return
+		}
+
+		err = documentService.UpdateDocumentContent(ctx, request.principal.UserID, request.documentID, string(content), expectedRevision)
+		if err != nil {
+			writeDocumentV1UpdateError(ctx, w, err, "update document content", documentService, request)
+			return
+		}
+
+		if document, err := documentService.GetDocumentInfo(ctx, request.principal.UserID, request.documentID); err == nil {
+			w.Header().Set("ETag", documentETag(document.Revision))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func getDocumentChunksV1(documentService documentservice.DocumentService) documentV1Route {
+	return func(w http.ResponseWriter, r *http.Request, request documentV1Request) {
+		ctx := r.Context()
+
+		chunks, err := documentService.GetDocumentChunks(ctx, request.principal.UserID, request.documentID)
+		if err != nil {
+			writeDocumentV1Error(w, err, "get document chunks")
+			return
+		}
+		if chunks == nil {
+			chunks = []storemodels.Chunk{}
+		}
+
+		writeDocumentV1JSON(w, http.StatusOK, chunks)
+	}
+}
+
+func getDocumentVersionsV1(documentService documentservice.DocumentService) documentV1Route {
+	return func(w http.ResponseWriter, r *http.Request, request documentV1Request) {
+		ctx := r.Context()
+
+		versions, err := documentService.GetDocumentVersions(ctx, request.principal.UserID, request.documentID)
+		if err != nil {
+			writeDocumentV1Error(w, err, "get document versions")
+			return
+		}
+		if versions == nil {
+			versions = []storemodels.DocumentVersion{}
+		}
+
+		writeDocumentV1JSON(w, http.StatusOK, versions)
+	}
+}
+
+func getDocumentVersionV1(documentService documentservice.DocumentService) documentV1Route {
+	return func(w http.ResponseWriter, r *http.Request, request documentV1Request) {
+		ctx := r.Context()
+
+		version, err := documentService.GetDocumentVersion(ctx, request.principal.UserID, request.documentID, request.version)
+		if err != nil {
+			writeDocumentV1Error(w, err, "get document version")
+			return
+		}
+
+		writeDocumentV1JSON(w, http.StatusOK, version)
+	}
+}
+
+func parseOptionalBool(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
@@ -0,0 +1,286 @@
+// //go:build integration
+// // +build integration
+package documentsapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/http/apierror"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDocumentsV1HandlerIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	setup := SetupTestEnvironment(t)
+	cfg := setup.Config
+	postgresqlDB := setup.PostgresqlDB
+
+	mux := http.NewServeMux()
+	SetupRoutes(cfg, mux, setup.DocumentService, setup.UserService, setup.Authenticator)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	t.Cleanup(func() {
+		postgresqlDB.DeleteDocument(ctx, cfg.TestUserID, "V1 Document")
+		postgresqlDB.DeleteDocument(ctx, cfg.TestUserID, "V1 Document Renamed")
+		postgresqlDB.DeleteUserInUsersTable(ctx, cfg.TestUserID)
+	})
+
+	doIfMatch := func(method, path, body, etag string) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+setup.JWTSessionToken)
+		if etag != "" {
+			req.Header.Set("If-Match", etag)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send %s %s: %v", method, path, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	do := func(method, path, body string) *http.Response {
+		return doIfMatch(method, path, body, "")
+	}
+	expectStatus := func(resp *http.Response, status int) {
+		t.Helper()
+		if resp.StatusCode != status {
+			body, _ := io.ReadAll(resp.Body)
+			t.Fatalf("Expected status code %d for %s %s, got %d: %s", status, resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, body)
+		}
+	}
+	expectErrorCode := func(resp *http.Response, code string) {
+		t.Helper()
+		var envelope apierror.Envelope
+		if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+			t.Fatalf("Failed to decode error envelope: %v", err)
+		}
+		if envelope.Error.Code != code {
+			t.Errorf("Expected error code %s, got %+v", code, envelope.Error)
+		}
+	}
+
+	resp := do(http.MethodPost, "/v1/documents", `{"document_name": "V1 Document", "content": "First content"}`)
+	expectStatus(resp, http.StatusCreated)
+	var document storemodels.Document
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		t.Fatalf("Failed to decode document: %v", err)
+	}
+	documentPath := "/v1/documents/" + document.DocumentUUID.String()
+	if location := resp.Header.Get("Location"); location != documentPath {
+		t.Errorf("Expected location %s, got %s", documentPath, location)
+	}
+
+	resp = do(http.MethodPost, "/v1/documents", `{"document_name": "V1 Document", "content": "Duplicate"}`)
+	expectStatus(resp, http.StatusConflict)
+	expectErrorCode(resp, apierror.CodeConflict)
+
+	// The ETag of the summary is the one to send back when updating
+	resp = do(http.MethodGet, documentPath, "")
+	expectStatus(resp, http.StatusOK)
+	etag := resp.Header.Get("ETag")
+	if etag != `"1"` {
+		t.Errorf("Expected the ETag %q of the summary, got %q", `"1"`, etag)
+	}
+
+	resp = doIfMatch(http.MethodPatch, documentPath, `{"document_name": "V1 Document Renamed", "metadata": {"team": "search"}}`, etag)
+	expectStatus(resp, http.StatusOK)
+	if etag := resp.Header.Get("ETag"); etag != `"2"` {
+		t.Errorf("Expected the ETag %q after the patch, got %q", `"2"`, etag)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		t.Fatalf("Failed to decode document: %v", err)
+	}
+	if document.DocumentName != "V1 Document Renamed" || document.Metadata["team"] != "search" {
+		t.Errorf("Unexpected document after patch: %+v", document)
+	}
+
+	// The rename and the metadata made a single revision
+	resp = doIfMatch(http.MethodPut, documentPath+"/content", "Stale content", etag)
+	expectStatus(resp, http.StatusPreconditionFailed)
+	if etag := resp.Header.Get("ETag"); etag != `"2"` {
+		t.Errorf("Expected the current ETag %q, got %q", `"2"`, etag)
+	}
+	expectErrorCode(resp, apierror.CodePreconditionFailed)
+
+	resp = do(http.MethodPut, documentPath+"/content", "Second content")
+	expectStatus(resp, http.StatusNoContent)
+
+	resp = do(http.MethodGet, documentPath+"/content", "")
+	expectStatus(resp, http.StatusOK)
+	content, _ := io.ReadAll(resp.Body)
+	if string(content) != "Second content" {
+		t.Errorf("Expected content %q, got %q", "Second content", content)
+	}
+
+	resp = do(http.MethodGet, documentPath+"/versions", "")
+	expectStatus(resp, http.StatusOK)
+	var versions []storemodels.DocumentVersion
+	if err := json.NewDecoder(resp.Body).Decode(&versions); err != nil {
+		t.Fatalf("Failed to decode versions: %v", err)
+	}
+	if len(versions) != 1 || versions[0].Version != 1 || versions[0].Content != "" {
+		t.Fatalf("Expected one version without content, got %+v", versions)
+	}
+
+	resp = do(http.MethodGet, documentPath+"/versions/1", "")
+	expectStatus(resp, http.StatusOK)
+	var version storemodels.DocumentVersion
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		t.Fatalf("Failed to decode version: %v", err)
+	}
+	if version.Content != "First content" {
+		t.Errorf("Expected version content %q, got %q", "First content", version.Content)
+	}
+
+	resp = do(http.MethodGet, documentPath+"/versions/2", "")
+	expectStatus(resp, http.StatusNotFound)
+	expectErrorCode(resp, apierror.CodeNotFound)
+
+	resp = do(http.MethodGet, documentPath+"/chunks", "")
+	expectStatus(resp, http.StatusOK)
+	var chunks []storemodels.Chunk
+	if err := json.NewDecoder(resp.Body).Decode(&chunks); err != nil {
+		t.Fatalf("Failed to decode chunks: %v", err)
+	}
+	if len(chunks) == 0 || chunks[0].DocumentID != document.DocumentUUID {
+		t.Errorf("Expected the chunks of the document, got %+v", chunks)
+	}
+
+	resp = do(http.MethodPost, documentPath, "")
+	expectStatus(resp, http.StatusMethodNotAllowed)
+	if allow := resp.Header.Values("Allow"); len(allow) != 4 {
+		t.Errorf("Expected four allowed methods, got %v", allow)
+	}
+
+	resp = do(http.MethodDelete, documentPath+"?permanent=true", "")
+	expectStatus(resp, http.StatusNoContent)
+
+	resp = do(http.MethodGet, documentPath, "")
+	expectStatus(resp, http.StatusNotFound)
+	expectErrorCode(resp, apierror.CodeNotFound)
+
+	resp = do(http.MethodGet, "/v1/documents/"+uuid.NewString()+"/unknown", "")
+	expectStatus(resp, http.StatusNotFound)
+}
+
+func TestDocumentsDeprecatedRoutesIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	setup := SetupTestEnvironment(t)
+	cfg := setup.Config
+
+	mux := http.NewServeMux()
+	SetupRoutes(cfg, mux, setup.DocumentService, setup.UserService, setup.Authenticator)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	t.Cleanup(func() {
+		setup.PostgresqlDB.DeleteUserInUsersTable(ctx, cfg.TestUserID)
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/documents/get_all_documents", bytes.NewBuffer(nil))
+	req.Header.Set("Authorization", "Bearer "+setup.JWTSessionToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if resp.Header.Get("Deprecation") != "true" || !strings.Contains(resp.Header.Get("Link"), "/v1/documents") {
+		t.Errorf("Expected deprecation headers, got %v", resp.Header)
+	}
+}
+
+func TestDocumentsByIDContentDownloadIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	setup := SetupTestEnvironment(t)
+	cfg := setup.Config
+	postgresqlDB := setup.PostgresqlDB
+
+	mux := http.NewServeMux()
+	SetupRoutes(cfg, mux, setup.DocumentService, setup.UserService, setup.Authenticator)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	document, err := setup.DocumentService.UploadDocument(ctx, cfg.TestUserID, "Download Document", "0123456789")
+	if err != nil {
+		t.Fatalf("Failed to upload document: %v", err)
+	}
+	t.Cleanup(func() {
+		postgresqlDB.DeleteDocument(ctx, cfg.TestUserID, "Download Document")
+		postgresqlDB.DeleteUserInUsersTable(ctx, cfg.TestUserID)
+	})
+
+	get := func(path string, header http.Header) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		req.Header.Set("Authorization", "Bearer "+setup.JWTSessionToken)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	documentPath := "/documents/" + document.DocumentUUID.String()
+
+	resp := get(documentPath, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	var info storemodels.DocumentListItem
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode document: %v", err)
+	}
+	if info.Content != "" || info.Size != 10 || info.DocumentName != "Download Document" {
+		t.Errorf("Expected the document without its content, got %+v", info)
+	}
+
+	resp = get(documentPath+"/content", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Errorf("Expected an ETag")
+	}
+	if disposition := resp.Header.Get("Content-Disposition"); disposition != `attachment; filename="Download Document.txt"` {
+		t.Errorf("Unexpected Content-Disposition %q", disposition)
+	}
+
+	resp = get(documentPath+"/content", http.Header{"If-None-Match": {etag}})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotModified, resp.StatusCode)
+	}
+
+	resp = get(documentPath+"/content", http.Header{"Range": {"bytes=2-5"}})
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Fatalf("Expected status code %d, got %d", http.StatusPartialContent, resp.StatusCode)
+	}
+	content, _ := io.ReadAll(resp.Body)
+	if string(content) != "2345" {
+		t.Errorf("Expected range %q, got %q", "2345", content)
+	}
+
+	resp = get("/documents/"+uuid.NewString(), nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
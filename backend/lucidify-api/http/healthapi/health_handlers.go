@@ -0,0 +1,61 @@
+package healthapi
+
+import (
+	"encoding/json"
+	"lucidify-api/service/healthservice"
+	"net/http"
+)
+
+// HealthzHandler reports that the process is up, without checking its
+// dependencies.
+func HealthzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"ok"}`))
+	}
+}
+
+// ReadyzHandler responds with 503 Service Unavailable when a dependency cannot
+// be reached, listing which in the body. The errors of the dependencies are
+// left out, as the probe is unauthenticated; /status shows them.
+func ReadyzHandler(healthService healthservice.HealthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		readiness := withoutErrors(healthService.Ready(ctx))
+
+		w.Header().Set("Content-Type", "application/json")
+		if !readiness.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(readiness); err != nil {
+			http.Error(w, "Internal server error. Unable to encode readiness as JSON", http.StatusInternalServerError)
+		}
+	}
+}
+
+func withoutErrors(readiness *healthservice.Readiness) *healthservice.Readiness {
+	public := *readiness
+	public.Dependencies = make([]healthservice.DependencyCheck, len(readiness.Dependencies))
+	for i, dependency := range readiness.Dependencies {
+		dependency.Error = ""
+		public.Dependencies[i] = dependency
+	}
+	return &public
+}
+
+func StatusHandler(healthService healthservice.HealthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(healthService.Status(ctx)); err != nil {
+			http.Error(w, "Internal server error. Unable to encode status as JSON", http.StatusInternalServerError)
+		}
+	}
+}
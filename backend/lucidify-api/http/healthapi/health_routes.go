@@ -0,0 +1,30 @@
+package healthapi
+
+import (
+	"lucidify-api/server/config"
+	"lucidify-api/server/middleware"
+	"lucidify-api/service/healthservice"
+	"net/http"
+)
+
+func SetupRoutes(config *config.ServerConfig, mux *http.ServeMux, healthService healthservice.HealthService) *http.ServeMux {
+	// Probes are not logged, as orchestrators call them every few seconds
+	mux.HandleFunc("/healthz", HealthzHandler())
+	mux.HandleFunc("/readyz", ReadyzHandler(healthService))
+	mux = SetupStatusHandler(config, mux, healthService)
+
+	return mux
+}
+
+// SetupStatusHandler serves the status to admins, as it describes the
+// deployment.
+func SetupStatusHandler(config *config.ServerConfig, mux *http.ServeMux, healthService healthservice.HealthService) *http.ServeMux {
+	handler := StatusHandler(healthService)
+
+	handler = middleware.AdminAuthenticationMiddleware(config)(handler)
+	handler = middleware.Logging(handler)
+
+	mux.HandleFunc("/status", handler)
+
+	return mux
+}
@@ -5,10 +5,9 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"lucidify-api/server/auth"
 	"lucidify-api/service/syncservice"
 	"net/http"
-
-	"github.com/clerkinc/clerk-sdk-go/clerk"
 )
 
 // LocalStorageKey defines valid keys for LocalStorage operations.
@@ -53,8 +52,10 @@ func sendJSONResponse(w http.ResponseWriter, statusCode int, response syncservic
 	}
 }
 
-func SyncHandler(syncService syncservice.SyncService, clerkInstance clerk.Client) http.Handler {
+func SyncHandler(syncService syncservice.SyncService) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
 		w.Header().Set("Content-Type", "application/json")
 
 		key := r.URL.Query().Get("key")
@@ -63,7 +64,7 @@ func SyncHandler(syncService syncservice.SyncService, clerkInstance clerk.Client
 			return
 		}
 
-		userID, err := getUserIDFromSession(r, clerkInstance)
+		userID, err := getUserIDFromSession(r)
 		if err != nil {
 			sendError(w, "Unauthorized", http.StatusUnauthorized)
 			return
@@ -80,16 +81,16 @@ func SyncHandler(syncService syncservice.SyncService, clerkInstance clerk.Client
 
 		switch r.Method {
 		case http.MethodGet:
-			response = syncService.HandleGet(userID, key)
+			response = syncService.HandleGet(ctx, userID, key)
 		case http.MethodDelete:
 			if key == string(clearConversations) {
-				response = syncService.HandleClearConversations(userID)
+				response = syncService.HandleClearConversations(ctx, userID)
 			} else {
 				sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
 				return
 			}
 		case http.MethodPost:
-			response = syncService.HandleSet(userID, key, value)
+			response = syncService.HandleSet(ctx, userID, key, value)
 		default:
 			sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
@@ -107,17 +108,11 @@ func sendError(w http.ResponseWriter, message string, statusCode int) {
 	sendJSONResponse(w, statusCode, response)
 }
 
-func getUserIDFromSession(r *http.Request, clerkInstance clerk.Client) (string, error) {
-	ctx := r.Context()
-	sessClaims, ok := clerk.SessionFromContext(ctx)
+func getUserIDFromSession(r *http.Request) (string, error) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
 	if !ok {
 		return "", fmt.Errorf("session not found")
 	}
 
-	user, err := clerkInstance.Users().Read(sessClaims.Claims.Subject)
-	if err != nil {
-		return "", err
-	}
-
-	return user.ID, nil
+	return principal.UserID, nil
 }
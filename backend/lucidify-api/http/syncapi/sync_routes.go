@@ -1,21 +1,23 @@
 package syncapi
 
 import (
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/auth"
 	"lucidify-api/server/config"
 	"lucidify-api/server/middleware"
 	"lucidify-api/service/syncservice"
+	"lucidify-api/service/userservice"
 	"net/http"
-
-	"github.com/clerkinc/clerk-sdk-go/clerk"
 )
 
 func SetupRoutes(
 	config *config.ServerConfig,
 	mux *http.ServeMux,
-	clerkInstance clerk.Client,
-	syncService syncservice.SyncService) *http.ServeMux {
+	authenticator auth.Authenticator,
+	syncService syncservice.SyncService,
+	userService userservice.UserService) *http.ServeMux {
 
-	mux = SetupSyncHandler(config, mux, syncService, clerkInstance)
+	mux = SetupSyncHandler(config, mux, syncService, userService, authenticator)
 
 	return mux
 }
@@ -23,15 +25,18 @@ func SetupRoutes(
 func SetupSyncHandler(config *config.ServerConfig,
 	mux *http.ServeMux,
 	syncService syncservice.SyncService,
-	clerkInstance clerk.Client) *http.ServeMux {
+	userService userservice.UserService,
+	authenticator auth.Authenticator) *http.ServeMux {
 
-	handler := SyncHandler(syncService, clerkInstance)
+	handler := SyncHandler(syncService)
 
-	injectActiveSession := clerk.WithSession(clerkInstance)
+	authenticate := auth.Middleware(authenticator)
 
 	handler = middleware.LoggingHandler(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler.ServeHTTP)
+	handler = auth.RequireScope(storemodels.ScopeChat)(handler.ServeHTTP)
 
-	mux.Handle("/api/sync/localstorage/", injectActiveSession(http.StripPrefix("/api/sync/localstorage/", handler)))
+	mux.Handle("/api/sync/localstorage/", authenticate(http.StripPrefix("/api/sync/localstorage/", handler)))
 
 	return mux
 }
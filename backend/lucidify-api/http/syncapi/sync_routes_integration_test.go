@@ -4,26 +4,27 @@ package syncapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"log"
 	"lucidify-api/data/store/postgresqlclient"
 	"lucidify-api/data/store/storemodels"
 	"lucidify-api/data/store/weaviateclient"
+	"lucidify-api/server/auth"
+	"lucidify-api/server/auth/authtest"
 	"lucidify-api/server/config"
-	"lucidify-api/service/clerkservice"
 	"lucidify-api/service/syncservice"
 	"lucidify-api/service/userservice"
 	"net/http"
 	"net/http/httptest"
 	"testing"
-
-	"github.com/clerkinc/clerk-sdk-go/clerk"
 )
 
 func createTestUserInDb() error {
+	ctx := context.Background()
 	testconfig := config.NewServerConfig()
-	db, err := postgresqlclient.NewPostgreSQL()
+	db, err := postgresqlclient.NewPostgreSQL(testconfig.PostgresqlURL)
 
 	// the user id registered by the jwt token must exist in the local database
 	user := storemodels.User{
@@ -41,7 +42,7 @@ func createTestUserInDb() error {
 		UpdatedAt:        1654012591514,
 	}
 
-	weaviate, err := weaviateclient.NewWeaviateClientTest()
+	weaviate, err := weaviateclient.NewWeaviateClientTest(testconfig.OPENAI_API_KEY)
 	if err != nil {
 		log.Fatalf("Failed to create WeaviateClient: %v", err)
 	}
@@ -50,21 +51,21 @@ func createTestUserInDb() error {
 		log.Fatalf("Failed to create UserService: %v", err)
 	}
 
-	err = userService.DeleteUser(user.UserID)
+	err = userService.DeleteUser(ctx, user.UserID)
 	if err != nil {
 		log.Fatalf("Failed to delete user: %v", err)
 	}
-	if !userService.HasUserBeenDeleted(user.UserID, 3) {
+	if !userService.HasUserBeenDeleted(ctx, user.UserID, 3) {
 		log.Fatalf("Failed to delete user: %v", err)
 	}
 
-	err = db.CreateUserInUsersTable(user)
+	err = db.CreateUserInUsersTable(ctx, user)
 	if err != nil {
 		log.Fatalf("Failed to create user: %v", err)
 	}
 
 	// Check if the user exists
-	_, err = userService.GetUserWithRetries(user.UserID, 3)
+	_, err = userService.GetUserWithRetries(ctx, user.UserID, 3)
 	if err != nil {
 		log.Fatalf("User not found after creation: %v", err)
 	}
@@ -73,22 +74,41 @@ func createTestUserInDb() error {
 }
 
 type TestSetup struct {
-	Config        *config.ServerConfig
-	PostgresqlDB  *postgresqlclient.PostgreSQL
-	ClerkInstance clerk.Client
+	Config          *config.ServerConfig
+	PostgresqlDB    *postgresqlclient.PostgreSQL
+	Authenticator   auth.Authenticator
+	JWTSessionToken string
+	UserService     userservice.UserService
 }
 
 func SetupTestEnvironment(t *testing.T) *TestSetup {
 	cfg := config.NewServerConfig()
 
-	postgresqlDB, err := postgresqlclient.NewPostgreSQL()
+	postgresqlDB, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
 	if err != nil {
 		t.Fatalf("Failed to create test postgresqlclient: %v", err)
 	}
 
-	clerkInstance, err := clerkservice.NewClerkClient()
+	// Sessions are signed by a local test issuer instead of a Clerk tenant
+	issuer, err := authtest.NewIssuer()
+	if err != nil {
+		t.Fatalf("Failed to create test token issuer: %v", err)
+	}
+	t.Cleanup(issuer.Close)
+
+	jwtSessionToken, err := issuer.Token(cfg.TestUserID)
+	if err != nil {
+		t.Fatalf("Failed to create session token: %v", err)
+	}
+
+	weaviate, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
 	if err != nil {
-		t.Fatalf("Failed to create Clerk client: %v", err)
+		t.Fatalf("Failed to create WeaviateClient: %v", err)
+	}
+
+	userService, err := userservice.NewUserService(postgresqlDB, weaviate)
+	if err != nil {
+		t.Fatalf("Failed to create UserService: %v", err)
 	}
 
 	err = createTestUserInDb()
@@ -97,31 +117,35 @@ func SetupTestEnvironment(t *testing.T) *TestSetup {
 	}
 
 	return &TestSetup{
-		Config:        cfg,
-		PostgresqlDB:  postgresqlDB,
-		ClerkInstance: clerkInstance,
+		Config:          cfg,
+		PostgresqlDB:    postgresqlDB,
+		Authenticator:   issuer.Authenticator(),
+		JWTSessionToken: jwtSessionToken,
+		UserService:     userService,
 	}
 }
 
 func TestConversationHistoryIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
 	postgresqlDB := setup.PostgresqlDB
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 
-	syncService, err := syncservice.NewSyncService()
+	syncService, err := syncservice.NewSyncService(postgresqlDB)
 	if err != nil {
 		t.Fatalf("Failed to create SyncService: %v", err)
 	}
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, clerkInstance, syncService)
+	SetupRoutes(cfg, mux, authenticator, syncService, setup.UserService)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	// Obtain a JWT token from Clerk
-	jwtToken := cfg.TestJWTSessionToken
+	// Obtain a JWT token from the test issuer
+	jwtToken := setup.JWTSessionToken
 
 	// Send a POST request to the server with the JWT token
 	body, _ := json.Marshal("conversationHistory")
@@ -315,29 +339,31 @@ func TestConversationHistoryIntegration(t *testing.T) {
 	t.Cleanup(func() {
 		testconfig := config.NewServerConfig()
 		UserID := testconfig.TestUserID
-		postgresqlDB.DeleteUserInUsersTable(UserID)
+		postgresqlDB.DeleteUserInUsersTable(ctx, UserID)
 	})
 }
 
 func TestFoldersIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
 	postgresqlDB := setup.PostgresqlDB
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 
-	syncService, err := syncservice.NewSyncService()
+	syncService, err := syncservice.NewSyncService(postgresqlDB)
 	if err != nil {
 		t.Fatalf("Failed to create SyncService: %v", err)
 	}
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, clerkInstance, syncService)
+	SetupRoutes(cfg, mux, authenticator, syncService, setup.UserService)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	// Obtain a JWT token from Clerk
-	jwtToken := cfg.TestJWTSessionToken
+	// Obtain a JWT token from the test issuer
+	jwtToken := setup.JWTSessionToken
 
 	// Send a POST request to the server with the JWT token
 	body, _ := json.Marshal("someFoldersData")
@@ -531,29 +557,31 @@ func TestFoldersIntegration(t *testing.T) {
 	t.Cleanup(func() {
 		testconfig := config.NewServerConfig()
 		UserID := testconfig.TestUserID
-		postgresqlDB.DeleteUserInUsersTable(UserID)
+		postgresqlDB.DeleteUserInUsersTable(ctx, UserID)
 	})
 }
 
 func TestPromptsIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	setup := SetupTestEnvironment(t)
 	cfg := setup.Config
 	postgresqlDB := setup.PostgresqlDB
-	clerkInstance := setup.ClerkInstance
+	authenticator := setup.Authenticator
 
-	syncService, err := syncservice.NewSyncService()
+	syncService, err := syncservice.NewSyncService(postgresqlDB)
 	if err != nil {
 		t.Fatalf("Failed to create SyncService: %v", err)
 	}
 
 	// Create a test server
 	mux := http.NewServeMux()
-	SetupRoutes(cfg, mux, clerkInstance, syncService)
+	SetupRoutes(cfg, mux, authenticator, syncService, setup.UserService)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	// Obtain a JWT token from Clerk
-	jwtToken := cfg.TestJWTSessionToken
+	// Obtain a JWT token from the test issuer
+	jwtToken := setup.JWTSessionToken
 
 	// Send a POST request to the server with the JWT token
 	body, _ := json.Marshal("somePromptsData")
@@ -750,6 +778,6 @@ func TestPromptsIntegration(t *testing.T) {
 	t.Cleanup(func() {
 		testconfig := config.NewServerConfig()
 		UserID := testconfig.TestUserID
-		postgresqlDB.DeleteUserInUsersTable(UserID)
+		postgresqlDB.DeleteUserInUsersTable(ctx, UserID)
 	})
 }
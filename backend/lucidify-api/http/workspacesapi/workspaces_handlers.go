@@ -0,0 +1,43 @@
+package workspacesapi
+
+import (
+	"encoding/json"
+	"lucidify-api/server/auth"
+	"lucidify-api/service/workspaceservice"
+	"net/http"
+)
+
+// GetWorkspacesHandler lists the workspaces of the user along with their role
+// in each of them.
+func GetWorkspacesHandler(workspaceService workspaceservice.WorkspaceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+			return
+		}
+
+		memberships, err := workspaceService.GetMemberships(ctx, principal.UserID)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to get workspaces", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		encoder := json.NewEncoder(w)
+		err = encoder.Encode(memberships)
+		if err != nil {
+			http.Error(w, "Internal server error. Unable to encode workspaces as JSON", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package workspacesapi
+
+import (
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/auth"
+	"lucidify-api/server/config"
+	"lucidify-api/server/middleware"
+	"lucidify-api/service/userservice"
+	"lucidify-api/service/workspaceservice"
+	"net/http"
+)
+
+func SetupRoutes(config *config.ServerConfig, mux *http.ServeMux, workspaceService workspaceservice.WorkspaceService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+	mux = SetupGetWorkspacesHandler(config, mux, workspaceService, userService, authenticator)
+
+	return mux
+}
+
+func SetupGetWorkspacesHandler(config *config.ServerConfig, mux *http.ServeMux, workspaceService workspaceservice.WorkspaceService, userService userservice.UserService, authenticator auth.Authenticator) *http.ServeMux {
+
+	handler := GetWorkspacesHandler(workspaceService)
+
+	authenticate := auth.Middleware(authenticator)
+
+	handler = middleware.Logging(handler)
+	handler = middleware.UserProvisioningMiddleware(userService, config)(handler)
+	handler = auth.RequireScope(storemodels.ScopeDocumentsRead)(handler)
+
+	mux.Handle("/workspaces/list", authenticate(handler))
+
+	return mux
+}
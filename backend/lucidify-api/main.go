@@ -1,13 +1,48 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
 	"lucidify-api/server"
+	"lucidify-api/server/config"
+	"os"
 )
 
 func main() {
-	// This stays as production. This just means the server will use the .env file
-	// in the root directory of the project.
-	server.StartServer()
+	// lucidify-api [flags] [migrate up|down [N]|status|to N | config print]
+	flags := flag.NewFlagSet("lucidify-api", flag.ExitOnError)
+	cfg, err := config.Load(flags, os.Args[1:])
+	args := flags.Args()
 
-	// Use server.StartServer("development") for running the test suites locally.
+	var validationErr *config.ValidationError
+	if len(args) > 0 && args[0] == "config" && errors.As(err, &validationErr) {
+		// The config is printed even if invalid, to show what is wrong
+		if err := server.RunConfigCommand(cfg, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			err = server.RunMigrateCommand(cfg, args[1:])
+		case "config":
+			err = server.RunConfigCommand(cfg, args[1:])
+		default:
+			err = fmt.Errorf("unknown command %q", args[0])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	server.StartServer(cfg)
 }
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"lucidify-api/service/apikeyservice"
+	"net/http"
+	"strings"
+)
+
+// APIKeyAuthenticator authenticates requests made with a personal API key,
+// passed as a bearer token. Other bearer tokens are left to the next
+// authenticator of a chain.
+type APIKeyAuthenticator struct {
+	apiKeyService apikeyservice.APIKeyService
+}
+
+func NewAPIKeyAuthenticator(apiKeyService apikeyservice.APIKeyService) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{apiKeyService: apiKeyService}
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	ctx := r.Context()
+
+	token, err := BearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(token, apikeyservice.KeyPrefix) {
+		return nil, ErrNoCredentials
+	}
+
+	apiKey, err := a.apiKeyService.VerifyAPIKey(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Principal{
+		UserID:   apiKey.UserID,
+		APIKeyID: apiKey.APIKeyID.String(),
+		Scopes:   apiKey.Scopes,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// ErrNoCredentials is returned by an Authenticator when the request carries no
+// credentials at all, as opposed to invalid ones.
+var ErrNoCredentials = errors.New("no credentials")
+
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Middleware puts the principal of authenticated requests in the request
+// context. Requests without credentials are passed through so that each
+// handler decides how to respond, while requests with invalid credentials are
+// rejected with a 401.
+func Middleware(authenticator Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := authenticator.Authenticate(r)
+			if errors.Is(err, ErrNoCredentials) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if err != nil {
+				log.Printf("Authentication failed: %v", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// BearerToken returns the token from the Authorization header, falling back to
+// the __session cookie set by Clerk in the browser.
+func BearerToken(r *http.Request) (string, error) {
+	if header := strings.TrimSpace(r.Header.Get("Authorization")); header != "" {
+		token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+		if token == "" {
+			return "", ErrNoCredentials
+		}
+		return token, nil
+	}
+
+	if cookie, err := r.Cookie("__session"); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	return "", ErrNoCredentials
+}
@@ -0,0 +1,129 @@
+// //go:build integration
+// // +build integration
+package auth_test
+
+import (
+	"lucidify-api/server/auth"
+	"lucidify-api/server/auth/authtest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+func TestMiddlewareWithTestIssuer(t *testing.T) {
+	issuer, err := authtest.NewIssuer()
+	if err != nil {
+		t.Fatalf("Failed to create test token issuer: %v", err)
+	}
+	defer issuer.Close()
+
+	handler := auth.Middleware(issuer.Authenticator())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(principal.UserID))
+	}))
+
+	token, err := issuer.Token("user_test")
+	if err != nil {
+		t.Fatalf("Failed to create session token: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantBody      string
+	}{
+		{"valid token", "Bearer " + token, http.StatusOK, "user_test"},
+		{"invalid token", "Bearer " + token + "invalid", http.StatusUnauthorized, "Unauthorized\n"},
+		{"no token", "", http.StatusNoContent, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if rr.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestJWKSAuthenticatorProfile(t *testing.T) {
+	issuer, err := authtest.NewIssuer()
+	if err != nil {
+		t.Fatalf("Failed to create test token issuer: %v", err)
+	}
+	defer issuer.Close()
+
+	token, err := issuer.TokenWithProfile("user_test", auth.Profile{Email: "user@example.com", GivenName: "Test"})
+	if err != nil {
+		t.Fatalf("Failed to create session token: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	principal, err := issuer.Authenticator().Authenticate(req)
+	if err != nil {
+		t.Fatalf("Failed to authenticate: %v", err)
+	}
+	if principal.Profile == nil || principal.Profile.Email != "user@example.com" || principal.Profile.GivenName != "Test" {
+		t.Errorf("Expected the profile claims on the principal, got %+v", principal.Profile)
+	}
+}
+
+func TestJWKSAuthenticatorTimeClaims(t *testing.T) {
+	issuer, err := authtest.NewIssuer()
+	if err != nil {
+		t.Fatalf("Failed to create test token issuer: %v", err)
+	}
+	defer issuer.Close()
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		claims  jwt.Claims
+		wantErr bool
+	}{
+		{"valid", jwt.Claims{Expiry: jwt.NewNumericDate(now.Add(time.Hour))}, false},
+		{"within leeway", jwt.Claims{Expiry: jwt.NewNumericDate(now.Add(-time.Second)), IssuedAt: jwt.NewNumericDate(now.Add(time.Second))}, false},
+		{"no expiry", jwt.Claims{IssuedAt: jwt.NewNumericDate(now)}, true},
+		{"expired", jwt.Claims{Expiry: jwt.NewNumericDate(now.Add(-time.Minute))}, true},
+		{"not yet valid", jwt.Claims{Expiry: jwt.NewNumericDate(now.Add(time.Hour)), NotBefore: jwt.NewNumericDate(now.Add(time.Minute))}, true},
+		{"issued in the future", jwt.Claims{Expiry: jwt.NewNumericDate(now.Add(time.Hour)), IssuedAt: jwt.NewNumericDate(now.Add(time.Minute))}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := tt.claims
+			claims.Issuer = issuer.URL()
+			claims.Subject = "user_test"
+			token, err := issuer.TokenWithClaims(claims)
+			if err != nil {
+				t.Fatalf("Failed to create token: %v", err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			_, err = issuer.Authenticator().Authenticate(req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
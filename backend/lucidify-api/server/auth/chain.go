@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ChainAuthenticator tries each authenticator in turn until one of them finds
+// credentials it is responsible for.
+type ChainAuthenticator struct {
+	authenticators []Authenticator
+}
+
+func NewChainAuthenticator(authenticators ...Authenticator) *ChainAuthenticator {
+	return &ChainAuthenticator{authenticators: authenticators}
+}
+
+func (c *ChainAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	for _, authenticator := range c.authenticators {
+		principal, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrNoCredentials
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/clerkinc/clerk-sdk-go/clerk"
+)
+
+// ClerkAuthenticator verifies Clerk session tokens locally. The Clerk client
+// caches the instance JWKS, so no request is made to Clerk per request.
+type ClerkAuthenticator struct {
+	client clerk.Client
+}
+
+func NewClerkAuthenticator(client clerk.Client) *ClerkAuthenticator {
+	return &ClerkAuthenticator{client: client}
+}
+
+func (a *ClerkAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token, err := BearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := a.client.VerifyToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Principal{
+		UserID:    claims.Subject,
+		SessionID: claims.SessionID,
+		Issuer:    claims.Issuer,
+	}, nil
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+const (
+	jwksRefreshInterval = time.Hour
+	// An unknown kid triggers a refresh at most this often, so that forged
+	// tokens cannot be used to hammer the issuer.
+	jwksMinRefreshInterval = time.Minute
+	clockSkewLeeway        = 5 * time.Second
+)
+
+var allowedSigningAlgorithms = map[string]bool{
+	string(jose.RS256): true,
+	string(jose.RS384): true,
+	string(jose.RS512): true,
+	string(jose.ES256): true,
+	string(jose.ES384): true,
+	string(jose.ES512): true,
+}
+
+// JWKSAuthenticator verifies JWTs from any OIDC issuer against the issuer's
+// JSON Web Key Set. The key set is cached and refreshed periodically, or early
+// when a token is signed with a key that is not in the cache.
+type JWKSAuthenticator struct {
+	issuer   string
+	audience string
+	jwksURL  string
+	client   *http.Client
+
+	mu          sync.RWMutex
+	keys        jose.JSONWebKeySet
+	lastRefresh time.Time
+}
+
+// NewJWKSAuthenticator returns an authenticator for tokens issued by issuer.
+// When jwksURL is empty it is discovered from the issuer's OpenID
+// configuration on first use. An empty audience skips the audience check.
+func NewJWKSAuthenticator(issuer, audience, jwksURL string) *JWKSAuthenticator {
+	return &JWKSAuthenticator{
+		issuer:   issuer,
+		audience: audience,
+		jwksURL:  jwksURL,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (a *JWKSAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token, err := BearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedToken, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsedToken.Headers) != 1 {
+		return nil, errors.New("expected exactly one jwt header")
+	}
+	header := parsedToken.Headers[0]
+	if !allowedSigningAlgorithms[header.Algorithm] {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Algorithm)
+	}
+
+	key, err := a.getKey(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims struct {
+		jwt.Claims
+		Profile
+		SessionID string `json:"sid"`
+	}
+	if err := parsedToken.Claims(key.Key, &claims); err != nil {
+		return nil, err
+	}
+
+	expected := jwt.Expected{Issuer: a.issuer, Time: time.Now()}
+	if a.audience != "" {
+		expected.Audience = jwt.Audience{a.audience}
+	}
+	// Validation skips the time claims that are missing, and a token without
+	// an expiry would never expire
+	if claims.Expiry == nil {
+		return nil, errors.New("missing jwt expiry")
+	}
+	if err := claims.ValidateWithLeeway(expected, clockSkewLeeway); err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("missing jwt subject")
+	}
+
+	return &Principal{
+		UserID:    claims.Subject,
+		SessionID: claims.SessionID,
+		Issuer:    claims.Issuer,
+		Profile:   &claims.Profile,
+	}, nil
+}
+
+func (a *JWKSAuthenticator) getKey(kid string) (*jose.JSONWebKey, error) {
+	a.mu.RLock()
+	keys := a.keys.Key(kid)
+	stale := time.Since(a.lastRefresh) > jwksRefreshInterval
+	canRefresh := time.Since(a.lastRefresh) > jwksMinRefreshInterval
+	a.mu.RUnlock()
+
+	if (len(keys) == 0 && canRefresh) || stale {
+		if err := a.refresh(); err != nil && len(keys) == 0 {
+			return nil, err
+		}
+		a.mu.RLock()
+		keys = a.keys.Key(kid)
+		a.mu.RUnlock()
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signing key found for kid %q", kid)
+	}
+	return &keys[0], nil
+}
+
+func (a *JWKSAuthenticator) refresh() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.jwksURL == "" {
+		jwksURL, err := a.discoverJWKSURL()
+		if err != nil {
+			return err
+		}
+		a.jwksURL = jwksURL
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := a.getJSON(a.jwksURL, &keys); err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	a.keys = keys
+	a.lastRefresh = time.Now()
+	return nil
+}
+
+func (a *JWKSAuthenticator) discoverJWKSURL() (string, error) {
+	var configuration struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := a.getJSON(a.issuer+"/.well-known/openid-configuration", &configuration); err != nil {
+		return "", fmt.Errorf("failed to discover JWKS URL: %w", err)
+	}
+	if configuration.JWKSURI == "" {
+		return "", errors.New("issuer OpenID configuration has no jwks_uri")
+	}
+	return configuration.JWKSURI, nil
+}
+
+func (a *JWKSAuthenticator) getJSON(url string, v interface{}) error {
+	resp, err := a.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+)
+
+type contextKey string
+
+const principalContextKey contextKey = "principal"
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID    string
+	SessionID string
+	Issuer    string
+
+	// APIKeyID and Scopes are only set when the request was authenticated
+	// with an API key. Sessions are not restricted by scopes.
+	APIKeyID string
+	Scopes   []string
+
+	// Profile is only set when the request was authenticated with a token of
+	// an OIDC issuer, so that the user can be provisioned without Clerk.
+	Profile *Profile
+}
+
+// Profile holds the standard OIDC claims about the user found in the token.
+type Profile struct {
+	Email             string `json:"email,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	GivenName         string `json:"given_name,omitempty"`
+	FamilyName        string `json:"family_name,omitempty"`
+	Picture           string `json:"picture,omitempty"`
+}
+
+// HasScope reports whether the principal may perform actions that require
+// scope.
+func (p *Principal) HasScope(scope string) bool {
+	if p.APIKeyID == "" {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey, principal)
+}
+
+// PrincipalFromContext returns the principal put in the context by Middleware,
+// if the request was authenticated.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey).(*Principal)
+	return principal, ok && principal != nil
+}
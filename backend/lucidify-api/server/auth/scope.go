@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"net/http"
+)
+
+// RequireScope rejects requests whose principal lacks scope with a 403.
+// Unauthenticated requests are passed through, so that the handler responds
+// with a 401 as before.
+func RequireScope(scope string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if ok && !principal.HasScope(scope) {
+				http.Error(w, "Forbidden. The API key is missing the "+scope+" scope", http.StatusForbidden)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
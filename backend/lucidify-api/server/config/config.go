@@ -1,123 +1,297 @@
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/clerkinc/clerk-sdk-go/clerk"
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// ServerConfig is the configuration of the server. Each setting is read, from
+// lowest to highest precedence, from its default, the config file, the
+// environment and the command line flags, see settings.
 type ServerConfig struct {
-	OPENAI_API_KEY      string
-	AllowedOrigins      []string
-	Port                string
-	PostgresqlURL       string
-	ClerkClient         clerk.Client
-	ClerkSigningSecret  string
-	ClerkSecretKey      string
-	TestJWTSessionToken string
-	TestUserID          string
-	X_AI_API_KEY        string
-	AI_API_URL          string
+	OPENAI_API_KEY      string   `yaml:"openai_api_key"`
+	AllowedOrigins      []string `yaml:"allowed_origins"`
+	Port                string   `yaml:"port"`
+	PostgresqlURL       string   `yaml:"postgresql_url"`
+	ClerkSigningSecret  string   `yaml:"clerk_signing_secret"`
+	ClerkSecretKey      string   `yaml:"clerk_secret_key"`
+	TestJWTSessionToken string   `yaml:"test_jwt_session_token"`
+	TestUserID          string   `yaml:"test_user_id"`
+	X_AI_API_KEY        string   `yaml:"ai_api_key"`
+	AI_API_URL          string   `yaml:"ai_api_url"`
+
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownDelay     time.Duration `yaml:"shutdown_delay"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+	ReadinessTimeout  time.Duration `yaml:"readiness_timeout"`
+
+	WeaviateHost    string        `yaml:"weaviate_host"`
+	WeaviateScheme  string        `yaml:"weaviate_scheme"`
+	WeaviateTimeout time.Duration `yaml:"weaviate_timeout"`
+
+	DBMaxOpenConns    int           `yaml:"db_max_open_conns"`
+	DBMaxIdleConns    int           `yaml:"db_max_idle_conns"`
+	DBConnMaxLifetime time.Duration `yaml:"db_conn_max_lifetime"`
+	DBConnMaxIdleTime time.Duration `yaml:"db_conn_max_idle_time"`
+
+	ChunkerTimeout    time.Duration `yaml:"chunker_timeout"`
+	ChunkerWindowSize int           `yaml:"chunker_window_size"`
+	ChunkerBlockSize  int           `yaml:"chunker_block_size"`
+
+	SearchDefaultLimit int `yaml:"search_default_limit"`
+	SearchMaxLimit     int `yaml:"search_max_limit"`
+	ChatContextChunks  int `yaml:"chat_context_chunks"`
+
+	DocumentTrashRetention  time.Duration `yaml:"document_trash_retention"`
+	UserDeletionGracePeriod time.Duration `yaml:"user_deletion_grace_period"`
+	PurgeInterval           time.Duration `yaml:"purge_interval"`
+
+	AdminAPIKey         string        `yaml:"admin_api_key"`
+	WebhookMaxAttempts  int           `yaml:"webhook_max_attempts"`
+	WebhookPollInterval time.Duration `yaml:"webhook_poll_interval"`
+
+	AuthProvider string `yaml:"auth_provider"`
+	OIDCIssuer   string `yaml:"oidc_issuer"`
+	OIDCJWKSURL  string `yaml:"oidc_jwks_url"`
+	OIDCAudience string `yaml:"oidc_audience"`
+
+	EmbeddingModel     string `yaml:"embedding_model"`
+	EmbeddingCacheSize int    `yaml:"embedding_cache_size"`
+
+	DBMigrationCheck string `yaml:"db_migration_check"`
+
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
 }
 
-func getGitRoot() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	out, err := cmd.Output()
-	if err != nil {
-		return "", err
+// ValidationError lists every invalid setting of a config.
+type ValidationError struct {
+	Errors []error
+}
+
+func (v *ValidationError) Error() string {
+	messages := make([]string, len(v.Errors))
+	for i, err := range v.Errors {
+		messages[i] = "  - " + err.Error()
 	}
-	return strings.TrimSpace(string(out)), nil
+	return "invalid config:\n" + strings.Join(messages, "\n")
 }
 
-func NewServerConfig() *ServerConfig {
-	if os.Getenv("POSTGRESQL_URL") == "" || os.Getenv("OPENAI_API_KEY") == "" || os.Getenv("CLERK_SECRET_KEY") == "" || os.Getenv("CLERK_SIGNING_SECRET") == "" {
-		// If missing, load the .env file
-		gitRoot, err := getGitRoot()
-		if err != nil {
-			log.Fatalf("Error getting git root: %v", err)
+// loadDotEnv loads the nearest .env file from the working directory up. The
+// variables already set in the environment take precedence.
+func loadDotEnv() {
+	dir, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	for {
+		envPath := filepath.Join(dir, ".env")
+		if _, err := os.Stat(envPath); err == nil {
+			if err := godotenv.Load(envPath); err != nil {
+				log.Printf("Failed to load %s: %v", envPath, err)
+			}
+			return
 		}
-		envPath := filepath.Join(gitRoot, ".env")
-		if err := godotenv.Load(envPath); err != nil {
-			log.Fatalf("Failed to load .env file: %v", err)
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return
 		}
+		dir = parent
+	}
+}
+
+// readConfigFile overrides the settings set in the YAML config file.
+func readConfigFile(config *ServerConfig, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
 	}
+	defer file.Close()
 
-	// Now retrieve the environment variables
-	OPENAI_API_KEY := os.Getenv("OPENAI_API_KEY")
-	if OPENAI_API_KEY == "" {
-		log.Fatal("OPENAI_API_KEY environment variable is not set")
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
 	}
+	return nil
+}
+
+// Load reads the config, registering a flag per setting on flags and parsing
+// args with it. The config file is given by the -config flag or the
+// LUCIDIFY_CONFIG environment variable. A *ValidationError is returned along
+// with the config when settings are invalid, so that it can still be printed.
+func Load(flags *flag.FlagSet, args []string) (*ServerConfig, error) {
+	loadDotEnv()
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-		log.Printf("Defaulting to port %s", port)
+	configPath := flags.String("config", os.Getenv("LUCIDIFY_CONFIG"), "path of the YAML config file")
+	flagValues := registerFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return nil, err
 	}
 
-	allowedOrigins := []string{
-		"http://localhost:3000",
-		"http://localhost:3001",
-		"http://localhost:3002",
+	config := defaultConfig()
+	if *configPath != "" {
+		if err := readConfigFile(config, *configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		value, ok := os.LookupEnv(s.env)
+		if !ok || value == "" {
+			continue
+		}
+		if err := s.set(config, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s environment variable: %w", s.env, err))
+		}
+	}
+	for i, s := range settings {
+		if !flagValues[i].set {
+			continue
+		}
+		if err := s.set(config, flagValues[i].value); err != nil {
+			errs = append(errs, fmt.Errorf("-%s flag: %w", s.flag(), err))
+		}
 	}
 
-	postgresqlURL := os.Getenv("POSTGRESQL_URL")
-	if postgresqlURL == "" {
-		log.Fatal("POSTGRESQL_URL environment variable is not set")
+	errs = append(errs, config.validate()...)
+	if len(errs) > 0 {
+		return config, &ValidationError{Errors: errs}
 	}
+	return config, nil
+}
 
-	clerkClient, err := clerk.NewClient(os.Getenv("CLERK_SECRET_KEY"))
+// NewServerConfig reads the config from its defaults, the config file and the
+// environment, and exits if it is invalid.
+func NewServerConfig() *ServerConfig {
+	config, err := Load(flag.NewFlagSet("config", flag.ContinueOnError), nil)
 	if err != nil {
-		log.Fatalf("Failed to create Clerk client: %v", err)
+		log.Fatal(err)
+	}
+	return config
+}
+
+func defaultConfig() *ServerConfig {
+	return &ServerConfig{
+		AllowedOrigins: []string{
+			"http://localhost:3000",
+			"http://localhost:3001",
+			"http://localhost:3002",
+		},
+		Port: "8080",
+
+		// Uploads and chats, which wait for the chunker and OpenAI, are given
+		// minutes
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       5 * time.Minute,
+		WriteTimeout:      5 * time.Minute,
+		IdleTimeout:       2 * time.Minute,
+		ShutdownTimeout:   30 * time.Second,
+		ReadinessTimeout:  3 * time.Second,
+
+		WeaviateHost:   "weaviate:8080",
+		WeaviateScheme: "http",
+
+		// The defaults of database/sql
+		DBMaxIdleConns: 2,
+
+		ChunkerTimeout: 2 * time.Minute,
+		// The defaults of the chunker, see chunker.py of the AI API
+		ChunkerWindowSize: 20,
+		ChunkerBlockSize:  10,
+
+		SearchDefaultLimit: 10,
+		SearchMaxLimit:     50,
+		ChatContextChunks:  4,
+
+		DocumentTrashRetention:  30 * 24 * time.Hour,
+		UserDeletionGracePeriod: 7 * 24 * time.Hour,
+		PurgeInterval:           time.Hour,
+
+		WebhookMaxAttempts:  8,
+		WebhookPollInterval: 5 * time.Second,
+
+		AuthProvider: "clerk",
+
+		// The model of the text2vec-openai module of Weaviate
+		EmbeddingModel:     "text-embedding-ada-002",
+		EmbeddingCacheSize: 10000,
+
+		DBMigrationCheck: "off",
 	}
+}
 
-	clerkSigningSecret := os.Getenv("CLERK_SIGNING_SECRET")
-	if clerkSigningSecret == "" {
-		log.Fatal("CLERK_SIGNING_SECRET environment variable is not set")
+// validate returns every invalid setting.
+func (c *ServerConfig) validate() []error {
+	var errs []error
+	for _, s := range settings {
+		if s.required && s.isZero(c) {
+			errs = append(errs, fmt.Errorf("%s is not set (%s)", s.key, s.env))
+		}
 	}
 
-	clerkSecretKey := os.Getenv("CLERK_SECRET_KEY")
-	if clerkSecretKey == "" {
-		log.Fatalf("CLERK_SECRET_KEY environment variable is not set")
+	switch c.AuthProvider {
+	case "clerk":
+	case "oidc":
+		if c.OIDCIssuer == "" {
+			errs = append(errs, errors.New("oidc_issuer is not set (OIDC_ISSUER) but auth_provider is oidc"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("auth_provider must be either clerk or oidc, got %q", c.AuthProvider))
 	}
 
-	testJWTSessionToken := os.Getenv("TEST_JWT_SESSION_TOKEN")
-	if testJWTSessionToken == "" {
-		log.Printf("Either TEST_JWT_SESSION_TOKEN environment variable is not set " +
-			"in development, or this is production and thus not an issue.")
+	switch c.DBMigrationCheck {
+	case "off", "check", "up":
+	default:
+		errs = append(errs, fmt.Errorf("db_migration_check must be off, check or up, got %q", c.DBMigrationCheck))
 	}
 
-	testUserID := os.Getenv("TEST_USER_ID")
-	if testUserID == "" {
-		log.Printf("Either TEST_USER_ID environment variable is not set " +
-			"in development, or this is production and thus not an issue.")
+	switch c.WeaviateScheme {
+	case "http", "https":
+	default:
+		errs = append(errs, fmt.Errorf("weaviate_scheme must be http or https, got %q", c.WeaviateScheme))
 	}
 
-	X_AI_API_KEY := os.Getenv("X_AI_API_KEY")
-	if X_AI_API_KEY == "" {
-		log.Fatal("AI_API_KEY environment variable is not set")
+	if c.OTLPEndpoint != "" {
+		endpoint, err := url.Parse(c.OTLPEndpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			errs = append(errs, fmt.Errorf("otlp_endpoint must be an http or https URL, got %q", c.OTLPEndpoint))
+		}
 	}
 
-	AI_API_URL := os.Getenv("AI_API_URL")
-	if AI_API_URL == "" {
-		log.Fatal("AI_API_URL environment variable is not set")
+	for _, s := range settings {
+		if err := s.checkRange(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.SearchDefaultLimit > c.SearchMaxLimit {
+		errs = append(errs, fmt.Errorf("search_default_limit (%d) is above search_max_limit (%d)", c.SearchDefaultLimit, c.SearchMaxLimit))
 	}
+	return errs
+}
 
-	return &ServerConfig{
-		OPENAI_API_KEY:      OPENAI_API_KEY,
-		AllowedOrigins:      allowedOrigins,
-		Port:                port,
-		PostgresqlURL:       postgresqlURL,
-		ClerkClient:         clerkClient,
-		ClerkSigningSecret:  clerkSigningSecret,
-		ClerkSecretKey:      clerkSecretKey,
-		TestJWTSessionToken: testJWTSessionToken,
-		TestUserID:          testUserID,
-		X_AI_API_KEY:        X_AI_API_KEY,
-		AI_API_URL:          AI_API_URL,
+// Redacted returns a copy of the config with the secrets replaced.
+func (c *ServerConfig) Redacted() *ServerConfig {
+	redacted := *c
+	redacted.AllowedOrigins = append([]string(nil), c.AllowedOrigins...)
+	for _, s := range settings {
+		if s.secret && !s.isZero(&redacted) {
+			s.set(&redacted, "[redacted]")
+		}
 	}
+	return &redacted
 }
@@ -0,0 +1,101 @@
+// //go:build integration
+// // +build integration
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func setRequiredEnv(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "test-openai-key")
+	t.Setenv("POSTGRESQL_URL", "postgres://test")
+	t.Setenv("CLERK_SECRET_KEY", "test-clerk-key")
+	t.Setenv("CLERK_SIGNING_SECRET", "test-clerk-secret")
+	t.Setenv("X_AI_API_KEY", "test-ai-key")
+	t.Setenv("AI_API_URL", "http://ai-api")
+}
+
+func TestIntegrationConfigLayers(t *testing.T) {
+	setRequiredEnv(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	file := "port: \"9000\"\nsearch_max_limit: 20\nsearch_default_limit: 8\nchunker_timeout: 30s\n"
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SEARCH_MAX_LIMIT", "30")
+	t.Setenv("ALLOWED_ORIGINS", "https://a.example, https://b.example")
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	config, err := Load(flags, []string{"-config", path, "-search-default-limit", "5", "migrate", "up"})
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if config.Port != "9000" {
+		t.Errorf("port from the file: got %s", config.Port)
+	}
+	if config.ChunkerTimeout != 30*time.Second {
+		t.Errorf("chunker_timeout from the file: got %s", config.ChunkerTimeout)
+	}
+	if config.SearchMaxLimit != 30 {
+		t.Errorf("search_max_limit from the environment: got %d", config.SearchMaxLimit)
+	}
+	if config.SearchDefaultLimit != 5 {
+		t.Errorf("search_default_limit from the flag: got %d", config.SearchDefaultLimit)
+	}
+	if len(config.AllowedOrigins) != 2 || config.AllowedOrigins[1] != "https://b.example" {
+		t.Errorf("allowed_origins from the environment: got %v", config.AllowedOrigins)
+	}
+	if config.WebhookMaxAttempts != 8 {
+		t.Errorf("webhook_max_attempts default: got %d", config.WebhookMaxAttempts)
+	}
+	if args := flags.Args(); len(args) != 2 || args[0] != "migrate" {
+		t.Errorf("arguments after the flags: got %v", args)
+	}
+}
+
+func TestIntegrationConfigValidation(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("WEBHOOK_MAX_ATTEMPTS", "many")
+	t.Setenv("AUTH_PROVIDER", "ldap")
+
+	config, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-search-max-limit", "0"})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	if config == nil {
+		t.Fatal("Expected the config along with the validation error")
+	}
+	// openai_api_key, webhook_max_attempts, auth_provider, search_max_limit
+	// and search_default_limit above search_max_limit
+	if len(validationErr.Errors) != 5 {
+		t.Errorf("Expected 5 errors, got %d: %v", len(validationErr.Errors), validationErr)
+	}
+}
+
+func TestIntegrationConfigRedacted(t *testing.T) {
+	setRequiredEnv(t)
+
+	config, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	redacted := config.Redacted()
+	if redacted.OPENAI_API_KEY != "[redacted]" || redacted.PostgresqlURL != "[redacted]" {
+		t.Errorf("Secrets were not redacted: %+v", redacted)
+	}
+	if redacted.AdminAPIKey != "" {
+		t.Errorf("Unset secrets should stay empty, got %s", redacted.AdminAPIKey)
+	}
+	if config.OPENAI_API_KEY != "test-openai-key" {
+		t.Errorf("Redacted changed the config: %s", config.OPENAI_API_KEY)
+	}
+}
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setting describes how a field of ServerConfig is set from the environment
+// and the command line. Its key is the one of the config file, and its flag
+// is the key with dashes.
+type setting struct {
+	key   string
+	env   string
+	usage string
+	field func(c *ServerConfig) interface{}
+
+	// secret settings are redacted when the config is printed
+	secret   bool
+	required bool
+	// Numbers and durations must not be negative, and positive ones must be
+	// above zero
+	positive bool
+}
+
+var settings = []setting{
+	{key: "openai_api_key", env: "OPENAI_API_KEY", usage: "OpenAI API key", secret: true, required: true,
+		field: func(c *ServerConfig) interface{} { return &c.OPENAI_API_KEY }},
+	{key: "allowed_origins", env: "ALLOWED_ORIGINS", usage: "comma separated origins allowed by CORS",
+		field: func(c *ServerConfig) interface{} { return &c.AllowedOrigins }},
+	{key: "port", env: "PORT", usage: "port to listen on",
+		field: func(c *ServerConfig) interface{} { return &c.Port }},
+	{key: "postgresql_url", env: "POSTGRESQL_URL", usage: "PostgreSQL connection URL", secret: true, required: true,
+		field: func(c *ServerConfig) interface{} { return &c.PostgresqlURL }},
+	{key: "clerk_signing_secret", env: "CLERK_SIGNING_SECRET", usage: "secret of the Clerk webhooks", secret: true, required: true,
+		field: func(c *ServerConfig) interface{} { return &c.ClerkSigningSecret }},
+	{key: "clerk_secret_key", env: "CLERK_SECRET_KEY", usage: "Clerk secret key", secret: true, required: true,
+		field: func(c *ServerConfig) interface{} { return &c.ClerkSecretKey }},
+	{key: "test_jwt_session_token", env: "TEST_JWT_SESSION_TOKEN", usage: "session token of the test user, development only", secret: true,
+		field: func(c *ServerConfig) interface{} { return &c.TestJWTSessionToken }},
+	{key: "test_user_id", env: "TEST_USER_ID", usage: "id of the test user, development only",
+		field: func(c *ServerConfig) interface{} { return &c.TestUserID }},
+	{key: "ai_api_key", env: "X_AI_API_KEY", usage: "key of the AI API", secret: true, required: true,
+		field: func(c *ServerConfig) interface{} { return &c.X_AI_API_KEY }},
+	{key: "ai_api_url", env: "AI_API_URL", usage: "URL of the AI API", required: true,
+		field: func(c *ServerConfig) interface{} { return &c.AI_API_URL }},
+
+	{key: "read_header_timeout", env: "READ_HEADER_TIMEOUT", usage: "time to read the headers of a request", positive: true,
+		field: func(c *ServerConfig) interface{} { return &c.ReadHeaderTimeout }},
+	{key: "read_timeout", env: "READ_TIMEOUT", usage: "time to read a request, 0 for none",
+		field: func(c *ServerConfig) interface{} { return &c.ReadTimeout }},
+	{key: "write_timeout", env: "WRITE_TIMEOUT", usage: "time to handle a request and write its response, 0 for none",
+		field: func(c *ServerConfig) interface{} { return &c.WriteTimeout }},
+	{key: "idle_timeout", env: "IDLE_TIMEOUT", usage: "time to keep an idle connection open",
+		field: func(c *ServerConfig) interface{} { return &c.IdleTimeout }},
+	{key: "shutdown_delay", env: "SHUTDOWN_DELAY", usage: "time /readyz fails on shutdown before connections are refused, 0 for none",
+		field: func(c *ServerConfig) interface{} { return &c.ShutdownDelay }},
+	{key: "shutdown_timeout", env: "SHUTDOWN_TIMEOUT", usage: "time given to requests and background jobs in flight on shutdown", positive: true,
+		field: func(c *ServerConfig) interface{} { return &c.ShutdownTimeout }},
+	{key: "readiness_timeout", env: "READINESS_TIMEOUT", usage: "time given to each dependency checked by /readyz", positive: true,
+		field: func(c *ServerConfig) interface{} { return &c.ReadinessTimeout }},
+
+	{key: "weaviate_host", env: "WEAVIATE_HOST", usage: "host and port of Weaviate", required: true,
+		field: func(c *ServerConfig) interface{} { return &c.WeaviateHost }},
+	{key: "weaviate_scheme", env: "WEAVIATE_SCHEME", usage: "http or https",
+		field: func(c *ServerConfig) interface{} { return &c.WeaviateScheme }},
+	{key: "weaviate_timeout", env: "WEAVIATE_TIMEOUT", usage: "timeout of Weaviate requests, 0 for none",
+		field: func(c *ServerConfig) interface{} { return &c.WeaviateTimeout }},
+
+	{key: "db_max_open_conns", env: "DB_MAX_OPEN_CONNS", usage: "maximum number of open PostgreSQL connections, 0 for no limit",
+		field: func(c *ServerConfig) interface{} { return &c.DBMaxOpenConns }},
+	{key: "db_max_idle_conns", env: "DB_MAX_IDLE_CONNS", usage: "maximum number of idle PostgreSQL connections",
+		field: func(c *ServerConfig) interface{} { return &c.DBMaxIdleConns }},
+	{key: "db_conn_max_lifetime", env: "DB_CONN_MAX_LIFETIME", usage: "maximum lifetime of a PostgreSQL connection, 0 for none",
+		field: func(c *ServerConfig) interface{} { return &c.DBConnMaxLifetime }},
+	{key: "db_conn_max_idle_time", env: "DB_CONN_MAX_IDLE_TIME", usage: "maximum idle time of a PostgreSQL connection, 0 for none",
+		field: func(c *ServerConfig) interface{} { return &c.DBConnMaxIdleTime }},
+
+	{key: "chunker_timeout", env: "CHUNKER_TIMEOUT", usage: "timeout of the chunker of the AI API, 0 for none",
+		field: func(c *ServerConfig) interface{} { return &c.ChunkerTimeout }},
+	{key: "chunker_window_size", env: "CHUNKER_WINDOW_SIZE", usage: "pseudosentence size of the chunker", positive: true,
+		field: func(c *ServerConfig) interface{} { return &c.ChunkerWindowSize }},
+	{key: "chunker_block_size", env: "CHUNKER_BLOCK_SIZE", usage: "block size of the chunker", positive: true,
+		field: func(c *ServerConfig) interface{} { return &c.ChunkerBlockSize }},
+
+	{key: "search_default_limit", env: "SEARCH_DEFAULT_LIMIT", usage: "number of chunks returned by a search by default", positive: true,
+		field: func(c *ServerConfig) interface{} { return &c.SearchDefaultLimit }},
+	{key: "search_max_limit", env: "SEARCH_MAX_LIMIT", usage: "maximum number of chunks returned by a search", positive: true,
+		field: func(c *ServerConfig) interface{} { return &c.SearchMaxLimit }},
+	{key: "chat_context_chunks", env: "CHAT_CONTEXT_CHUNKS", usage: "number of chunks added to the system prompt of a chat", positive: true,
+		field: func(c *ServerConfig) interface{} { return &c.ChatContextChunks }},
+
+	{key: "document_trash_retention", env: "DOCUMENT_TRASH_RETENTION", usage: "time documents stay in the trash", positive: true,
+		field: func(c *ServerConfig) interface{} { return &c.DocumentTrashRetention }},
+	{key: "user_deletion_grace_period", env: "USER_DELETION_GRACE_PERIOD", usage: "time before deleted users are purged",
+		field: func(c *ServerConfig) interface{} { return &c.UserDeletionGracePeriod }},
+	{key: "purge_interval", env: "PURGE_INTERVAL", usage: "interval between purges", positive: true,
+		field: func(c *ServerConfig) interface{} { return &c.PurgeInterval }},
+
+	{key: "admin_api_key", env: "ADMIN_API_KEY", usage: "key of the admin endpoints, which are disabled without it", secret: true,
+		field: func(c *ServerConfig) interface{} { return &c.AdminAPIKey }},
+	{key: "webhook_max_attempts", env: "WEBHOOK_MAX_ATTEMPTS", usage: "attempts to deliver a webhook event", positive: true,
+		field: func(c *ServerConfig) interface{} { return &c.WebhookMaxAttempts }},
+	{key: "webhook_poll_interval", env: "WEBHOOK_POLL_INTERVAL", usage: "interval between webhook deliveries", positive: true,
+		field: func(c *ServerConfig) interface{} { return &c.WebhookPollInterval }},
+
+	{key: "auth_provider", env: "AUTH_PROVIDER", usage: "clerk or oidc",
+		field: func(c *ServerConfig) interface{} { return &c.AuthProvider }},
+	{key: "oidc_issuer", env: "OIDC_ISSUER", usage: "issuer of the OIDC tokens",
+		field: func(c *ServerConfig) interface{} { return &c.OIDCIssuer }},
+	{key: "oidc_jwks_url", env: "OIDC_JWKS_URL", usage: "JWKS of the OIDC issuer, discovered when empty",
+		field: func(c *ServerConfig) interface{} { return &c.OIDCJWKSURL }},
+	{key: "oidc_audience", env: "OIDC_AUDIENCE", usage: "audience of the OIDC tokens",
+		field: func(c *ServerConfig) interface{} { return &c.OIDCAudience }},
+
+	{key: "embedding_model", env: "EMBEDDING_MODEL", usage: "OpenAI embedding model",
+		field: func(c *ServerConfig) interface{} { return &c.EmbeddingModel }},
+	{key: "embedding_cache_size", env: "EMBEDDING_CACHE_SIZE", usage: "number of embeddings cached in memory",
+		field: func(c *ServerConfig) interface{} { return &c.EmbeddingCacheSize }},
+
+	{key: "db_migration_check", env: "DB_MIGRATION_CHECK", usage: "off, check or up",
+		field: func(c *ServerConfig) interface{} { return &c.DBMigrationCheck }},
+
+	{key: "otlp_endpoint", env: "OTLP_ENDPOINT", usage: "URL of the OTLP/HTTP collector receiving the traces, none when empty",
+		field: func(c *ServerConfig) interface{} { return &c.OTLPEndpoint }},
+}
+
+func (s *setting) flag() string {
+	return strings.ReplaceAll(s.key, "_", "-")
+}
+
+// set parses the value into the field.
+func (s *setting) set(c *ServerConfig, value string) error {
+	switch field := s.field(c).(type) {
+	case *string:
+		*field = value
+	case *[]string:
+		var values []string
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		*field = values
+	case *int:
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a valid integer", value)
+		}
+		*field = number
+	case *time.Duration:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a valid duration", value)
+		}
+		*field = duration
+	default:
+		return fmt.Errorf("unsupported type %T", field)
+	}
+	return nil
+}
+
+func (s *setting) isZero(c *ServerConfig) bool {
+	switch field := s.field(c).(type) {
+	case *string:
+		return *field == ""
+	case *[]string:
+		return len(*field) == 0
+	case *int:
+		return *field == 0
+	case *time.Duration:
+		return *field == 0
+	}
+	return false
+}
+
+func (s *setting) checkRange(c *ServerConfig) error {
+	var value int64
+	switch field := s.field(c).(type) {
+	case *int:
+		value = int64(*field)
+	case *time.Duration:
+		value = int64(*field)
+	default:
+		return nil
+	}
+	if s.positive && value <= 0 {
+		return fmt.Errorf("%s must be positive", s.key)
+	}
+	if value < 0 {
+		return fmt.Errorf("%s must not be negative", s.key)
+	}
+	return nil
+}
+
+// flagValue records the value of a flag, so that it is only applied when set.
+type flagValue struct {
+	value string
+	set   bool
+}
+
+func (f *flagValue) String() string {
+	return f.value
+}
+
+func (f *flagValue) Set(value string) error {
+	f.value = value
+	f.set = true
+	return nil
+}
+
+func registerFlags(flags *flag.FlagSet) []*flagValue {
+	values := make([]*flagValue, len(settings))
+	for i := range settings {
+		values[i] = &flagValue{}
+		flags.Var(values[i], settings[i].flag(), fmt.Sprintf("%s (%s)", settings[i].usage, settings[i].env))
+	}
+	return values
+}
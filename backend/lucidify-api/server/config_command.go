@@ -0,0 +1,27 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"lucidify-api/server/config"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configUsage = "Usage: lucidify-api config print"
+
+// RunConfigCommand runs the config subcommand: print writes the effective
+// config as YAML, with its secrets redacted.
+func RunConfigCommand(config *config.ServerConfig, args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return errors.New(configUsage)
+	}
+
+	output, err := yaml.Marshal(config.Redacted())
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(os.Stdout, string(output))
+	return nil
+}
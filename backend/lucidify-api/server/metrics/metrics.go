@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "lucidify"
+
+// Registry holds the metrics of the API, along with those of the Go runtime
+// and of the process.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the HTTP requests by route, method and status.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"route", "method", "status"})
+
+	PostgreSQLQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "postgresql_query_duration_seconds",
+		Help:      "Latency of the PostgreSQL statements by method of the client.",
+		Buckets:   prometheus.ExponentialBuckets(.0005, 2, 14),
+	}, []string{"operation"})
+
+	PostgreSQLQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "postgresql_query_errors_total",
+		Help:      "Failed PostgreSQL statements by method of the client.",
+	}, []string{"operation"})
+
+	WeaviateRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "weaviate_request_duration_seconds",
+		Help:      "Latency of the Weaviate calls by method of the client.",
+		Buckets:   prometheus.ExponentialBuckets(.001, 2, 14),
+	}, []string{"operation"})
+
+	WeaviateRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "weaviate_request_errors_total",
+		Help:      "Failed Weaviate calls by method of the client.",
+	}, []string{"operation"})
+
+	DocumentOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "document_operation_duration_seconds",
+		Help:      "Latency of the document service by operation.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"operation"})
+
+	DocumentOperationErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "document_operation_errors_total",
+		Help:      "Failed operations of the document service.",
+	}, []string{"operation"})
+
+	IngestionsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ingestions_in_flight",
+		Help:      "Documents being chunked and embedded, by uploads and content updates.",
+	})
+
+	ChunkerRequestDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "chunker_request_duration_seconds",
+		Help:      "Latency of the chunker of the AI API.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	})
+
+	ChunkerRequestErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "chunker_request_errors_total",
+		Help:      "Failed calls to the chunker of the AI API.",
+	})
+
+	ChunksPerDocument = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "chunker_chunks_per_document",
+		Help:      "Chunks the chunker split each document into.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+	})
+
+	ModelTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "openai_tokens_total",
+		Help:      "Tokens used with OpenAI by model and kind, prompt or completion.",
+	}, []string{"model", "kind"})
+
+	ModelCost = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "openai_cost_dollars_total",
+		Help:      "Estimated cost of the OpenAI calls by model, from the list prices.",
+	}, []string{"model"})
+
+	RetrievalHits = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "retrieval_hits",
+		Help:      "Chunks returned by each vector search.",
+		Buckets:   []float64{0, 1, 2, 4, 8, 16, 32, 64},
+	})
+
+	RetrievalDistance = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "retrieval_distance",
+		Help:      "Cosine distance of the chunks returned by the vector searches.",
+		Buckets:   prometheus.LinearBuckets(0, .05, 21),
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		PostgreSQLQueryDuration,
+		PostgreSQLQueryErrors,
+		WeaviateRequestDuration,
+		WeaviateRequestErrors,
+		DocumentOperationDuration,
+		DocumentOperationErrors,
+		IngestionsInFlight,
+		ChunkerRequestDuration,
+		ChunkerRequestErrors,
+		ChunksPerDocument,
+		ModelTokens,
+		ModelCost,
+		RetrievalHits,
+		RetrievalDistance,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,28 @@
+package metrics
+
+// modelPrices are the list prices of the OpenAI models, in dollars per 1K
+// tokens. The cost of models left out is not estimated.
+var modelPrices = map[string]struct{ prompt, completion float64 }{
+	"text-embedding-ada-002": {prompt: 0.0001},
+	"gpt-3.5-turbo":          {prompt: 0.0015, completion: 0.002},
+	"gpt-3.5-turbo-16k":      {prompt: 0.003, completion: 0.004},
+	"gpt-4":                  {prompt: 0.03, completion: 0.06},
+	"gpt-4-32k":              {prompt: 0.06, completion: 0.12},
+}
+
+// RecordModelUsage counts the tokens of an OpenAI call and its estimated cost.
+func RecordModelUsage(model string, promptTokens, completionTokens int) {
+	if promptTokens > 0 {
+		ModelTokens.WithLabelValues(model, "prompt").Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		ModelTokens.WithLabelValues(model, "completion").Add(float64(completionTokens))
+	}
+
+	price, ok := modelPrices[model]
+	if !ok {
+		return
+	}
+	cost := (float64(promptTokens)*price.prompt + float64(completionTokens)*price.completion) / 1000
+	ModelCost.WithLabelValues(model).Add(cost)
+}
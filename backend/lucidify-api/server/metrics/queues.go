@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"lucidify-api/data/store/storemodels"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var queueDepthDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "queue_depth"),
+	"Rows waiting for a background worker, by queue.",
+	[]string{"queue"}, nil,
+)
+
+// queueDepthCollector counts the queues in the database on each scrape.
+type queueDepthCollector struct {
+	getQueueDepths func(ctx context.Context) (*storemodels.QueueDepths, error)
+}
+
+// RegisterQueueDepths exports the depths of the webhook and purge queues,
+// read with getQueueDepths on each scrape.
+func RegisterQueueDepths(getQueueDepths func(ctx context.Context) (*storemodels.QueueDepths, error)) {
+	Registry.MustRegister(&queueDepthCollector{getQueueDepths: getQueueDepths})
+}
+
+func (c *queueDepthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+}
+
+func (c *queueDepthCollector) Collect(ch chan<- prometheus.Metric) {
+	depths, err := c.getQueueDepths(context.Background())
+	if err != nil {
+		log.Printf("Failed to get the queue depths: %v", err)
+		ch <- prometheus.NewInvalidMetric(queueDepthDesc, err)
+		return
+	}
+
+	queues := map[string]int{
+		"pending_webhook_events": depths.PendingWebhookEvents,
+		"dead_webhook_events":    depths.DeadWebhookEvents,
+		"trashed_documents":      depths.TrashedDocuments,
+		"deleted_users":          depths.DeletedUsers,
+	}
+	for queue, depth := range queues {
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(depth), queue)
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"lucidify-api/server/config"
+	"net/http"
+)
+
+// AdminAuthenticationMiddleware only lets requests through that carry the
+// configured ADMIN_API_KEY in the X-Admin-API-Key header. Admin endpoints are
+// disabled entirely when no key is configured.
+func AdminAuthenticationMiddleware(config *config.ServerConfig) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if config.AdminAPIKey == "" {
+				http.Error(w, "Not found", http.StatusNotFound)
+				return
+			}
+
+			key := r.Header.Get("X-Admin-API-Key")
+			if subtle.ConstantTimeCompare([]byte(key), []byte(config.AdminAPIKey)) != 1 {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// Deprecated marks the responses of a route as deprecated and links to the
+// route that replaces it, so clients can find out before the route is removed.
+func Deprecated(successor string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Link", "<"+successor+">; rel=\"successor-version\"")
+			next(w, r)
+		}
+	}
+}
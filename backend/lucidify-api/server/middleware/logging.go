@@ -38,6 +38,28 @@ import (
 // 	}
 // }
 
+// redactedHeaders carry credentials, such as session tokens and API keys,
+// which must not be written to the logs.
+var redactedHeaders = map[string]bool{
+	"Authorization":   true,
+	"Cookie":          true,
+	"X-Admin-Api-Key": true,
+}
+
+// formatHeaders lists the headers one per line, with the values of
+// redactedHeaders hidden.
+func formatHeaders(header http.Header) string {
+	var headerStrings []string
+	for name, values := range header {
+		value := strings.Join(values, ", ")
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			value = "[REDACTED]"
+		}
+		headerStrings = append(headerStrings, fmt.Sprintf("%s: %s", name, value))
+	}
+	return strings.Join(headerStrings, "\n\t")
+}
+
 func Logging(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Read the request body
@@ -52,11 +74,7 @@ func Logging(next http.HandlerFunc) http.HandlerFunc {
 		log.Printf("Request Body: %s", bodyBytes)
 
 		// Log the headers in a structured manner
-		var headerStrings []string
-		for name, values := range r.Header {
-			headerStrings = append(headerStrings, fmt.Sprintf("%s: %s", name, strings.Join(values, ", ")))
-		}
-		log.Printf("Headers: {\n\t%s\n}", strings.Join(headerStrings, "\n\t"))
+		log.Printf("Headers: {\n\t%s\n}", formatHeaders(r.Header))
 
 		// Reset the request body to its original state
 		r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
@@ -81,11 +99,7 @@ func LoggingHandler(next http.Handler) http.Handler {
 		log.Printf("Request Body: %s", bodyBytes)
 
 		// Log the headers in a structured manner
-		var headerStrings []string
-		for name, values := range r.Header {
-			headerStrings = append(headerStrings, fmt.Sprintf("%s: %s", name, strings.Join(values, ", ")))
-		}
-		log.Printf("Headers: {\n\t%s\n}", strings.Join(headerStrings, "\n\t"))
+		log.Printf("Headers: {\n\t%s\n}", formatHeaders(r.Header))
 
 		// Reset the request body to its original state
 		r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
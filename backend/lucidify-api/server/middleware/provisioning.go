@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"log"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/auth"
+	"lucidify-api/server/config"
+	"lucidify-api/service/userservice"
+	"net/http"
+	"time"
+)
+
+// UserProvisioningMiddleware creates the signed-in user in the users table on
+// their first authenticated request, for when the user.created webhook has not
+// been processed yet. It must run after auth.Middleware. The user service
+// remembers the users known to exist, across routes, until they are deleted.
+//
+// Users of an OIDC issuer are created from the claims of their token, those
+// of Clerk from their Clerk profile. API keys are only issued to existing
+// users.
+func UserProvisioningMiddleware(userService userservice.UserService, config *config.ServerConfig) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			principal, ok := auth.PrincipalFromContext(r.Context())
+			if !ok {
+				// Unauthenticated requests are rejected by the handler itself
+				next(w, r)
+				return
+			}
+
+			userID := principal.UserID
+			var err error
+			switch {
+			case principal.Profile != nil:
+				err = userService.ProvisionUserFromClaims(ctx, userFromProfile(userID, principal.Profile))
+			case config.AuthProvider == "oidc":
+				// Only API keys lack a profile, and their user exists
+			default:
+				err = userService.ProvisionUser(ctx, userID, config.ClerkSecretKey)
+			}
+			if err != nil {
+				log.Printf("Failed to provision user %s: %v", userID, err)
+				http.Error(w, "Service unavailable. Unable to provision user", http.StatusServiceUnavailable)
+				return
+			}
+
+			next(w, r)
+		}
+	}
+}
+
+func userFromProfile(userID string, profile *auth.Profile) storemodels.User {
+	// Usernames are unique, so users without one get their id
+	username := profile.PreferredUsername
+	if username == "" {
+		username = userID
+	}
+	now := time.Now().UnixMilli()
+	return storemodels.User{
+		UserID:          userID,
+		Username:        username,
+		Email:           profile.Email,
+		FirstName:       profile.GivenName,
+		LastName:        profile.FamilyName,
+		ImageURL:        profile.Picture,
+		ProfileImageURL: profile.Picture,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"lucidify-api/server/tracing"
+	"net/http"
+)
+
+// Tracing starts a span for each request served by the handler, continuing
+// the trace of the caller if any. Spans are named after the method and the
+// pattern of mux the request matches.
+func Tracing(mux *http.ServeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return tracing.Handler(next, func(r *http.Request) string {
+			return r.Method + " " + routeOf(mux, r)
+		})
+	}
+}
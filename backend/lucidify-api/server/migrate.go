@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"lucidify-api/data/store/postgresqlclient"
+	"lucidify-api/server/config"
+	"os"
+	"strconv"
+)
+
+const migrateUsage = "Usage: lucidify-api migrate up|down [N]|status|to N"
+
+// RunMigrateCommand runs the migrate subcommand: up applies every pending
+// migration, down reverts the last N migrations (1 by default), to migrates up
+// or down to version N and status prints the version of the database.
+func RunMigrateCommand(config *config.ServerConfig, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	postgre, err := postgresqlclient.NewPostgreSQL(config.PostgresqlURL)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		err = postgre.MigrateUp(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return errors.New(migrateUsage)
+			}
+		}
+		err = postgre.MigrateDown(ctx, steps)
+	case "to":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil || version < 0 {
+			return errors.New(migrateUsage)
+		}
+		err = postgre.MigrateTo(ctx, version)
+	case "status":
+	default:
+		return errors.New(migrateUsage)
+	}
+	if err != nil {
+		return err
+	}
+
+	status, err := postgre.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+	output, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout, string(output))
+	return nil
+}
+
+// checkDatabaseSchema applies the DB_MIGRATION_CHECK mode before serving:
+// "check" refuses to serve when the database is behind the migrations of the
+// binary, "up" applies the pending migrations first, and "off" skips the
+// check.
+func checkDatabaseSchema(ctx context.Context, postgre *postgresqlclient.PostgreSQL, mode string) error {
+	if mode == "off" {
+		return nil
+	}
+	if mode == "up" {
+		if err := postgre.MigrateUp(ctx); err != nil {
+			return fmt.Errorf("failed to migrate the database: %w", err)
+		}
+	}
+
+	status, err := postgre.MigrationStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get the database schema version: %w", err)
+	}
+	if status.Behind() {
+		return fmt.Errorf("database schema is at version %d (dirty: %t) but version %d is expected, run lucidify-api migrate up",
+			status.Version, status.Dirty, status.LatestVersion)
+	}
+	if status.Version > status.LatestVersion {
+		log.Printf("Database schema is at version %d, ahead of version %d expected by this binary", status.Version, status.LatestVersion)
+	}
+	return nil
+}
@@ -3,33 +3,58 @@ package server
 import (
 	"lucidify-api/data/store/postgresqlclient"
 	"lucidify-api/data/store/weaviateclient"
+	"lucidify-api/http/adminapi"
+	"lucidify-api/http/apikeysapi"
 	"lucidify-api/http/chatapi"
 	"lucidify-api/http/clerkapi"
+	"lucidify-api/http/collectionsapi"
 	"lucidify-api/http/documentsapi"
+	"lucidify-api/http/healthapi"
 	"lucidify-api/http/syncapi"
+	"lucidify-api/http/workspacesapi"
+	"lucidify-api/server/auth"
 	"lucidify-api/server/config"
+	"lucidify-api/server/metrics"
+	"lucidify-api/service/apikeyservice"
 	"lucidify-api/service/chatservice"
+	"lucidify-api/service/collectionservice"
 	"lucidify-api/service/documentservice"
+	"lucidify-api/service/embeddingservice"
+	"lucidify-api/service/healthservice"
 	"lucidify-api/service/syncservice"
 	"lucidify-api/service/userservice"
+	"lucidify-api/service/webhookservice"
+	"lucidify-api/service/workspaceservice"
 	"net/http"
-
-	"github.com/clerkinc/clerk-sdk-go/clerk"
 )
 
 func SetupRoutes(
 	config *config.ServerConfig,
 	mux *http.ServeMux,
 	storeInstance *postgresqlclient.PostgreSQL,
-	clerkInstance clerk.Client,
+	authenticator auth.Authenticator,
 	weaviateInstance weaviateclient.WeaviateClient,
 	documentsService documentservice.DocumentService,
 	cvs chatservice.ChatVectorService,
 	syncService syncservice.SyncService,
-	userService userservice.UserService) {
+	userService userservice.UserService,
+	webhookService webhookservice.WebhookService,
+	apiKeyService apikeyservice.APIKeyService,
+	workspaceService workspaceservice.WorkspaceService,
+	collectionService collectionservice.CollectionService,
+	embeddingService embeddingservice.EmbeddingService,
+	healthService healthservice.HealthService) {
+
+	chatapi.SetupRoutes(config, mux, cvs, userService, authenticator)
+	documentsapi.SetupRoutes(config, mux, documentsService, userService, authenticator)
+	clerkapi.SetupRoutes(webhookService, config, mux)
+	syncapi.SetupRoutes(config, mux, authenticator, syncService, userService)
+	adminapi.SetupRoutes(config, mux, webhookService, embeddingService)
+	apikeysapi.SetupRoutes(config, mux, apiKeyService, userService, authenticator)
+	workspacesapi.SetupRoutes(config, mux, workspaceService, userService, authenticator)
+	collectionsapi.SetupRoutes(config, mux, collectionService, userService, authenticator)
+	healthapi.SetupRoutes(config, mux, healthService)
 
-	chatapi.SetupRoutes(config, mux, cvs, clerkInstance)
-	documentsapi.SetupRoutes(config, mux, documentsService, clerkInstance)
-	clerkapi.SetupRoutes(storeInstance, userService, config, mux)
-	syncapi.SetupRoutes(config, mux, clerkInstance, syncService)
+	// Scraped by Prometheus, so neither logged nor authenticated
+	mux.Handle("/metrics", metrics.Handler())
 }
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"log"
+	"lucidify-api/data/store/postgresqlclient"
+	"lucidify-api/data/store/weaviateclient"
+	"lucidify-api/service/healthservice"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// worker is a background loop, such as the purge or webhook services. Stop
+// cancels the work in flight once ctx is done.
+type worker interface {
+	Stop(ctx context.Context)
+}
+
+// serve serves until SIGINT or SIGTERM, then shuts down. /readyz fails for
+// shutdownDelay before the server stops accepting connections, so that load
+// balancers stop sending it requests first.
+func serve(
+	server *http.Server,
+	shutdownDelay time.Duration,
+	shutdownTimeout time.Duration,
+	healthService healthservice.HealthService,
+	workers []worker,
+	postgre *postgresqlclient.PostgreSQL,
+	weaviate weaviateclient.WeaviateClient,
+	shutdownTracing func(context.Context) error) {
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serverErr:
+		log.Fatalf("Server failed to start: %v", err)
+	case <-ctx.Done():
+	}
+
+	healthService.Drain()
+	if shutdownDelay > 0 {
+		log.Printf("Draining for %s before shutting down", shutdownDelay)
+		time.Sleep(shutdownDelay)
+	}
+	log.Printf("Shutting down, waiting up to %s for requests and background jobs in flight", shutdownTimeout)
+	shutdown(server, shutdownTimeout, workers, postgre, weaviate, shutdownTracing)
+}
+
+// shutdown stops accepting requests and waits for the requests in flight and
+// the background workers until the timeout, when the workers are cancelled.
+// It then closes the clients and flushes the spans left.
+func shutdown(
+	server *http.Server,
+	timeout time.Duration,
+	workers []worker,
+	postgre *postgresqlclient.PostgreSQL,
+	weaviate weaviateclient.WeaviateClient,
+	shutdownTracing func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("Requests were still in flight after %s, closing their connections: %v", timeout, err)
+			server.Close()
+		}
+	}()
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w worker) {
+			defer wg.Done()
+			w.Stop(ctx)
+		}(w)
+	}
+	// The workers only return once their batch is done or cancelled, so that
+	// the clients are not closed under them
+	wg.Wait()
+	if ctx.Err() != nil {
+		log.Printf("Background jobs were still running after %s and were cancelled", timeout)
+	}
+
+	weaviate.Close()
+	if err := postgre.Close(); err != nil {
+		log.Printf("Failed to close PostgreSQL: %v", err)
+	}
+
+	// The spans of the requests cut short are flushed even past the timeout
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		log.Printf("Failed to flush the traces: %v", err)
+	}
+	log.Printf("Server stopped")
+}
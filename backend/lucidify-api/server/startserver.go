@@ -1,83 +1,189 @@
 package server
 
 import (
+	"context"
 	"log"
 	"lucidify-api/data/store/postgresqlclient"
 	"lucidify-api/data/store/weaviateclient"
+	"lucidify-api/server/auth"
 	"lucidify-api/server/config"
+	"lucidify-api/server/metrics"
+	"lucidify-api/server/middleware"
+	"lucidify-api/server/tracing"
+	"lucidify-api/service/apikeyservice"
 	"lucidify-api/service/chatservice"
 	"lucidify-api/service/clerkservice"
+	"lucidify-api/service/collectionservice"
 	"lucidify-api/service/documentservice"
+	"lucidify-api/service/embeddingservice"
+	"lucidify-api/service/healthservice"
+	"lucidify-api/service/purgeservice"
 	"lucidify-api/service/syncservice"
 	"lucidify-api/service/userservice"
+	"lucidify-api/service/vectorschemaservice"
+	"lucidify-api/service/webhookservice"
+	"lucidify-api/service/workspaceservice"
 	"net/http"
 
 	"github.com/gorilla/handlers"
 	"github.com/sashabaranov/go-openai"
 )
 
-func StartServer() {
-	config := config.NewServerConfig()
+// StartServer builds every client and service from the config and serves the
+// API.
+func StartServer(config *config.ServerConfig) {
+	if config.AdminAPIKey == "" {
+		log.Printf("admin_api_key is not set, admin endpoints are disabled")
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), config.OTLPEndpoint)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	mux := http.NewServeMux()
 
-	postgre, err := postgresqlclient.NewPostgreSQL()
+	postgre, err := postgresqlclient.NewPostgreSQL(config.PostgresqlURL)
 	if err != nil {
 		log.Fatal(err)
 	}
+	postgre.SetPoolLimits(config.DBMaxOpenConns, config.DBMaxIdleConns, config.DBConnMaxLifetime, config.DBConnMaxIdleTime)
+	if err := checkDatabaseSchema(context.Background(), postgre, config.DBMigrationCheck); err != nil {
+		log.Fatal(err)
+	}
+	metrics.RegisterQueueDepths(postgre.GetQueueDepths)
 
-	clerk, err := clerkservice.NewClerkClient()
+	clerk, err := clerkservice.NewClerkClient(config.ClerkSecretKey)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	weaviate, err := weaviateclient.NewWeaviateClient()
+	var sessionAuthenticator auth.Authenticator
+	if config.AuthProvider == "oidc" {
+		sessionAuthenticator = auth.NewJWKSAuthenticator(config.OIDCIssuer, config.OIDCAudience, config.OIDCJWKSURL)
+	} else {
+		sessionAuthenticator = auth.NewClerkAuthenticator(clerk)
+	}
+
+	apiKeyService := apikeyservice.NewAPIKeyService(postgre)
+
+	// API keys are tried first, as they are recognised by their prefix
+	authenticator := auth.NewChainAuthenticator(
+		auth.NewAPIKeyAuthenticator(apiKeyService),
+		sessionAuthenticator,
+	)
+
+	openaiConfig := openai.DefaultConfig(config.OPENAI_API_KEY)
+	openaiConfig.HTTPClient = &http.Client{Transport: tracing.Transport(nil)}
+	openaiClient := openai.NewClientWithConfig(openaiConfig)
+
+	embeddingService := embeddingservice.NewEmbeddingService(
+		postgre,
+		embeddingservice.NewOpenAIEmbedder(openaiClient),
+		config.EmbeddingModel,
+		config.EmbeddingCacheSize,
+	)
+
+	weaviate, err := weaviateclient.NewWeaviateClient(
+		config.WeaviateHost,
+		config.WeaviateScheme,
+		config.WeaviateTimeout,
+		config.OPENAI_API_KEY,
+		embeddingService,
+	)
 	if err != nil {
 		log.Fatal(err)
 	}
+	weaviate = weaviateclient.NewInstrumentedWeaviateClient(weaviate)
 
-	documentService := documentservice.NewDocumentService(postgre, weaviate)
+	documentService := documentservice.NewDocumentService(postgre, weaviate, documentservice.ChunkerOptions{
+		URL:        config.AI_API_URL,
+		APIKey:     config.X_AI_API_KEY,
+		Timeout:    config.ChunkerTimeout,
+		WindowSize: config.ChunkerWindowSize,
+		BlockSize:  config.ChunkerBlockSize,
+	})
+	documentService = documentservice.NewInstrumentedDocumentService(documentService)
 
-	openaiClient := openai.NewClient(config.OPENAI_API_KEY)
+	workspaceService := workspaceservice.NewWorkspaceService(postgre, documentService)
 
-	cvs := chatservice.NewChatVectorService(weaviate, openaiClient, documentService)
+	collectionService := collectionservice.NewCollectionService(postgre, documentService)
 
-	syncService, err := syncservice.NewSyncService()
+	cvs := chatservice.NewChatVectorService(weaviate, openaiClient, documentService, workspaceService, collectionService, config.ChatContextChunks)
+	cvs = chatservice.NewInstrumentedChatVectorService(cvs)
+
+	syncService, err := syncservice.NewSyncService(postgre)
 	if err != nil {
 		log.Fatal(err)
 	}
+	syncService = syncservice.NewInstrumentedSyncService(syncService)
 
 	userService, err := userservice.NewUserService(postgre, weaviate)
 	if err != nil {
 		log.Fatal(err)
 	}
+	userService = userservice.NewInstrumentedUserService(userService)
+
+	purgeService := purgeservice.NewPurgeService(
+		documentService,
+		userService,
+		config.DocumentTrashRetention,
+		config.UserDeletionGracePeriod,
+	)
+	purgeService.Start(config.PurgeInterval)
+
+	webhookService := webhookservice.NewWebhookService(postgre, userService, workspaceService, config.WebhookMaxAttempts)
+	webhookService.Start(config.WebhookPollInterval)
+
+	healthService := healthservice.NewHealthService(
+		postgre,
+		weaviate,
+		vectorschemaservice.NewVectorSchemaService(postgre, weaviate),
+		openaiClient,
+		config.AI_API_URL,
+		config.ReadinessTimeout,
+	)
 
 	SetupRoutes(
 		config,
 		mux,
 		postgre,
-		clerk,
+		authenticator,
 		weaviate,
 		documentService,
 		cvs,
 		syncService,
 		userService,
+		webhookService,
+		apiKeyService,
+		workspaceService,
+		collectionService,
+		embeddingService,
+		healthService,
 	)
 
 	// Set up CORS middlware
 	corsHandler := handlers.CORS(
 		handlers.AllowedOrigins(config.AllowedOrigins), // Adjust this to the origins you want to allow.
-		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"Content-Type", "Authorization", "X-User-ID"}),
+		handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization", "X-User-ID", "If-Match", "If-None-Match", "Range", "If-Range"}),
+		// Partial downloads answer with Content-Range
+		handlers.ExposedHeaders([]string{"ETag", "Location", "Content-Disposition", "Content-Range", "Accept-Ranges"}),
 		handlers.AllowCredentials(), // This line sets Access-Control-Allow-Credentials to true
 	)
 
 	// Wrap the original mux with the CORS handler.
 	corsEnabledMux := corsHandler(mux)
 
-	// Use the CORS-enabled mux in your server.
-	log.Printf("Server starting on :%s", config.Port)
-	if err := http.ListenAndServe(":"+config.Port, corsEnabledMux); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	// Trace, count and time every request, preflights included.
+	server := &http.Server{
+		Addr:              ":" + config.Port,
+		Handler:           middleware.Tracing(mux)(middleware.Metrics(mux)(corsEnabledMux)),
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
 	}
+	log.Printf("Server starting on :%s", config.Port)
+	serve(server, config.ShutdownDelay, config.ShutdownTimeout, healthService, []worker{purgeService, webhookService}, postgre, weaviate, shutdownTracing)
 }
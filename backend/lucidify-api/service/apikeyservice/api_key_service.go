@@ -0,0 +1,151 @@
+package apikeyservice
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"lucidify-api/data/store/postgresqlclient"
+	"lucidify-api/data/store/storemodels"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// KeyPrefix starts every API key, so that keys can be told apart from session
+// tokens and found by secret scanners.
+const KeyPrefix = "lk_"
+
+// displayPrefixLength is the number of characters of a key, including
+// KeyPrefix, that are stored in clear text and shown in listings.
+const displayPrefixLength = 11
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+	ErrAPIKeyRevoked  = errors.New("api key has been revoked")
+	ErrAPIKeyExpired  = errors.New("api key has expired")
+	ErrAPIKeyNotFound = errors.New("api key not found")
+)
+
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, userID, name string, scopes []string, expiresAt *time.Time) (string, *storemodels.APIKey, error)
+	GetAPIKeys(ctx context.Context, userID string) ([]storemodels.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID string, apiKeyID uuid.UUID) error
+	VerifyAPIKey(ctx context.Context, key string) (*storemodels.APIKey, error)
+}
+
+type APIKeyServiceImpl struct {
+	postgresqlDB *postgresqlclient.PostgreSQL
+}
+
+func NewAPIKeyService(postgresqlDB *postgresqlclient.PostgreSQL) APIKeyService {
+	return &APIKeyServiceImpl{postgresqlDB: postgresqlDB}
+}
+
+// CreateAPIKey generates a new key for the user and returns it together with
+// its stored record. The key itself is not stored and cannot be retrieved
+// again.
+func (s *APIKeyServiceImpl) CreateAPIKey(ctx context.Context, userID, name string, scopes []string, expiresAt *time.Time) (string, *storemodels.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, fmt.Errorf("api key name is required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !isValidScope(scope) {
+			return "", nil, fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", nil, fmt.Errorf("expiry must be in the future")
+	}
+	if expiresAt != nil {
+		// The timestamp columns are stored without a time zone
+		utc := expiresAt.UTC()
+		expiresAt = &utc
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	key := KeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey := &storemodels.APIKey{
+		UserID:    userID,
+		Name:      name,
+		KeyPrefix: key[:displayPrefixLength],
+		KeyHash:   hashAPIKey(key),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.postgresqlDB.CreateAPIKey(ctx, apiKey); err != nil {
+		return "", nil, fmt.Errorf("Failed to store api key: %w", err)
+	}
+
+	return key, apiKey, nil
+}
+
+func (s *APIKeyServiceImpl) GetAPIKeys(ctx context.Context, userID string) ([]storemodels.APIKey, error) {
+	return s.postgresqlDB.GetAPIKeysByUser(ctx, userID)
+}
+
+func (s *APIKeyServiceImpl) RevokeAPIKey(ctx context.Context, userID string, apiKeyID uuid.UUID) error {
+	err := s.postgresqlDB.RevokeAPIKey(ctx, userID, apiKeyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+// VerifyAPIKey returns the record of a valid key and records its use.
+func (s *APIKeyServiceImpl) VerifyAPIKey(ctx context.Context, key string) (*storemodels.APIKey, error) {
+	if !strings.HasPrefix(key, KeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := s.postgresqlDB.GetAPIKeyByHash(ctx, hashAPIKey(key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if apiKey.RevokedAt != nil {
+		return nil, ErrAPIKeyRevoked
+	}
+	if apiKey.ExpiresAt != nil && !apiKey.ExpiresAt.After(time.Now()) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	if err := s.postgresqlDB.TouchAPIKey(ctx, apiKey.APIKeyID); err != nil {
+		// Not worth failing the request over
+		log.Printf("Failed to record use of api key %s: %v", apiKey.APIKeyID, err)
+	}
+
+	return apiKey, nil
+}
+
+// hashAPIKey hashes a key for storage. Keys carry 256 bits of randomness, so a
+// plain SHA-256 is enough and keeps lookups by hash possible.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func isValidScope(scope string) bool {
+	for _, validScope := range storemodels.ValidScopes {
+		if scope == validScope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+// //go:build integration
+// // +build integration
+package apikeyservice
+
+import (
+	"context"
+	"errors"
+	"lucidify-api/data/store/postgresqlclient"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/config"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyLifecycleIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := postgresqlclient.NewPostgreSQL(config.NewServerConfig().PostgresqlURL)
+	if err != nil {
+		t.Fatalf("Failed to create test postgresqlclient: %v", err)
+	}
+	apiKeyService := NewAPIKeyService(db)
+
+	user := storemodels.User{
+		UserID:    "TestAPIKeyServiceIntegrationUserID",
+		Username:  "TestAPIKeyServiceIntegrationUsername",
+		Email:     "api_key_service_integration@example.com",
+		CreatedAt: 1654012591514,
+		UpdatedAt: 1654012591514,
+	}
+	err = db.UpsertUserInUsersTable(ctx, user)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	t.Cleanup(func() {
+		db.DeleteUserInUsersTable(ctx, user.UserID)
+	})
+
+	_, _, err = apiKeyService.CreateAPIKey(ctx, user.UserID, "ci", []string{"documents:everything"}, nil)
+	if err == nil {
+		t.Errorf("Expected an error for an unknown scope")
+	}
+
+	key, apiKey, err := apiKeyService.CreateAPIKey(ctx, user.UserID, "ci", []string{storemodels.ScopeDocumentsRead}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create api key: %v", err)
+	}
+	if !strings.HasPrefix(key, KeyPrefix) || !strings.HasPrefix(key, apiKey.KeyPrefix) {
+		t.Errorf("Expected key %q to start with %q and its display prefix %q", key, KeyPrefix, apiKey.KeyPrefix)
+	}
+	if apiKey.KeyHash == key {
+		t.Errorf("Expected the key to be stored hashed")
+	}
+
+	verified, err := apiKeyService.VerifyAPIKey(ctx, key)
+	if err != nil {
+		t.Fatalf("Failed to verify api key: %v", err)
+	}
+	if verified.UserID != user.UserID || len(verified.Scopes) != 1 || verified.Scopes[0] != storemodels.ScopeDocumentsRead {
+		t.Errorf("Unexpected verified api key: %+v", verified)
+	}
+
+	apiKeys, err := apiKeyService.GetAPIKeys(ctx, user.UserID)
+	if err != nil {
+		t.Fatalf("Failed to get api keys: %v", err)
+	}
+	if len(apiKeys) != 1 || apiKeys[0].LastUsedAt == nil {
+		t.Errorf("Expected one api key with a last used timestamp, got %+v", apiKeys)
+	}
+
+	err = apiKeyService.RevokeAPIKey(ctx, user.UserID, apiKey.APIKeyID)
+	if err != nil {
+		t.Fatalf("Failed to revoke api key: %v", err)
+	}
+	_, err = apiKeyService.VerifyAPIKey(ctx, key)
+	if !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Errorf("Expected ErrAPIKeyRevoked, got %v", err)
+	}
+	err = apiKeyService.RevokeAPIKey(ctx, user.UserID, apiKey.APIKeyID)
+	if !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("Expected ErrAPIKeyNotFound when revoking twice, got %v", err)
+	}
+
+	_, err = apiKeyService.VerifyAPIKey(ctx, KeyPrefix+"unknown")
+	if !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected ErrInvalidAPIKey, got %v", err)
+	}
+}
@@ -1,38 +1,159 @@
 package chatservice
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"lucidify-api/data/store/storemodels"
 	"lucidify-api/data/store/weaviateclient"
+	"lucidify-api/service/collectionservice"
 	"lucidify-api/service/documentservice"
+	"lucidify-api/service/workspaceservice"
 
+	"github.com/google/uuid"
 	"github.com/sashabaranov/go-openai"
 )
 
+// RetrievalOptions select the documents searched for chat and search. By
+// default the private documents of the user and the documents of their
+// workspaces are searched.
+type RetrievalOptions struct {
+	// IncludeShared adds the documents other users have shared with the user.
+	IncludeShared bool
+	// CollectionID and DocumentIDs restrict the search to the documents of a
+	// collection of the user and to the given documents. When both are given,
+	// documents from either are searched.
+	CollectionID *uuid.UUID
+	DocumentIDs  []uuid.UUID
+	// MetadataFilters restrict the search to documents whose metadata matches
+	// every filter.
+	MetadataFilters []storemodels.MetadataFilter
+}
+
 type ChatVectorService interface {
-	ConstructSystemMessage(string, string) (string, error)
+	ConstructSystemMessage(ctx context.Context, question string, userID string, options RetrievalOptions) (string, error)
+	Search(ctx context.Context, query string, userID string, limit int, options RetrievalOptions) ([]storemodels.ChunkFromVectorSearch, error)
 }
 
 type ChatVectorServiceImpl struct {
-	weaviateDB      weaviateclient.WeaviateClient
-	openaiClient    openai.Client
-	documentService documentservice.DocumentService
+	weaviateDB        weaviateclient.WeaviateClient
+	openaiClient      openai.Client
+	documentService   documentservice.DocumentService
+	workspaceService  workspaceservice.WorkspaceService
+	collectionService collectionservice.CollectionService
+
+	// contextChunks is the number of chunks added to the system message
+	contextChunks int
 }
 
 func NewChatVectorService(
 	weaviateDB weaviateclient.WeaviateClient,
 	openaiClient *openai.Client,
-	documentService documentservice.DocumentService) ChatVectorService {
-	return &ChatVectorServiceImpl{weaviateDB: weaviateDB, openaiClient: *openaiClient, documentService: documentService}
+	documentService documentservice.DocumentService,
+	workspaceService workspaceservice.WorkspaceService,
+	collectionService collectionservice.CollectionService,
+	contextChunks int) ChatVectorService {
+	return &ChatVectorServiceImpl{
+		weaviateDB:        weaviateDB,
+		openaiClient:      *openaiClient,
+		documentService:   documentService,
+		workspaceService:  workspaceService,
+		collectionService: collectionService,
+		contextChunks:     contextChunks,
+	}
+}
+
+// Search returns the chunks closest to the query among the documents selected
+// by the options.
+func (c *ChatVectorServiceImpl) Search(ctx context.Context, query string, userID string, limit int, options RetrievalOptions) ([]storemodels.ChunkFromVectorSearch, error) {
+	scope, err := c.searchScope(ctx, userID, options)
+	if err != nil {
+		return nil, err
+	}
+	results, err := c.weaviateDB.SearchDocumentsByText(ctx, limit, scope, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	return results, c.resolveUploaders(ctx, results)
+}
+
+// resolveUploaders sets the uploader of the chunks of workspace documents,
+// which the vector store does not keep.
+func (c *ChatVectorServiceImpl) resolveUploaders(ctx context.Context, results []storemodels.ChunkFromVectorSearch) error {
+	var documentIDs []uuid.UUID
+	for _, result := range results {
+		if result.UserID == "" {
+			documentIDs = append(documentIDs, result.DocumentID)
+		}
+	}
+	if len(documentIDs) == 0 {
+		return nil
+	}
+
+	owners, err := c.documentService.GetDocumentOwners(ctx, documentIDs)
+	if err != nil {
+		return fmt.Errorf("Failed to get uploaders of documents: %w", err)
+	}
+	for i := range results {
+		if results[i].UserID == "" {
+			results[i].UserID = owners[results[i].DocumentID]
+		}
+	}
+	return nil
 }
 
-func (c *ChatVectorServiceImpl) ConstructSystemMessage(question string, userID string) (string, error) {
-	// Get the vector embedding for the question. You'll need a Go function equivalent to 'get_embedding' in Python.
-	concepts := []string{question}
-	TOP_K := 4
+func (c *ChatVectorServiceImpl) searchScope(ctx context.Context, userID string, options RetrievalOptions) (weaviateclient.SearchScope, error) {
+	// Documents shared in the user's workspaces are searched as well
+	workspaceIDs, err := c.workspaceService.GetWorkspaceIDs(ctx, userID)
+	if err != nil {
+		return weaviateclient.SearchScope{}, err
+	}
+
+	// With multi-tenancy, the chunks of these documents are in the tenants of
+	// their uploaders
+	owners, err := c.documentService.GetWorkspaceDocumentOwners(ctx, workspaceIDs)
+	if err != nil {
+		return weaviateclient.SearchScope{}, err
+	}
+
+	scope := weaviateclient.SearchScope{
+		UserID:          userID,
+		WorkspaceIDs:    workspaceIDs,
+		MetadataFilters: options.MetadataFilters,
+		OwnerUserIDs:    owners,
+	}
+	if options.IncludeShared {
+		sharedDocuments, err := c.documentService.GetSharedDocuments(ctx, userID)
+		if err != nil {
+			return weaviateclient.SearchScope{}, err
+		}
+		for _, document := range sharedDocuments {
+			scope.DocumentIDs = append(scope.DocumentIDs, document.DocumentUUID)
+			scope.OwnerUserIDs = append(scope.OwnerUserIDs, document.UserID)
+		}
+	}
+
+	if options.CollectionID != nil || options.DocumentIDs != nil {
+		restrictTo := append([]uuid.UUID{}, options.DocumentIDs...)
+		if options.CollectionID != nil {
+			collectionDocumentIDs, err := c.collectionService.GetCollectionDocumentIDs(ctx, userID, *options.CollectionID)
+			if err != nil {
+				return weaviateclient.SearchScope{}, err
+			}
+			restrictTo = append(restrictTo, collectionDocumentIDs...)
+		}
+		scope.RestrictToDocumentIDs = restrictTo
+	}
+
+	return scope, nil
+}
 
+// ConstructSystemMessage searches the documents selected by the options for
+// the question.
+func (c *ChatVectorServiceImpl) ConstructSystemMessage(ctx context.Context, question string, userID string, options RetrievalOptions) (string, error) {
 	// Query your vector database
-	results, err := c.weaviateDB.SearchDocumentsByText(TOP_K, userID, concepts)
+	results, err := c.Search(ctx, question, userID, c.contextChunks, options)
 	if err != nil {
 		return "", err
 	}
@@ -40,11 +161,16 @@ func (c *ChatVectorServiceImpl) ConstructSystemMessage(question string, userID s
 	// Process the results and construct the files_string, similar to Python code
 	filesString := ""
 	for _, result := range results {
-		document, err := c.documentService.GetDocumentByID(userID, result.DocumentID)
-		filename := document.DocumentName
+		document, err := c.documentService.GetDocumentByID(ctx, userID, result.DocumentID)
+		if errors.Is(err, documentservice.ErrDocumentNotFound) || errors.Is(err, documentservice.ErrDocumentAccessDenied) {
+			// The chunk outlived its document or the access to it
+			log.Printf("Skipping chunk of document %s: %v", result.DocumentID, err)
+			continue
+		}
 		if err != nil {
 			return "", err
 		}
+		filename := document.DocumentName
 		fileText := result.ChunkContent
 		// if result.Certainty > 0.5 {
 		fileString := fmt.Sprintf("###\n\"%s\"\n%s\n", filename, fileText)
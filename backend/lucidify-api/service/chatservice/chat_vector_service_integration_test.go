@@ -3,21 +3,27 @@
 package chatservice
 
 import (
+	"context"
 	"log"
 	"lucidify-api/data/store/postgresqlclient"
 	"lucidify-api/data/store/storemodels"
 	"lucidify-api/data/store/weaviateclient"
 	"lucidify-api/server/config"
+	"lucidify-api/service/collectionservice"
 	"lucidify-api/service/documentservice"
 	"lucidify-api/service/userservice"
+	"lucidify-api/service/workspaceservice"
 	"strings"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/sashabaranov/go-openai"
 )
 
 func createTestUserInDb() string {
-	db, err := postgresqlclient.NewPostgreSQL()
+	ctx := context.Background()
+	cfg := config.NewServerConfig()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
 
 	// the user id registered by the jwt token must exist in the local database
 	user := storemodels.User{
@@ -35,7 +41,7 @@ func createTestUserInDb() string {
 		UpdatedAt:        1654012591514,
 	}
 
-	weaviate, err := weaviateclient.NewWeaviateClientTest()
+	weaviate, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
 	if err != nil {
 		log.Fatalf("Failed to create WeaviateClient: %v", err)
 	}
@@ -44,20 +50,20 @@ func createTestUserInDb() string {
 		log.Fatalf("Failed to create UserService: %v", err)
 	}
 
-	err = userService.DeleteUser(user.UserID)
+	err = userService.DeleteUser(ctx, user.UserID)
 	if err != nil {
 		log.Fatalf("Failed to delete user: %v", err)
 	}
-	if !userService.HasUserBeenDeleted(user.UserID, 3) {
+	if !userService.HasUserBeenDeleted(ctx, user.UserID, 3) {
 		log.Fatalf("Failed to delete user: %v", err)
 	}
 
-	err = db.CreateUserInUsersTable(user)
+	err = db.CreateUserInUsersTable(ctx, user)
 	if err != nil {
 		log.Fatalf("Failed to create user: %v", err)
 	}
 
-	_, err = userService.GetUserWithRetries(user.UserID, 3)
+	_, err = userService.GetUserWithRetries(ctx, user.UserID, 3)
 	if err != nil {
 		log.Fatalf("User not found after creation: %v", err)
 	}
@@ -65,29 +71,33 @@ func createTestUserInDb() string {
 }
 
 func setupTestChatService() ChatVectorService {
+	ctx := context.Background()
+	cfg := config.NewServerConfig()
+
 	// Initialize PostgreSQL for tests
-	postgresqlDB, err := postgresqlclient.NewPostgreSQL() // Adjust this to match your actual constructor
+	postgresqlDB, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL) // Adjust this to match your actual constructor
 	if err != nil {
 		log.Fatalf("Failed to initialize PostgreSQL: %v", err)
 	}
 
 	// Initialize Weaviate for tests
-	weaviateDB, err := weaviateclient.NewWeaviateClientTest() // Adjust this to match your actual constructor
+	weaviateDB, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY) // Adjust this to match your actual constructor
 	if err != nil {
 		log.Fatalf("Failed to create Weaviate client: %v", err)
 	}
 
-	cfg := config.NewServerConfig()
 	openaiClient := openai.NewClient(cfg.OPENAI_API_KEY)
 
-	documentService := documentservice.NewDocumentService(postgresqlDB, weaviateDB)
+	documentService := documentservice.NewDocumentService(postgresqlDB, weaviateDB, documentservice.ChunkerOptions{URL: cfg.AI_API_URL, APIKey: cfg.X_AI_API_KEY})
 
 	// Create instance of ChatVectorService
-	cvs := NewChatVectorService(weaviateDB, openaiClient, documentService)
+	workspaceService := workspaceservice.NewWorkspaceService(postgresqlDB, documentService)
+	collectionService := collectionservice.NewCollectionService(postgresqlDB, documentService)
+	cvs := NewChatVectorService(weaviateDB, openaiClient, documentService, workspaceService, collectionService, cfg.ChatContextChunks)
 
 	createTestUserInDb()
 
-	documentService.UploadDocument("TestChatServiceIntegrationTestUUID", "Cat Knowledge",
+	documentService.UploadDocument(ctx, "TestChatServiceIntegrationTestUUID", "Cat Knowledge",
 		`Cats, with their graceful movements and independent nature,
 		have been revered and adored by many civilizations throughout history. In
 		ancient Egypt, they were considered sacred and were even associated with
@@ -128,7 +138,7 @@ func setupTestChatService() ChatVectorService {
 		ingrained and serve as a reminder that beneath their cuddly exterior lies a
 		skilled predator, honed by millions of years of evolution.`)
 
-	documentService.UploadDocument("TestChatServiceIntegrationTestUUID", "Dog Knowledge",
+	documentService.UploadDocument(ctx, "TestChatServiceIntegrationTestUUID", "Dog Knowledge",
 		`Introduction to Dogs: Dogs, often referred to as "man's best friend," have been
 		companions to humans for thousands of years. Originating from wild wolves,
 		these loyal creatures have been domesticated and bred for various roles
@@ -177,6 +187,8 @@ func setupTestChatService() ChatVectorService {
 }
 
 func TestConstructSystemMessageTemp(t *testing.T) {
+	ctx := context.Background()
+
 	// Set up the ChatVectorService with test data.
 	cvs := setupTestChatService()
 
@@ -184,7 +196,7 @@ func TestConstructSystemMessageTemp(t *testing.T) {
 	testUserID := "TestChatServiceIntegrationTestUUID"
 
 	// Call ConstructSystemMessage with a test question.
-	systemMessage, err := cvs.ConstructSystemMessage("Tell me about dogs", testUserID)
+	systemMessage, err := cvs.ConstructSystemMessage(ctx, "Tell me about dogs", testUserID, RetrievalOptions{})
 
 	// Check for unexpected errors.
 	if err != nil {
@@ -215,6 +227,42 @@ func TestConstructSystemMessageTemp(t *testing.T) {
 	// More assertions can be added here to validate the system message content.
 }
 
+func TestSearchRestrictedToDocuments(t *testing.T) {
+	ctx := context.Background()
+
+	cvs := setupTestChatService()
+	testUserID := "TestChatServiceIntegrationTestUUID"
+
+	results, err := cvs.Search(ctx, "Tell me about dogs", testUserID, 4, RetrievalOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) == 0 {
+		t.Fatalf("Expected results without restrictions")
+	}
+
+	// Restricting to one of the documents found only returns its chunks
+	documentID := results[0].DocumentID
+	results, err = cvs.Search(ctx, "Tell me about dogs", testUserID, 4, RetrievalOptions{DocumentIDs: []uuid.UUID{documentID}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	for _, result := range results {
+		if result.DocumentID != documentID {
+			t.Errorf("Expected only chunks of %s, got a chunk of %s", documentID, result.DocumentID)
+		}
+	}
+
+	// Documents of other users stay out of reach
+	results, err = cvs.Search(ctx, "Tell me about dogs", testUserID, 4, RetrievalOptions{DocumentIDs: []uuid.UUID{uuid.New()}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results for an unknown document, got %+v", results)
+	}
+}
+
 // func TestConstructSystemMessage(t *testing.T) {
 // 	cvs := setupTestChatService()
 //
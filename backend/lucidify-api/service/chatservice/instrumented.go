@@ -0,0 +1,29 @@
+package chatservice
+
+import (
+	"context"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/tracing"
+)
+
+// InstrumentedChatVectorService traces each operation of the ChatVectorService
+// it wraps.
+type InstrumentedChatVectorService struct {
+	next ChatVectorService
+}
+
+func NewInstrumentedChatVectorService(next ChatVectorService) ChatVectorService {
+	return &InstrumentedChatVectorService{next: next}
+}
+
+func (i *InstrumentedChatVectorService) ConstructSystemMessage(ctx context.Context, question string, userID string, options RetrievalOptions) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "chatservice.ConstructSystemMessage")
+	defer func() { tracing.End(span, err) }()
+	return i.next.ConstructSystemMessage(ctx, question, userID, options)
+}
+
+func (i *InstrumentedChatVectorService) Search(ctx context.Context, query string, userID string, limit int, options RetrievalOptions) (_ []storemodels.ChunkFromVectorSearch, err error) {
+	ctx, span := tracing.Start(ctx, "chatservice.Search")
+	defer func() { tracing.End(span, err) }()
+	return i.next.Search(ctx, query, userID, limit, options)
+}
@@ -1,6 +1,7 @@
 package clerkservice
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -132,10 +133,10 @@ func UpdateUserInClerk(apiKey string, userID string, firstName string, lastName
 	return nil
 }
 
-func RetrieveUser(apiKey string, userID string) (map[string]interface{}, error) {
+func RetrieveUser(ctx context.Context, apiKey string, userID string) (map[string]interface{}, error) {
 	url := fmt.Sprintf("https://api.clerk.dev/v1/users/%s", userID)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	req.Header.Add("Authorization", "Bearer "+apiKey)
 
 	res, err := http.DefaultClient.Do(req)
@@ -3,6 +3,7 @@
 package clerkservice
 
 import (
+	"context"
 	"log"
 	"lucidify-api/server/config"
 	"testing"
@@ -35,7 +36,7 @@ func TestIntegration_store_clerk(t *testing.T) {
 	}
 
 	// Retrieve the user to verify the update
-	user, err := RetrieveUser(clerkSecretKey, userID)
+	user, err := RetrieveUser(context.Background(), clerkSecretKey, userID)
 	if err != nil {
 		t.Errorf("Failed to retrieve user from Clerk: %v", err)
 	}
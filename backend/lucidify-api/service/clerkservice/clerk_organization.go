@@ -0,0 +1,60 @@
+package clerkservice
+
+import (
+	"fmt"
+	"lucidify-api/data/store/storemodels"
+)
+
+// WorkspaceFromClerkData maps a Clerk organization object, as found in the data
+// field of organization.* webhook events, to a storemodels.Workspace.
+func WorkspaceFromClerkData(data map[string]interface{}) (storemodels.Workspace, error) {
+	workspaceID := getStringFromMap(data, "id")
+	if workspaceID == "" {
+		return storemodels.Workspace{}, fmt.Errorf("organization has no id")
+	}
+
+	return storemodels.Workspace{
+		WorkspaceID: workspaceID,
+		Name:        getStringFromMap(data, "name"),
+		Slug:        getStringFromMap(data, "slug"),
+		CreatedBy:   getStringFromMap(data, "created_by"),
+		CreatedAt:   getInt64FromMap(data, "created_at"),
+		UpdatedAt:   getInt64FromMap(data, "updated_at"),
+	}, nil
+}
+
+// WorkspaceMembershipFromClerkData maps a Clerk organization membership object,
+// as found in the data field of organizationMembership.* webhook events, to a
+// storemodels.WorkspaceMembership.
+func WorkspaceMembershipFromClerkData(data map[string]interface{}) (storemodels.WorkspaceMembership, error) {
+	organization, _ := data["organization"].(map[string]interface{})
+	publicUserData, _ := data["public_user_data"].(map[string]interface{})
+
+	workspaceID := getStringFromMap(organization, "id")
+	userID := getStringFromMap(publicUserData, "user_id")
+	if workspaceID == "" || userID == "" {
+		return storemodels.WorkspaceMembership{}, fmt.Errorf("organization membership has no organization or user id")
+	}
+
+	return storemodels.WorkspaceMembership{
+		WorkspaceID: workspaceID,
+		UserID:      userID,
+		Role:        workspaceRoleFromClerkRole(getStringFromMap(data, "role")),
+		CreatedAt:   getInt64FromMap(data, "created_at"),
+		UpdatedAt:   getInt64FromMap(data, "updated_at"),
+	}, nil
+}
+
+// workspaceRoleFromClerkRole maps Clerk's default organization roles to
+// workspace roles. Custom roles other than org:viewer are treated as viewers,
+// so that they never get more rights than intended.
+func workspaceRoleFromClerkRole(role string) string {
+	switch role {
+	case "admin", "org:admin":
+		return storemodels.WorkspaceRoleOwner
+	case "basic_member", "org:member":
+		return storemodels.WorkspaceRoleEditor
+	default:
+		return storemodels.WorkspaceRoleViewer
+	}
+}
@@ -0,0 +1,91 @@
+package clerkservice
+
+import (
+	"fmt"
+	"lucidify-api/data/store/storemodels"
+)
+
+func getStringFromMap(m map[string]interface{}, key string) string {
+	if val, ok := m[key]; ok {
+		if strVal, ok := val.(string); ok {
+			return strVal
+		}
+	}
+	return ""
+}
+
+func getBoolFromMap(m map[string]interface{}, key string) bool {
+	if val, ok := m[key]; ok {
+		if boolVal, ok := val.(bool); ok {
+			return boolVal
+		}
+	}
+	return false
+}
+
+// getInt64FromMap accepts float64 as well, since that is what encoding/json
+// decodes Clerk's millisecond timestamps into.
+func getInt64FromMap(m map[string]interface{}, key string) int64 {
+	if val, ok := m[key]; ok {
+		switch numVal := val.(type) {
+		case int64:
+			return numVal
+		case float64:
+			return int64(numVal)
+		}
+	}
+	return 0
+}
+
+// primaryEmailFromClerkData returns the primary email address of a Clerk user,
+// falling back to the first address when no primary address is set.
+func primaryEmailFromClerkData(data map[string]interface{}) (string, error) {
+	emailAddresses, ok := data["email_addresses"].([]interface{})
+	if !ok || len(emailAddresses) == 0 {
+		return "", fmt.Errorf("user has no email addresses")
+	}
+
+	primaryID := getStringFromMap(data, "primary_email_address_id")
+	for _, emailAddress := range emailAddresses {
+		emailMap, ok := emailAddress.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if primaryID == "" || getStringFromMap(emailMap, "id") == primaryID {
+			if email := getStringFromMap(emailMap, "email_address"); email != "" {
+				return email, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("user has no primary email address")
+}
+
+// UserFromClerkData maps a Clerk user object, as found in the data field of
+// user.* webhook events and in Backend API responses, to a storemodels.User.
+func UserFromClerkData(data map[string]interface{}) (storemodels.User, error) {
+	userID := getStringFromMap(data, "id")
+	if userID == "" {
+		return storemodels.User{}, fmt.Errorf("user has no id")
+	}
+
+	email, err := primaryEmailFromClerkData(data)
+	if err != nil {
+		return storemodels.User{}, fmt.Errorf("user %s: %w", userID, err)
+	}
+
+	return storemodels.User{
+		UserID:           userID,
+		ExternalID:       getStringFromMap(data, "external_id"),
+		Username:         getStringFromMap(data, "username"),
+		PasswordEnabled:  getBoolFromMap(data, "password_enabled"),
+		Email:            email,
+		FirstName:        getStringFromMap(data, "first_name"),
+		LastName:         getStringFromMap(data, "last_name"),
+		ImageURL:         getStringFromMap(data, "image_url"),
+		ProfileImageURL:  getStringFromMap(data, "profile_image_url"),
+		TwoFactorEnabled: getBoolFromMap(data, "two_factor_enabled"),
+		CreatedAt:        getInt64FromMap(data, "created_at"),
+		UpdatedAt:        getInt64FromMap(data, "updated_at"),
+	}, nil
+}
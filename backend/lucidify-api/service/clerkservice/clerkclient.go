@@ -1,14 +1,11 @@
 package clerkservice
 
 import (
-	"lucidify-api/server/config"
-
 	"github.com/clerkinc/clerk-sdk-go/clerk"
 )
 
-func NewClerkClient() (clerk.Client, error) {
-	cfg := config.NewServerConfig()
-	client, err := clerk.NewClient(cfg.ClerkSecretKey)
+func NewClerkClient(secretKey string) (clerk.Client, error) {
+	client, err := clerk.NewClient(secretKey)
 	if err != nil {
 		return nil, err
 	}
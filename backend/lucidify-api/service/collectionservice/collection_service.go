@@ -0,0 +1,131 @@
+package collectionservice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"lucidify-api/data/store/postgresqlclient"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/service/documentservice"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCollectionNotFound          = errors.New("collection not found")
+	ErrDocumentNotInCollection     = errors.New("document is not in the collection")
+	ErrCollectionNameRequired      = errors.New("collection name is required")
+	ErrDocumentNotAccessibleToUser = errors.New("document is not accessible to the user")
+)
+
+type CollectionService interface {
+	CreateCollection(ctx context.Context, userID, name string) (*storemodels.Collection, error)
+	GetCollections(ctx context.Context, userID string) ([]storemodels.Collection, error)
+	RenameCollection(ctx context.Context, userID string, collectionID uuid.UUID, name string) error
+	DeleteCollection(ctx context.Context, userID string, collectionID uuid.UUID) error
+	AddDocument(ctx context.Context, userID string, collectionID, documentID uuid.UUID) error
+	RemoveDocument(ctx context.Context, userID string, collectionID, documentID uuid.UUID) error
+	GetCollectionDocuments(ctx context.Context, userID string, collectionID uuid.UUID) ([]storemodels.Document, error)
+	GetCollectionDocumentIDs(ctx context.Context, userID string, collectionID uuid.UUID) ([]uuid.UUID, error)
+}
+
+type CollectionServiceImpl struct {
+	postgresqlDB    *postgresqlclient.PostgreSQL
+	documentService documentservice.DocumentService
+}
+
+func NewCollectionService(
+	postgresqlDB *postgresqlclient.PostgreSQL,
+	documentService documentservice.DocumentService) CollectionService {
+	return &CollectionServiceImpl{postgresqlDB: postgresqlDB, documentService: documentService}
+}
+
+func (s *CollectionServiceImpl) CreateCollection(ctx context.Context, userID, name string) (*storemodels.Collection, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrCollectionNameRequired
+	}
+	collection := &storemodels.Collection{UserID: userID, Name: name}
+	if err := s.postgresqlDB.CreateCollection(ctx, collection); err != nil {
+		return nil, fmt.Errorf("Failed to create collection: %w", err)
+	}
+	return collection, nil
+}
+
+func (s *CollectionServiceImpl) GetCollections(ctx context.Context, userID string) ([]storemodels.Collection, error) {
+	return s.postgresqlDB.GetCollectionsByUser(ctx, userID)
+}
+
+func (s *CollectionServiceImpl) RenameCollection(ctx context.Context, userID string, collectionID uuid.UUID, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ErrCollectionNameRequired
+	}
+	return notFound(s.postgresqlDB.RenameCollection(ctx, userID, collectionID, name), ErrCollectionNotFound)
+}
+
+func (s *CollectionServiceImpl) DeleteCollection(ctx context.Context, userID string, collectionID uuid.UUID) error {
+	return notFound(s.postgresqlDB.DeleteCollection(ctx, userID, collectionID), ErrCollectionNotFound)
+}
+
+// AddDocument adds a document the user can read to one of their collections.
+func (s *CollectionServiceImpl) AddDocument(ctx context.Context, userID string, collectionID, documentID uuid.UUID) error {
+	if err := s.ensureCollectionBelongsToUser(ctx, userID, collectionID); err != nil {
+		return err
+	}
+	if _, err := s.documentService.GetDocumentByID(ctx, userID, documentID); err != nil {
+		return ErrDocumentNotAccessibleToUser
+	}
+	return s.postgresqlDB.AddDocumentToCollection(ctx, collectionID, documentID)
+}
+
+func (s *CollectionServiceImpl) RemoveDocument(ctx context.Context, userID string, collectionID, documentID uuid.UUID) error {
+	if err := s.ensureCollectionBelongsToUser(ctx, userID, collectionID); err != nil {
+		return err
+	}
+	return notFound(s.postgresqlDB.RemoveDocumentFromCollection(ctx, collectionID, documentID), ErrDocumentNotInCollection)
+}
+
+// GetCollectionDocuments returns the documents of the collection the user can
+// still read. Access to a document may have been lost since it was added, for
+// example when a grant is revoked.
+func (s *CollectionServiceImpl) GetCollectionDocuments(ctx context.Context, userID string, collectionID uuid.UUID) ([]storemodels.Document, error) {
+	documentIDs, err := s.GetCollectionDocumentIDs(ctx, userID, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	documents := make([]storemodels.Document, 0, len(documentIDs))
+	for _, documentID := range documentIDs {
+		document, err := s.documentService.GetDocumentByID(ctx, userID, documentID)
+		if err != nil {
+			continue
+		}
+		documents = append(documents, *document)
+	}
+	return documents, nil
+}
+
+// GetCollectionDocumentIDs returns the ids of the documents of the collection,
+// without checking that the user can still read them. Callers searching the
+// documents must restrict the search to the documents of the user as well.
+func (s *CollectionServiceImpl) GetCollectionDocumentIDs(ctx context.Context, userID string, collectionID uuid.UUID) ([]uuid.UUID, error) {
+	if err := s.ensureCollectionBelongsToUser(ctx, userID, collectionID); err != nil {
+		return nil, err
+	}
+	return s.postgresqlDB.GetCollectionDocumentIDs(ctx, collectionID)
+}
+
+func (s *CollectionServiceImpl) ensureCollectionBelongsToUser(ctx context.Context, userID string, collectionID uuid.UUID) error {
+	_, err := s.postgresqlDB.GetCollection(ctx, userID, collectionID)
+	return notFound(err, ErrCollectionNotFound)
+}
+
+// notFound replaces sql.ErrNoRows with the given error.
+func notFound(err error, notFoundErr error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFoundErr
+	}
+	return err
+}
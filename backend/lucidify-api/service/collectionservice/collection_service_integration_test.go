@@ -0,0 +1,116 @@
+// //go:build integration
+// // +build integration
+package collectionservice
+
+import (
+	"context"
+	"errors"
+	"lucidify-api/data/store/postgresqlclient"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/data/store/weaviateclient"
+	"lucidify-api/server/config"
+	"lucidify-api/service/documentservice"
+	"testing"
+)
+
+func TestCollectionLifecycleIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.NewServerConfig()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
+	if err != nil {
+		t.Fatalf("Failed to create test postgresqlclient: %v", err)
+	}
+	weaviateClient, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
+	if err != nil {
+		t.Fatalf("Failed to create WeaviateClient: %v", err)
+	}
+	documentService := documentservice.NewDocumentService(db, weaviateClient, documentservice.ChunkerOptions{URL: cfg.AI_API_URL, APIKey: cfg.X_AI_API_KEY})
+	collectionService := NewCollectionService(db, documentService)
+
+	user := storemodels.User{
+		UserID:    "TestCollectionServiceIntegrationUserID",
+		Username:  "TestCollectionServiceIntegrationUsername",
+		Email:     "collection_service_integration@example.com",
+		CreatedAt: 1654012591514,
+		UpdatedAt: 1654012591514,
+	}
+	otherUser := storemodels.User{
+		UserID:    "TestCollectionServiceIntegrationOtherUserID",
+		Username:  "TestCollectionServiceIntegrationOtherUsername",
+		Email:     "collection_service_integration_other@example.com",
+		CreatedAt: 1654012591514,
+		UpdatedAt: 1654012591514,
+	}
+	for _, u := range []storemodels.User{user, otherUser} {
+		if err := db.UpsertUserInUsersTable(ctx, u); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+
+	document, err := documentService.UploadDocument(ctx, user.UserID, "Collected document", "Collected content")
+	if err != nil {
+		t.Fatalf("Failed to upload document: %v", err)
+	}
+	otherDocument, err := documentService.UploadDocument(ctx, otherUser.UserID, "Other document", "Other content")
+	if err != nil {
+		t.Fatalf("Failed to upload document: %v", err)
+	}
+	t.Cleanup(func() {
+		documentService.PurgeDocument(ctx, user.UserID, document.DocumentUUID)
+		documentService.PurgeDocument(ctx, otherUser.UserID, otherDocument.DocumentUUID)
+		db.DeleteUserInUsersTable(ctx, user.UserID)
+		db.DeleteUserInUsersTable(ctx, otherUser.UserID)
+	})
+
+	_, err = collectionService.CreateCollection(ctx, user.UserID, "  ")
+	if !errors.Is(err, ErrCollectionNameRequired) {
+		t.Errorf("Expected ErrCollectionNameRequired, got %v", err)
+	}
+
+	collection, err := collectionService.CreateCollection(ctx, user.UserID, "Research")
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	err = collectionService.AddDocument(ctx, user.UserID, collection.CollectionID, document.DocumentUUID)
+	if err != nil {
+		t.Fatalf("Failed to add document: %v", err)
+	}
+	err = collectionService.AddDocument(ctx, user.UserID, collection.CollectionID, otherDocument.DocumentUUID)
+	if !errors.Is(err, ErrDocumentNotAccessibleToUser) {
+		t.Errorf("Expected ErrDocumentNotAccessibleToUser, got %v", err)
+	}
+	err = collectionService.AddDocument(ctx, otherUser.UserID, collection.CollectionID, otherDocument.DocumentUUID)
+	if !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected ErrCollectionNotFound for another user's collection, got %v", err)
+	}
+
+	documents, err := collectionService.GetCollectionDocuments(ctx, user.UserID, collection.CollectionID)
+	if err != nil || len(documents) != 1 || documents[0].DocumentUUID != document.DocumentUUID {
+		t.Errorf("Expected the collected document, got %+v: %v", documents, err)
+	}
+
+	collections, err := collectionService.GetCollections(ctx, user.UserID)
+	if err != nil || len(collections) != 1 || collections[0].DocumentCount != 1 {
+		t.Errorf("Expected one collection with one document, got %+v: %v", collections, err)
+	}
+
+	err = collectionService.RemoveDocument(ctx, user.UserID, collection.CollectionID, document.DocumentUUID)
+	if err != nil {
+		t.Errorf("Failed to remove document: %v", err)
+	}
+	err = collectionService.RemoveDocument(ctx, user.UserID, collection.CollectionID, document.DocumentUUID)
+	if !errors.Is(err, ErrDocumentNotInCollection) {
+		t.Errorf("Expected ErrDocumentNotInCollection, got %v", err)
+	}
+
+	err = collectionService.DeleteCollection(ctx, user.UserID, collection.CollectionID)
+	if err != nil {
+		t.Errorf("Failed to delete collection: %v", err)
+	}
+	_, err = documentService.GetDocumentByID(ctx, user.UserID, document.DocumentUUID)
+	if err != nil {
+		t.Errorf("Expected the document to outlive its collection: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package documentservice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"lucidify-api/data/store/postgresqlclient"
+	"lucidify-api/data/store/storemodels"
+)
+
+var ErrDuplicateDocument = errors.New("a document with the same content already exists")
+
+// DuplicatePolicy selects what an upload does when the user, or the workspace
+// for workspace documents, already has a document outside of the trash with
+// exactly the same content.
+type DuplicatePolicy string
+
+const (
+	// DuplicateAllow uploads the document anyway. Its chunks still reuse the
+	// embeddings of identical chunks.
+	DuplicateAllow DuplicatePolicy = "allow"
+	// DuplicateReject fails the upload with ErrDuplicateDocument.
+	DuplicateReject DuplicatePolicy = "reject"
+	// DuplicateReturnExisting returns the existing document instead of
+	// uploading, regardless of the name and metadata given.
+	DuplicateReturnExisting DuplicatePolicy = "return_existing"
+	// DuplicateLink creates the document under the new name with the chunks of
+	// the existing document, without splitting or embedding the content again.
+	DuplicateLink DuplicatePolicy = "link"
+)
+
+// ParseDuplicatePolicy parses the policy of an upload request, where an empty
+// value means DuplicateAllow.
+func ParseDuplicatePolicy(value string) (DuplicatePolicy, error) {
+	switch policy := DuplicatePolicy(value); policy {
+	case "":
+		return DuplicateAllow, nil
+	case DuplicateAllow, DuplicateReject, DuplicateReturnExisting, DuplicateLink:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid duplicate policy %q, expected allow, reject, return_existing or link", value)
+	}
+}
+
+// UploadDocumentDeduplicated is UploadDocumentWithMetadata, applying the
+// policy when a document with the same content exists. The returned boolean
+// is true if a new document was created. With DuplicateReject, the existing
+// document is returned along with ErrDuplicateDocument.
+func (d *DocumentServiceImpl) UploadDocumentDeduplicated(ctx context.Context,
+	userID, workspaceID, name, content string, metadata storemodels.DocumentMetadata, policy DuplicatePolicy) (*storemodels.Document, bool, error) {
+	if err := metadata.Validate(); err != nil {
+		return nil, false, err
+	}
+	if workspaceID != "" {
+		if err := d.authorizeWorkspace(ctx, userID, workspaceID, storemodels.WorkspaceRoleEditor); err != nil {
+			return nil, false, err
+		}
+	}
+
+	var existing *storemodels.Document
+	if policy != DuplicateAllow {
+		var workspaceIDOrNil *string
+		if workspaceID != "" {
+			workspaceIDOrNil = &workspaceID
+		}
+		var err error
+		existing, err = d.postgresqlDB.FindDocumentByContentHash(ctx, userID, workspaceIDOrNil, storemodels.ContentHash(content))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, false, fmt.Errorf("Failed to find duplicate documents: %w", err)
+		}
+	}
+	if existing == nil {
+		document, err := d.uploadDocument(ctx, userID, workspaceID, name, content, metadata)
+		return document, err == nil, err
+	}
+
+	switch policy {
+	case DuplicateReject:
+		return existing, false, ErrDuplicateDocument
+	case DuplicateReturnExisting:
+		return existing, false, nil
+	case DuplicateLink:
+		document, err := d.linkDocument(ctx, existing, userID, workspaceID, name, metadata)
+		return document, err == nil, err
+	default:
+		return nil, false, fmt.Errorf("unknown duplicate policy %q", policy)
+	}
+}
+
+// linkDocument uploads a document with the content of an existing one, copying
+// its chunks rather than splitting the content again. The copies reuse the
+// embeddings of the existing chunks.
+func (d *DocumentServiceImpl) linkDocument(ctx context.Context,
+	existing *storemodels.Document, userID, workspaceID, name string, metadata storemodels.DocumentMetadata) (*storemodels.Document, error) {
+	existingChunks, err := d.postgresqlDB.GetChunksOfDocumentByDocumentID(ctx, existing.DocumentUUID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get chunks of the existing document: %w", err)
+	}
+	if len(existingChunks) == 0 {
+		// The existing document was never indexed, there is nothing to link
+		return d.uploadDocument(ctx, userID, workspaceID, name, existing.Content, metadata)
+	}
+
+	var workspaceIDOrNil *string
+	if workspaceID != "" {
+		workspaceIDOrNil = &workspaceID
+	}
+	document, err := d.postgresqlDB.UploadDocumentWithMetadata(ctx, userID, workspaceIDOrNil, name, existing.Content, metadata)
+	if postgresqlclient.IsUniqueViolation(err) {
+		return nil, ErrDocumentNameConflict
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Link failed at upload document to PostgreSQL: %w", err)
+	}
+
+	chunks := make([]storemodels.Chunk, len(existingChunks))
+	for i, chunk := range existingChunks {
+		chunks[i] = storemodels.Chunk{
+			UserID:       document.UserID,
+			WorkspaceID:  workspaceID,
+			DocumentID:   document.DocumentUUID,
+			ChunkContent: chunk.ChunkContent,
+			ChunkIndex:   chunk.ChunkIndex,
+			Metadata:     document.Metadata,
+		}
+	}
+	return document, d.storeChunks(ctx, document, chunks)
+}
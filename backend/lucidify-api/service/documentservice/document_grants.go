@@ -0,0 +1,136 @@
+package documentservice
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"lucidify-api/data/store/storemodels"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ShareDocument gives another user access to a document. Only users with the
+// rights of an owner on the document may share it.
+func (d *DocumentServiceImpl) ShareDocument(ctx context.Context, userID string, documentID uuid.UUID, granteeUserID, permission string, expiresAt *time.Time) (*storemodels.DocumentGrant, error) {
+	if permission != storemodels.GrantPermissionRead && permission != storemodels.GrantPermissionWrite {
+		return nil, fmt.Errorf("unknown permission %q", permission)
+	}
+	if granteeUserID == "" || granteeUserID == userID {
+		return nil, fmt.Errorf("documents can only be shared with another user")
+	}
+	expiresAt, err := normalizeExpiry(expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.authorizeDocument(ctx, userID, documentID, storemodels.WorkspaceRoleOwner); err != nil {
+		return nil, err
+	}
+
+	grant := &storemodels.DocumentGrant{
+		DocumentID:    documentID,
+		GranteeUserID: &granteeUserID,
+		Permission:    permission,
+		CreatedBy:     userID,
+		ExpiresAt:     expiresAt,
+	}
+	if err := d.postgresqlDB.UpsertDocumentGrant(ctx, grant); err != nil {
+		return nil, fmt.Errorf("Failed to store document grant: %w", err)
+	}
+	return grant, nil
+}
+
+// CreateDocumentLink creates a read-only link to the document and returns its
+// token. The token itself is not stored and cannot be retrieved again.
+func (d *DocumentServiceImpl) CreateDocumentLink(ctx context.Context, userID string, documentID uuid.UUID, expiresAt *time.Time) (string, *storemodels.DocumentGrant, error) {
+	expiresAt, err := normalizeExpiry(expiresAt)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := d.authorizeDocument(ctx, userID, documentID, storemodels.WorkspaceRoleOwner); err != nil {
+		return "", nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	tokenHash := hashLinkToken(token)
+
+	grant := &storemodels.DocumentGrant{
+		DocumentID:    documentID,
+		LinkTokenHash: &tokenHash,
+		Permission:    storemodels.GrantPermissionRead,
+		CreatedBy:     userID,
+		ExpiresAt:     expiresAt,
+	}
+	if err := d.postgresqlDB.UpsertDocumentGrant(ctx, grant); err != nil {
+		return "", nil, fmt.Errorf("Failed to store document link: %w", err)
+	}
+	return token, grant, nil
+}
+
+func (d *DocumentServiceImpl) GetDocumentGrants(ctx context.Context, userID string, documentID uuid.UUID) ([]storemodels.DocumentGrant, error) {
+	if _, err := d.authorizeDocument(ctx, userID, documentID, storemodels.WorkspaceRoleOwner); err != nil {
+		return nil, err
+	}
+	return d.postgresqlDB.GetDocumentGrants(ctx, documentID)
+}
+
+func (d *DocumentServiceImpl) RevokeDocumentGrant(ctx context.Context, userID string, documentID, grantID uuid.UUID) error {
+	if _, err := d.authorizeDocument(ctx, userID, documentID, storemodels.WorkspaceRoleOwner); err != nil {
+		return err
+	}
+	err := d.postgresqlDB.DeleteDocumentGrant(ctx, documentID, grantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("No grant with this ID exists on the document")
+	}
+	return err
+}
+
+// GetDocumentByLinkToken returns the document a sharing link points to, as
+// long as the link has not expired and the document is not in the trash.
+func (d *DocumentServiceImpl) GetDocumentByLinkToken(ctx context.Context, token string) (*storemodels.Document, error) {
+	grant, err := d.postgresqlDB.GetDocumentGrantByLinkTokenHash(ctx, hashLinkToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid or expired link: %w", err)
+	}
+	document, err := d.postgresqlDB.GetDocumentByUUID(ctx, grant.DocumentID)
+	if err != nil {
+		return nil, err
+	}
+	if document.DeletedAt != nil {
+		return nil, fmt.Errorf("Document has been deleted")
+	}
+	return document, nil
+}
+
+// GetSharedDocuments returns the documents other users have shared with the
+// user.
+func (d *DocumentServiceImpl) GetSharedDocuments(ctx context.Context, userID string) ([]storemodels.Document, error) {
+	return d.postgresqlDB.GetDocumentsSharedWithUser(ctx, userID)
+}
+
+// normalizeExpiry rejects expiries in the past and converts the expiry to UTC,
+// as the timestamp columns are stored without a time zone.
+func normalizeExpiry(expiresAt *time.Time) (*time.Time, error) {
+	if expiresAt == nil {
+		return nil, nil
+	}
+	if !expiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("expiry must be in the future")
+	}
+	utc := expiresAt.UTC()
+	return &utc, nil
+}
+
+func hashLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,122 @@
+package documentservice
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lucidify-api/data/store/storemodels"
+)
+
+// Page sizes of document listings.
+const (
+	DefaultDocumentPageSize = 50
+	MaxDocumentPageSize     = 200
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// documentPageCursor is the opaque cursor handed out to clients. It records
+// the listing order, so that a cursor cannot be reused with another one.
+type documentPageCursor struct {
+	SortBy     string                     `json:"s"`
+	Descending bool                       `json:"d,omitempty"`
+	After      storemodels.DocumentCursor `json:"a"`
+}
+
+// ListDocuments returns a page of documents. cursor is empty for the first
+// page, and the NextCursor of the previous page otherwise.
+func (d *DocumentServiceImpl) ListDocuments(ctx context.Context, userID string, options storemodels.DocumentListOptions, cursor string) (*storemodels.DocumentPage, error) {
+	if options.SortBy == "" {
+		options.SortBy = storemodels.DocumentSortCreated
+	}
+	switch options.SortBy {
+	case storemodels.DocumentSortName, storemodels.DocumentSortCreated, storemodels.DocumentSortUpdated:
+	default:
+		return nil, fmt.Errorf("unknown sort order %q", options.SortBy)
+	}
+	if options.Limit <= 0 {
+		options.Limit = DefaultDocumentPageSize
+	}
+	if options.Limit > MaxDocumentPageSize {
+		options.Limit = MaxDocumentPageSize
+	}
+	for _, filter := range options.MetadataFilters {
+		if err := filter.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cursor != "" {
+		after, err := decodeDocumentCursor(cursor, options)
+		if err != nil {
+			return nil, err
+		}
+		options.After = after
+	}
+
+	if options.WorkspaceID != nil {
+		if err := d.authorizeWorkspace(ctx, userID, *options.WorkspaceID, storemodels.WorkspaceRoleViewer); err != nil {
+			return nil, err
+		}
+	}
+
+	// One more document than requested tells whether there is a next page
+	pageSize := options.Limit
+	options.Limit++
+	documents, err := d.postgresqlDB.ListDocuments(ctx, userID, options)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list documents: %w", err)
+	}
+
+	page := &storemodels.DocumentPage{Documents: documents}
+	if page.Documents == nil {
+		page.Documents = []storemodels.DocumentListItem{}
+	}
+	if len(documents) > pageSize {
+		page.Documents = documents[:pageSize]
+		last := page.Documents[pageSize-1]
+		after := storemodels.DocumentCursor{DocumentID: last.DocumentUUID}
+		switch options.SortBy {
+		case storemodels.DocumentSortName:
+			after.Name = last.DocumentName
+		case storemodels.DocumentSortCreated:
+			after.Time = last.CreatedAt
+		case storemodels.DocumentSortUpdated:
+			after.Time = last.UpdatedAt
+		}
+		page.NextCursor, err = encodeDocumentCursor(documentPageCursor{
+			SortBy:     options.SortBy,
+			Descending: options.Descending,
+			After:      after,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+func encodeDocumentCursor(cursor documentPageCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeDocumentCursor(encoded string, options storemodels.DocumentListOptions) (*storemodels.DocumentCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor documentPageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.SortBy != options.SortBy || cursor.Descending != options.Descending {
+		return nil, fmt.Errorf("%w: the cursor belongs to a listing in another order", ErrInvalidCursor)
+	}
+	return &cursor.After, nil
+}
@@ -0,0 +1,38 @@
+package documentservice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"lucidify-api/data/store/storemodels"
+
+	"github.com/google/uuid"
+)
+
+// GetDocumentChunks returns the chunks of the document in order.
+func (d *DocumentServiceImpl) GetDocumentChunks(ctx context.Context, userID string, documentID uuid.UUID) ([]storemodels.Chunk, error) {
+	if _, err := d.authorizeDocument(ctx, userID, documentID, storemodels.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+	return d.postgresqlDB.GetChunksOfDocumentByDocumentID(ctx, documentID)
+}
+
+// GetDocumentVersions returns the previous versions of the document, newest
+// first and without their content.
+func (d *DocumentServiceImpl) GetDocumentVersions(ctx context.Context, userID string, documentID uuid.UUID) ([]storemodels.DocumentVersion, error) {
+	if _, err := d.authorizeDocument(ctx, userID, documentID, storemodels.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+	return d.postgresqlDB.GetDocumentVersions(ctx, documentID)
+}
+
+func (d *DocumentServiceImpl) GetDocumentVersion(ctx context.Context, userID string, documentID uuid.UUID, version int) (*storemodels.DocumentVersion, error) {
+	if _, err := d.authorizeDocument(ctx, userID, documentID, storemodels.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+	documentVersion, err := d.postgresqlDB.GetDocumentVersion(ctx, documentID, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDocumentVersionNotFound
+	}
+	return documentVersion, err
+}
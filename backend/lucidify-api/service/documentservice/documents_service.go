@@ -2,61 +2,166 @@ package documentservice
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"lucidify-api/data/store/postgresqlclient"
 	"lucidify-api/data/store/storemodels"
 	"lucidify-api/data/store/weaviateclient"
-	"lucidify-api/server/config"
+	"lucidify-api/server/metrics"
+	"lucidify-api/server/tracing"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+var (
+	ErrDocumentNotFound        = errors.New("document not found")
+	ErrDocumentAccessDenied    = errors.New("access to the document denied")
+	ErrWorkspaceAccessDenied   = errors.New("access to the workspace denied")
+	ErrDocumentNameConflict    = errors.New("a document with this name already exists")
+	ErrDocumentVersionNotFound = errors.New("document version not found")
+	ErrDocumentModified        = errors.New("document has been modified since the expected revision")
+	ErrDocumentNotInTrash      = errors.New("document is not in the trash")
+)
+
+// AnyRevision makes an update of a document unconditional. Updates given any
+// other revision fail with ErrDocumentModified unless the document is still at
+// that revision.
+const AnyRevision int64 = 0
+
 type DocumentService interface {
-	UploadDocument(userID, name, content string) (*storemodels.Document, error)
-	GetDocument(userID, name string) (*storemodels.Document, error)
-	GetDocumentByID(userID string, documentID uuid.UUID) (*storemodels.Document, error)
-	GetAllDocuments(userID string) ([]storemodels.Document, error)
-	DeleteDocument(userID string, documentID uuid.UUID) error
-	UpdateDocumentName(userID string, documentID uuid.UUID, name string) error
-	UpdateDocumentContent(userID string, documentUUID uuid.UUID, content string) error
+	UploadDocument(ctx context.Context, userID, name, content string) (*storemodels.Document, error)
+	UploadWorkspaceDocument(ctx context.Context, userID, workspaceID, name, content string) (*storemodels.Document, error)
+	GetDocument(ctx context.Context, userID, name string) (*storemodels.Document, error)
+	GetDocumentByID(ctx context.Context, userID string, documentID uuid.UUID) (*storemodels.Document, error)
+	GetDocumentInfo(ctx context.Context, userID string, documentID uuid.UUID) (*storemodels.DocumentListItem, error)
+	GetAllDocuments(ctx context.Context, userID string) ([]storemodels.Document, error)
+	GetWorkspaceDocuments(ctx context.Context, userID, workspaceID string) ([]storemodels.Document, error)
+	GetWorkspaceDocumentOwners(ctx context.Context, workspaceIDs []string) ([]string, error)
+	GetDocumentOwners(ctx context.Context, documentIDs []uuid.UUID) (map[uuid.UUID]string, error)
+	DeleteDocument(ctx context.Context, userID string, documentID uuid.UUID) error
+	GetDeletedDocuments(ctx context.Context, userID string) ([]storemodels.Document, error)
+	RestoreDocument(ctx context.Context, userID string, documentID uuid.UUID) error
+	PurgeDocument(ctx context.Context, userID string, documentID uuid.UUID) error
+	PurgeDeletedDocuments(ctx context.Context, before time.Time) (int, error)
+	PurgeWorkspaceDocuments(ctx context.Context, workspaceID string) (int, error)
+	UpdateDocumentName(ctx context.Context, userID string, documentID uuid.UUID, name string, expectedRevision int64) error
+	UpdateDocument(ctx context.Context, userID string, documentID uuid.UUID, name *string, metadata *storemodels.DocumentMetadata, expectedRevision int64) error
+	UpdateDocumentContent(ctx context.Context, userID string, documentUUID uuid.UUID, content string, expectedRevision int64) error
+	GetDocumentChunks(ctx context.Context, userID string, documentID uuid.UUID) ([]storemodels.Chunk, error)
+	GetDocumentVersions(ctx context.Context, userID string, documentID uuid.UUID) ([]storemodels.DocumentVersion, error)
+	GetDocumentVersion(ctx context.Context, userID string, documentID uuid.UUID, version int) (*storemodels.DocumentVersion, error)
+	UploadDocumentWithMetadata(ctx context.Context, userID, workspaceID, name, content string, metadata storemodels.DocumentMetadata) (*storemodels.Document, error)
+	UploadDocumentDeduplicated(ctx context.Context, userID, workspaceID, name, content string, metadata storemodels.DocumentMetadata, policy DuplicatePolicy) (*storemodels.Document, bool, error)
+	UpdateDocumentMetadata(ctx context.Context, userID string, documentID uuid.UUID, metadata storemodels.DocumentMetadata, expectedRevision int64) error
+	FilterDocuments(ctx context.Context, userID, workspaceID string, metadataFilters []storemodels.MetadataFilter) ([]storemodels.Document, error)
+	ListDocuments(ctx context.Context, userID string, options storemodels.DocumentListOptions, cursor string) (*storemodels.DocumentPage, error)
+	ShareDocument(ctx context.Context, userID string, documentID uuid.UUID, granteeUserID, permission string, expiresAt *time.Time) (*storemodels.DocumentGrant, error)
+	CreateDocumentLink(ctx context.Context, userID string, documentID uuid.UUID, expiresAt *time.Time) (string, *storemodels.DocumentGrant, error)
+	GetDocumentGrants(ctx context.Context, userID string, documentID uuid.UUID) ([]storemodels.DocumentGrant, error)
+	RevokeDocumentGrant(ctx context.Context, userID string, documentID, grantID uuid.UUID) error
+	GetDocumentByLinkToken(ctx context.Context, token string) (*storemodels.Document, error)
+	GetSharedDocuments(ctx context.Context, userID string) ([]storemodels.Document, error)
 }
 
+// ChunkerOptions configures the chunker of the AI API. Zero sizes leave the
+// defaults of the chunker, and a zero timeout means none.
+type ChunkerOptions struct {
+	URL        string
+	APIKey     string
+	Timeout    time.Duration
+	WindowSize int
+	BlockSize  int
+}
+
+// DocumentServiceImpl splits the content of documents into chunks with the
+// chunker of the AI API.
 type DocumentServiceImpl struct {
 	postgresqlDB postgresqlclient.PostgreSQL
 	weaviateDB   weaviateclient.WeaviateClient
+	chunker      ChunkerOptions
+	httpClient   *http.Client
 }
 
 func NewDocumentService(
 	postgresqlDB *postgresqlclient.PostgreSQL,
-	weaviateDB weaviateclient.WeaviateClient) DocumentService {
-	return &DocumentServiceImpl{postgresqlDB: *postgresqlDB, weaviateDB: weaviateDB}
+	weaviateDB weaviateclient.WeaviateClient,
+	chunker ChunkerOptions) DocumentService {
+	return &DocumentServiceImpl{
+		postgresqlDB: *postgresqlDB,
+		weaviateDB:   weaviateDB,
+		chunker:      chunker,
+		httpClient:   &http.Client{Timeout: chunker.Timeout, Transport: tracing.Transport(nil)},
+	}
+}
+
+func (d *DocumentServiceImpl) splitContentIntoChunks(ctx context.Context, document storemodels.Document) ([]storemodels.Chunk, error) {
+	chunkContents, err := d.requestChunks(ctx, document.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []storemodels.Chunk
+	workspaceID := ""
+	if document.WorkspaceID != nil {
+		workspaceID = *document.WorkspaceID
+	}
+
+	for index, content := range chunkContents {
+		chunk := storemodels.Chunk{
+			UserID:       document.UserID,
+			WorkspaceID:  workspaceID,
+			DocumentID:   document.DocumentUUID,
+			ChunkContent: content,
+			ChunkIndex:   index,
+			Metadata:     document.Metadata,
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, nil
 }
 
-func splitContentIntoChunks(document storemodels.Document) ([]storemodels.Chunk, error) {
-	cfg := config.NewServerConfig()
+// requestChunks has the chunker of the AI API split the content.
+func (d *DocumentServiceImpl) requestChunks(ctx context.Context, content string) (chunkContents []string, err error) {
+	defer func(start time.Time) {
+		metrics.ChunkerRequestDuration.Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.ChunkerRequestErrors.Inc()
+		} else {
+			metrics.ChunksPerDocument.Observe(float64(len(chunkContents)))
+		}
+	}(time.Now())
 
-	url := cfg.AI_API_URL + "/chunker/split_text_to_chunks"
-	payload := map[string]string{
-		"text": document.Content,
+	url := d.chunker.URL + "/chunker/split_text_to_chunks"
+	payload := map[string]interface{}{
+		"text": content,
+	}
+	if d.chunker.WindowSize > 0 {
+		payload["w"] = d.chunker.WindowSize
+	}
+	if d.chunker.BlockSize > 0 {
+		payload["k"] = d.chunker.BlockSize
 	}
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-AI-API-KEY", cfg.X_AI_API_KEY)
+	req.Header.Set("X-AI-API-KEY", d.chunker.APIKey)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := d.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -67,28 +172,68 @@ func splitContentIntoChunks(document storemodels.Document) ([]storemodels.Chunk,
 		return nil, fmt.Errorf("API call failed with status %d: %s", resp.StatusCode, body)
 	}
 
-	var chunkContents []string
 	if err := json.NewDecoder(resp.Body).Decode(&chunkContents); err != nil {
 		return nil, err
 	}
+	return chunkContents, nil
+}
 
-	var chunks []storemodels.Chunk
-	for index, content := range chunkContents {
-		chunk := storemodels.Chunk{
-			UserID:       document.UserID,
-			DocumentID:   document.DocumentUUID,
-			ChunkContent: content,
-			ChunkIndex:   index,
-		}
-		chunks = append(chunks, chunk)
+func (d *DocumentServiceImpl) UploadDocument(ctx context.Context,
+	userID, name, content string) (*storemodels.Document, error) {
+	return d.uploadDocument(ctx, userID, "", name, content, nil)
+}
+
+// UploadWorkspaceDocument uploads a document shared with every member of the
+// workspace. Only owners and editors of the workspace may upload.
+func (d *DocumentServiceImpl) UploadWorkspaceDocument(ctx context.Context,
+	userID, workspaceID, name, content string) (*storemodels.Document, error) {
+	if err := d.authorizeWorkspace(ctx, userID, workspaceID, storemodels.WorkspaceRoleEditor); err != nil {
+		return nil, err
 	}
+	return d.uploadDocument(ctx, userID, workspaceID, name, content, nil)
+}
 
-	return chunks, nil
+// UploadDocumentWithMetadata uploads a document with user-defined metadata,
+// which is copied onto each of its chunks. The document is private unless a
+// workspace is given.
+func (d *DocumentServiceImpl) UploadDocumentWithMetadata(ctx context.Context,
+	userID, workspaceID, name, content string, metadata storemodels.DocumentMetadata) (*storemodels.Document, error) {
+	if err := metadata.Validate(); err != nil {
+		return nil, err
+	}
+	if workspaceID != "" {
+		if err := d.authorizeWorkspace(ctx, userID, workspaceID, storemodels.WorkspaceRoleEditor); err != nil {
+			return nil, err
+		}
+	}
+	return d.uploadDocument(ctx, userID, workspaceID, name, content, metadata)
 }
 
-func (d *DocumentServiceImpl) UploadDocument(
-	userID, name, content string) (*storemodels.Document, error) {
+func (d *DocumentServiceImpl) uploadDocument(ctx context.Context,
+	userID, workspaceID, name, content string, metadata storemodels.DocumentMetadata) (*storemodels.Document, error) {
+	var workspaceIDOrNil *string
+	if workspaceID != "" {
+		workspaceIDOrNil = &workspaceID
+	}
+	document, err := d.postgresqlDB.UploadDocumentWithMetadata(ctx, userID, workspaceIDOrNil, name, content, metadata)
+	if postgresqlclient.IsUniqueViolation(err) {
+		return nil, ErrDocumentNameConflict
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Upload failed at upload document to PostgreSQL: %w", err)
+	}
+
+	chunks, err := d.splitContentIntoChunks(ctx, *document)
+	if err != nil {
+		return document, fmt.Errorf("Upload failed at split content into chunks: %w", err)
+	}
+
+	return document, d.storeChunks(ctx, document, chunks)
+}
 
+// storeChunks stores the chunks of a newly uploaded document in PostgreSQL and
+// Weaviate. The document is removed if either fails.
+func (d *DocumentServiceImpl) storeChunks(ctx context.Context, document *storemodels.Document, chunks []storemodels.Chunk) error {
 	var cleanupTasks []func() error
 	shouldCleanup := false
 
@@ -103,144 +248,509 @@ func (d *DocumentServiceImpl) UploadDocument(
 		}
 	}()
 
-	document, err := d.postgresqlDB.UploadDocument(userID, name, content)
-	if err != nil {
-		// Append a cleanup task for the uploaded document in PostgreSQL
-		cleanupTasks = append(cleanupTasks, func() error {
-			return d.postgresqlDB.DeleteDocumentByUUID(document.DocumentUUID)
-		})
-		shouldCleanup = true
-		return document, fmt.Errorf("Upload failed at upload document to PostgreSQL: %w", err)
-	}
-
-	chunks, err := splitContentIntoChunks(*document)
-	if err != nil {
-		return document, fmt.Errorf("Upload failed at split content into chunks: %w", err)
-	}
-
-	chunksWithChunkID, err := d.postgresqlDB.UploadChunks(chunks)
+	chunksWithChunkID, err := d.postgresqlDB.UploadChunks(ctx, chunks)
 	if err != nil {
 		cleanupTasks = append(cleanupTasks, func() error {
-			return d.postgresqlDB.DeleteDocumentByUUID(document.DocumentUUID)
+			return d.postgresqlDB.DeleteDocumentByUUID(ctx, document.DocumentUUID)
 		})
 		shouldCleanup = true
-		return document, fmt.Errorf("Upload failed at upload chunks to PostgreSQL: %w", err)
+		return fmt.Errorf("Upload failed at upload chunks to PostgreSQL: %w", err)
 	}
 
-	err = d.weaviateDB.UploadChunks(chunksWithChunkID)
+	err = d.weaviateDB.UploadChunks(ctx, chunksWithChunkID)
 	if err != nil {
 		shouldCleanup = true
 		cleanupTasks = append(cleanupTasks, func() error {
-			return d.DeleteDocument(document.UserID, document.DocumentUUID)
+			return d.purgeDocument(ctx, document)
 		})
-		return document, fmt.Errorf("Upload failed at upload chunks to weaviate: %w", err)
+		return fmt.Errorf("Upload failed at upload chunks to weaviate: %w", err)
 	}
 
-	return document, nil
+	return nil
+}
+
+// workspaceRoleRanks orders the workspace roles, a higher rank includes the
+// rights of the lower ones.
+var workspaceRoleRanks = map[string]int{
+	storemodels.WorkspaceRoleViewer: 1,
+	storemodels.WorkspaceRoleEditor: 2,
+	storemodels.WorkspaceRoleOwner:  3,
 }
 
-func documentBelongsToUserID(userID string, documentID uuid.UUID) (bool, error) {
-	postgresqlDB, err := postgresqlclient.NewPostgreSQL()
+// authorizeWorkspace checks that the user is a member of the workspace with at
+// least the given role.
+func (d *DocumentServiceImpl) authorizeWorkspace(ctx context.Context, userID, workspaceID, role string) error {
+	membership, err := d.postgresqlDB.GetWorkspaceMembership(ctx, workspaceID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: User is not a member of the workspace", ErrWorkspaceAccessDenied)
+	}
 	if err != nil {
-		return false, err
+		return fmt.Errorf("Failed to get workspace membership from PostgreSQL: %w", err)
+	}
+	if workspaceRoleRanks[membership.Role] < workspaceRoleRanks[role] {
+		return fmt.Errorf("%w: Workspace role %s is not allowed to do this, %s is required", ErrWorkspaceAccessDenied, membership.Role, role)
+	}
+	return nil
+}
+
+// authorizeDocument returns the document if the user may act on it with the
+// given workspace role. Private documents are accessible to their user, who has
+// every right on them, and shared documents to the members of their workspace.
+// Failing that, a grant on the document gives the rights of a viewer or editor.
+func (d *DocumentServiceImpl) authorizeDocument(ctx context.Context, userID string, documentID uuid.UUID, role string) (*storemodels.Document, error) {
+	document, err := d.postgresqlDB.GetDocumentByUUID(ctx, documentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDocumentNotFound
 	}
-	document, err := postgresqlDB.GetDocumentByUUID(documentID)
 	if err != nil {
 		log.Printf("Failed to get document by UUID from PostgreSQL: %v", err)
-		return false, err
+		return nil, err
+	}
+
+	var accessErr error
+	if document.WorkspaceID == nil {
+		if document.UserID == userID {
+			return document, nil
+		}
+		accessErr = fmt.Errorf("%w: Document does not belong to user", ErrDocumentAccessDenied)
+	} else {
+		accessErr = d.authorizeWorkspace(ctx, userID, *document.WorkspaceID, role)
+		if accessErr == nil {
+			return document, nil
+		}
+		if !errors.Is(accessErr, ErrWorkspaceAccessDenied) {
+			return nil, accessErr
+		}
+		accessErr = fmt.Errorf("%w: %v", ErrDocumentAccessDenied, accessErr)
 	}
-	if document == nil {
-		return false, fmt.Errorf("Document does not exist")
+
+	if d.hasGrant(ctx, userID, documentID, role) {
+		return document, nil
 	}
-	if document.UserID != userID {
-		return false, fmt.Errorf("Document does not belong to user")
+	return nil, accessErr
+}
+
+// grantPermissionRanks ranks grant permissions against workspaceRoleRanks.
+// No grant gives the rights of an owner.
+var grantPermissionRanks = map[string]int{
+	storemodels.GrantPermissionRead:  workspaceRoleRanks[storemodels.WorkspaceRoleViewer],
+	storemodels.GrantPermissionWrite: workspaceRoleRanks[storemodels.WorkspaceRoleEditor],
+}
+
+func (d *DocumentServiceImpl) hasGrant(ctx context.Context, userID string, documentID uuid.UUID, role string) bool {
+	grant, err := d.postgresqlDB.GetUserDocumentGrant(ctx, documentID, userID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Failed to get document grant from PostgreSQL: %v", err)
+		}
+		return false
 	}
-	return true, nil
+	return grantPermissionRanks[grant.Permission] >= workspaceRoleRanks[role]
 }
 
-func (d *DocumentServiceImpl) ensureDocumentBelongsToUser(userID string, documentID uuid.UUID) error {
-	belongs, err := documentBelongsToUserID(userID, documentID)
+func (d *DocumentServiceImpl) GetDocument(ctx context.Context, userID, name string) (*storemodels.Document, error) {
+	doc, err := d.postgresqlDB.GetDocument(ctx, userID, name)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if !belongs {
-		return fmt.Errorf("No document with this ID exists")
+
+	if _, err := d.authorizeDocument(ctx, userID, doc.DocumentUUID, storemodels.WorkspaceRoleViewer); err != nil {
+		return nil, err
 	}
-	return nil
+
+	return d.postgresqlDB.GetDocument(ctx, userID, name)
 }
 
-func (d *DocumentServiceImpl) GetDocument(userID, name string) (*storemodels.Document, error) {
-	doc, err := d.postgresqlDB.GetDocument(userID, name)
-	if err != nil {
+func (d *DocumentServiceImpl) GetDocumentByID(ctx context.Context, userID string, documentID uuid.UUID) (*storemodels.Document, error) {
+	if _, err := d.authorizeDocument(ctx, userID, documentID, storemodels.WorkspaceRoleViewer); err != nil {
 		return nil, err
 	}
+	return d.postgresqlDB.GetDocumentByUUID(ctx, documentID)
+}
 
-	if err := d.ensureDocumentBelongsToUser(userID, doc.DocumentUUID); err != nil {
+// GetDocumentInfo describes the document without its content.
+func (d *DocumentServiceImpl) GetDocumentInfo(ctx context.Context, userID string, documentID uuid.UUID) (*storemodels.DocumentListItem, error) {
+	document, err := d.authorizeDocument(ctx, userID, documentID, storemodels.WorkspaceRoleViewer)
+	if err != nil {
 		return nil, err
 	}
 
-	return d.postgresqlDB.GetDocument(userID, name)
+	chunkCount, err := d.postgresqlDB.CountChunksOfDocument(ctx, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to count chunks of document: %w", err)
+	}
+
+	return &storemodels.DocumentListItem{
+		DocumentUUID: document.DocumentUUID,
+		UserID:       document.UserID,
+		WorkspaceID:  document.WorkspaceID,
+		DocumentName: document.DocumentName,
+		Metadata:     document.Metadata,
+		Size:         len(document.Content),
+		ChunkCount:   chunkCount,
+		Revision:     document.Revision,
+		CreatedAt:    document.CreatedAt,
+		UpdatedAt:    document.UpdatedAt,
+		DeletedAt:    document.DeletedAt,
+	}, nil
+}
+
+func (d *DocumentServiceImpl) GetAllDocuments(ctx context.Context, userID string) ([]storemodels.Document, error) {
+	return d.postgresqlDB.GetAllDocuments(ctx, userID)
 }
 
-func (d *DocumentServiceImpl) GetDocumentByID(userID string, documentID uuid.UUID) (*storemodels.Document, error) {
-	if err := d.ensureDocumentBelongsToUser(userID, documentID); err != nil {
+func (d *DocumentServiceImpl) GetWorkspaceDocuments(ctx context.Context, userID, workspaceID string) ([]storemodels.Document, error) {
+	if err := d.authorizeWorkspace(ctx, userID, workspaceID, storemodels.WorkspaceRoleViewer); err != nil {
 		return nil, err
 	}
-	return d.postgresqlDB.GetDocumentByUUID(documentID)
+	return d.postgresqlDB.GetWorkspaceDocuments(ctx, workspaceID)
 }
 
-func (d *DocumentServiceImpl) GetAllDocuments(userID string) ([]storemodels.Document, error) {
-	return d.postgresqlDB.GetAllDocuments(userID)
+// GetWorkspaceDocumentOwners returns the uploaders of the documents of the
+// workspaces, whose tenants hold the chunks of those documents. The caller is
+// responsible for checking the membership of the workspaces.
+func (d *DocumentServiceImpl) GetWorkspaceDocumentOwners(ctx context.Context, workspaceIDs []string) ([]string, error) {
+	if len(workspaceIDs) == 0 {
+		return nil, nil
+	}
+	return d.postgresqlDB.GetWorkspaceDocumentOwners(ctx, workspaceIDs)
 }
 
-func (d *DocumentServiceImpl) DeleteDocument(userID string, documentID uuid.UUID) error {
-	if err := d.ensureDocumentBelongsToUser(userID, documentID); err != nil {
+// GetDocumentOwners returns the uploader of each of the documents. The caller
+// is responsible for checking the access to the documents.
+func (d *DocumentServiceImpl) GetDocumentOwners(ctx context.Context, documentIDs []uuid.UUID) (map[uuid.UUID]string, error) {
+	if len(documentIDs) == 0 {
+		return map[uuid.UUID]string{}, nil
+	}
+	return d.postgresqlDB.GetDocumentOwners(ctx, documentIDs)
+}
+
+// DeleteDocument moves the document to the trash. Its chunks stay in place but
+// are hidden from vector search until the document is restored or purged.
+// Deleting a document already in the trash does nothing.
+func (d *DocumentServiceImpl) DeleteDocument(ctx context.Context, userID string, documentID uuid.UUID) error {
+	document, err := d.authorizeDocument(ctx, userID, documentID, storemodels.WorkspaceRoleEditor)
+	if err != nil {
 		return err
 	}
+	if document.DeletedAt != nil {
+		return nil
+	}
 
-	chunks, err := d.postgresqlDB.GetChunksOfDocumentByDocumentID(documentID)
+	chunks, err := d.postgresqlDB.GetChunksOfDocumentByDocumentID(ctx, documentID)
 	if err != nil {
 		return fmt.Errorf("Failed to get chunks of document: %w", err)
 	}
-	err = d.weaviateDB.DeleteChunks(chunks)
+	err = d.weaviateDB.SetChunksDeleted(ctx, chunks, true)
+	if err != nil {
+		// Some chunks may have been flagged, put them back into search
+		if restoreErr := d.weaviateDB.SetChunksDeleted(ctx, chunks, false); restoreErr != nil {
+			log.Printf("Failed to restore chunks of document %s in Weaviate: %v", documentID, restoreErr)
+		}
+		return fmt.Errorf("Failed to mark chunks as deleted in Weaviate: %w", err)
+	}
+	err = d.postgresqlDB.SoftDeleteDocument(ctx, documentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// A concurrent request moved it to the trash first
+		return nil
+	}
+	if err != nil {
+		// Put the chunks back into search so both stores agree again
+		if restoreErr := d.weaviateDB.SetChunksDeleted(ctx, chunks, false); restoreErr != nil {
+			log.Printf("Failed to restore chunks in Weaviate: %v", restoreErr)
+		}
+		return fmt.Errorf("Failed to move document to trash: %w", err)
+	}
+	return nil
+}
+
+func (d *DocumentServiceImpl) GetDeletedDocuments(ctx context.Context, userID string) ([]storemodels.Document, error) {
+	return d.postgresqlDB.GetDeletedDocuments(ctx, userID)
+}
+
+// RestoreDocument moves the document out of the trash. It fails with
+// ErrDocumentNotInTrash if the document is not in the trash.
+func (d *DocumentServiceImpl) RestoreDocument(ctx context.Context, userID string, documentID uuid.UUID) error {
+	document, err := d.authorizeDocument(ctx, userID, documentID, storemodels.WorkspaceRoleEditor)
+	if err != nil {
+		return err
+	}
+	if document.DeletedAt == nil {
+		return ErrDocumentNotInTrash
+	}
+
+	err = d.postgresqlDB.RestoreDocument(ctx, documentID)
+	if postgresqlclient.IsUniqueViolation(err) {
+		// Another document took the name while this one was in the trash
+		return ErrDocumentNameConflict
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		// A concurrent request restored it first
+		return ErrDocumentNotInTrash
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to restore document: %w", err)
+	}
+
+	chunks, err := d.postgresqlDB.GetChunksOfDocumentByDocumentID(ctx, documentID)
+	if err != nil {
+		return fmt.Errorf("Failed to get chunks of document: %w", err)
+	}
+	err = d.weaviateDB.SetChunksDeleted(ctx, chunks, false)
+	if err != nil {
+		// Move the document back to the trash so that restoring it can be retried
+		if trashErr := d.postgresqlDB.SoftDeleteDocument(ctx, documentID); trashErr != nil {
+			log.Printf("Failed to move document %s back to trash: %v", documentID, trashErr)
+		}
+		if hideErr := d.weaviateDB.SetChunksDeleted(ctx, chunks, true); hideErr != nil {
+			log.Printf("Failed to hide chunks of document %s in Weaviate: %v", documentID, hideErr)
+		}
+		return fmt.Errorf("Failed to restore chunks in Weaviate: %w", err)
+	}
+	return nil
+}
+
+// PurgeDocument permanently deletes the document and its chunks, whether or
+// not it is in the trash.
+func (d *DocumentServiceImpl) PurgeDocument(ctx context.Context, userID string, documentID uuid.UUID) error {
+	document, err := d.authorizeDocument(ctx, userID, documentID, storemodels.WorkspaceRoleOwner)
+	if err != nil {
+		return err
+	}
+	return d.purgeDocument(ctx, document)
+}
+
+func (d *DocumentServiceImpl) purgeDocument(ctx context.Context, document *storemodels.Document) error {
+	workspaceID := ""
+	if document.WorkspaceID != nil {
+		workspaceID = *document.WorkspaceID
+	}
+	err := d.weaviateDB.DeleteChunksOfDocuments(ctx, document.UserID, workspaceID, []uuid.UUID{document.DocumentUUID})
 	if err != nil {
 		return fmt.Errorf("Failed to delete chunks from Weaviate: %w", err)
 	}
-	err = d.postgresqlDB.DeleteDocumentByUUID(documentID)
+	err = d.postgresqlDB.DeleteDocumentByUUID(ctx, document.DocumentUUID)
 	if err != nil {
 		log.Printf("Failed to delete document from PostgreSQL: %v", err)
 	}
 	return nil
 }
 
-func (d *DocumentServiceImpl) UpdateDocumentName(userID string, documentID uuid.UUID, name string) error {
-	if err := d.ensureDocumentBelongsToUser(userID, documentID); err != nil {
-		return err
+// PurgeDeletedDocuments permanently deletes every document that was moved to
+// the trash before the given time. It returns the number of purged documents.
+func (d *DocumentServiceImpl) PurgeDeletedDocuments(ctx context.Context, before time.Time) (int, error) {
+	documents, err := d.postgresqlDB.GetDocumentsDeletedBefore(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get expired documents from PostgreSQL: %w", err)
+	}
+
+	purged := 0
+	for i := range documents {
+		document := &documents[i]
+		if err := d.purgeDocument(ctx, document); err != nil {
+			return purged, fmt.Errorf("Failed to purge document %s: %w", document.DocumentUUID, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// PurgeWorkspaceDocuments permanently deletes every document of a workspace,
+// trashed or not, ahead of the deletion of the workspace itself.
+func (d *DocumentServiceImpl) PurgeWorkspaceDocuments(ctx context.Context, workspaceID string) (int, error) {
+	documentIDs, err := d.postgresqlDB.GetWorkspaceDocumentIDs(ctx, workspaceID)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get workspace documents from PostgreSQL: %w", err)
+	}
+
+	purged := 0
+	for _, documentID := range documentIDs {
+		document, err := d.postgresqlDB.GetDocumentByUUID(ctx, documentID)
+		if err != nil {
+			return purged, fmt.Errorf("Failed to get document %s from PostgreSQL: %w", documentID, err)
+		}
+		if err := d.purgeDocument(ctx, document); err != nil {
+			return purged, fmt.Errorf("Failed to purge document %s: %w", documentID, err)
+		}
+		purged++
 	}
+	return purged, nil
+}
 
-	return d.postgresqlDB.UpdateDocumentName(documentID, name)
+func (d *DocumentServiceImpl) UpdateDocumentName(ctx context.Context, userID string, documentID uuid.UUID, name string, expectedRevision int64) error {
+	return d.UpdateDocument(ctx, userID, documentID, &name, nil, expectedRevision)
+}
+
+// checkRevision fails early on a stale revision, before any work is done. The
+// revision is checked again by the update itself.
+func checkRevision(document *storemodels.Document, expectedRevision int64) error {
+	if expectedRevision != AnyRevision && document.Revision != expectedRevision {
+		return ErrDocumentModified
+	}
+	return nil
 }
 
-func (d *DocumentServiceImpl) UpdateDocumentContent(userID string, documentID uuid.UUID, content string) error {
-	if err := d.ensureDocumentBelongsToUser(userID, documentID); err != nil {
+// revisionError replaces the errors of PostgreSQL about the revision of a
+// document with the errors of the service.
+func revisionError(err error) error {
+	switch {
+	case errors.Is(err, postgresqlclient.ErrRevisionMismatch):
+		return ErrDocumentModified
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrDocumentNotFound
+	}
+	return err
+}
+
+// UpdateDocumentContent replaces the content of the document in place, so that
+// it keeps its ID, grants and collections. The previous content is kept as a
+// version of the document. The chunks of the new content are indexed before
+// the content is replaced, so a failure leaves the document as it was.
+//
+// The content is only replaced if the document is still at the revision it was
+// read at, so that concurrent updates cannot mix their chunks. The update that
+// loses fails with ErrDocumentModified, even without an expected revision.
+func (d *DocumentServiceImpl) UpdateDocumentContent(ctx context.Context, userID string, documentID uuid.UUID, content string, expectedRevision int64) error {
+	document, err := d.authorizeDocument(ctx, userID, documentID, storemodels.WorkspaceRoleEditor)
+	if err != nil {
 		return err
 	}
+	if err := checkRevision(document, expectedRevision); err != nil {
+		return err
+	}
+
+	previousChunks, err := d.postgresqlDB.GetChunksOfDocumentByDocumentID(ctx, documentID)
+	if err != nil {
+		return fmt.Errorf("Failed to get chunks of document: %w", err)
+	}
 
-	// First, get the document by UUID to ensure it exists and to get the current content.
-	documentBeforeUpdate, err := d.postgresqlDB.GetDocumentByUUID(documentID)
+	updatedDocument := *document
+	updatedDocument.Content = content
+	chunks, err := d.splitContentIntoChunks(ctx, updatedDocument)
 	if err != nil {
-		return fmt.Errorf("Failed to get document by UUID from PostgreSQL: %w", err)
+		return fmt.Errorf("Failed to split content into chunks: %w", err)
+	}
+
+	chunks, err = d.postgresqlDB.UploadChunks(ctx, chunks)
+	if err != nil {
+		return fmt.Errorf("Failed to upload chunks to PostgreSQL: %w", err)
+	}
+	removeChunks := func() {
+		if err := d.weaviateDB.DeleteChunks(ctx, chunks); err != nil {
+			log.Printf("Failed to cleanup: %v", err)
+		}
+		if err := d.postgresqlDB.DeleteChunks(ctx, chunks); err != nil {
+			log.Printf("Failed to cleanup: %v", err)
+		}
 	}
 
-	err = d.DeleteDocument(userID, documentID)
+	err = d.weaviateDB.UploadChunks(ctx, chunks)
+	if err == nil && document.DeletedAt != nil {
+		// A document in the trash stays hidden from search
+		err = d.weaviateDB.SetChunksDeleted(ctx, chunks, true)
+	}
 	if err != nil {
-		return fmt.Errorf("Failed to delete document: %w", err)
+		removeChunks()
+		return fmt.Errorf("Failed to upload chunks to Weaviate: %w", err)
 	}
 
-	_, err = d.UploadDocument(userID, documentBeforeUpdate.DocumentName, content)
+	err = d.postgresqlDB.ReplaceDocumentContent(ctx, documentID, content, userID, document.Revision, previousChunks)
 	if err != nil {
-		return fmt.Errorf("Failed to upload document: %w", err)
+		removeChunks()
+		if err := revisionError(err); errors.Is(err, ErrDocumentModified) || errors.Is(err, ErrDocumentNotFound) {
+			return err
+		}
+		return fmt.Errorf("Failed to replace document content: %w", err)
 	}
 
+	if err := d.weaviateDB.DeleteChunks(ctx, previousChunks); err != nil {
+		// The content has been replaced, only stale search results are left
+		log.Printf("Failed to delete previous chunks from Weaviate: %v", err)
+	}
 	return nil
 }
+
+// UpdateDocumentMetadata replaces the metadata of the document and of its
+// chunks. As with UpdateDocumentContent, concurrent updates fail with
+// ErrDocumentModified rather than leave the chunks with other metadata than
+// the document.
+func (d *DocumentServiceImpl) UpdateDocumentMetadata(ctx context.Context, userID string, documentID uuid.UUID, metadata storemodels.DocumentMetadata, expectedRevision int64) error {
+	return d.UpdateDocument(ctx, userID, documentID, nil, &metadata, expectedRevision)
+}
+
+// UpdateDocument renames the document and replaces its metadata, leaving out
+// those that are nil. Both changes make a single revision, so either both are
+// applied or neither is. New metadata is given to the chunks as described in
+// UpdateDocumentMetadata.
+func (d *DocumentServiceImpl) UpdateDocument(ctx context.Context, userID string, documentID uuid.UUID, name *string, metadata *storemodels.DocumentMetadata, expectedRevision int64) error {
+	if metadata != nil {
+		if err := metadata.Validate(); err != nil {
+			return err
+		}
+	}
+	document, err := d.authorizeDocument(ctx, userID, documentID, storemodels.WorkspaceRoleEditor)
+	if err != nil {
+		return err
+	}
+	if err := checkRevision(document, expectedRevision); err != nil {
+		return err
+	}
+
+	if metadata == nil {
+		err = d.postgresqlDB.UpdateDocumentFields(ctx, documentID, name, nil, expectedRevision)
+		if postgresqlclient.IsUniqueViolation(err) {
+			return ErrDocumentNameConflict
+		}
+		return revisionError(err)
+	}
+
+	chunks, err := d.postgresqlDB.GetChunksOfDocumentByDocumentID(ctx, documentID)
+	if err != nil {
+		return fmt.Errorf("Failed to get chunks of document: %w", err)
+	}
+	for i := range chunks {
+		if document.WorkspaceID != nil {
+			chunks[i].WorkspaceID = *document.WorkspaceID
+		}
+		chunks[i].Metadata = *metadata
+	}
+	err = d.weaviateDB.ReplaceChunksMetadata(ctx, chunks, document.DeletedAt != nil)
+	if err != nil {
+		return fmt.Errorf("Failed to update metadata of chunks in Weaviate: %w", err)
+	}
+
+	err = d.postgresqlDB.UpdateDocumentFields(ctx, documentID, name, metadata, document.Revision)
+	if err != nil {
+		// Give the chunks back the metadata of the document, as changed by the
+		// update that won if any
+		if current, getErr := d.postgresqlDB.GetDocumentByUUID(ctx, documentID); getErr == nil {
+			for i := range chunks {
+				chunks[i].Metadata = current.Metadata
+			}
+			if restoreErr := d.weaviateDB.ReplaceChunksMetadata(ctx, chunks, current.DeletedAt != nil); restoreErr != nil {
+				log.Printf("Failed to restore metadata of chunks in Weaviate: %v", restoreErr)
+			}
+		}
+	}
+	if postgresqlclient.IsUniqueViolation(err) {
+		return ErrDocumentNameConflict
+	}
+	return revisionError(err)
+}
+
+// FilterDocuments returns the private documents of the user, or the documents
+// of the workspace if one is given, whose metadata matches every filter.
+func (d *DocumentServiceImpl) FilterDocuments(ctx context.Context, userID, workspaceID string, metadataFilters []storemodels.MetadataFilter) ([]storemodels.Document, error) {
+	for _, filter := range metadataFilters {
+		if err := filter.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if workspaceID == "" {
+		return d.postgresqlDB.FilterDocumentsByMetadata(ctx, userID, nil, metadataFilters)
+	}
+	if err := d.authorizeWorkspace(ctx, userID, workspaceID, storemodels.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+	return d.postgresqlDB.FilterDocumentsByMetadata(ctx, userID, &workspaceID, metadataFilters)
+}
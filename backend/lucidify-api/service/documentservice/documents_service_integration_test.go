@@ -3,12 +3,18 @@
 package documentservice
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"log"
 	"lucidify-api/data/store/postgresqlclient"
 	"lucidify-api/data/store/storemodels"
 	"lucidify-api/data/store/weaviateclient"
+	"lucidify-api/server/config"
 	"lucidify-api/service/userservice"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,7 +22,9 @@ import (
 )
 
 func createTestUserInDb() string {
-	db, err := postgresqlclient.NewPostgreSQL()
+	ctx := context.Background()
+	cfg := config.NewServerConfig()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
 
 	// the user id registered by the jwt token must exist in the local database
 	user := storemodels.User{
@@ -34,7 +42,7 @@ func createTestUserInDb() string {
 		UpdatedAt:        1654012591514,
 	}
 
-	weaviate, err := weaviateclient.NewWeaviateClientTest()
+	weaviate, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
 	if err != nil {
 		log.Fatalf("Failed to create WeaviateClient: %v", err)
 	}
@@ -43,21 +51,21 @@ func createTestUserInDb() string {
 		log.Fatalf("Failed to create UserService: %v", err)
 	}
 
-	err = userService.DeleteUser(user.UserID)
+	err = userService.DeleteUser(ctx, user.UserID)
 	if err != nil {
 		log.Fatalf("Failed to delete user: %v", err)
 	}
-	if !userService.HasUserBeenDeleted(user.UserID, 3) {
+	if !userService.HasUserBeenDeleted(ctx, user.UserID, 3) {
 		log.Fatalf("Failed to delete user: %v", err)
 	}
 
-	err = db.CreateUserInUsersTable(user)
+	err = db.CreateUserInUsersTable(ctx, user)
 	if err != nil {
 		log.Fatalf("Failed to create user: %v", err)
 	}
 
 	// Check if the user exists
-	_, err = userService.GetUserWithRetries(user.UserID, 3)
+	_, err = userService.GetUserWithRetries(ctx, user.UserID, 3)
 	if err != nil {
 		log.Fatalf("User not found after creation: %v", err)
 	}
@@ -74,6 +82,8 @@ func readFileContent(filename string) (string, error) {
 }
 
 func TestSplitContentIntoChunks(t *testing.T) {
+	ctx := context.Background()
+
 	// Define a struct for test cases
 	type testCase struct {
 		filename       string
@@ -87,6 +97,12 @@ func TestSplitContentIntoChunks(t *testing.T) {
 		{"test_doc_vector_databases.txt", 4},
 	}
 
+	cfg := config.NewServerConfig()
+	documentService := &DocumentServiceImpl{
+		chunker:    ChunkerOptions{URL: cfg.AI_API_URL, APIKey: cfg.X_AI_API_KEY},
+		httpClient: &http.Client{},
+	}
+
 	for _, tc := range testCases {
 		t.Run(tc.filename, func(t *testing.T) {
 			content, err := readFileContent(tc.filename)
@@ -102,7 +118,7 @@ func TestSplitContentIntoChunks(t *testing.T) {
 			}
 
 			// Use the function to split the content
-			chunks, err := splitContentIntoChunks(document)
+			chunks, err := documentService.splitContentIntoChunks(ctx, document)
 			if err != nil {
 				t.Errorf("failed to split content: %v", err)
 			}
@@ -114,20 +130,24 @@ func TestSplitContentIntoChunks(t *testing.T) {
 }
 
 func TestUploadDocumentIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.NewServerConfig()
+
 	// 1. Setup
 	// Initialize PostgreSQL for tests
-	db, err := postgresqlclient.NewPostgreSQL()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
 	if err != nil {
 		t.Fatalf("Failed to initialize PostgreSQL: %v", err)
 	}
 
 	// Initialize Weaviate for tests
-	weaviateClient, err := weaviateclient.NewWeaviateClientTest()
+	weaviateClient, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
 	if err != nil {
 		t.Fatalf("Failed to create Weaviate client: %v", err)
 	}
 
-	documentService := NewDocumentService(db, weaviateClient)
+	documentService := NewDocumentService(db, weaviateClient, ChunkerOptions{URL: cfg.AI_API_URL, APIKey: cfg.X_AI_API_KEY})
 
 	// Test data
 	name := "test-document-name"
@@ -136,18 +156,18 @@ func TestUploadDocumentIntegration(t *testing.T) {
 	userID := createTestUserInDb()
 
 	// 2. Call the function
-	document, err := documentService.UploadDocument(userID, name, content)
+	document, err := documentService.UploadDocument(ctx, userID, name, content)
 	if err != nil {
 		t.Fatalf("Failed to upload document: %v", err)
 	}
 
 	// // 3. Verify upload
-	doc, err := db.GetDocumentByUUID(document.DocumentUUID)
+	doc, err := db.GetDocumentByUUID(ctx, document.DocumentUUID)
 	if err != nil || doc == nil {
 		t.Error("Document was not uploaded to PostgreSQL")
 	}
 
-	chunks, err := db.GetChunksOfDocument(document)
+	chunks, err := db.GetChunksOfDocument(ctx, document)
 	if err != nil || len(chunks) == 0 {
 		t.Error("Chunks were not uploaded to PostgreSQL")
 	}
@@ -156,7 +176,7 @@ func TestUploadDocumentIntegration(t *testing.T) {
 	success := false
 
 	for i := 0; i < 10; i++ { // Retry up to 10 times
-		chunksFromWeaviate, err = weaviateClient.GetChunks(chunks)
+		chunksFromWeaviate, err = weaviateClient.GetChunks(ctx, chunks)
 		if err == nil && len(chunksFromWeaviate) > 0 {
 			success = true
 			break // Exit the loop if the condition is met
@@ -167,14 +187,14 @@ func TestUploadDocumentIntegration(t *testing.T) {
 		t.Error("Chunks were not uploaded to Weaviate")
 	}
 
-	db.DeleteUserInUsersTable(userID)
+	db.DeleteUserInUsersTable(ctx, userID)
 
 	// 4. Cleanup
 	// t.Cleanup(func() {
 	// 	db.DeleteUserInUsersTable(userID)
 	// })
 
-	chunksFromWeaviate, err = weaviateClient.GetChunks(chunks)
+	chunksFromWeaviate, err = weaviateClient.GetChunks(ctx, chunks)
 	if err != nil || len(chunksFromWeaviate) == 0 {
 		t.Error("Chunks were not deleted from Weaviate")
 	}
@@ -277,3 +297,583 @@ func TestUploadDocumentIntegration(t *testing.T) {
 // 		t.Errorf("failed to delete test user: %v", err)
 // 	}
 // })
+
+func TestWorkspaceRolesIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.NewServerConfig()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
+	if err != nil {
+		t.Fatalf("Failed to create test postgresqlclient: %v", err)
+	}
+	weaviateClient, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
+	if err != nil {
+		t.Fatalf("Failed to create WeaviateClient: %v", err)
+	}
+	documentService := NewDocumentService(db, weaviateClient, ChunkerOptions{URL: cfg.AI_API_URL, APIKey: cfg.X_AI_API_KEY})
+
+	userID := createTestUserInDb()
+	workspaceID := "org_TestDocumentsServiceWorkspaceRoles"
+
+	err = db.UpsertWorkspace(ctx, storemodels.Workspace{WorkspaceID: workspaceID, Name: "Test workspace"})
+	if err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	t.Cleanup(func() {
+		documentService.PurgeWorkspaceDocuments(ctx, workspaceID)
+		db.DeleteWorkspace(ctx, workspaceID)
+	})
+
+	// Not a member yet
+	_, err = documentService.GetWorkspaceDocuments(ctx, userID, workspaceID)
+	if err == nil {
+		t.Errorf("Expected an error for a user outside of the workspace")
+	}
+
+	setRole := func(role string) {
+		err := db.UpsertWorkspaceMembership(ctx, storemodels.WorkspaceMembership{
+			WorkspaceID: workspaceID,
+			UserID:      userID,
+			Role:        role,
+			UpdatedAt:   time.Now().UnixNano(),
+		})
+		if err != nil {
+			t.Fatalf("Failed to set workspace role: %v", err)
+		}
+	}
+
+	setRole(storemodels.WorkspaceRoleViewer)
+	_, err = documentService.UploadWorkspaceDocument(ctx, userID, workspaceID, "Shared document", "Shared content")
+	if err == nil {
+		t.Errorf("Expected viewers not to be allowed to upload")
+	}
+
+	setRole(storemodels.WorkspaceRoleEditor)
+	document, err := documentService.UploadWorkspaceDocument(ctx, userID, workspaceID, "Shared document", "Shared content")
+	if err != nil {
+		t.Fatalf("Failed to upload workspace document as editor: %v", err)
+	}
+
+	documents, err := documentService.GetWorkspaceDocuments(ctx, userID, workspaceID)
+	if err != nil || len(documents) != 1 {
+		t.Errorf("Expected one workspace document, got %d: %v", len(documents), err)
+	}
+	personalDocuments, err := documentService.GetAllDocuments(ctx, userID)
+	if err != nil {
+		t.Errorf("Failed to get personal documents: %v", err)
+	}
+	for _, personalDocument := range personalDocuments {
+		if personalDocument.DocumentUUID == document.DocumentUUID {
+			t.Errorf("Workspace document listed among personal documents")
+		}
+	}
+
+	err = documentService.PurgeDocument(ctx, userID, document.DocumentUUID)
+	if err == nil {
+		t.Errorf("Expected editors not to be allowed to purge")
+	}
+
+	setRole(storemodels.WorkspaceRoleOwner)
+	err = documentService.PurgeDocument(ctx, userID, document.DocumentUUID)
+	if err != nil {
+		t.Errorf("Failed to purge workspace document as owner: %v", err)
+	}
+}
+
+func TestDocumentGrantsIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.NewServerConfig()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
+	if err != nil {
+		t.Fatalf("Failed to create test postgresqlclient: %v", err)
+	}
+	weaviateClient, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
+	if err != nil {
+		t.Fatalf("Failed to create WeaviateClient: %v", err)
+	}
+	documentService := NewDocumentService(db, weaviateClient, ChunkerOptions{URL: cfg.AI_API_URL, APIKey: cfg.X_AI_API_KEY})
+
+	ownerID := createTestUserInDb()
+	grantee := storemodels.User{
+		UserID:    "TestDocumentsServiceGranteeUserID",
+		Username:  "TestDocumentsServiceGranteeUsername",
+		Email:     "documents_service_grantee@example.com",
+		CreatedAt: 1654012591514,
+		UpdatedAt: 1654012591514,
+	}
+	err = db.UpsertUserInUsersTable(ctx, grantee)
+	if err != nil {
+		t.Fatalf("Failed to create grantee: %v", err)
+	}
+	t.Cleanup(func() {
+		db.DeleteUserInUsersTable(ctx, grantee.UserID)
+	})
+
+	document, err := documentService.UploadDocument(ctx, ownerID, "Granted document", "Granted content")
+	if err != nil {
+		t.Fatalf("Failed to upload document: %v", err)
+	}
+	t.Cleanup(func() {
+		documentService.PurgeDocument(ctx, ownerID, document.DocumentUUID)
+	})
+
+	_, err = documentService.GetDocumentByID(ctx, grantee.UserID, document.DocumentUUID)
+	if err == nil {
+		t.Errorf("Expected an error before the document is shared")
+	}
+
+	grant, err := documentService.ShareDocument(ctx, ownerID, document.DocumentUUID, grantee.UserID, storemodels.GrantPermissionRead, nil)
+	if err != nil {
+		t.Fatalf("Failed to share document: %v", err)
+	}
+	_, err = documentService.GetDocumentByID(ctx, grantee.UserID, document.DocumentUUID)
+	if err != nil {
+		t.Errorf("Expected a read grant to allow reading: %v", err)
+	}
+	err = documentService.UpdateDocumentName(ctx, grantee.UserID, document.DocumentUUID, "Renamed by grantee", AnyRevision)
+	if err == nil {
+		t.Errorf("Expected a read grant not to allow renaming")
+	}
+
+	_, err = documentService.ShareDocument(ctx, ownerID, document.DocumentUUID, grantee.UserID, storemodels.GrantPermissionWrite, nil)
+	if err != nil {
+		t.Fatalf("Failed to upgrade grant: %v", err)
+	}
+	err = documentService.UpdateDocumentName(ctx, grantee.UserID, document.DocumentUUID, "Renamed by grantee", AnyRevision)
+	if err != nil {
+		t.Errorf("Expected a write grant to allow renaming: %v", err)
+	}
+	_, err = documentService.ShareDocument(ctx, grantee.UserID, document.DocumentUUID, ownerID, storemodels.GrantPermissionRead, nil)
+	if err == nil {
+		t.Errorf("Expected grantees not to be allowed to share")
+	}
+
+	shared, err := documentService.GetSharedDocuments(ctx, grantee.UserID)
+	if err != nil || len(shared) != 1 || shared[0].DocumentUUID != document.DocumentUUID {
+		t.Errorf("Expected the document among the shared documents, got %+v: %v", shared, err)
+	}
+
+	token, _, err := documentService.CreateDocumentLink(ctx, ownerID, document.DocumentUUID, nil)
+	if err != nil {
+		t.Fatalf("Failed to create document link: %v", err)
+	}
+	linked, err := documentService.GetDocumentByLinkToken(ctx, token)
+	if err != nil || linked.DocumentUUID != document.DocumentUUID {
+		t.Errorf("Expected the link to resolve to the document: %v", err)
+	}
+	_, err = documentService.GetDocumentByLinkToken(ctx, "not-a-token")
+	if err == nil {
+		t.Errorf("Expected an error for an unknown link token")
+	}
+
+	grants, err := documentService.GetDocumentGrants(ctx, ownerID, document.DocumentUUID)
+	if err != nil || len(grants) != 2 {
+		t.Errorf("Expected a user grant and a link, got %+v: %v", grants, err)
+	}
+
+	err = documentService.RevokeDocumentGrant(ctx, ownerID, document.DocumentUUID, grant.GrantID)
+	if err != nil {
+		t.Fatalf("Failed to revoke grant: %v", err)
+	}
+	_, err = documentService.GetDocumentByID(ctx, grantee.UserID, document.DocumentUUID)
+	if err == nil {
+		t.Errorf("Expected an error after the grant is revoked")
+	}
+}
+
+func TestDocumentMetadataIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.NewServerConfig()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
+	if err != nil {
+		t.Fatalf("Failed to create test postgresqlclient: %v", err)
+	}
+	weaviateClient, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
+	if err != nil {
+		t.Fatalf("Failed to create WeaviateClient: %v", err)
+	}
+	documentService := NewDocumentService(db, weaviateClient, ChunkerOptions{URL: cfg.AI_API_URL, APIKey: cfg.X_AI_API_KEY})
+	userID := createTestUserInDb()
+
+	_, err = documentService.UploadDocumentWithMetadata(ctx, userID, "", "Invalid metadata", "Content",
+		storemodels.DocumentMetadata{"not a field": "value"})
+	if err == nil {
+		t.Errorf("Expected an error for an invalid metadata field name")
+	}
+
+	report, err := documentService.UploadDocumentWithMetadata(ctx, userID, "", "Quarterly report", "Revenue grew this quarter.",
+		storemodels.DocumentMetadata{
+			"author":    "Ada",
+			"pages":     float64(12),
+			"published": "2023-04-01T00:00:00Z",
+			"tags":      []interface{}{"finance", "quarterly"},
+		})
+	if err != nil {
+		t.Fatalf("Failed to upload document with metadata: %v", err)
+	}
+	t.Cleanup(func() {
+		documentService.PurgeDocument(ctx, userID, report.DocumentUUID)
+	})
+
+	tests := []struct {
+		name    string
+		filter  storemodels.MetadataFilter
+		matches bool
+	}{
+		{"equal text", storemodels.MetadataFilter{Field: "author", Operator: storemodels.MetadataOpEqual, Value: "Ada"}, true},
+		{"equal other text", storemodels.MetadataFilter{Field: "author", Operator: storemodels.MetadataOpEqual, Value: "Grace"}, false},
+		{"number range", storemodels.MetadataFilter{Field: "pages", Operator: storemodels.MetadataOpGreaterThan, Value: float64(10)}, true},
+		{"number range excluded", storemodels.MetadataFilter{Field: "pages", Operator: storemodels.MetadataOpLessThan, Value: float64(10)}, false},
+		{"date range", storemodels.MetadataFilter{Field: "published", Operator: storemodels.MetadataOpGreaterThanOrEqual, Value: "2023-01-01T00:00:00Z"}, true},
+		{"contains list item", storemodels.MetadataFilter{Field: "tags", Operator: storemodels.MetadataOpContains, Value: "finance"}, true},
+		{"contains substring", storemodels.MetadataFilter{Field: "author", Operator: storemodels.MetadataOpContains, Value: "d"}, true},
+		{"range on text", storemodels.MetadataFilter{Field: "author", Operator: storemodels.MetadataOpGreaterThan, Value: float64(1)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			documents, err := documentService.FilterDocuments(ctx, userID, "", []storemodels.MetadataFilter{tt.filter})
+			if err != nil {
+				t.Fatalf("FilterDocuments failed: %v", err)
+			}
+			found := false
+			for _, document := range documents {
+				if document.DocumentUUID == report.DocumentUUID {
+					found = true
+				}
+			}
+			if found != tt.matches {
+				t.Errorf("Expected match %v, got %v", tt.matches, found)
+			}
+		})
+	}
+
+	err = documentService.UpdateDocumentMetadata(ctx, userID, report.DocumentUUID, storemodels.DocumentMetadata{"author": "Grace"}, AnyRevision)
+	if err != nil {
+		t.Fatalf("Failed to update metadata: %v", err)
+	}
+	document, err := documentService.GetDocumentByID(ctx, userID, report.DocumentUUID)
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	if document.Metadata["author"] != "Grace" || document.Metadata["pages"] != nil {
+		t.Errorf("Expected the metadata to be replaced, got %v", document.Metadata)
+	}
+}
+
+func TestListDocumentsIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.NewServerConfig()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
+	if err != nil {
+		t.Fatalf("Failed to create test postgresqlclient: %v", err)
+	}
+	weaviateClient, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
+	if err != nil {
+		t.Fatalf("Failed to create WeaviateClient: %v", err)
+	}
+	documentService := NewDocumentService(db, weaviateClient, ChunkerOptions{URL: cfg.AI_API_URL, APIKey: cfg.X_AI_API_KEY})
+	userID := createTestUserInDb()
+
+	names := []string{"Listing C", "Listing A", "Listing B"}
+	for _, name := range names {
+		document, err := documentService.UploadDocument(ctx, userID, name, "Content of "+name)
+		if err != nil {
+			t.Fatalf("Failed to upload document: %v", err)
+		}
+		t.Cleanup(func() {
+			documentService.PurgeDocument(ctx, userID, document.DocumentUUID)
+		})
+	}
+
+	options := storemodels.DocumentListOptions{
+		SortBy:     storemodels.DocumentSortName,
+		NamePrefix: "Listing ",
+		Limit:      2,
+	}
+	page, err := documentService.ListDocuments(ctx, userID, options, "")
+	if err != nil {
+		t.Fatalf("Failed to list documents: %v", err)
+	}
+	if len(page.Documents) != 2 || page.Documents[0].DocumentName != "Listing A" || page.Documents[1].DocumentName != "Listing B" {
+		t.Fatalf("Unexpected first page: %+v", page.Documents)
+	}
+	if page.NextCursor == "" {
+		t.Fatalf("Expected a cursor to the next page")
+	}
+	if page.Documents[0].Content != "" || page.Documents[0].Size != len("Content of Listing A") {
+		t.Errorf("Expected a summary without content, got %+v", page.Documents[0])
+	}
+
+	page, err = documentService.ListDocuments(ctx, userID, options, page.NextCursor)
+	if err != nil {
+		t.Fatalf("Failed to list next page: %v", err)
+	}
+	if len(page.Documents) != 1 || page.Documents[0].DocumentName != "Listing C" || page.NextCursor != "" {
+		t.Errorf("Unexpected last page: %+v", page)
+	}
+
+	descending := options
+	descending.Descending = true
+	_, err = documentService.ListDocuments(ctx, userID, descending, "bm90LWEtY3Vyc29y")
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestDocumentRevisionsIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.NewServerConfig()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
+	if err != nil {
+		t.Fatalf("Failed to create test postgresqlclient: %v", err)
+	}
+	weaviateClient, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
+	if err != nil {
+		t.Fatalf("Failed to create WeaviateClient: %v", err)
+	}
+	documentService := NewDocumentService(db, weaviateClient, ChunkerOptions{URL: cfg.AI_API_URL, APIKey: cfg.X_AI_API_KEY})
+	userID := createTestUserInDb()
+
+	document, err := documentService.UploadDocument(ctx, userID, "Revisions", "First content")
+	if err != nil {
+		t.Fatalf("Failed to upload document: %v", err)
+	}
+	t.Cleanup(func() {
+		documentService.PurgeDocument(ctx, userID, document.DocumentUUID)
+	})
+	if document.Revision != 1 {
+		t.Fatalf("Expected a new document at revision 1, got %d", document.Revision)
+	}
+
+	err = documentService.UpdateDocumentName(ctx, userID, document.DocumentUUID, "Revisions renamed", 1)
+	if err != nil {
+		t.Fatalf("Failed to rename document at its revision: %v", err)
+	}
+
+	// A second editor still holding revision 1 must not overwrite the rename
+	err = documentService.UpdateDocumentContent(ctx, userID, document.DocumentUUID, "Stale content", 1)
+	if !errors.Is(err, ErrDocumentModified) {
+		t.Fatalf("Expected ErrDocumentModified, got %v", err)
+	}
+	err = documentService.UpdateDocumentName(ctx, userID, document.DocumentUUID, "Stale name", 1)
+	if !errors.Is(err, ErrDocumentModified) {
+		t.Fatalf("Expected ErrDocumentModified, got %v", err)
+	}
+
+	err = documentService.UpdateDocumentContent(ctx, userID, document.DocumentUUID, "Second content", 2)
+	if err != nil {
+		t.Fatalf("Failed to update content at the current revision: %v", err)
+	}
+
+	updated, err := documentService.GetDocumentByID(ctx, userID, document.DocumentUUID)
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	if updated.Revision != 3 || updated.DocumentName != "Revisions renamed" || updated.Content != "Second content" {
+		t.Errorf("Unexpected document after updates: %+v", updated)
+	}
+
+	err = documentService.UpdateDocumentMetadata(ctx, userID, document.DocumentUUID, storemodels.DocumentMetadata{"team": "docs"}, AnyRevision)
+	if err != nil {
+		t.Fatalf("Failed to update metadata unconditionally: %v", err)
+	}
+
+	// Renaming and replacing the metadata together makes a single revision
+	name := "Revisions renamed again"
+	err = documentService.UpdateDocument(ctx, userID, document.DocumentUUID, &name, &storemodels.DocumentMetadata{"team": "search"}, 4)
+	if err != nil {
+		t.Fatalf("Failed to update name and metadata: %v", err)
+	}
+	err = documentService.UpdateDocument(ctx, userID, document.DocumentUUID, &name, &storemodels.DocumentMetadata{"team": "stale"}, 4)
+	if !errors.Is(err, ErrDocumentModified) {
+		t.Fatalf("Expected ErrDocumentModified, got %v", err)
+	}
+	updated, err = documentService.GetDocumentByID(ctx, userID, document.DocumentUUID)
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	if updated.Revision != 5 || updated.DocumentName != name || updated.Metadata["team"] != "search" {
+		t.Errorf("Unexpected document after updating name and metadata: %+v", updated)
+	}
+}
+
+func TestUploadDocumentDeduplicatedIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.NewServerConfig()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
+	if err != nil {
+		t.Fatalf("Failed to create test postgresqlclient: %v", err)
+	}
+	weaviateClient, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
+	if err != nil {
+		t.Fatalf("Failed to create WeaviateClient: %v", err)
+	}
+	documentService := NewDocumentService(db, weaviateClient, ChunkerOptions{URL: cfg.AI_API_URL, APIKey: cfg.X_AI_API_KEY})
+	userID := createTestUserInDb()
+
+	content := "Duplicated content"
+	original, created, err := documentService.UploadDocumentDeduplicated(ctx, userID, "", "Original", content, nil, DuplicateReject)
+	if err != nil || !created {
+		t.Fatalf("Failed to upload document: created %v, %v", created, err)
+	}
+	t.Cleanup(func() {
+		documentService.PurgeDocument(ctx, userID, original.DocumentUUID)
+	})
+	if original.ContentHash != storemodels.ContentHash(content) {
+		t.Errorf("Expected content hash %s, got %s", storemodels.ContentHash(content), original.ContentHash)
+	}
+
+	existing, created, err := documentService.UploadDocumentDeduplicated(ctx, userID, "", "Copy", content, nil, DuplicateReject)
+	if !errors.Is(err, ErrDuplicateDocument) || created || existing.DocumentUUID != original.DocumentUUID {
+		t.Errorf("Expected ErrDuplicateDocument with the original document, got %v, %+v", err, existing)
+	}
+
+	existing, created, err = documentService.UploadDocumentDeduplicated(ctx, userID, "", "Copy", content, nil, DuplicateReturnExisting)
+	if err != nil || created || existing.DocumentUUID != original.DocumentUUID {
+		t.Errorf("Expected the original document, got %v, %+v", err, existing)
+	}
+
+	linked, created, err := documentService.UploadDocumentDeduplicated(ctx, userID, "", "Linked", content, nil, DuplicateLink)
+	if err != nil || !created {
+		t.Fatalf("Failed to link document: created %v, %v", created, err)
+	}
+	t.Cleanup(func() {
+		documentService.PurgeDocument(ctx, userID, linked.DocumentUUID)
+	})
+	if linked.DocumentUUID == original.DocumentUUID || linked.DocumentName != "Linked" || linked.Content != content {
+		t.Errorf("Expected a new document with the same content, got %+v", linked)
+	}
+
+	originalChunks, err := db.GetChunksOfDocumentByDocumentID(ctx, original.DocumentUUID)
+	if err != nil {
+		t.Fatalf("Failed to get chunks: %v", err)
+	}
+	linkedChunks, err := db.GetChunksOfDocumentByDocumentID(ctx, linked.DocumentUUID)
+	if err != nil {
+		t.Fatalf("Failed to get chunks: %v", err)
+	}
+	if len(linkedChunks) != len(originalChunks) {
+		t.Fatalf("Expected %d linked chunks, got %d", len(originalChunks), len(linkedChunks))
+	}
+	for i := range linkedChunks {
+		if linkedChunks[i].ContentHash != originalChunks[i].ContentHash || linkedChunks[i].ChunkID == originalChunks[i].ChunkID {
+			t.Errorf("Expected a copy of chunk %+v, got %+v", originalChunks[i], linkedChunks[i])
+		}
+	}
+
+	// The policy only applies to exact duplicates
+	other, created, err := documentService.UploadDocumentDeduplicated(ctx, userID, "", "Other", content+".", nil, DuplicateReject)
+	if err != nil || !created {
+		t.Fatalf("Failed to upload a different document: created %v, %v", created, err)
+	}
+	documentService.PurgeDocument(ctx, userID, other.DocumentUUID)
+}
+
+func TestDeleteTrashedDocumentIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.NewServerConfig()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
+	if err != nil {
+		t.Fatalf("Failed to create test postgresqlclient: %v", err)
+	}
+	weaviateClient, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
+	if err != nil {
+		t.Fatalf("Failed to create WeaviateClient: %v", err)
+	}
+	documentService := NewDocumentService(db, weaviateClient, ChunkerOptions{URL: cfg.AI_API_URL, APIKey: cfg.X_AI_API_KEY})
+	userID := createTestUserInDb()
+
+	document, err := documentService.UploadDocument(ctx, userID, "Trashed twice", "Trashed content")
+	if err != nil {
+		t.Fatalf("Failed to upload document: %v", err)
+	}
+	t.Cleanup(func() {
+		documentService.PurgeDocument(ctx, userID, document.DocumentUUID)
+	})
+
+	if err := documentService.RestoreDocument(ctx, userID, document.DocumentUUID); !errors.Is(err, ErrDocumentNotInTrash) {
+		t.Errorf("Expected ErrDocumentNotInTrash for a document outside of the trash, got %v", err)
+	}
+	if err := documentService.DeleteDocument(ctx, userID, document.DocumentUUID); err != nil {
+		t.Fatalf("Failed to delete document: %v", err)
+	}
+	// Deleting it again succeeds and leaves its chunks out of search
+	if err := documentService.DeleteDocument(ctx, userID, document.DocumentUUID); err != nil {
+		t.Fatalf("Failed to delete trashed document: %v", err)
+	}
+	scope := weaviateclient.SearchScope{UserID: userID, RestrictToDocumentIDs: []uuid.UUID{document.DocumentUUID}}
+	chunks, err := weaviateClient.SearchDocumentsByText(ctx, 10, scope, []string{"Trashed content"})
+	if err != nil {
+		t.Fatalf("Failed to search documents: %v", err)
+	}
+	if len(chunks) != 0 {
+		t.Errorf("Trashed document surfaced in search: %+v", chunks)
+	}
+}
+
+func TestPurgeDeletedWorkspaceDocumentsIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.NewServerConfig()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
+	if err != nil {
+		t.Fatalf("Failed to create test postgresqlclient: %v", err)
+	}
+	weaviateClient, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
+	if err != nil {
+		t.Fatalf("Failed to create WeaviateClient: %v", err)
+	}
+	documentService := NewDocumentService(db, weaviateClient, ChunkerOptions{URL: cfg.AI_API_URL, APIKey: cfg.X_AI_API_KEY})
+
+	userID := createTestUserInDb()
+	workspaceID := "org_TestDocumentsServicePurgeDeleted"
+
+	err = db.UpsertWorkspace(ctx, storemodels.Workspace{WorkspaceID: workspaceID, Name: "Test workspace"})
+	if err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	err = db.UpsertWorkspaceMembership(ctx, storemodels.WorkspaceMembership{
+		WorkspaceID: workspaceID,
+		UserID:      userID,
+		Role:        storemodels.WorkspaceRoleOwner,
+		UpdatedAt:   time.Now().UnixNano(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to set workspace role: %v", err)
+	}
+	t.Cleanup(func() {
+		documentService.PurgeWorkspaceDocuments(ctx, workspaceID)
+		db.DeleteWorkspace(ctx, workspaceID)
+	})
+
+	document, err := documentService.UploadWorkspaceDocument(ctx, userID, workspaceID, "Expired shared document", "Expired shared content")
+	if err != nil {
+		t.Fatalf("Failed to upload workspace document: %v", err)
+	}
+	chunks, err := db.GetChunksOfDocumentByDocumentID(ctx, document.DocumentUUID)
+	if err != nil || len(chunks) == 0 {
+		t.Fatalf("Expected chunks for the document, got %d: %v", len(chunks), err)
+	}
+	if err := documentService.DeleteDocument(ctx, userID, document.DocumentUUID); err != nil {
+		t.Fatalf("Failed to delete document: %v", err)
+	}
+
+	if _, err := documentService.PurgeDeletedDocuments(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to purge deleted documents: %v", err)
+	}
+
+	// The chunks are gone from the tenant of the workspace too
+	_, err = weaviateClient.GetChunks(ctx, chunks)
+	if err == nil || !strings.Contains(err.Error(), "no object found") {
+		t.Errorf("Expected the chunks of the purged document to be deleted from Weaviate, got %v", err)
+	}
+	if _, err := db.GetDocumentByUUID(ctx, document.DocumentUUID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected the purged document to be deleted from PostgreSQL, got %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+package documentservice
+
+import (
+	"context"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/metrics"
+	"lucidify-api/server/tracing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InstrumentedDocumentService traces each operation of the DocumentService it
+// wraps and records its latency and errors, and counts the documents being
+// ingested.
+type InstrumentedDocumentService struct {
+	next DocumentService
+}
+
+func NewInstrumentedDocumentService(next DocumentService) DocumentService {
+	return &InstrumentedDocumentService{next: next}
+}
+
+// observe times and traces the operation until the returned function is
+// called with its error.
+func observe(ctx context.Context, operation string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "documentservice."+operation)
+	return ctx, func(err error) {
+		metrics.DocumentOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.DocumentOperationErrors.WithLabelValues(operation).Inc()
+		}
+		tracing.End(span, err)
+	}
+}
+
+// ingest counts a document in flight until the returned function is called.
+func ingest() func() {
+	metrics.IngestionsInFlight.Inc()
+	return metrics.IngestionsInFlight.Dec
+}
+
+func (i *InstrumentedDocumentService) UploadDocument(ctx context.Context, userID, name, content string) (_ *storemodels.Document, err error) {
+	defer ingest()()
+	ctx, done := observe(ctx, "UploadDocument")
+	defer func() { done(err) }()
+	return i.next.UploadDocument(ctx, userID, name, content)
+}
+
+func (i *InstrumentedDocumentService) UploadWorkspaceDocument(ctx context.Context, userID, workspaceID, name, content string) (_ *storemodels.Document, err error) {
+	defer ingest()()
+	ctx, done := observe(ctx, "UploadWorkspaceDocument")
+	defer func() { done(err) }()
+	return i.next.UploadWorkspaceDocument(ctx, userID, workspaceID, name, content)
+}
+
+func (i *InstrumentedDocumentService) GetDocument(ctx context.Context, userID, name string) (_ *storemodels.Document, err error) {
+	ctx, done := observe(ctx, "GetDocument")
+	defer func() { done(err) }()
+	return i.next.GetDocument(ctx, userID, name)
+}
+
+func (i *InstrumentedDocumentService) GetDocumentByID(ctx context.Context, userID string, documentID uuid.UUID) (_ *storemodels.Document, err error) {
+	ctx, done := observe(ctx, "GetDocumentByID")
+	defer func() { done(err) }()
+	return i.next.GetDocumentByID(ctx, userID, documentID)
+}
+
+func (i *InstrumentedDocumentService) GetDocumentInfo(ctx context.Context, userID string, documentID uuid.UUID) (_ *storemodels.DocumentListItem, err error) {
+	ctx, done := observe(ctx, "GetDocumentInfo")
+	defer func() { done(err) }()
+	return i.next.GetDocumentInfo(ctx, userID, documentID)
+}
+
+func (i *InstrumentedDocumentService) GetAllDocuments(ctx context.Context, userID string) (_ []storemodels.Document, err error) {
+	ctx, done := observe(ctx, "GetAllDocuments")
+	defer func() { done(err) }()
+	return i.next.GetAllDocuments(ctx, userID)
+}
+
+func (i *InstrumentedDocumentService) GetWorkspaceDocuments(ctx context.Context, userID, workspaceID string) (_ []storemodels.Document, err error) {
+	ctx, done := observe(ctx, "GetWorkspaceDocuments")
+	defer func() { done(err) }()
+	return i.next.GetWorkspaceDocuments(ctx, userID, workspaceID)
+}
+
+func (i *InstrumentedDocumentService) GetWorkspaceDocumentOwners(ctx context.Context, workspaceIDs []string) (_ []string, err error) {
+	ctx, done := observe(ctx, "GetWorkspaceDocumentOwners")
+	defer func() { done(err) }()
+	return i.next.GetWorkspaceDocumentOwners(ctx, workspaceIDs)
+}
+
+func (i *InstrumentedDocumentService) GetDocumentOwners(ctx context.Context, documentIDs []uuid.UUID) (_ map[uuid.UUID]string, err error) {
+	ctx, done := observe(ctx, "GetDocumentOwners")
+	defer func() { done(err) }()
+	return i.next.GetDocumentOwners(ctx, documentIDs)
+}
+
+func (i *InstrumentedDocumentService) DeleteDocument(ctx context.Context, userID string, documentID uuid.UUID) (err error) {
+	ctx, done := observe(ctx, "DeleteDocument")
+	defer func() { done(err) }()
+	return i.next.DeleteDocument(ctx, userID, documentID)
+}
+
+func (i *InstrumentedDocumentService) GetDeletedDocuments(ctx context.Context, userID string) (_ []storemodels.Document, err error) {
+	ctx, done := observe(ctx, "GetDeletedDocuments")
+	defer func() { done(err) }()
+	return i.next.GetDeletedDocuments(ctx, userID)
+}
+
+func (i *InstrumentedDocumentService) RestoreDocument(ctx context.Context, userID string, documentID uuid.UUID) (err error) {
+	ctx, done := observe(ctx, "RestoreDocument")
+	defer func() { done(err) }()
+	return i.next.RestoreDocument(ctx, userID, documentID)
+}
+
+func (i *InstrumentedDocumentService) PurgeDocument(ctx context.Context, userID string, documentID uuid.UUID) (err error) {
+	ctx, done := observe(ctx, "PurgeDocument")
+	defer func() { done(err) }()
+	return i.next.PurgeDocument(ctx, userID, documentID)
+}
+
+func (i *InstrumentedDocumentService) PurgeDeletedDocuments(ctx context.Context, before time.Time) (_ int, err error) {
+	ctx, done := observe(ctx, "PurgeDeletedDocuments")
+	defer func() { done(err) }()
+	return i.next.PurgeDeletedDocuments(ctx, before)
+}
+
+func (i *InstrumentedDocumentService) PurgeWorkspaceDocuments(ctx context.Context, workspaceID string) (_ int, err error) {
+	ctx, done := observe(ctx, "PurgeWorkspaceDocuments")
+	defer func() { done(err) }()
+	return i.next.PurgeWorkspaceDocuments(ctx, workspaceID)
+}
+
+func (i *InstrumentedDocumentService) UpdateDocumentName(ctx context.Context, userID string, documentID uuid.UUID, name string, expectedRevision int64) (err error) {
+	ctx, done := observe(ctx, "UpdateDocumentName")
+	defer func() { done(err) }()
+	return i.next.UpdateDocumentName(ctx, userID, documentID, name, expectedRevision)
+}
+
+func (i *InstrumentedDocumentService) UpdateDocument(ctx context.Context, userID string, documentID uuid.UUID, name *string, metadata *storemodels.DocumentMetadata, expectedRevision int64) (err error) {
+	ctx, done := observe(ctx, "UpdateDocument")
+	defer func() { done(err) }()
+	return i.next.UpdateDocument(ctx, userID, documentID, name, metadata, expectedRevision)
+}
+
+func (i *InstrumentedDocumentService) UpdateDocumentContent(ctx context.Context, userID string, documentUUID uuid.UUID, content string, expectedRevision int64) (err error) {
+	defer ingest()()
+	ctx, done := observe(ctx, "UpdateDocumentContent")
+	defer func() { done(err) }()
+	return i.next.UpdateDocumentContent(ctx, userID, documentUUID, content, expectedRevision)
+}
+
+func (i *InstrumentedDocumentService) GetDocumentChunks(ctx context.Context, userID string, documentID uuid.UUID) (_ []storemodels.Chunk, err error) {
+	ctx, done := observe(ctx, "GetDocumentChunks")
+	defer func() { done(err) }()
+	return i.next.GetDocumentChunks(ctx, userID, documentID)
+}
+
+func (i *InstrumentedDocumentService) GetDocumentVersions(ctx context.Context, userID string, documentID uuid.UUID) (_ []storemodels.DocumentVersion, err error) {
+	ctx, done := observe(ctx, "GetDocumentVersions")
+	defer func() { done(err) }()
+	return i.next.GetDocumentVersions(ctx, userID, documentID)
+}
+
+func (i *InstrumentedDocumentService) GetDocumentVersion(ctx context.Context, userID string, documentID uuid.UUID, version int) (_ *storemodels.DocumentVersion, err error) {
+	ctx, done := observe(ctx, "GetDocumentVersion")
+	defer func() { done(err) }()
+	return i.next.GetDocumentVersion(ctx, userID, documentID, version)
+}
+
+func (i *InstrumentedDocumentService) UploadDocumentWithMetadata(ctx context.Context, userID, workspaceID, name, content string, metadata storemodels.DocumentMetadata) (_ *storemodels.Document, err error) {
+	defer ingest()()
+	ctx, done := observe(ctx, "UploadDocumentWithMetadata")
+	defer func() { done(err) }()
+	return i.next.UploadDocumentWithMetadata(ctx, userID, workspaceID, name, content, metadata)
+}
+
+func (i *InstrumentedDocumentService) UploadDocumentDeduplicated(ctx context.Context, userID, workspaceID, name, content string, metadata storemodels.DocumentMetadata, policy DuplicatePolicy) (_ *storemodels.Document, _ bool, err error) {
+	defer ingest()()
+	ctx, done := observe(ctx, "UploadDocumentDeduplicated")
+	defer func() { done(err) }()
+	return i.next.UploadDocumentDeduplicated(ctx, userID, workspaceID, name, content, metadata, policy)
+}
+
+func (i *InstrumentedDocumentService) UpdateDocumentMetadata(ctx context.Context, userID string, documentID uuid.UUID, metadata storemodels.DocumentMetadata, expectedRevision int64) (err error) {
+	ctx, done := observe(ctx, "UpdateDocumentMetadata")
+	defer func() { done(err) }()
+	return i.next.UpdateDocumentMetadata(ctx, userID, documentID, metadata, expectedRevision)
+}
+
+func (i *InstrumentedDocumentService) FilterDocuments(ctx context.Context, userID, workspaceID string, metadataFilters []storemodels.MetadataFilter) (_ []storemodels.Document, err error) {
+	ctx, done := observe(ctx, "FilterDocuments")
+	defer func() { done(err) }()
+	return i.next.FilterDocuments(ctx, userID, workspaceID, metadataFilters)
+}
+
+func (i *InstrumentedDocumentService) ListDocuments(ctx context.Context, userID string, options storemodels.DocumentListOptions, cursor string) (_ *storemodels.DocumentPage, err error) {
+	ctx, done := observe(ctx, "ListDocuments")
+	defer func() { done(err) }()
+	return i.next.ListDocuments(ctx, userID, options, cursor)
+}
+
+func (i *InstrumentedDocumentService) ShareDocument(ctx context.Context, userID string, documentID uuid.UUID, granteeUserID, permission string, expiresAt *time.Time) (_ *storemodels.DocumentGrant, err error) {
+	ctx, done := observe(ctx, "ShareDocument")
+	defer func() { done(err) }()
+	return i.next.ShareDocument(ctx, userID, documentID, granteeUserID, permission, expiresAt)
+}
+
+func (i *InstrumentedDocumentService) CreateDocumentLink(ctx context.Context, userID string, documentID uuid.UUID, expiresAt *time.Time) (_ string, _ *storemodels.DocumentGrant, err error) {
+	ctx, done := observe(ctx, "CreateDocumentLink")
+	defer func() { done(err) }()
+	return i.next.CreateDocumentLink(ctx, userID, documentID, expiresAt)
+}
+
+func (i *InstrumentedDocumentService) GetDocumentGrants(ctx context.Context, userID string, documentID uuid.UUID) (_ []storemodels.DocumentGrant, err error) {
+	ctx, done := observe(ctx, "GetDocumentGrants")
+	defer func() { done(err) }()
+	return i.next.GetDocumentGrants(ctx, userID, documentID)
+}
+
+func (i *InstrumentedDocumentService) RevokeDocumentGrant(ctx context.Context, userID string, documentID, grantID uuid.UUID) (err error) {
+	ctx, done := observe(ctx, "RevokeDocumentGrant")
+	defer func() { done(err) }()
+	return i.next.RevokeDocumentGrant(ctx, userID, documentID, grantID)
+}
+
+func (i *InstrumentedDocumentService) GetDocumentByLinkToken(ctx context.Context, token string) (_ *storemodels.Document, err error) {
+	ctx, done := observe(ctx, "GetDocumentByLinkToken")
+	defer func() { done(err) }()
+	return i.next.GetDocumentByLinkToken(ctx, token)
+}
+
+func (i *InstrumentedDocumentService) GetSharedDocuments(ctx context.Context, userID string) (_ []storemodels.Document, err error) {
+	ctx, done := observe(ctx, "GetSharedDocuments")
+	defer func() { done(err) }()
+	return i.next.GetSharedDocuments(ctx, userID)
+}
@@ -0,0 +1,160 @@
+package embeddingservice
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"lucidify-api/data/store/postgresqlclient"
+	"lucidify-api/data/store/storemodels"
+	"sync/atomic"
+)
+
+// maxEmbeddingBatch bounds the number of texts sent to the embedding model in
+// one request.
+const maxEmbeddingBatch = 256
+
+// EmbeddingCacheStats counts the texts embedded since the server started, by
+// where their vector came from.
+type EmbeddingCacheStats struct {
+	Model         string `json:"model"`
+	MemoryHits    int64  `json:"memory_hits"`
+	StoreHits     int64  `json:"store_hits"`
+	Misses        int64  `json:"misses"`
+	MemoryEntries int    `json:"memory_entries"`
+}
+
+type EmbeddingService interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Model() string
+	Stats() EmbeddingCacheStats
+	PurgeModel(ctx context.Context, model string) (int64, error)
+}
+
+// EmbeddingServiceImpl embeds texts with the configured model. Vectors are
+// cached by model and text hash, in memory and in the embedding_cache table,
+// so that the same text is only embedded once.
+type EmbeddingServiceImpl struct {
+	postgresqlDB *postgresqlclient.PostgreSQL
+	embedder     Embedder
+	model        string
+	memory       *lruCache
+
+	memoryHits atomic.Int64
+	storeHits  atomic.Int64
+	misses     atomic.Int64
+}
+
+// NewEmbeddingService keeps up to cacheSize vectors in memory. A cacheSize of
+// zero leaves only the embedding_cache table.
+func NewEmbeddingService(postgresqlDB *postgresqlclient.PostgreSQL, embedder Embedder, model string, cacheSize int) EmbeddingService {
+	return &EmbeddingServiceImpl{
+		postgresqlDB: postgresqlDB,
+		embedder:     embedder,
+		model:        model,
+		memory:       newLRUCache(cacheSize),
+	}
+}
+
+func (e *EmbeddingServiceImpl) Model() string {
+	return e.model
+}
+
+// Embed returns the vectors of the texts, in order. Only texts found in
+// neither cache are sent to the embedding model. Failures of the
+// embedding_cache table are logged rather than returned, as the vectors can
+// still be computed.
+func (e *EmbeddingServiceImpl) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	textHashes := make([]string, len(texts))
+
+	// Texts missing from memory, once per hash
+	var missingHashes []string
+	missingTexts := make(map[string]string)
+	for i, text := range texts {
+		textHashes[i] = storemodels.ContentHash(text)
+		if vector, ok := e.memory.get(lruKey{model: e.model, textHash: textHashes[i]}); ok {
+			vectors[i] = vector
+			e.memoryHits.Add(1)
+			continue
+		}
+		if _, ok := missingTexts[textHashes[i]]; !ok {
+			missingHashes = append(missingHashes, textHashes[i])
+			missingTexts[textHashes[i]] = text
+		}
+	}
+	if len(missingHashes) == 0 {
+		return vectors, nil
+	}
+
+	found, err := e.postgresqlDB.GetCachedEmbeddings(ctx, e.model, missingHashes)
+	if err != nil {
+		log.Printf("Failed to get cached embeddings: %v", err)
+		found = make(map[string][]float32)
+	}
+
+	var unknownHashes []string
+	for _, textHash := range missingHashes {
+		if _, ok := found[textHash]; !ok {
+			unknownHashes = append(unknownHashes, textHash)
+		}
+	}
+	computed := make(map[string][]float32)
+	for start := 0; start < len(unknownHashes); start += maxEmbeddingBatch {
+		end := start + maxEmbeddingBatch
+		if end > len(unknownHashes) {
+			end = len(unknownHashes)
+		}
+		batch := make([]string, end-start)
+		for i, textHash := range unknownHashes[start:end] {
+			batch[i] = missingTexts[textHash]
+		}
+		batchVectors, err := e.embedder.CreateEmbeddings(ctx, e.model, batch)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create embeddings: %w", err)
+		}
+		for i, textHash := range unknownHashes[start:end] {
+			computed[textHash] = batchVectors[i]
+		}
+	}
+	if len(computed) > 0 {
+		if err := e.postgresqlDB.StoreCachedEmbeddings(ctx, e.model, computed); err != nil {
+			log.Printf("Failed to store cached embeddings: %v", err)
+		}
+	}
+
+	for i, textHash := range textHashes {
+		if vectors[i] != nil {
+			continue
+		}
+		if vector, ok := found[textHash]; ok {
+			vectors[i] = vector
+			e.storeHits.Add(1)
+		} else {
+			vectors[i] = computed[textHash]
+			e.misses.Add(1)
+		}
+		e.memory.add(lruKey{model: e.model, textHash: textHash}, vectors[i])
+	}
+	return vectors, nil
+}
+
+func (e *EmbeddingServiceImpl) Stats() EmbeddingCacheStats {
+	return EmbeddingCacheStats{
+		Model:         e.model,
+		MemoryHits:    e.memoryHits.Load(),
+		StoreHits:     e.storeHits.Load(),
+		Misses:        e.misses.Load(),
+		MemoryEntries: e.memory.len(),
+	}
+}
+
+// PurgeModel removes the cached vectors of a model, for example one that is no
+// longer used, and returns how many were stored.
+func (e *EmbeddingServiceImpl) PurgeModel(ctx context.Context, model string) (int64, error) {
+	e.memory.removeModel(model)
+	purged, err := e.postgresqlDB.DeleteCachedEmbeddings(ctx, model)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to purge cached embeddings of model %s: %w", model, err)
+	}
+	return purged, nil
+}
@@ -0,0 +1,85 @@
+// //go:build integration
+// // +build integration
+package embeddingservice
+
+import (
+	"context"
+	"lucidify-api/data/store/postgresqlclient"
+	"lucidify-api/server/config"
+	"testing"
+)
+
+// countingEmbedder returns the length of each text as its vector and counts
+// the texts it embedded.
+type countingEmbedder struct {
+	embedded int
+}
+
+func (e *countingEmbedder) CreateEmbeddings(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float32{float32(len(text)), 1}
+	}
+	e.embedded += len(texts)
+	return vectors, nil
+}
+
+func TestEmbeddingCacheIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := postgresqlclient.NewPostgreSQL(config.NewServerConfig().PostgresqlURL)
+	if err != nil {
+		t.Fatalf("Failed to create test postgresqlclient: %v", err)
+	}
+	model := "test-embedding-cache-model"
+	t.Cleanup(func() {
+		db.DeleteCachedEmbeddings(ctx, model)
+	})
+
+	embedder := &countingEmbedder{}
+	embeddingService := NewEmbeddingService(db, embedder, model, 10)
+
+	vectors, err := embeddingService.Embed(ctx, []string{"one", "three", "one"})
+	if err != nil {
+		t.Fatalf("Failed to embed: %v", err)
+	}
+	if len(vectors) != 3 || vectors[0][0] != 3 || vectors[1][0] != 5 || vectors[2][0] != 3 {
+		t.Errorf("Unexpected vectors %v", vectors)
+	}
+	if embedder.embedded != 2 {
+		t.Errorf("Expected each distinct text to be embedded once, got %d embeddings", embedder.embedded)
+	}
+
+	_, err = embeddingService.Embed(ctx, []string{"three"})
+	if err != nil {
+		t.Fatalf("Failed to embed: %v", err)
+	}
+	if stats := embeddingService.Stats(); stats.MemoryHits != 1 || stats.Misses != 3 || stats.MemoryEntries != 2 {
+		t.Errorf("Unexpected stats after a memory hit: %+v", stats)
+	}
+
+	// A new service starts with an empty memory but finds the stored vectors
+	restarted := NewEmbeddingService(db, embedder, model, 10)
+	_, err = restarted.Embed(ctx, []string{"one", "three"})
+	if err != nil {
+		t.Fatalf("Failed to embed: %v", err)
+	}
+	if stats := restarted.Stats(); stats.StoreHits != 2 || stats.Misses != 0 || embedder.embedded != 2 {
+		t.Errorf("Expected the vectors from the embedding_cache table, got %+v and %d embeddings", stats, embedder.embedded)
+	}
+
+	purged, err := restarted.PurgeModel(ctx, model)
+	if err != nil {
+		t.Fatalf("Failed to purge model: %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("Expected 2 purged embeddings, got %d", purged)
+	}
+	_, err = restarted.Embed(ctx, []string{"one"})
+	if err != nil {
+		t.Fatalf("Failed to embed: %v", err)
+	}
+	if embedder.embedded != 3 {
+		t.Errorf("Expected the purged text to be embedded again, got %d embeddings", embedder.embedded)
+	}
+}
@@ -0,0 +1,50 @@
+package embeddingservice
+
+import (
+	"context"
+	"fmt"
+	"lucidify-api/server/metrics"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Embedder computes the vectors of texts with an embedding model.
+type Embedder interface {
+	CreateEmbeddings(ctx context.Context, model string, texts []string) ([][]float32, error)
+}
+
+type OpenAIEmbedder struct {
+	client *openai.Client
+}
+
+func NewOpenAIEmbedder(client *openai.Client) Embedder {
+	return &OpenAIEmbedder{client: client}
+}
+
+func (e *OpenAIEmbedder) CreateEmbeddings(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	var embeddingModel openai.EmbeddingModel
+	if err := embeddingModel.UnmarshalText([]byte(model)); err != nil || embeddingModel == openai.Unknown {
+		return nil, fmt.Errorf("unknown embedding model %q", model)
+	}
+
+	response, err := e.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Input: texts,
+		Model: embeddingModel,
+	})
+	if err != nil {
+		return nil, err
+	}
+	metrics.RecordModelUsage(model, response.Usage.PromptTokens, response.Usage.CompletionTokens)
+	if len(response.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(response.Data))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, embedding := range response.Data {
+		if embedding.Index < 0 || embedding.Index >= len(texts) {
+			return nil, fmt.Errorf("unexpected embedding index %d", embedding.Index)
+		}
+		vectors[embedding.Index] = embedding.Embedding
+	}
+	return vectors, nil
+}
@@ -0,0 +1,261 @@
+package healthservice
+
+import (
+	"context"
+	"fmt"
+	"lucidify-api/data/store/postgresqlclient"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/data/store/weaviateclient"
+	"lucidify-api/server/tracing"
+	"lucidify-api/service/vectorschemaservice"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	DependencyOK      = "ok"
+	DependencyFailing = "failing"
+)
+
+// DependencyCheck is the result of checking that the API can reach one of its
+// dependencies. Error is only shown to admins, see ServerStatus.
+type DependencyCheck struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+// Readiness is whether every dependency can be reached. A draining server is
+// not ready, whatever its dependencies.
+type Readiness struct {
+	Ready        bool              `json:"ready"`
+	Draining     bool              `json:"draining,omitempty"`
+	Dependencies []DependencyCheck `json:"dependencies"`
+}
+
+// BuildInfo describes the binary, as recorded by the Go toolchain.
+type BuildInfo struct {
+	GoVersion    string `json:"go_version"`
+	Path         string `json:"path,omitempty"`
+	Version      string `json:"version,omitempty"`
+	Revision     string `json:"revision,omitempty"`
+	RevisionTime string `json:"revision_time,omitempty"`
+	Modified     bool   `json:"modified,omitempty"`
+}
+
+// ServerStatus is what the server knows about its state. Sections that could
+// not be loaded are left out and their error is listed in Errors.
+type ServerStatus struct {
+	Build        BuildInfo                               `json:"build"`
+	StartedAt    time.Time                               `json:"started_at"`
+	Uptime       string                                  `json:"uptime"`
+	Database     *postgresqlclient.MigrationStatus       `json:"database,omitempty"`
+	VectorSchema *vectorschemaservice.VectorSchemaStatus `json:"vector_schema,omitempty"`
+	Queues       *storemodels.QueueDepths                `json:"queues,omitempty"`
+	Readiness    *Readiness                              `json:"readiness"`
+	Errors       map[string]string                       `json:"errors,omitempty"`
+}
+
+type HealthService interface {
+	Ready(ctx context.Context) *Readiness
+	Status(ctx context.Context) *ServerStatus
+	Drain()
+}
+
+type HealthServiceImpl struct {
+	postgresqlDB        *postgresqlclient.PostgreSQL
+	weaviateDB          weaviateclient.WeaviateClient
+	vectorSchemaService vectorschemaservice.VectorSchemaService
+	openaiClient        *openai.Client
+	aiAPIURL            string
+	httpClient          *http.Client
+
+	// checkTimeout bounds each dependency check
+	checkTimeout time.Duration
+	startedAt    time.Time
+	build        BuildInfo
+	draining     atomic.Bool
+
+	// The last readiness is reused for checkTimeout, so that frequent probes
+	// do not call the dependencies each time
+	readinessMu sync.Mutex
+	readiness   *Readiness
+	checkedAt   time.Time
+}
+
+func NewHealthService(
+	postgresqlDB *postgresqlclient.PostgreSQL,
+	weaviateDB weaviateclient.WeaviateClient,
+	vectorSchemaService vectorschemaservice.VectorSchemaService,
+	openaiClient *openai.Client,
+	aiAPIURL string,
+	checkTimeout time.Duration) HealthService {
+	return &HealthServiceImpl{
+		postgresqlDB:        postgresqlDB,
+		weaviateDB:          weaviateDB,
+		vectorSchemaService: vectorSchemaService,
+		openaiClient:        openaiClient,
+		aiAPIURL:            aiAPIURL,
+		httpClient:          &http.Client{Transport: tracing.Transport(nil)},
+		checkTimeout:        checkTimeout,
+		startedAt:           time.Now(),
+		build:               readBuildInfo(),
+	}
+}
+
+func readBuildInfo() BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return BuildInfo{}
+	}
+	build := BuildInfo{
+		GoVersion: info.GoVersion,
+		Path:      info.Main.Path,
+		Version:   info.Main.Version,
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Revision = setting.Value
+		case "vcs.time":
+			build.RevisionTime = setting.Value
+		case "vcs.modified":
+			build.Modified = setting.Value == "true"
+		}
+	}
+	return build
+}
+
+// Drain makes the server report that it is not ready, as it is shutting down.
+func (h *HealthServiceImpl) Drain() {
+	h.draining.Store(true)
+}
+
+// Ready checks every dependency concurrently, each within the check timeout.
+// The result is reused until the check timeout has passed again.
+func (h *HealthServiceImpl) Ready(ctx context.Context) *Readiness {
+	if h.draining.Load() {
+		return &Readiness{Draining: true, Dependencies: []DependencyCheck{}}
+	}
+
+	h.readinessMu.Lock()
+	defer h.readinessMu.Unlock()
+	if h.readiness != nil && time.Since(h.checkedAt) < h.checkTimeout {
+		return h.readiness
+	}
+	// A probe that gives up must not leave a failed result for the next ones
+	h.readiness = h.checkDependencies(context.WithoutCancel(ctx))
+	h.checkedAt = time.Now()
+	return h.readiness
+}
+
+func (h *HealthServiceImpl) checkDependencies(ctx context.Context) *Readiness {
+	checks := []struct {
+		name  string
+		check func(ctx context.Context) error
+	}{
+		{"postgresql", h.postgresqlDB.Ping},
+		{"weaviate", h.weaviateDB.Ready},
+		{"chunker", h.checkChunker},
+		{"openai", h.checkOpenAI},
+	}
+
+	readiness := &Readiness{Ready: true, Dependencies: make([]DependencyCheck, len(checks))}
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+			readiness.Dependencies[i] = h.runCheck(ctx, name, check)
+		}(i, c.name, c.check)
+	}
+	wg.Wait()
+
+	for _, dependency := range readiness.Dependencies {
+		if dependency.Status != DependencyOK {
+			readiness.Ready = false
+		}
+	}
+	return readiness
+}
+
+func (h *HealthServiceImpl) runCheck(ctx context.Context, name string, check func(ctx context.Context) error) DependencyCheck {
+	ctx, cancel := context.WithTimeout(ctx, h.checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := DependencyCheck{
+		Name:      name,
+		Status:    DependencyOK,
+		LatencyMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = DependencyFailing
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// checkChunker calls the health endpoint of the AI API.
+func (h *HealthServiceImpl) checkChunker(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.aiAPIURL+"/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("AI API responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// checkOpenAI lists the models, which uses no tokens.
+func (h *HealthServiceImpl) checkOpenAI(ctx context.Context) error {
+	_, err := h.openaiClient.ListModels(ctx)
+	return err
+}
+
+func (h *HealthServiceImpl) Status(ctx context.Context) *ServerStatus {
+	status := &ServerStatus{
+		Build:     h.build,
+		StartedAt: h.startedAt,
+		Uptime:    time.Since(h.startedAt).Round(time.Second).String(),
+		Errors:    map[string]string{},
+	}
+
+	database, err := h.postgresqlDB.MigrationStatus(ctx)
+	if err != nil {
+		status.Errors["database"] = err.Error()
+	} else {
+		status.Database = database
+	}
+
+	vectorSchema, err := h.vectorSchemaService.Status(ctx)
+	if err != nil {
+		status.Errors["vector_schema"] = err.Error()
+	} else {
+		status.VectorSchema = vectorSchema
+	}
+
+	queues, err := h.postgresqlDB.GetQueueDepths(ctx)
+	if err != nil {
+		status.Errors["queues"] = err.Error()
+	} else {
+		status.Queues = queues
+	}
+
+	status.Readiness = h.Ready(ctx)
+
+	return status
+}
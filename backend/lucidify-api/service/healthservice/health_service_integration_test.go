@@ -0,0 +1,89 @@
+// //go:build integration
+// // +build integration
+package healthservice
+
+import (
+	"context"
+	"lucidify-api/data/store/postgresqlclient"
+	"lucidify-api/data/store/weaviateclient"
+	"lucidify-api/server/config"
+	"lucidify-api/service/vectorschemaservice"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func setupHealthService(t *testing.T, aiAPIURL string) HealthService {
+	cfg := config.NewServerConfig()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
+	if err != nil {
+		t.Fatalf("Failed to create test postgresqlclient: %v", err)
+	}
+	weaviate, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
+	if err != nil {
+		t.Fatalf("Failed to create WeaviateClient: %v", err)
+	}
+	if aiAPIURL == "" {
+		aiAPIURL = cfg.AI_API_URL
+	}
+	return NewHealthService(
+		db,
+		weaviate,
+		vectorschemaservice.NewVectorSchemaService(db, weaviate),
+		openai.NewClient(cfg.OPENAI_API_KEY),
+		aiAPIURL,
+		5*time.Second,
+	)
+}
+
+func TestIntegrationReadiness(t *testing.T) {
+	ctx := context.Background()
+
+	readiness := setupHealthService(t, "").Ready(ctx)
+	if !readiness.Ready {
+		t.Errorf("Expected every dependency to be reachable, got %+v", readiness.Dependencies)
+	}
+	if len(readiness.Dependencies) != 4 {
+		t.Errorf("Expected 4 dependencies, got %d", len(readiness.Dependencies))
+	}
+}
+
+func TestIntegrationReadinessReportsFailingDependency(t *testing.T) {
+	ctx := context.Background()
+
+	// Nothing listens on the discard port
+	readiness := setupHealthService(t, "http://localhost:9").Ready(ctx)
+	if readiness.Ready {
+		t.Fatal("Expected the API not to be ready without the chunker")
+	}
+	for _, dependency := range readiness.Dependencies {
+		if dependency.Name == "chunker" && dependency.Status != DependencyFailing {
+			t.Errorf("Expected the chunker to be failing, got %+v", dependency)
+		}
+		if dependency.Name == "postgresql" && dependency.Status != DependencyOK {
+			t.Errorf("Expected PostgreSQL to be ok, got %+v", dependency)
+		}
+	}
+}
+
+func TestIntegrationStatus(t *testing.T) {
+	ctx := context.Background()
+
+	status := setupHealthService(t, "").Status(ctx)
+	if len(status.Errors) != 0 {
+		t.Fatalf("Unexpected errors: %v", status.Errors)
+	}
+	if status.Database == nil || status.Database.LatestVersion == 0 {
+		t.Errorf("Expected the migration status, got %+v", status.Database)
+	}
+	if status.VectorSchema == nil || status.VectorSchema.ActiveVersion == 0 {
+		t.Errorf("Expected the vector schema status, got %+v", status.VectorSchema)
+	}
+	if status.Queues == nil {
+		t.Error("Expected the queue depths")
+	}
+	if status.Build.GoVersion == "" {
+		t.Error("Expected the Go version in the build info")
+	}
+}
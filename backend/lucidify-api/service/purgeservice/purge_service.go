@@ -0,0 +1,115 @@
+package purgeservice
+
+import (
+	"context"
+	"log"
+	"lucidify-api/server/tracing"
+	"lucidify-api/service/documentservice"
+	"lucidify-api/service/userservice"
+	"sync"
+	"time"
+)
+
+type PurgeService interface {
+	PurgeExpired(ctx context.Context) error
+	Start(interval time.Duration)
+	Stop(ctx context.Context)
+}
+
+type PurgeServiceImpl struct {
+	documentService         documentservice.DocumentService
+	userService             userservice.UserService
+	documentTrashRetention  time.Duration
+	userDeletionGracePeriod time.Duration
+
+	// ctx is cancelled by Stop once the running purge is given up on
+	ctx    context.Context
+	cancel context.CancelFunc
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewPurgeService(
+	documentService documentservice.DocumentService,
+	userService userservice.UserService,
+	documentTrashRetention time.Duration,
+	userDeletionGracePeriod time.Duration) PurgeService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &PurgeServiceImpl{
+		documentService:         documentService,
+		userService:             userService,
+		documentTrashRetention:  documentTrashRetention,
+		userDeletionGracePeriod: userDeletionGracePeriod,
+		ctx:                     ctx,
+		cancel:                  cancel,
+		stop:                    make(chan struct{}),
+	}
+}
+
+// PurgeExpired permanently deletes documents that have been in the trash for
+// longer than the retention period, and users whose deletion grace period has
+// passed.
+func (p *PurgeServiceImpl) PurgeExpired(ctx context.Context) error {
+	now := time.Now()
+
+	documents, err := p.documentService.PurgeDeletedDocuments(ctx, now.Add(-p.documentTrashRetention))
+	if err != nil {
+		return err
+	}
+
+	users, err := p.userService.PurgeDeletedUsers(ctx, now.Add(-p.userDeletionGracePeriod))
+	if err != nil {
+		return err
+	}
+
+	if documents > 0 || users > 0 {
+		log.Printf("Purged %d documents and %d users", documents, users)
+	}
+	return nil
+}
+
+// Start runs PurgeExpired in the background every interval until Stop is called.
+func (p *PurgeServiceImpl) Start(interval time.Duration) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			// Each run is a trace of its own
+			ctx, span := tracing.Start(p.ctx, "purgeservice.PurgeExpired")
+			err := p.PurgeExpired(ctx)
+			tracing.End(span, err)
+			if err != nil {
+				log.Printf("Failed to purge expired data: %v", err)
+			}
+
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the background loop, waiting for a running purge to finish until
+// ctx is done. The purge is cancelled then, to be run again on the next start.
+func (p *PurgeServiceImpl) Stop(ctx context.Context) {
+	defer p.cancel()
+	close(p.stop)
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		p.cancel()
+		<-done
+	}
+}
@@ -0,0 +1,43 @@
+package syncservice
+
+import (
+	"context"
+	"errors"
+	"lucidify-api/server/tracing"
+)
+
+// InstrumentedSyncService traces each operation of the SyncService it wraps.
+// Unsuccessful responses are recorded as errors.
+type InstrumentedSyncService struct {
+	next SyncService
+}
+
+func NewInstrumentedSyncService(next SyncService) SyncService {
+	return &InstrumentedSyncService{next: next}
+}
+
+func (i *InstrumentedSyncService) HandleSet(ctx context.Context, userID, key, value string) (response ServerResponse) {
+	ctx, span := tracing.Start(ctx, "syncservice.HandleSet")
+	defer func() { tracing.End(span, responseError(response)) }()
+	return i.next.HandleSet(ctx, userID, key, value)
+}
+
+func (i *InstrumentedSyncService) HandleGet(ctx context.Context, userID, key string) (response ServerResponse) {
+	ctx, span := tracing.Start(ctx, "syncservice.HandleGet")
+	defer func() { tracing.End(span, responseError(response)) }()
+	return i.next.HandleGet(ctx, userID, key)
+}
+
+func (i *InstrumentedSyncService) HandleClearConversations(ctx context.Context, userID string) (response ServerResponse) {
+	ctx, span := tracing.Start(ctx, "syncservice.HandleClearConversations")
+	defer func() { tracing.End(span, responseError(response)) }()
+	return i.next.HandleClearConversations(ctx, userID)
+}
+
+// responseError returns the message of an unsuccessful response as an error.
+func responseError(response ServerResponse) error {
+	if response.Success {
+		return nil
+	}
+	return errors.New(response.Message)
+}
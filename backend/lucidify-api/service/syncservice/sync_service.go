@@ -1,6 +1,7 @@
 package syncservice
 
 import (
+	"context"
 	"log"
 	"lucidify-api/data/store/postgresqlclient"
 )
@@ -13,40 +14,36 @@ type ServerResponse struct {
 }
 
 type SyncService interface {
-	HandleSet(userID, key, value string) ServerResponse
-	HandleGet(userID, key string) ServerResponse
-	HandleClearConversations(userID string) ServerResponse
+	HandleSet(ctx context.Context, userID, key, value string) ServerResponse
+	HandleGet(ctx context.Context, userID, key string) ServerResponse
+	HandleClearConversations(ctx context.Context, userID string) ServerResponse
 }
 
 type SyncServiceImpl struct {
 	postgresqlDB *postgresqlclient.PostgreSQL
 }
 
-func NewSyncService() (SyncService, error) {
-	postgresqlDB, err := postgresqlclient.NewPostgreSQL()
-	if err != nil {
-		return nil, err
-	}
+func NewSyncService(postgresqlDB *postgresqlclient.PostgreSQL) (SyncService, error) {
 	return &SyncServiceImpl{postgresqlDB: postgresqlDB}, nil
 }
 
 var store = make(map[string]string)
 
-func (s *SyncServiceImpl) HandleSet(userID, key, value string) ServerResponse {
+func (s *SyncServiceImpl) HandleSet(ctx context.Context, userID, key, value string) ServerResponse {
 	log.Println("Setting data for key:", key)
 	switch key {
 	case "conversationHistory":
-		err := s.postgresqlDB.SetData(userID, "conversationHistory", value)
+		err := s.postgresqlDB.SetData(ctx, userID, "conversationHistory", value)
 		if err != nil {
 			return ServerResponse{Success: false, Message: "Error setting data for key: " + key}
 		}
 	case "prompts":
-		err := s.postgresqlDB.SetData(userID, "prompts", value)
+		err := s.postgresqlDB.SetData(ctx, userID, "prompts", value)
 		if err != nil {
 			return ServerResponse{Success: false, Message: "Error setting data for key: " + key}
 		}
 	case "folders":
-		err := s.postgresqlDB.SetData(userID, "folders", value)
+		err := s.postgresqlDB.SetData(ctx, userID, "folders", value)
 		if err != nil {
 			return ServerResponse{Success: false, Message: "Error setting data for key: " + key}
 		}
@@ -57,23 +54,23 @@ func (s *SyncServiceImpl) HandleSet(userID, key, value string) ServerResponse {
 	return ServerResponse{Success: true, Message: "Data set successfully for key: " + key}
 }
 
-func (s *SyncServiceImpl) HandleGet(userID, key string) ServerResponse {
+func (s *SyncServiceImpl) HandleGet(ctx context.Context, userID, key string) ServerResponse {
 	log.Println("Getting data for key:", key)
 	switch key {
 	case "conversationHistory":
-		data, err := s.postgresqlDB.GetData(userID, "conversationHistory")
+		data, err := s.postgresqlDB.GetData(ctx, userID, "conversationHistory")
 		if err != nil {
 			return ServerResponse{Success: false, Message: "Error getting data for key: " + key}
 		}
 		return ServerResponse{Success: true, Data: data, Message: "Data fetched successfully"}
 	case "prompts":
-		data, err := s.postgresqlDB.GetData(userID, "prompts")
+		data, err := s.postgresqlDB.GetData(ctx, userID, "prompts")
 		if err != nil {
 			return ServerResponse{Success: false, Message: "Error getting data for key: " + key}
 		}
 		return ServerResponse{Success: true, Data: data, Message: "Data fetched successfully"}
 	case "folders":
-		data, err := s.postgresqlDB.GetData(userID, "folders")
+		data, err := s.postgresqlDB.GetData(ctx, userID, "folders")
 		if err != nil {
 			return ServerResponse{Success: false, Message: "Error getting data for key: " + key}
 		}
@@ -83,8 +80,8 @@ func (s *SyncServiceImpl) HandleGet(userID, key string) ServerResponse {
 	}
 }
 
-func (s *SyncServiceImpl) HandleClearConversations(userID string) ServerResponse {
-	err := s.postgresqlDB.ClearConversations(userID)
+func (s *SyncServiceImpl) HandleClearConversations(ctx context.Context, userID string) ServerResponse {
+	err := s.postgresqlDB.ClearConversations(ctx, userID)
 	if err != nil {
 		return ServerResponse{Success: false, Message: "Something went wrong with clear conversations: " + err.Error()}
 	}
@@ -3,16 +3,20 @@
 package syncservice
 
 import (
+	"context"
 	"log"
 	"lucidify-api/data/store/postgresqlclient"
 	"lucidify-api/data/store/storemodels"
 	"lucidify-api/data/store/weaviateclient"
+	"lucidify-api/server/config"
 	"lucidify-api/service/userservice"
 	"testing"
 )
 
 func createTestUserInDb() error {
-	db, err := postgresqlclient.NewPostgreSQL()
+	ctx := context.Background()
+	cfg := config.NewServerConfig()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
 
 	// the user id registered by the jwt token must exist in the local database
 	user := storemodels.User{
@@ -30,7 +34,7 @@ func createTestUserInDb() error {
 		UpdatedAt:        1654012591514,
 	}
 
-	weaviate, err := weaviateclient.NewWeaviateClientTest()
+	weaviate, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
 	if err != nil {
 		log.Fatalf("Failed to create WeaviateClient: %v", err)
 	}
@@ -39,21 +43,21 @@ func createTestUserInDb() error {
 		log.Fatalf("Failed to create UserService: %v", err)
 	}
 
-	err = userService.DeleteUser(user.UserID)
+	err = userService.DeleteUser(ctx, user.UserID)
 	if err != nil {
 		log.Fatalf("Failed to delete user: %v", err)
 	}
-	if !userService.HasUserBeenDeleted(user.UserID, 3) {
+	if !userService.HasUserBeenDeleted(ctx, user.UserID, 3) {
 		log.Fatalf("Failed to delete user: %v", err)
 	}
 
-	err = db.CreateUserInUsersTable(user)
+	err = db.CreateUserInUsersTable(ctx, user)
 	if err != nil {
 		log.Fatalf("Failed to create user: %v", err)
 	}
 
 	// Check if the user exists
-	_, err = userService.GetUserWithRetries(user.UserID, 3)
+	_, err = userService.GetUserWithRetries(ctx, user.UserID, 3)
 	if err != nil {
 		log.Fatalf("User not found after creation: %v", err)
 	}
@@ -62,6 +66,8 @@ func createTestUserInDb() error {
 }
 
 func TestSyncServiceIntegration(t *testing.T) {
+	ctx := context.Background()
+
 	testUserID := "TestUserIDSyncService"
 
 	err := createTestUserInDb()
@@ -69,12 +75,13 @@ func TestSyncServiceIntegration(t *testing.T) {
 		t.Fatalf("Failed to create test user: %v", err)
 	}
 
-	postgre, err := postgresqlclient.NewPostgreSQL()
+	cfg := config.NewServerConfig()
+	postgre, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
 	if err != nil {
 		log.Fatalf("Failed to create PostgreSQLClient: %v", err)
 	}
 
-	weaviate, err := weaviateclient.NewWeaviateClientTest()
+	weaviate, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
 	if err != nil {
 		log.Fatalf("Failed to create WeaviateClient: %v", err)
 	}
@@ -83,10 +90,10 @@ func TestSyncServiceIntegration(t *testing.T) {
 		log.Fatalf("Failed to create UserService: %v", err)
 	}
 
-	defer userService.DeleteUser(testUserID) // Cleanup the test user after the test
+	defer userService.DeleteUser(ctx, testUserID) // Cleanup the test user after the test
 
 	// Initialize SyncService
-	syncSrv, err := NewSyncService()
+	syncSrv, err := NewSyncService(postgre)
 	if err != nil {
 		t.Fatalf("Failed to initialize SyncService: %v", err)
 	}
@@ -100,14 +107,16 @@ func TestSyncServiceIntegration(t *testing.T) {
 }
 
 func runTestForKey(t *testing.T, syncSrv SyncService, userID, key, value string) {
+	ctx := context.Background()
+
 	// Test HandleSet
-	resp := syncSrv.HandleSet(userID, key, value)
+	resp := syncSrv.HandleSet(ctx, userID, key, value)
 	if !resp.Success {
 		t.Fatalf("HandleSet failed for key '%s': %s", key, resp.Message)
 	}
 
 	// Test HandleGet
-	resp = syncSrv.HandleGet(userID, key)
+	resp = syncSrv.HandleGet(ctx, userID, key)
 	if !resp.Success {
 		t.Fatalf("HandleGet failed for key '%s': %s", key, resp.Message)
 	}
@@ -116,7 +125,7 @@ func runTestForKey(t *testing.T, syncSrv SyncService, userID, key, value string)
 	}
 
 	// Test HandleClearConversations
-	resp = syncSrv.HandleClearConversations(userID)
+	resp = syncSrv.HandleClearConversations(ctx, userID)
 	if !resp.Success {
 		t.Fatalf("HandleClearConversations failed for key '%s': %s", key, resp.Message)
 	}
@@ -124,7 +133,7 @@ func runTestForKey(t *testing.T, syncSrv SyncService, userID, key, value string)
 	// Verify conversationHistory & folders are cleared
 	if key == "conversationHistory" || key == "folders" {
 		// Verify data is cleared
-		resp = syncSrv.HandleGet(userID, key)
+		resp = syncSrv.HandleGet(ctx, userID, key)
 		if resp.Success {
 			t.Fatalf("For key '%s', expected data to be cleared, but HandleGet was successful", key)
 		}
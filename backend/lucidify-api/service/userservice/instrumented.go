@@ -0,0 +1,77 @@
+package userservice
+
+import (
+	"context"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/tracing"
+	"time"
+)
+
+// InstrumentedUserService traces each operation of the UserService it wraps.
+type InstrumentedUserService struct {
+	next UserService
+}
+
+func NewInstrumentedUserService(next UserService) UserService {
+	return &InstrumentedUserService{next: next}
+}
+
+func (i *InstrumentedUserService) CreateUser(ctx context.Context, user storemodels.User) (err error) {
+	ctx, span := tracing.Start(ctx, "userservice.CreateUser")
+	defer func() { tracing.End(span, err) }()
+	return i.next.CreateUser(ctx, user)
+}
+
+func (i *InstrumentedUserService) ProvisionUser(ctx context.Context, userID string, clerkSecretKey string) (err error) {
+	ctx, span := tracing.Start(ctx, "userservice.ProvisionUser")
+	defer func() { tracing.End(span, err) }()
+	return i.next.ProvisionUser(ctx, userID, clerkSecretKey)
+}
+
+func (i *InstrumentedUserService) ProvisionUserFromClaims(ctx context.Context, user storemodels.User) (err error) {
+	ctx, span := tracing.Start(ctx, "userservice.ProvisionUserFromClaims")
+	defer func() { tracing.End(span, err) }()
+	return i.next.ProvisionUserFromClaims(ctx, user)
+}
+
+func (i *InstrumentedUserService) UpdateUser(ctx context.Context, user storemodels.User) (err error) {
+	ctx, span := tracing.Start(ctx, "userservice.UpdateUser")
+	defer func() { tracing.End(span, err) }()
+	return i.next.UpdateUser(ctx, user)
+}
+
+func (i *InstrumentedUserService) DeleteUser(ctx context.Context, userID string) (err error) {
+	ctx, span := tracing.Start(ctx, "userservice.DeleteUser")
+	defer func() { tracing.End(span, err) }()
+	return i.next.DeleteUser(ctx, userID)
+}
+
+func (i *InstrumentedUserService) MarkUserDeleted(ctx context.Context, userID string) (err error) {
+	ctx, span := tracing.Start(ctx, "userservice.MarkUserDeleted")
+	defer func() { tracing.End(span, err) }()
+	return i.next.MarkUserDeleted(ctx, userID)
+}
+
+func (i *InstrumentedUserService) PurgeDeletedUsers(ctx context.Context, before time.Time) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "userservice.PurgeDeletedUsers")
+	defer func() { tracing.End(span, err) }()
+	return i.next.PurgeDeletedUsers(ctx, before)
+}
+
+func (i *InstrumentedUserService) GetUser(ctx context.Context, userID string) (_ *storemodels.User, err error) {
+	ctx, span := tracing.Start(ctx, "userservice.GetUser")
+	defer func() { tracing.End(span, err) }()
+	return i.next.GetUser(ctx, userID)
+}
+
+func (i *InstrumentedUserService) GetUserWithRetries(ctx context.Context, userID string, retries int) (_ *storemodels.User, err error) {
+	ctx, span := tracing.Start(ctx, "userservice.GetUserWithRetries")
+	defer func() { tracing.End(span, err) }()
+	return i.next.GetUserWithRetries(ctx, userID, retries)
+}
+
+func (i *InstrumentedUserService) HasUserBeenDeleted(ctx context.Context, userID string, retries int) bool {
+	ctx, span := tracing.Start(ctx, "userservice.HasUserBeenDeleted")
+	defer span.End()
+	return i.next.HasUserBeenDeleted(ctx, userID, retries)
+}
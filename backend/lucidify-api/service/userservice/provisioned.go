@@ -0,0 +1,81 @@
+package userservice
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	provisionedUsersCapacity = 10000
+	// Users deleted through another replica are forgotten after this long
+	provisionedUsersTTL = 10 * time.Minute
+)
+
+type provisionedEntry struct {
+	userID  string
+	addedAt time.Time
+}
+
+// provisionedUsers remembers the most recently seen users known to exist, so
+// that provisioning only looks them up once in a while.
+type provisionedUsers struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List // Most recently used first
+	entries  map[string]*list.Element
+}
+
+func newProvisionedUsers(capacity int, ttl time.Duration) *provisionedUsers {
+	return &provisionedUsers{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (c *provisionedUsers) contains(userID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[userID]
+	if !ok {
+		return false
+	}
+	if time.Since(element.Value.(*provisionedEntry).addedAt) > c.ttl {
+		c.order.Remove(element)
+		delete(c.entries, userID)
+		return false
+	}
+	c.order.MoveToFront(element)
+	return true
+}
+
+func (c *provisionedUsers) add(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[userID]; ok {
+		element.Value.(*provisionedEntry).addedAt = time.Now()
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[userID] = c.order.PushFront(&provisionedEntry{userID: userID, addedAt: time.Now()})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*provisionedEntry).userID)
+	}
+}
+
+func (c *provisionedUsers) remove(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[userID]; ok {
+		c.order.Remove(element)
+		delete(c.entries, userID)
+	}
+}
@@ -1,108 +1,261 @@
 package userservice
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"lucidify-api/data/store/postgresqlclient"
 	"lucidify-api/data/store/storemodels"
 	"lucidify-api/data/store/weaviateclient"
+	"lucidify-api/service/clerkservice"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type UserService interface {
-	CreateUser(user storemodels.User) error
-	UpdateUser(user storemodels.User) error
-	DeleteUser(userID string) error
-	GetUser(userID string) (*storemodels.User, error)
-	GetUserWithRetries(userID string, retries int) (*storemodels.User, error)
-	HasUserBeenDeleted(userID string, retries int) bool
+	CreateUser(ctx context.Context, user storemodels.User) error
+	ProvisionUser(ctx context.Context, userID string, clerkSecretKey string) error
+	ProvisionUserFromClaims(ctx context.Context, user storemodels.User) error
+	UpdateUser(ctx context.Context, user storemodels.User) error
+	DeleteUser(ctx context.Context, userID string) error
+	MarkUserDeleted(ctx context.Context, userID string) error
+	PurgeDeletedUsers(ctx context.Context, before time.Time) (int, error)
+	GetUser(ctx context.Context, userID string) (*storemodels.User, error)
+	GetUserWithRetries(ctx context.Context, userID string, retries int) (*storemodels.User, error)
+	HasUserBeenDeleted(ctx context.Context, userID string, retries int) bool
 }
 
 type UserServiceImpl struct {
 	postgresqlDB *postgresqlclient.PostgreSQL
 	weaviateDB   weaviateclient.WeaviateClient
+	provisioned  *provisionedUsers
 }
 
 func NewUserService(postgresqlDB *postgresqlclient.PostgreSQL, weaviateClient weaviateclient.WeaviateClient) (UserService, error) {
-	return &UserServiceImpl{postgresqlDB: postgresqlDB, weaviateDB: weaviateClient}, nil
+	return &UserServiceImpl{
+		postgresqlDB: postgresqlDB,
+		weaviateDB:   weaviateClient,
+		provisioned:  newProvisionedUsers(provisionedUsersCapacity, provisionedUsersTTL),
+	}, nil
 }
 
-func (u *UserServiceImpl) CreateUser(user storemodels.User) error {
-	err := u.postgresqlDB.CreateUserInUsersTable(user)
+// CreateUser is idempotent, so that it can race with ProvisionUser and be
+// retried by the webhook worker.
+func (u *UserServiceImpl) CreateUser(ctx context.Context, user storemodels.User) error {
+	err := u.postgresqlDB.UpsertUserInUsersTable(ctx, user)
 	if err != nil {
 		return err
 	}
-	return nil
+	return u.weaviateDB.CreateTenant(ctx, user.UserID)
 }
 
-func (u *UserServiceImpl) UpdateUser(user storemodels.User) error {
-	err := u.postgresqlDB.UpdateUserInUsersTable(user)
+// ProvisionUser makes sure a signed-in user exists in the users table, fetching
+// their profile from Clerk when the user.created webhook has not been processed
+// yet. An existing row is never overwritten, so the webhook stays authoritative.
+// Users known to exist are remembered for a while, so that they are not looked
+// up on each request.
+func (u *UserServiceImpl) ProvisionUser(ctx context.Context, userID string, clerkSecretKey string) error {
+	if u.provisioned.contains(userID) {
+		return nil
+	}
+	_, err := u.postgresqlDB.GetUserInUsersTable(ctx, userID)
+	if err == nil {
+		u.provisioned.add(userID)
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("Failed to get user from PostgreSQL: %w", err)
+	}
+
+	clerkUser, err := clerkservice.RetrieveUser(ctx, clerkSecretKey, userID)
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve user from Clerk: %w", err)
+	}
+	user, err := clerkservice.UserFromClerkData(clerkUser)
 	if err != nil {
 		return err
 	}
+
+	log.Printf("Provisioning user %s ahead of the user.created webhook", userID)
+	if err := u.postgresqlDB.CreateUserInUsersTableIfNotExists(ctx, user); err != nil {
+		return err
+	}
+	u.provisioned.add(userID)
 	return nil
 }
 
-func (u *UserServiceImpl) deleteDocument(documentID uuid.UUID) error {
-	chunks, err := u.postgresqlDB.GetChunksOfDocumentByDocumentID(documentID)
-	if err != nil {
-		return fmt.Errorf("Failed to get chunks of document: %w", err)
+// ProvisionUserFromClaims makes sure a user signed in with an OIDC issuer
+// exists in the users table, creating them from the claims of their token.
+// As with ProvisionUser, an existing row is never overwritten.
+func (u *UserServiceImpl) ProvisionUserFromClaims(ctx context.Context, user storemodels.User) error {
+	if u.provisioned.contains(user.UserID) {
+		return nil
+	}
+	_, err := u.postgresqlDB.GetUserInUsersTable(ctx, user.UserID)
+	if err == nil {
+		u.provisioned.add(user.UserID)
+		return nil
 	}
-	// The error happens at this line
-	err = u.weaviateDB.DeleteChunks(chunks)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("Failed to get user from PostgreSQL: %w", err)
+	}
+	if user.Email == "" {
+		return fmt.Errorf("Token of user %s has no email claim", user.UserID)
+	}
+
+	log.Printf("Provisioning user %s from the claims of their token", user.UserID)
+	if err := u.postgresqlDB.CreateUserInUsersTableIfNotExists(ctx, user); err != nil {
+		return err
+	}
+	u.provisioned.add(user.UserID)
+	return nil
+}
+
+func (u *UserServiceImpl) UpdateUser(ctx context.Context, user storemodels.User) error {
+	err := u.postgresqlDB.UpdateUserInUsersTable(ctx, user)
 	if err != nil {
-		return fmt.Errorf("Failed to delete chunks from Weaviate: %w", err)
+		return err
 	}
+	return nil
+}
 
-	err = u.postgresqlDB.DeleteDocumentByUUID(documentID)
+func (u *UserServiceImpl) deleteDocument(ctx context.Context, documentID uuid.UUID) error {
+	err := u.postgresqlDB.DeleteDocumentByUUID(ctx, documentID)
 	if err != nil {
-		log.Printf("Failed to delete document from PostgreSQL: %v", err)
+		return fmt.Errorf("Failed to delete document from PostgreSQL: %w", err)
 	}
 	return nil
 }
 
-func (u *UserServiceImpl) DeleteUser(userID string) error {
-	// Test chunks must be created by uploading documents!
-	// documents, err := u.postgresqlDB.GetAllDocuments(userID)
-	documents, err := u.postgresqlDB.GetAllDocumentsIDs(userID)
-	log.Printf("Deleting %d documents", len(documents))
+// DeleteUser permanently deletes the user along with their private documents.
+// The documents they uploaded to a workspace are handed over to another member
+// of the workspace first, see GetWorkspaceDocumentHeirs, so that the workspace
+// keeps them. Those of a workspace left without members are deleted too.
+func (u *UserServiceImpl) DeleteUser(ctx context.Context, userID string) error {
 	log.Printf("Deleting user %s", userID)
-	log.Printf("Deleting documents %s", documents)
+	u.provisioned.remove(userID)
+	if err := u.handOverWorkspaceDocuments(ctx, userID); err != nil {
+		return fmt.Errorf("Failed to hand over workspace documents: %w", err)
+	}
+
+	documentIDsByWorkspace, err := u.postgresqlDB.GetDocumentIDsByWorkspace(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("Failed to get all documents from PostgreSQL: %w", err)
 	}
 
-	for _, document := range documents {
-		// if err := u.deleteDocument(document.DocumentUUID); err != nil {
-		if err := u.deleteDocument(uuid.MustParse(document)); err != nil {
-			return fmt.Errorf("Failed to delete document: %w", err)
+	err = u.weaviateDB.DeleteChunksOfUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("Failed to delete chunks of user from Weaviate: %w", err)
+	}
+	// The chunks of workspace documents are only found by document
+	for workspaceID, documentIDs := range documentIDsByWorkspace {
+		log.Printf("Deleting %d documents", len(documentIDs))
+		err = u.weaviateDB.DeleteChunksOfDocuments(ctx, userID, workspaceID, documentIDs)
+		if err != nil {
+			return fmt.Errorf("Failed to delete chunks of documents from Weaviate: %w", err)
+		}
+	}
+	err = u.weaviateDB.DeleteTenant(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("Failed to delete tenant of user from Weaviate: %w", err)
+	}
+
+	for _, documentIDs := range documentIDsByWorkspace {
+		for _, documentID := range documentIDs {
+			if err := u.deleteDocument(ctx, documentID); err != nil {
+				return fmt.Errorf("Failed to delete document %s: %w", documentID, err)
+			}
 		}
 	}
 
-	err = u.postgresqlDB.DeleteUserInUsersTable(userID)
+	err = u.postgresqlDB.DeleteUserInUsersTable(ctx, userID)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (u *UserServiceImpl) GetUser(userID string) (*storemodels.User, error) {
-	user, err := u.postgresqlDB.GetUserInUsersTable(userID)
+// handOverWorkspaceDocuments makes an heir the uploader of each workspace
+// document of the user. Their chunks are written again under the heir first,
+// since the tenant of the user is about to be deleted along with the chunks
+// of older schema versions it holds.
+func (u *UserServiceImpl) handOverWorkspaceDocuments(ctx context.Context, userID string) error {
+	heirs, err := u.postgresqlDB.GetWorkspaceDocumentHeirs(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("Failed to get heirs of documents: %w", err)
+	}
+
+	for documentID, heirID := range heirs {
+		document, err := u.postgresqlDB.GetDocumentByUUID(ctx, documentID)
+		if err != nil {
+			return fmt.Errorf("Failed to get document: %w", err)
+		}
+		chunks, err := u.postgresqlDB.GetChunksOfDocumentByDocumentID(ctx, documentID)
+		if err != nil {
+			return fmt.Errorf("Failed to get chunks of document: %w", err)
+		}
+		if len(chunks) == 0 {
+			continue
+		}
+		for i := range chunks {
+			chunks[i].UserID = heirID
+		}
+		err = u.weaviateDB.UploadChunks(ctx, chunks)
+		if err == nil && document.DeletedAt != nil {
+			// A document in the trash stays hidden from search
+			err = u.weaviateDB.SetChunksDeleted(ctx, chunks, true)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to upload chunks to Weaviate: %w", err)
+		}
+	}
+
+	return u.postgresqlDB.TransferDocuments(ctx, heirs)
+}
+
+// MarkUserDeleted hides the user without removing any of their data. The data
+// is removed by PurgeDeletedUsers once the grace period has passed.
+func (u *UserServiceImpl) MarkUserDeleted(ctx context.Context, userID string) error {
+	u.provisioned.remove(userID)
+	return u.postgresqlDB.SoftDeleteUserInUsersTable(ctx, userID)
+}
+
+// PurgeDeletedUsers permanently deletes every user marked as deleted before the
+// given time, along with their documents. It returns the number of purged users.
+func (u *UserServiceImpl) PurgeDeletedUsers(ctx context.Context, before time.Time) (int, error) {
+	userIDs, err := u.postgresqlDB.GetUserIDsDeletedBefore(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get expired users from PostgreSQL: %w", err)
+	}
+
+	purged := 0
+	for _, userID := range userIDs {
+		if err := u.DeleteUser(ctx, userID); err != nil {
+			return purged, fmt.Errorf("Failed to purge user %s: %w", userID, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (u *UserServiceImpl) GetUser(ctx context.Context, userID string) (*storemodels.User, error) {
+	user, err := u.postgresqlDB.GetUserInUsersTable(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func (u *UserServiceImpl) GetUserWithRetries(userID string, retries int) (*storemodels.User, error) {
+func (u *UserServiceImpl) GetUserWithRetries(ctx context.Context, userID string, retries int) (*storemodels.User, error) {
 	var found bool
 	var user *storemodels.User
 	var err error
 
 	for i := 0; i < retries; i++ {
-		user, err = u.GetUser(userID)
+		user, err = u.GetUser(ctx, userID)
 		if err == nil {
 			found = true
 			break
@@ -115,9 +268,9 @@ func (u *UserServiceImpl) GetUserWithRetries(userID string, retries int) (*store
 	return nil, fmt.Errorf("User not found after %d retries", retries)
 }
 
-func (u *UserServiceImpl) HasUserBeenDeleted(userID string, retries int) bool {
+func (u *UserServiceImpl) HasUserBeenDeleted(ctx context.Context, userID string, retries int) bool {
 	for i := 0; i < retries; i++ {
-		_, err := u.GetUser(userID)
+		_, err := u.GetUser(ctx, userID)
 		if err != nil {
 			return true
 		}
@@ -1,10 +1,12 @@
 package userservice
 
 import (
+	"context"
 	"log"
 	"lucidify-api/data/store/postgresqlclient"
 	"lucidify-api/data/store/storemodels"
 	"lucidify-api/data/store/weaviateclient"
+	"lucidify-api/server/config"
 	"lucidify-api/service/documentservice"
 	"testing"
 )
@@ -25,11 +27,12 @@ func setupTests() (UserService, storemodels.User, error, *postgresqlclient.Postg
 		UpdatedAt:        1654012591514,
 	}
 
-	db, err := postgresqlclient.NewPostgreSQL()
+	cfg := config.NewServerConfig()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
 	if err != nil {
 		return nil, user, err, db
 	}
-	weaviate, err := weaviateclient.NewWeaviateClientTest()
+	weaviate, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
 	if err != nil {
 		log.Fatalf("Failed to create WeaviateClient: %v", err)
 	}
@@ -41,15 +44,17 @@ func setupTests() (UserService, storemodels.User, error, *postgresqlclient.Postg
 }
 
 func TestCreateUser(t *testing.T) {
+	ctx := context.Background()
+
 	userService, user, err, db := setupTests()
 	if err != nil {
 		t.Error(err)
 	}
-	err = userService.CreateUser(user)
+	err = userService.CreateUser(ctx, user)
 	if err != nil {
 		t.Error(err)
 	}
-	userFromDb, err := db.GetUserInUsersTable(user.UserID)
+	userFromDb, err := db.GetUserInUsersTable(ctx, user.UserID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -58,15 +63,62 @@ func TestCreateUser(t *testing.T) {
 	}
 
 	t.Cleanup(func() {
-		userService.DeleteUser(user.UserID)
+		userService.DeleteUser(ctx, user.UserID)
 	})
 }
+
+func TestCreateUserIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+
+	userService, user, err, _ := setupTests()
+	if err != nil {
+		t.Error(err)
+	}
+	err = userService.CreateUser(ctx, user)
+	if err != nil {
+		t.Error(err)
+	}
+	// A retried or racing user.created must not fail
+	err = userService.CreateUser(ctx, user)
+	if err != nil {
+		t.Errorf("Creating an existing user should succeed: %v", err)
+	}
+
+	t.Cleanup(func() {
+		userService.DeleteUser(ctx, user.UserID)
+	})
+}
+
+func TestProvisionUserSkipsExistingUser(t *testing.T) {
+	ctx := context.Background()
+
+	userService, user, err, _ := setupTests()
+	if err != nil {
+		t.Error(err)
+	}
+	err = userService.CreateUser(ctx, user)
+	if err != nil {
+		t.Error(err)
+	}
+	// No Clerk secret key is needed as the user already exists
+	err = userService.ProvisionUser(ctx, user.UserID, "")
+	if err != nil {
+		t.Errorf("Provisioning an existing user should succeed: %v", err)
+	}
+
+	t.Cleanup(func() {
+		userService.DeleteUser(ctx, user.UserID)
+	})
+}
+
 func TestUpdateUser(t *testing.T) {
+	ctx := context.Background()
+
 	userService, user, err, db := setupTests()
 	if err != nil {
 		t.Error(err)
 	}
-	err = userService.CreateUser(user)
+	err = userService.CreateUser(ctx, user)
 	if err != nil {
 		t.Error(err)
 	}
@@ -84,11 +136,11 @@ func TestUpdateUser(t *testing.T) {
 		CreatedAt:        1654012591514,
 		UpdatedAt:        1654012591514,
 	}
-	err = userService.UpdateUser(userUpdated)
+	err = userService.UpdateUser(ctx, userUpdated)
 	if err != nil {
 		t.Error(err)
 	}
-	userAfterUpdate, err := db.GetUserInUsersTable(user.UserID)
+	userAfterUpdate, err := db.GetUserInUsersTable(ctx, user.UserID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -130,11 +182,13 @@ func TestUpdateUser(t *testing.T) {
 	}
 
 	t.Cleanup(func() {
-		userService.DeleteUser(user.UserID)
+		userService.DeleteUser(ctx, user.UserID)
 	})
 }
 
 func TestDeleteUser(t *testing.T) {
+	ctx := context.Background()
+
 	user := storemodels.User{
 		UserID:           "TestDeleteUserAndAssociatedDocumentsUserID",
 		ExternalID:       "TestCreateUserTableExternalID",
@@ -150,12 +204,13 @@ func TestDeleteUser(t *testing.T) {
 		UpdatedAt:        1654012591514,
 	}
 
-	weaviateClient, err := weaviateclient.NewWeaviateClientTest()
+	cfg := config.NewServerConfig()
+	weaviateClient, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
 	if err != nil {
 		t.Fatalf("failed to create weaviate client: %v", err)
 	}
 
-	postgre, err := postgresqlclient.NewPostgreSQL()
+	postgre, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
 	if err != nil {
 		t.Errorf("Failed to create test postgresqlclient: %v", err)
 	}
@@ -165,24 +220,24 @@ func TestDeleteUser(t *testing.T) {
 		t.Errorf("Failed to create test userservice: %v", err)
 	}
 
-	err = userService.CreateUser(user)
+	err = userService.CreateUser(ctx, user)
 	if err != nil {
 		t.Error(err)
 	}
-	_, err = userService.GetUserWithRetries(user.UserID, 5)
+	_, err = userService.GetUserWithRetries(ctx, user.UserID, 5)
 	if err != nil {
 		t.Errorf("User not found after creation: %v", err)
 	}
 	// _, err = weaviateclient.NewWeaviateClientTest()
-	postgres, err := postgresqlclient.NewPostgreSQL()
+	postgres, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
 	if err != nil {
 		t.Errorf("Failed to create test postgresqlclient: %v", err)
 	}
 
-	documentService := documentservice.NewDocumentService(postgres, weaviateClient)
+	documentService := documentservice.NewDocumentService(postgres, weaviateClient, documentservice.ChunkerOptions{URL: cfg.AI_API_URL, APIKey: cfg.X_AI_API_KEY})
 
 	// // 2. Call the function
-	document, err := documentService.UploadDocument("TestDeleteUserAndAssociatedDocumentsUserID", "Dog Knowledge",
+	document, err := documentService.UploadDocument(ctx, "TestDeleteUserAndAssociatedDocumentsUserID", "Dog Knowledge",
 		`Introduction to Dogs: Dogs, often referred to as "man's best friend," have been
 		companions to humans for thousands of years. Originating from wild wolves,
 		these loyal creatures have been domesticated and bred for various roles
@@ -233,53 +288,55 @@ func TestDeleteUser(t *testing.T) {
 
 	// time.Sleep(2 * time.Second)
 
-	chunks, err := postgre.GetChunksOfDocument(document)
+	chunks, err := postgre.GetChunksOfDocument(ctx, document)
 	if err != nil || len(chunks) != 4 {
 		t.Errorf("Chunks were not uploaded to PostgreSQL: %v", len(chunks))
 	}
 
-	chunksWeaviate, err := weaviateClient.GetChunks(chunks)
+	chunksWeaviate, err := weaviateClient.GetChunks(ctx, chunks)
 	if err != nil || len(chunksWeaviate) != 4 {
 		t.Errorf("Chunks were not uploaded to Weaviate: %v", len(chunksWeaviate))
 	}
 
-	err = userService.DeleteUser(user.UserID)
+	err = userService.DeleteUser(ctx, user.UserID)
 	if err != nil {
 		t.Error(err)
 	}
 	// weaviateClient.DeleteAllChunksByUserID(user.UserID)
 
-	chunks, err = postgre.GetChunksOfDocument(document)
+	chunks, err = postgre.GetChunksOfDocument(ctx, document)
 	if err != nil || len(chunks) != 0 {
 		t.Errorf("Chunks were not deleted from postgre: %v", len(chunks))
 	}
 
-	chunksWeaviate, err = weaviateClient.GetChunks(chunks)
+	chunksWeaviate, err = weaviateClient.GetChunks(ctx, chunks)
 	if err != nil || len(chunksWeaviate) != 0 {
 		t.Errorf("Chunks were not deleted from Weaviate: %v", len(chunksWeaviate))
 	}
 
 	t.Cleanup(func() {
-		userService.DeleteUser(user.UserID)
-		weaviateClient.DeleteChunks(chunks)
-		postgre.DeleteAllChunksByDocumentID(document.DocumentUUID)
+		userService.DeleteUser(ctx, user.UserID)
+		weaviateClient.DeleteChunks(ctx, chunks)
+		postgre.DeleteAllChunksByDocumentID(ctx, document.DocumentUUID)
 	})
 }
 
 func TestGetUser(t *testing.T) {
+	ctx := context.Background()
+
 	userService, user, err, _ := setupTests()
 	if err != nil {
 		t.Error(err)
 	}
-	err = userService.CreateUser(user)
+	err = userService.CreateUser(ctx, user)
 	if err != nil {
 		t.Error(err)
 	}
-	_, err = userService.GetUserWithRetries(user.UserID, 5)
+	_, err = userService.GetUserWithRetries(ctx, user.UserID, 5)
 	if err != nil {
 		t.Errorf("User not found after creation: %v", err)
 	}
-	userFromDb, err := userService.GetUser(user.UserID)
+	userFromDb, err := userService.GetUser(ctx, user.UserID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -288,22 +345,24 @@ func TestGetUser(t *testing.T) {
 	}
 
 	t.Cleanup(func() {
-		userService.DeleteUser(user.UserID)
+		userService.DeleteUser(ctx, user.UserID)
 	})
 }
 
 func TestGetUserWithRetries(t *testing.T) {
+	ctx := context.Background()
+
 	userService, user, err, _ := setupTests()
 	if err != nil {
 		t.Error(err)
 	}
 
-	err = userService.CreateUser(user)
+	err = userService.CreateUser(ctx, user)
 	if err != nil {
 		t.Error(err)
 	}
 	// Case 1: User is found without needing retries
-	foundUser, err := userService.GetUserWithRetries(user.UserID, 5)
+	foundUser, err := userService.GetUserWithRetries(ctx, user.UserID, 5)
 	if err != nil || foundUser == nil {
 		t.Error("GetUserWithRetries failed to retrieve the user, but the user should have been found")
 	}
@@ -312,43 +371,45 @@ func TestGetUserWithRetries(t *testing.T) {
 	// // Delay the start of the next case to avoid immediate execution after user creation
 	// time.Sleep(2 * time.Second)
 
-	_, err = userService.GetUserWithRetries(nonExistentUserID, 5)
+	_, err = userService.GetUserWithRetries(ctx, nonExistentUserID, 5)
 	if err == nil {
 		t.Error("GetUserWithRetries did not return an error, but it should have since the user does not exist")
 	}
 
 	t.Cleanup(func() {
-		userService.DeleteUser(user.UserID)
+		userService.DeleteUser(ctx, user.UserID)
 	})
 }
 
 func TestHasUserBeenDeleted(t *testing.T) {
+	ctx := context.Background()
+
 	userService, user, err, _ := setupTests()
 	if err != nil {
 		t.Error(err)
 	}
 
-	err = userService.CreateUser(user)
+	err = userService.CreateUser(ctx, user)
 	if err != nil {
 		t.Error(err)
 	}
 
 	// Case 1: User has not been deleted
-	userNotDeleted := userService.HasUserBeenDeleted(user.UserID, 5)
+	userNotDeleted := userService.HasUserBeenDeleted(ctx, user.UserID, 5)
 	if userNotDeleted {
 		t.Error("HasUserBeenDeleted returns true, but the user was not deleted")
 	}
 
 	// Assume we have a function to delete a user for test
-	userService.DeleteUser(user.UserID)
+	userService.DeleteUser(ctx, user.UserID)
 
 	// Case 2: User has been deleted
-	userHasBeenDeleted := userService.HasUserBeenDeleted(user.UserID, 5)
+	userHasBeenDeleted := userService.HasUserBeenDeleted(ctx, user.UserID, 5)
 	if !userHasBeenDeleted {
 		t.Error("HasUserBeenDeleted returns false, but the user was deleted")
 	}
 
 	t.Cleanup(func() {
-		userService.DeleteUser(user.UserID)
+		userService.DeleteUser(ctx, user.UserID)
 	})
 }
@@ -0,0 +1,211 @@
+package vectorschemaservice
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"lucidify-api/data/store/postgresqlclient"
+	"lucidify-api/data/store/weaviateclient"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// backfillPageSize bounds the number of chunks copied at once.
+const backfillPageSize = 500
+
+// VectorSchemaStatus describes the schema versions of the vector store.
+type VectorSchemaStatus struct {
+	ActiveVersion     int    `json:"active_version"`
+	ActiveClassName   string `json:"active_class_name"`
+	NextVersion       int    `json:"next_version,omitempty"`
+	LatestVersion     int    `json:"latest_version"`
+	PendingMigrations []int  `json:"pending_migrations"`
+}
+
+type VectorSchemaService interface {
+	Status(ctx context.Context) (*VectorSchemaStatus, error)
+	Migrate(ctx context.Context) (*VectorSchemaStatus, error)
+	Abort(ctx context.Context) error
+	Prune(ctx context.Context) ([]int, error)
+}
+
+type VectorSchemaServiceImpl struct {
+	postgresqlDB *postgresqlclient.PostgreSQL
+	weaviateDB   weaviateclient.WeaviateClient
+
+	// propagationDelay is how long to wait for every client to see a new
+	// schema state
+	propagationDelay time.Duration
+}
+
+func NewVectorSchemaService(postgresqlDB *postgresqlclient.PostgreSQL, weaviateDB weaviateclient.WeaviateClient) VectorSchemaService {
+	return &VectorSchemaServiceImpl{
+		postgresqlDB:     postgresqlDB,
+		weaviateDB:       weaviateDB,
+		propagationDelay: weaviateclient.SchemaStateTTL + 2*time.Second,
+	}
+}
+
+func (v *VectorSchemaServiceImpl) Status(ctx context.Context) (*VectorSchemaStatus, error) {
+	state, err := v.weaviateDB.SchemaState(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get vector schema state: %w", err)
+	}
+
+	status := &VectorSchemaStatus{
+		ActiveVersion:     state.Version,
+		ActiveClassName:   state.ClassName,
+		NextVersion:       state.NextVersion,
+		LatestVersion:     weaviateclient.LatestSchemaVersion(),
+		PendingMigrations: []int{},
+	}
+	for _, migration := range weaviateclient.SchemaMigrations {
+		if migration.Version > state.Version {
+			status.PendingMigrations = append(status.PendingMigrations, migration.Version)
+		}
+	}
+	return status, nil
+}
+
+// Migrate applies the pending migrations one version at a time. Each version
+// is backfilled from document_chunks while it already receives every write,
+// then the chunks deleted in the meantime are removed from it before it is
+// activated. An interrupted migration is resumed.
+func (v *VectorSchemaServiceImpl) Migrate(ctx context.Context) (*VectorSchemaStatus, error) {
+	status, err := v.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, version := range status.PendingMigrations {
+		if err := v.migrateTo(ctx, version); err != nil {
+			return nil, err
+		}
+	}
+	return v.Status(ctx)
+}
+
+func (v *VectorSchemaServiceImpl) migrateTo(ctx context.Context, version int) error {
+	log.Printf("Migrating the vector schema to version %d", version)
+	if _, err := v.weaviateDB.BeginSchemaMigration(ctx, version); err != nil {
+		return fmt.Errorf("Failed to begin vector schema migration: %w", err)
+	}
+
+	// Chunks written before every client writes to the new version are
+	// copied by the backfill
+	time.Sleep(v.propagationDelay)
+
+	copied, err := v.backfill(ctx, version)
+	if err != nil {
+		return err
+	}
+	removed, err := v.removeDeletedChunks(ctx, version)
+	if err != nil {
+		return err
+	}
+
+	if err := v.weaviateDB.ActivateSchemaVersion(ctx, version); err != nil {
+		return fmt.Errorf("Failed to activate vector schema version %d: %w", version, err)
+	}
+	log.Printf("Activated vector schema version %d after copying %d chunks and removing %d deleted chunks", version, copied, removed)
+	return nil
+}
+
+func (v *VectorSchemaServiceImpl) backfill(ctx context.Context, version int) (int, error) {
+	copied := 0
+	after := uuid.Nil
+	for {
+		chunks, deletedDocuments, err := v.postgresqlDB.GetChunksForReindex(ctx, after, backfillPageSize)
+		if err != nil {
+			return copied, fmt.Errorf("Failed to get chunks to backfill: %w", err)
+		}
+		if len(chunks) == 0 {
+			return copied, nil
+		}
+		if err := v.weaviateDB.BackfillSchemaVersion(ctx, version, chunks, deletedDocuments); err != nil {
+			return copied, fmt.Errorf("Failed to backfill vector schema version %d: %w", version, err)
+		}
+		copied += len(chunks)
+		after = chunks[len(chunks)-1].ChunkID
+		log.Printf("Copied %d chunks to vector schema version %d", copied, version)
+	}
+}
+
+// removeDeletedChunks removes the chunks that were deleted from
+// document_chunks after the backfill copied them.
+func (v *VectorSchemaServiceImpl) removeDeletedChunks(ctx context.Context, version int) (int, error) {
+	tenants, err := v.weaviateDB.SchemaVersionTenants(ctx, version)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, tenant := range tenants {
+		after := ""
+		for {
+			chunkIDs, err := v.weaviateDB.SchemaVersionChunkIDs(ctx, version, tenant, after, backfillPageSize)
+			if err != nil {
+				return removed, err
+			}
+			if len(chunkIDs) == 0 {
+				break
+			}
+			existing, err := v.postgresqlDB.GetExistingChunkIDs(ctx, chunkIDs)
+			if err != nil {
+				return removed, fmt.Errorf("Failed to check backfilled chunks: %w", err)
+			}
+
+			var deleted []string
+			for _, chunkID := range chunkIDs {
+				if !existing[chunkID] {
+					deleted = append(deleted, chunkID)
+				}
+			}
+			if err := v.weaviateDB.DeleteSchemaVersionChunks(ctx, version, tenant, deleted); err != nil {
+				return removed, err
+			}
+			removed += len(deleted)
+			after = chunkIDs[len(chunkIDs)-1]
+		}
+	}
+	return removed, nil
+}
+
+// Abort stops the migration in progress. The class of the version it was
+// migrating to is left for Prune, as clients may still write to it for a
+// while.
+func (v *VectorSchemaServiceImpl) Abort(ctx context.Context) error {
+	if err := v.weaviateDB.AbortSchemaMigration(ctx); err != nil {
+		return fmt.Errorf("Failed to abort vector schema migration: %w", err)
+	}
+	return nil
+}
+
+// Prune drops the classes of the versions that are neither active nor being
+// migrated to, and returns those versions. It should only be run once every
+// client has seen the last schema state.
+func (v *VectorSchemaServiceImpl) Prune(ctx context.Context) ([]int, error) {
+	state, err := v.weaviateDB.SchemaState(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get vector schema state: %w", err)
+	}
+	if time.Since(state.UpdatedAt) < v.propagationDelay {
+		return nil, fmt.Errorf("the vector schema state changed less than %s ago", v.propagationDelay)
+	}
+
+	dropped := []int{}
+	for _, migration := range weaviateclient.SchemaMigrations {
+		if migration.Version == state.Version || migration.Version == state.NextVersion {
+			continue
+		}
+		ok, err := v.weaviateDB.DropSchemaVersion(ctx, migration.Version)
+		if err != nil {
+			return dropped, err
+		}
+		if ok {
+			dropped = append(dropped, migration.Version)
+		}
+	}
+	return dropped, nil
+}
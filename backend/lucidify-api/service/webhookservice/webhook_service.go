@@ -0,0 +1,271 @@
+package webhookservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"lucidify-api/data/store/postgresqlclient"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/server/tracing"
+	"lucidify-api/service/clerkservice"
+	"lucidify-api/service/userservice"
+	"lucidify-api/service/workspaceservice"
+	"sync"
+	"time"
+)
+
+const (
+	claimBatchSize = 10
+	claimLease     = 5 * time.Minute
+	maxBackoff     = time.Hour
+)
+
+// ErrInvalidEvent is returned for events that cannot be recorded whatever the
+// number of deliveries, such as a malformed payload.
+var ErrInvalidEvent = errors.New("invalid webhook event")
+
+type ClerkEvent struct {
+	Data   map[string]interface{} `json:"data"`
+	Object string                 `json:"object"`
+	Type   string                 `json:"type"`
+}
+
+type WebhookService interface {
+	RecordClerkEvent(ctx context.Context, svixID string, payload []byte) error
+	ProcessDueEvents(ctx context.Context) (int, error)
+	ReplayEvent(ctx context.Context, svixID string) error
+	GetDeadEvents(ctx context.Context) ([]storemodels.WebhookEvent, error)
+	Start(interval time.Duration)
+	Stop(ctx context.Context)
+}
+
+type WebhookServiceImpl struct {
+	postgresqlDB     *postgresqlclient.PostgreSQL
+	userService      userservice.UserService
+	workspaceService workspaceservice.WorkspaceService
+	maxAttempts      int
+
+	// ctx is cancelled by Stop once the batch being processed is given up on
+	ctx    context.Context
+	cancel context.CancelFunc
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewWebhookService(
+	postgresqlDB *postgresqlclient.PostgreSQL,
+	userService userservice.UserService,
+	workspaceService workspaceservice.WorkspaceService,
+	maxAttempts int) WebhookService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebhookServiceImpl{
+		postgresqlDB:     postgresqlDB,
+		userService:      userService,
+		workspaceService: workspaceService,
+		maxAttempts:      maxAttempts,
+		ctx:              ctx,
+		cancel:           cancel,
+		stop:             make(chan struct{}),
+	}
+}
+
+// RecordClerkEvent durably stores a verified event for the worker to process.
+// Redeliveries of an already stored event are accepted and ignored.
+func (s *WebhookServiceImpl) RecordClerkEvent(ctx context.Context, svixID string, payload []byte) error {
+	if svixID == "" {
+		return fmt.Errorf("%w: missing svix id", ErrInvalidEvent)
+	}
+
+	var event ClerkEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+	if event.Type == "" {
+		return fmt.Errorf("%w: missing event type", ErrInvalidEvent)
+	}
+
+	inserted, err := s.postgresqlDB.InsertWebhookEvent(ctx, svixID, event.Type, payload)
+	if err != nil {
+		return fmt.Errorf("Failed to store webhook event: %w", err)
+	}
+	if !inserted {
+		log.Printf("Ignoring duplicate webhook event %s", svixID)
+	}
+	return nil
+}
+
+// ProcessDueEvents processes pending events whose next attempt is due and
+// returns the number of events that were processed successfully.
+func (s *WebhookServiceImpl) ProcessDueEvents(ctx context.Context) (int, error) {
+	processed := 0
+	for {
+		events, err := s.postgresqlDB.ClaimDueWebhookEvents(ctx, claimBatchSize, claimLease)
+		if err != nil {
+			return processed, fmt.Errorf("Failed to claim webhook events: %w", err)
+		}
+		if len(events) == 0 {
+			return processed, nil
+		}
+
+		for _, event := range events {
+			if s.processEvent(ctx, event) {
+				processed++
+			}
+		}
+	}
+}
+
+func (s *WebhookServiceImpl) processEvent(ctx context.Context, event storemodels.WebhookEvent) bool {
+	err := s.handleClerkEvent(ctx, event.Payload)
+	if err == nil {
+		if err := s.postgresqlDB.MarkWebhookEventProcessed(ctx, event.SvixID); err != nil {
+			log.Printf("Failed to mark webhook event %s as processed: %v", event.SvixID, err)
+		}
+		return true
+	}
+
+	dead := event.Attempts >= s.maxAttempts
+	if dead {
+		log.Printf("Webhook event %s (%s) failed %d times, moving to dead letter: %v",
+			event.SvixID, event.EventType, event.Attempts, err)
+	} else {
+		log.Printf("Webhook event %s (%s) failed on attempt %d: %v",
+			event.SvixID, event.EventType, event.Attempts, err)
+	}
+
+	nextAttemptAt := time.Now().Add(backoff(event.Attempts))
+	if markErr := s.postgresqlDB.MarkWebhookEventFailed(ctx, event.SvixID, err.Error(), nextAttemptAt, dead); markErr != nil {
+		log.Printf("Failed to record failure of webhook event %s: %v", event.SvixID, markErr)
+	}
+	return false
+}
+
+// backoff doubles the delay after every attempt, starting at ten seconds.
+func backoff(attempts int) time.Duration {
+	delay := 10 * time.Second
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
+
+func (s *WebhookServiceImpl) handleClerkEvent(ctx context.Context, payload []byte) error {
+	var event ClerkEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return fmt.Errorf("invalid event payload: %w", err)
+	}
+
+	switch event.Type {
+	case "user.created":
+		user, err := clerkservice.UserFromClerkData(event.Data)
+		if err != nil {
+			return err
+		}
+		return s.userService.CreateUser(ctx, user)
+	case "user.updated":
+		user, err := clerkservice.UserFromClerkData(event.Data)
+		if err != nil {
+			return err
+		}
+		return s.userService.UpdateUser(ctx, user)
+	case "user.deleted":
+		userID, ok := event.Data["id"].(string)
+		if !ok || userID == "" {
+			return fmt.Errorf("user.deleted event has no user id")
+		}
+		// The user's data is purged once the deletion grace period has passed
+		return s.userService.MarkUserDeleted(ctx, userID)
+	case "organization.created", "organization.updated":
+		workspace, err := clerkservice.WorkspaceFromClerkData(event.Data)
+		if err != nil {
+			return err
+		}
+		return s.workspaceService.UpsertWorkspace(ctx, workspace)
+	case "organization.deleted":
+		workspaceID, ok := event.Data["id"].(string)
+		if !ok || workspaceID == "" {
+			return fmt.Errorf("organization.deleted event has no organization id")
+		}
+		return s.workspaceService.DeleteWorkspace(ctx, workspaceID)
+	case "organizationMembership.created", "organizationMembership.updated":
+		// Fails, and is retried, until the user and organization events
+		// it depends on have been processed
+		membership, err := clerkservice.WorkspaceMembershipFromClerkData(event.Data)
+		if err != nil {
+			return err
+		}
+		return s.workspaceService.UpsertMembership(ctx, membership)
+	case "organizationMembership.deleted":
+		membership, err := clerkservice.WorkspaceMembershipFromClerkData(event.Data)
+		if err != nil {
+			return err
+		}
+		return s.workspaceService.DeleteMembership(ctx, membership.WorkspaceID, membership.UserID)
+	default:
+		log.Printf("Unhandled event type: %s", event.Type)
+		return nil
+	}
+}
+
+// ReplayEvent queues an event for processing again, typically one that ended
+// up in the dead letter state.
+func (s *WebhookServiceImpl) ReplayEvent(ctx context.Context, svixID string) error {
+	return s.postgresqlDB.ResetWebhookEvent(ctx, svixID)
+}
+
+func (s *WebhookServiceImpl) GetDeadEvents(ctx context.Context) ([]storemodels.WebhookEvent, error) {
+	return s.postgresqlDB.GetDeadWebhookEvents(ctx)
+}
+
+// Start runs ProcessDueEvents in the background every interval until Stop is
+// called.
+func (s *WebhookServiceImpl) Start(interval time.Duration) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			// Each batch is a trace of its own
+			ctx, span := tracing.Start(s.ctx, "webhookservice.ProcessDueEvents")
+			_, err := s.ProcessDueEvents(ctx)
+			tracing.End(span, err)
+			if err != nil {
+				log.Printf("Failed to process webhook events: %v", err)
+			}
+
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the background loop, waiting for the batch being processed to
+// finish until ctx is done. The batch is cancelled then, and its events are
+// processed again on the next start.
+func (s *WebhookServiceImpl) Stop(ctx context.Context) {
+	defer s.cancel()
+	close(s.stop)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.cancel()
+		<-done
+	}
+}
@@ -0,0 +1,156 @@
+// //go:build integration
+// // +build integration
+package webhookservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lucidify-api/data/store/postgresqlclient"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/data/store/weaviateclient"
+	"lucidify-api/server/config"
+	"lucidify-api/service/documentservice"
+	"lucidify-api/service/userservice"
+	"lucidify-api/service/workspaceservice"
+	"testing"
+	"time"
+)
+
+func setupWebhookService(t *testing.T, maxAttempts int) (WebhookService, *postgresqlclient.PostgreSQL, userservice.UserService) {
+	cfg := config.NewServerConfig()
+	db, err := postgresqlclient.NewPostgreSQL(cfg.PostgresqlURL)
+	if err != nil {
+		t.Fatalf("Failed to create test postgresqlclient: %v", err)
+	}
+	weaviate, err := weaviateclient.NewWeaviateClientTest(cfg.OPENAI_API_KEY)
+	if err != nil {
+		t.Fatalf("Failed to create WeaviateClient: %v", err)
+	}
+	userService, err := userservice.NewUserService(db, weaviate)
+	if err != nil {
+		t.Fatalf("Failed to create UserService: %v", err)
+	}
+	workspaceService := workspaceservice.NewWorkspaceService(db, documentservice.NewDocumentService(db, weaviate, documentservice.ChunkerOptions{URL: cfg.AI_API_URL, APIKey: cfg.X_AI_API_KEY}))
+	return NewWebhookService(db, userService, workspaceService, maxAttempts), db, userService
+}
+
+func TestRecordAndProcessClerkEventIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	webhookService, db, userService := setupWebhookService(t, 3)
+
+	userID := "TestWebhookServiceIntegrationUserID"
+	svixID := fmt.Sprintf("msg_webhook_service_%d", time.Now().UnixNano())
+	payload := []byte(fmt.Sprintf(`{
+		"type": "user.created",
+		"object": "event",
+		"data": {
+			"id": "%s",
+			"email_addresses": [{"id": "idn_1", "email_address": "webhook_service_integration@example.com"}],
+			"primary_email_address_id": "idn_1",
+			"first_name": "Webhook",
+			"last_name": "Service",
+			"created_at": 1654012591514,
+			"updated_at": 1654012591514
+		}
+	}`, userID))
+
+	err := webhookService.RecordClerkEvent(ctx, svixID, payload)
+	if err != nil {
+		t.Fatalf("Failed to record event: %v", err)
+	}
+	// Redeliveries of the same event are accepted and ignored
+	err = webhookService.RecordClerkEvent(ctx, svixID, payload)
+	if err != nil {
+		t.Errorf("Failed to record duplicate event: %v", err)
+	}
+
+	_, err = webhookService.ProcessDueEvents(ctx)
+	if err != nil {
+		t.Errorf("Failed to process events: %v", err)
+	}
+
+	user, err := userService.GetUser(ctx, userID)
+	if err != nil {
+		t.Errorf("User not created by webhook event: %v", err)
+	} else if user.CreatedAt != 1654012591514 {
+		t.Errorf("Expected created_at 1654012591514, got %d", user.CreatedAt)
+	}
+
+	event, err := db.GetWebhookEvent(ctx, svixID)
+	if err != nil {
+		t.Errorf("Failed to get webhook event: %v", err)
+	} else if event.Status != storemodels.WebhookEventStatusProcessed || event.Attempts != 1 {
+		t.Errorf("Expected event to be processed once, got status %s after %d attempts", event.Status, event.Attempts)
+	}
+
+	t.Cleanup(func() {
+		userService.DeleteUser(ctx, userID)
+	})
+}
+
+func TestFailingClerkEventMovesToDeadLetterIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	webhookService, db, _ := setupWebhookService(t, 1)
+
+	svixID := fmt.Sprintf("msg_webhook_service_dead_%d", time.Now().UnixNano())
+	// A user.created event without an email address can never be processed
+	payload := []byte(`{"type": "user.created", "object": "event", "data": {"id": "TestWebhookServiceDeadUserID"}}`)
+
+	err := webhookService.RecordClerkEvent(ctx, svixID, payload)
+	if err != nil {
+		t.Fatalf("Failed to record event: %v", err)
+	}
+
+	_, err = webhookService.ProcessDueEvents(ctx)
+	if err != nil {
+		t.Errorf("Failed to process events: %v", err)
+	}
+
+	event, err := db.GetWebhookEvent(ctx, svixID)
+	if err != nil {
+		t.Fatalf("Failed to get webhook event: %v", err)
+	}
+	if event.Status != storemodels.WebhookEventStatusDead || event.LastError == nil {
+		t.Errorf("Expected event to be dead with an error, got status %s", event.Status)
+	}
+
+	err = webhookService.ReplayEvent(ctx, svixID)
+	if err != nil {
+		t.Errorf("Failed to replay event: %v", err)
+	}
+	event, err = db.GetWebhookEvent(ctx, svixID)
+	if err != nil {
+		t.Fatalf("Failed to get webhook event: %v", err)
+	}
+	if event.Status != storemodels.WebhookEventStatusPending || event.Attempts != 0 {
+		t.Errorf("Expected replayed event to be pending with no attempts, got status %s after %d attempts",
+			event.Status, event.Attempts)
+	}
+}
+
+func TestRecordInvalidClerkEventIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	webhookService, _, _ := setupWebhookService(t, 3)
+
+	tests := []struct {
+		name    string
+		svixID  string
+		payload string
+	}{
+		{"missing svix id", "", `{"type": "user.created", "data": {}}`},
+		{"malformed payload", "msg_webhook_service_invalid", `{"type":`},
+		{"missing type", "msg_webhook_service_invalid", `{"data": {}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := webhookService.RecordClerkEvent(ctx, tt.svixID, []byte(tt.payload))
+			if !errors.Is(err, ErrInvalidEvent) {
+				t.Errorf("Expected ErrInvalidEvent, got %v", err)
+			}
+		})
+	}
+}
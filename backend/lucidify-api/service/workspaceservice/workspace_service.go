@@ -0,0 +1,78 @@
+package workspaceservice
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"lucidify-api/data/store/postgresqlclient"
+	"lucidify-api/data/store/storemodels"
+	"lucidify-api/service/documentservice"
+)
+
+type WorkspaceService interface {
+	UpsertWorkspace(ctx context.Context, workspace storemodels.Workspace) error
+	DeleteWorkspace(ctx context.Context, workspaceID string) error
+	UpsertMembership(ctx context.Context, membership storemodels.WorkspaceMembership) error
+	DeleteMembership(ctx context.Context, workspaceID, userID string) error
+	GetMemberships(ctx context.Context, userID string) ([]storemodels.WorkspaceMembership, error)
+	GetWorkspaceIDs(ctx context.Context, userID string) ([]string, error)
+}
+
+type WorkspaceServiceImpl struct {
+	postgresqlDB    *postgresqlclient.PostgreSQL
+	documentService documentservice.DocumentService
+}
+
+func NewWorkspaceService(
+	postgresqlDB *postgresqlclient.PostgreSQL,
+	documentService documentservice.DocumentService) WorkspaceService {
+	return &WorkspaceServiceImpl{postgresqlDB: postgresqlDB, documentService: documentService}
+}
+
+func (s *WorkspaceServiceImpl) UpsertWorkspace(ctx context.Context, workspace storemodels.Workspace) error {
+	return s.postgresqlDB.UpsertWorkspace(ctx, workspace)
+}
+
+// DeleteWorkspace purges the documents of the workspace from both stores
+// before deleting the workspace and its memberships.
+func (s *WorkspaceServiceImpl) DeleteWorkspace(ctx context.Context, workspaceID string) error {
+	purged, err := s.documentService.PurgeWorkspaceDocuments(ctx, workspaceID)
+	if err != nil {
+		return fmt.Errorf("Failed to purge documents of workspace %s: %w", workspaceID, err)
+	}
+	if purged > 0 {
+		log.Printf("Purged %d documents of workspace %s", purged, workspaceID)
+	}
+	return s.postgresqlDB.DeleteWorkspace(ctx, workspaceID)
+}
+
+func (s *WorkspaceServiceImpl) UpsertMembership(ctx context.Context, membership storemodels.WorkspaceMembership) error {
+	switch membership.Role {
+	case storemodels.WorkspaceRoleOwner, storemodels.WorkspaceRoleEditor, storemodels.WorkspaceRoleViewer:
+	default:
+		return fmt.Errorf("unknown workspace role %q", membership.Role)
+	}
+	return s.postgresqlDB.UpsertWorkspaceMembership(ctx, membership)
+}
+
+func (s *WorkspaceServiceImpl) DeleteMembership(ctx context.Context, workspaceID, userID string) error {
+	return s.postgresqlDB.DeleteWorkspaceMembership(ctx, workspaceID, userID)
+}
+
+func (s *WorkspaceServiceImpl) GetMemberships(ctx context.Context, userID string) ([]storemodels.WorkspaceMembership, error) {
+	return s.postgresqlDB.GetWorkspaceMembershipsOfUser(ctx, userID)
+}
+
+// GetWorkspaceIDs returns the ids of the workspaces the user is a member of,
+// whatever their role.
+func (s *WorkspaceServiceImpl) GetWorkspaceIDs(ctx context.Context, userID string) ([]string, error) {
+	memberships, err := s.postgresqlDB.GetWorkspaceMembershipsOfUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	workspaceIDs := make([]string, 0, len(memberships))
+	for _, membership := range memberships {
+		workspaceIDs = append(workspaceIDs, membership.WorkspaceID)
+	}
+	return workspaceIDs, nil
+}